@@ -0,0 +1,171 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package acme
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/json"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	legoacme "github.com/go-acme/lego/v4/acme"
+	"github.com/go-acme/lego/v4/acme/api"
+	"github.com/go-acme/lego/v4/lego"
+	jose "gopkg.in/square/go-jose.v2"
+	"io/ioutil"
+	"net/http"
+)
+
+// RolloverAccountKey 更换账号私钥，参考RFC 8555 7.3.5
+// 成功后需要使用新的私钥代替用户原有的私钥
+func RolloverAccountKey(user *User, newKey crypto.PrivateKey) error {
+	if user == nil {
+		return errors.New("'user' must not be nil")
+	}
+	resource := user.GetRegistration()
+	if resource == nil || len(resource.URI) == 0 {
+		return errors.New("the acme user has not been registered")
+	}
+
+	config := lego.NewConfig(user)
+	core, err := api.New(config.HTTPClient, config.UserAgent, user.GetCA(), resource.URI, user.GetPrivateKey())
+	if err != nil {
+		return err
+	}
+	directory := core.GetDirectory()
+	if len(directory.KeyChangeURL) == 0 {
+		return errors.New("the acme server does not support key change")
+	}
+
+	nonce, err := fetchNonce(config.HTTPClient, directory.NewNonceURL)
+	if err != nil {
+		return err
+	}
+
+	body, err := signKeyChange(resource.URI, directory.KeyChangeURL, nonce, user.GetPrivateKey(), newKey)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, directory.KeyChangeURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/jose+json")
+	resp, err := config.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		respData, _ := ioutil.ReadAll(resp.Body)
+		problem := &legoacme.ProblemDetails{}
+		if json.Unmarshal(respData, problem) == nil && len(problem.Detail) > 0 {
+			problem.HTTPStatus = resp.StatusCode
+			problem.Method = http.MethodPost
+			problem.URL = directory.KeyChangeURL
+			return problem
+		}
+		return errors.New("key change failed: " + resp.Status)
+	}
+	return nil
+}
+
+// 获取一个新的Nonce
+func fetchNonce(client *http.Client, newNonceURL string) (string, error) {
+	if len(newNonceURL) == 0 {
+		return "", errors.New("invalid 'newNonce' url")
+	}
+	resp, err := client.Head(newNonceURL)
+	if err != nil {
+		return "", err
+	}
+	_ = resp.Body.Close()
+
+	nonce := resp.Header.Get("Replay-Nonce")
+	if len(nonce) == 0 {
+		return "", errors.New("server did not respond with a proper nonce header")
+	}
+	return nonce, nil
+}
+
+// 生成更换私钥的请求内容
+// 内层使用新私钥签名，外层使用原有私钥签名
+func signKeyChange(accountURL string, keyChangeURL string, nonce string, oldKey crypto.PrivateKey, newKey crypto.PrivateKey) ([]byte, error) {
+	oldSigner, ok := oldKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("invalid old key")
+	}
+	oldAlg, err := signatureAlgorithm(oldKey)
+	if err != nil {
+		return nil, err
+	}
+	newAlg, err := signatureAlgorithm(newKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// 内层
+	innerPayload, err := json.Marshal(map[string]interface{}{
+		"account": accountURL,
+		"oldKey":  jose.JSONWebKey{Key: oldSigner.Public()},
+	})
+	if err != nil {
+		return nil, err
+	}
+	innerSigner, err := jose.NewSigner(jose.SigningKey{Algorithm: newAlg, Key: newKey}, &jose.SignerOptions{
+		EmbedJWK: true,
+		ExtraHeaders: map[jose.HeaderKey]interface{}{
+			"url": keyChangeURL,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	inner, err := innerSigner.Sign(innerPayload)
+	if err != nil {
+		return nil, err
+	}
+
+	// 外层
+	outerSigner, err := jose.NewSigner(jose.SigningKey{Algorithm: oldAlg, Key: jose.JSONWebKey{Key: oldKey, KeyID: accountURL}}, &jose.SignerOptions{
+		NonceSource: staticNonce(nonce),
+		ExtraHeaders: map[jose.HeaderKey]interface{}{
+			"url": keyChangeURL,
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	outer, err := outerSigner.Sign([]byte(inner.FullSerialize()))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(outer.FullSerialize()), nil
+}
+
+// 私钥对应的签名算法
+func signatureAlgorithm(key crypto.PrivateKey) (jose.SignatureAlgorithm, error) {
+	switch k := key.(type) {
+	case *rsa.PrivateKey:
+		return jose.RS256, nil
+	case *ecdsa.PrivateKey:
+		if k.Curve == elliptic.P256() {
+			return jose.ES256, nil
+		} else if k.Curve == elliptic.P384() {
+			return jose.ES384, nil
+		}
+	}
+	return "", errors.New("unsupported key type")
+}
+
+type staticNonce string
+
+func (this staticNonce) Nonce() (string, error) {
+	return string(this), nil
+}
@@ -0,0 +1,73 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package acme
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	jose "gopkg.in/square/go-jose.v2"
+	"testing"
+)
+
+func TestSignKeyChange(t *testing.T) {
+	oldKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	newKey, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var accountURL = "https://acme.example.com/acct/1"
+	var keyChangeURL = "https://acme.example.com/key-change"
+	body, err := signKeyChange(accountURL, keyChangeURL, "nonce1", oldKey, newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 外层
+	outer, err := jose.ParseSigned(string(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var outerHeader = outer.Signatures[0].Protected
+	if outerHeader.KeyID != accountURL || outerHeader.Nonce != "nonce1" || outerHeader.ExtraHeaders["url"] != keyChangeURL {
+		t.Fatalf("unexpected outer header: %#v", outerHeader)
+	}
+	innerData, err := outer.Verify(&oldKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 内层
+	inner, err := jose.ParseSigned(string(innerData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var innerHeader = inner.Signatures[0].Protected
+	if innerHeader.JSONWebKey == nil || len(innerHeader.Nonce) > 0 || innerHeader.ExtraHeaders["url"] != keyChangeURL {
+		t.Fatalf("unexpected inner header: %#v", innerHeader)
+	}
+	payload, err := inner.Verify(&newKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m = struct {
+		Account string          `json:"account"`
+		OldKey  jose.JSONWebKey `json:"oldKey"`
+	}{}
+	err = json.Unmarshal(payload, &m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Account != accountURL {
+		t.Fatal("unexpected account:", m.Account)
+	}
+	oldPublicKey, ok := m.OldKey.Key.(*ecdsa.PublicKey)
+	if !ok || oldPublicKey.X.Cmp(oldKey.X) != 0 || oldPublicKey.Y.Cmp(oldKey.Y) != 0 {
+		t.Fatal("unexpected old key")
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package acme
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/miekg/dns"
+	"net"
+	"strings"
+	"time"
+)
+
+// DNSDelegationPrefix CNAME委托记录在我们控制的域名中的前缀
+const DNSDelegationPrefix = "acme"
+
+// DNSDelegation CNAME委托验证
+// 用户在自己的域名中添加一次 _acme-challenge.example.com CNAME <label>.acme.<zone>，
+// 之后每次申请证书时，TXT记录都写入到我们控制的域名 <zone> 中
+type DNSDelegation struct {
+	Token string // 任务的委托令牌
+	Zone  string // 我们控制的域名
+}
+
+// DNSDelegationRecord 用户需要添加的CNAME记录
+type DNSDelegationRecord struct {
+	Domain string // 证书域名
+	Name   string // 记录名，比如 _acme-challenge.example.com
+	Value  string // 记录值
+}
+
+// ChallengeName 证书域名对应的验证记录名
+func ChallengeName(domain string) string {
+	domain = strings.TrimPrefix(strings.ToLower(strings.TrimSuffix(domain, ".")), "*.")
+	return "_acme-challenge." + domain
+}
+
+// Target 证书域名对应的CNAME记录值
+// 同一个任务中不同的域名使用不同的记录值，以免TXT记录互相覆盖
+func (this *DNSDelegation) Target(domain string) string {
+	var challengeName = ChallengeName(domain)
+	var sum = sha256.Sum256([]byte(this.Token + "@" + challengeName))
+	return hex.EncodeToString(sum[:])[:32] + "." + DNSDelegationPrefix + "." + strings.ToLower(strings.TrimSuffix(this.Zone, "."))
+}
+
+// RecordName 记录值在我们控制的域名中的记录名
+func (this *DNSDelegation) RecordName(domain string) string {
+	return strings.TrimSuffix(this.Target(domain), "."+strings.ToLower(strings.TrimSuffix(this.Zone, ".")))
+}
+
+// Records 列出需要用户添加的CNAME记录
+// 泛域名和主域名使用同一个验证记录，所以会合并
+func (this *DNSDelegation) Records(domains []string) []*DNSDelegationRecord {
+	var result = []*DNSDelegationRecord{}
+	var nameMap = map[string]bool{}
+	for _, domain := range domains {
+		var name = ChallengeName(domain)
+		if nameMap[name] {
+			continue
+		}
+		nameMap[name] = true
+		result = append(result, &DNSDelegationRecord{
+			Domain: domain,
+			Name:   name,
+			Value:  this.Target(domain),
+		})
+	}
+	return result
+}
+
+// Check 检查用户添加的CNAME记录是否正确
+func (this *DNSDelegation) Check(domains []string) error {
+	if len(this.Token) == 0 || len(this.Zone) == 0 {
+		return errors.New("invalid dns delegation")
+	}
+	for _, record := range this.Records(domains) {
+		target, err := cnameLookupFunc(record.Name)
+		if err != nil {
+			return errors.New("lookup CNAME of '" + record.Name + "' failed: " + err.Error())
+		}
+		if len(target) == 0 {
+			return errors.New("CNAME of '" + record.Name + "' not found, it should be '" + record.Value + "'")
+		}
+		if !strings.EqualFold(strings.TrimSuffix(target, "."), record.Value) {
+			return errors.New("CNAME of '" + record.Name + "' is '" + target + "', but it should be '" + record.Value + "'")
+		}
+	}
+	return nil
+}
+
+// 查询CNAME记录，测试时可以替换
+var cnameLookupFunc = lookupCNAME
+
+// 通过系统设置的DNS服务器查询CNAME记录
+func lookupCNAME(name string) (string, error) {
+	var servers = []string{}
+	var port = "53"
+	config, err := dns.ClientConfigFromFile("/etc/resolv.conf")
+	if err == nil && len(config.Servers) > 0 {
+		servers = config.Servers
+		port = config.Port
+	} else {
+		servers = []string{"8.8.8.8", "223.5.5.5"}
+	}
+
+	var fqdn = dns.Fqdn(name)
+	var msg = &dns.Msg{}
+	msg.SetQuestion(fqdn, dns.TypeCNAME)
+	msg.RecursionDesired = true
+
+	var client = &dns.Client{
+		Timeout: 5 * time.Second,
+	}
+	var lastErr error
+	for _, server := range servers {
+		resp, _, err := client.Exchange(msg, net.JoinHostPort(server, port))
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+			lastErr = errors.New("unexpected rcode '" + dns.RcodeToString[resp.Rcode] + "'")
+			continue
+		}
+		for _, answer := range resp.Answer {
+			cname, ok := answer.(*dns.CNAME)
+			if ok && strings.EqualFold(cname.Hdr.Name, fqdn) {
+				return strings.TrimSuffix(cname.Target, "."), nil
+			}
+		}
+		return "", nil
+	}
+	return "", lastErr
+}
@@ -0,0 +1,65 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package acme
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDNSDelegation_Records(t *testing.T) {
+	var delegation = &DNSDelegation{
+		Token: "abc",
+		Zone:  "Example-CDN.com.",
+	}
+	var records = delegation.Records([]string{"example.com", "*.example.com", "www.example.com"})
+	if len(records) != 2 {
+		t.Fatal("expect 2 records, but got", len(records))
+	}
+	if records[0].Name != "_acme-challenge.example.com" || records[1].Name != "_acme-challenge.www.example.com" {
+		t.Fatal("unexpected names:", records[0].Name, records[1].Name)
+	}
+	if !strings.HasSuffix(records[0].Value, ".acme.example-cdn.com") || records[0].Value == records[1].Value {
+		t.Fatal("unexpected values:", records[0].Value, records[1].Value)
+	}
+	if delegation.RecordName("example.com")+".example-cdn.com" != records[0].Value {
+		t.Fatal("unexpected record name:", delegation.RecordName("example.com"))
+	}
+}
+
+func TestDNSDelegation_Check(t *testing.T) {
+	var delegation = &DNSDelegation{
+		Token: "abc",
+		Zone:  "example-cdn.com",
+	}
+
+	var cnames = map[string]string{}
+	var oldFunc = cnameLookupFunc
+	cnameLookupFunc = func(name string) (string, error) {
+		return cnames[name], nil
+	}
+	defer func() {
+		cnameLookupFunc = oldFunc
+	}()
+
+	var domains = []string{"example.com", "www.example.com"}
+	err := delegation.Check(domains)
+	if err == nil {
+		t.Fatal("expect error when CNAME not found")
+	}
+	t.Log(err)
+
+	cnames["_acme-challenge.example.com"] = delegation.Target("example.com") + "."
+	cnames["_acme-challenge.www.example.com"] = "wrong.example.net"
+	err = delegation.Check(domains)
+	if err == nil {
+		t.Fatal("expect error when CNAME is wrong")
+	}
+	t.Log(err)
+
+	cnames["_acme-challenge.www.example.com"] = strings.ToUpper(delegation.Target("www.example.com"))
+	err = delegation.Check(domains)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
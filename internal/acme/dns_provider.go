@@ -9,22 +9,34 @@ import (
 )
 
 type DNSProvider struct {
-	raw dnsclients.ProviderInterface
+	raw        dnsclients.ProviderInterface
+	delegation *DNSDelegation
 }
 
 func NewDNSProvider(raw dnsclients.ProviderInterface) *DNSProvider {
 	return &DNSProvider{raw: raw}
 }
 
+// NewDelegatedDNSProvider 使用CNAME委托验证，TXT记录写入到我们控制的域名中
+func NewDelegatedDNSProvider(raw dnsclients.ProviderInterface, delegation *DNSDelegation) *DNSProvider {
+	return &DNSProvider{raw: raw, delegation: delegation}
+}
+
 func (this *DNSProvider) Present(domain, token, keyAuth string) error {
 	fqdn, value := dns01.GetRecord(domain, keyAuth)
 
 	// 设置记录
-	index := strings.Index(fqdn, "."+domain)
-	if index < 0 {
-		return errors.New("invalid fqdn value")
+	var recordName string
+	if this.delegation != nil {
+		recordName = this.delegation.RecordName(domain)
+		domain = this.delegation.Zone
+	} else {
+		index := strings.Index(fqdn, "."+domain)
+		if index < 0 {
+			return errors.New("invalid fqdn value")
+		}
+		recordName = fqdn[:index]
 	}
-	recordName := fqdn[:index]
 	record, err := this.raw.QueryRecord(domain, recordName, dnstypes.RecordTypeTXT)
 	if err != nil {
 		return errors.New("query DNS record failed: " + err.Error())
@@ -0,0 +1,93 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package acme
+
+import (
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/iwind/TeaGo/maps"
+)
+
+type KeyType = string
+
+const (
+	KeyTypeRSA2048 KeyType = "rsa2048"
+	KeyTypeRSA4096 KeyType = "rsa4096"
+	KeyTypeEC256   KeyType = "ec256"
+	KeyTypeEC384   KeyType = "ec384"
+	KeyTypeDual    KeyType = "dual" // 同时申请RSA和ECDSA两个证书
+)
+
+// DefaultKeyType 默认的私钥类型
+const DefaultKeyType = KeyTypeRSA2048
+
+// FindAllKeyTypes 所有支持的私钥类型
+func FindAllKeyTypes() []maps.Map {
+	return []maps.Map{
+		{
+			"name":        "RSA 2048",
+			"code":        KeyTypeRSA2048,
+			"description": "兼容性最好的私钥类型。",
+		},
+		{
+			"name":        "RSA 4096",
+			"code":        KeyTypeRSA4096,
+			"description": "安全性更高的RSA私钥，握手速度较慢，适合有特殊要求的老旧设备。",
+		},
+		{
+			"name":        "ECDSA P-256",
+			"code":        KeyTypeEC256,
+			"description": "证书和握手数据更小，速度更快。",
+		},
+		{
+			"name":        "ECDSA P-384",
+			"code":        KeyTypeEC384,
+			"description": "安全性更高的ECDSA私钥。",
+		},
+		{
+			"name":        "RSA + ECDSA",
+			"code":        KeyTypeDual,
+			"description": "同时申请RSA 2048和ECDSA P-256两个证书，支持ECDSA的客户端优先使用ECDSA证书。",
+		},
+	}
+}
+
+// FindKeyTypeName 查找私钥类型名称
+func FindKeyTypeName(keyType KeyType) string {
+	for _, t := range FindAllKeyTypes() {
+		if t.GetString("code") == keyType {
+			return t.GetString("name")
+		}
+	}
+	return ""
+}
+
+// IsValidKeyType 判断私钥类型是否有效，空值表示使用默认类型
+func IsValidKeyType(keyType KeyType) bool {
+	return len(keyType) == 0 || len(FindKeyTypeName(keyType)) > 0
+}
+
+// SplitKeyType 分解成需要申请的证书私钥类型，第一个为主证书
+func SplitKeyType(keyType KeyType) []KeyType {
+	switch keyType {
+	case KeyTypeDual:
+		return []KeyType{KeyTypeRSA2048, KeyTypeEC256}
+	case KeyTypeRSA2048, KeyTypeRSA4096, KeyTypeEC256, KeyTypeEC384:
+		return []KeyType{keyType}
+	default:
+		return []KeyType{DefaultKeyType}
+	}
+}
+
+// 转换为lego中的私钥类型
+func toCertCryptoKeyType(keyType KeyType) certcrypto.KeyType {
+	switch keyType {
+	case KeyTypeRSA4096:
+		return certcrypto.RSA4096
+	case KeyTypeEC256:
+		return certcrypto.EC256
+	case KeyTypeEC384:
+		return certcrypto.EC384
+	default:
+		return certcrypto.RSA2048
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package acme
+
+import (
+	"github.com/go-acme/lego/v4/certcrypto"
+	"testing"
+)
+
+func TestSplitKeyType(t *testing.T) {
+	for _, testCase := range []struct {
+		keyType  KeyType
+		expected []KeyType
+	}{
+		{"", []KeyType{KeyTypeRSA2048}},
+		{"unknown", []KeyType{KeyTypeRSA2048}},
+		{KeyTypeRSA4096, []KeyType{KeyTypeRSA4096}},
+		{KeyTypeEC384, []KeyType{KeyTypeEC384}},
+		{KeyTypeDual, []KeyType{KeyTypeRSA2048, KeyTypeEC256}},
+	} {
+		var result = SplitKeyType(testCase.keyType)
+		if len(result) != len(testCase.expected) {
+			t.Fatal(testCase.keyType, "expect", testCase.expected, "but got", result)
+		}
+		for i := range result {
+			if result[i] != testCase.expected[i] {
+				t.Fatal(testCase.keyType, "expect", testCase.expected, "but got", result)
+			}
+		}
+	}
+}
+
+func TestIsValidKeyType(t *testing.T) {
+	if !IsValidKeyType("") || !IsValidKeyType(KeyTypeDual) || !IsValidKeyType(KeyTypeEC256) {
+		t.Fatal("expect valid")
+	}
+	if IsValidKeyType("rsa1024") {
+		t.Fatal("expect invalid")
+	}
+	if toCertCryptoKeyType(KeyTypeEC384) != certcrypto.EC384 || toCertCryptoKeyType("") != certcrypto.RSA2048 {
+		t.Fatal("invalid cert crypto key type")
+	}
+}
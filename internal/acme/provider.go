@@ -0,0 +1,93 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package acme
+
+import (
+	"encoding/base64"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/go-acme/lego/v4/lego"
+	"net/url"
+	"strings"
+)
+
+// DefaultCAURL 默认的CA目录地址
+const DefaultCAURL = lego.LEDirectoryProduction
+
+// Provider 常用的ACME证书服务商
+type Provider struct {
+	Name        string `json:"name"`
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	CAURL       string `json:"caURL"`      // 目录地址
+	RequireEAB  bool   `json:"requireEAB"` // 是否需要External Account Binding
+}
+
+// FindAllProviders 列出所有预置的服务商
+// 其他兼容ACME协议的CA（比如内部的step-ca）可以直接填写目录地址
+func FindAllProviders() []*Provider {
+	return []*Provider{
+		{
+			Name:        "Let's Encrypt",
+			Code:        "letsencrypt",
+			Description: "非盈利组织Let's Encrypt提供的免费证书",
+			CAURL:       lego.LEDirectoryProduction,
+		},
+		{
+			Name:        "ZeroSSL",
+			Code:        "zerossl",
+			Description: "需要在ZeroSSL网站的开发者设置中生成EAB凭据",
+			CAURL:       "https://acme.zerossl.com/v2/DV90",
+			RequireEAB:  true,
+		},
+		{
+			Name:        "Buypass",
+			Code:        "buypass",
+			Description: "挪威Buypass提供的免费证书，有效期180天",
+			CAURL:       "https://api.buypass.com/acme/directory",
+		},
+		{
+			Name:        "Google Trust Services",
+			Code:        "google",
+			Description: "需要在Google Cloud中生成EAB凭据",
+			CAURL:       "https://dv.acme-v02.api.pki.goog/directory",
+			RequireEAB:  true,
+		},
+	}
+}
+
+// FindProviderWithCAURL 根据目录地址查找预置的服务商
+func FindProviderWithCAURL(caURL string) *Provider {
+	for _, provider := range FindAllProviders() {
+		if provider.CAURL == caURL {
+			return provider
+		}
+	}
+	return nil
+}
+
+// ValidateCA 检查CA目录地址和EAB参数
+// caURL为空时表示使用默认的Let's Encrypt
+func ValidateCA(caURL string, eabKeyId string, eabHMACKey string) error {
+	if len(caURL) > 0 {
+		u, err := url.Parse(caURL)
+		if err != nil || (u.Scheme != "https" && u.Scheme != "http") || len(u.Host) == 0 {
+			return errors.New("invalid ca url '" + caURL + "'")
+		}
+	}
+
+	if len(eabKeyId) > 0 || len(eabHMACKey) > 0 {
+		if len(eabKeyId) == 0 || len(eabHMACKey) == 0 {
+			return errors.New("both eab key id and hmac key are required")
+		}
+		_, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(eabHMACKey, "="))
+		if err != nil {
+			return errors.New("eab hmac key should be base64url encoded")
+		}
+	} else {
+		var provider = FindProviderWithCAURL(caURL)
+		if provider != nil && provider.RequireEAB {
+			return errors.New("'" + provider.Name + "' requires external account binding")
+		}
+	}
+	return nil
+}
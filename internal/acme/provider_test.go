@@ -0,0 +1,29 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package acme
+
+import "testing"
+
+func TestValidateCA(t *testing.T) {
+	for _, testCase := range []struct {
+		caURL      string
+		eabKeyId   string
+		eabHMACKey string
+		ok         bool
+	}{
+		{"", "", "", true},
+		{"https://acme.example.com/directory", "", "", true},
+		{"https://localhost:14000/dir", "kid-1", "zWNDZM6eQGHWpSRTPal5eIUYFTu7EajVIoguysqZ9wG44nMEtx3MUAsUDkMTQ12W", true},
+		{"ftp://acme.example.com/directory", "", "", false},
+		{"acme.example.com", "", "", false},
+		{"https://acme.zerossl.com/v2/DV90", "", "", false},
+		{"https://acme.zerossl.com/v2/DV90", "kid-1", "", false},
+		{"https://acme.zerossl.com/v2/DV90", "kid-1", "not base64!", false},
+		{"https://acme.zerossl.com/v2/DV90", "kid-1", "c2VjcmV0", true},
+	} {
+		err := ValidateCA(testCase.caURL, testCase.eabKeyId, testCase.eabHMACKey)
+		if (err == nil) != testCase.ok {
+			t.Fatal("caURL:", testCase.caURL, "eab:", testCase.eabKeyId, testCase.eabHMACKey, "expect ok:", testCase.ok, "but got:", err)
+		}
+	}
+}
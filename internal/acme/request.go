@@ -2,7 +2,6 @@ package acme
 
 import (
 	"github.com/TeaOSLab/EdgeAPI/internal/errors"
-	"github.com/go-acme/lego/v4/certcrypto"
 	"github.com/go-acme/lego/v4/certificate"
 	"github.com/go-acme/lego/v4/lego"
 	acmelog "github.com/go-acme/lego/v4/log"
@@ -16,6 +15,9 @@ type Request struct {
 
 	task   *Task
 	onAuth AuthCallback
+
+	onTLSALPNAuth    TLSALPNCallback
+	onTLSALPNCleanUp TLSALPNCleanUpCallback
 }
 
 func NewRequest(task *Task) *Request {
@@ -32,12 +34,20 @@ func (this *Request) OnAuth(onAuth AuthCallback) {
 	this.onAuth = onAuth
 }
 
+// OnTLSALPNAuth 设置TLS-ALPN-01认证证书的分发和清理回调
+func (this *Request) OnTLSALPNAuth(onAuth TLSALPNCallback, onCleanUp TLSALPNCleanUpCallback) {
+	this.onTLSALPNAuth = onAuth
+	this.onTLSALPNCleanUp = onCleanUp
+}
+
 func (this *Request) Run() (certData []byte, keyData []byte, err error) {
 	switch this.task.AuthType {
 	case AuthTypeDNS:
 		return this.runDNS()
 	case AuthTypeHTTP:
 		return this.runHTTP()
+	case AuthTypeTLSALPN:
+		return this.runTLSALPN()
 	default:
 		err = errors.New("invalid task type '" + this.task.AuthType + "'")
 		return
@@ -49,10 +59,6 @@ func (this *Request) runDNS() (certData []byte, keyData []byte, err error) {
 		acmelog.Logger = log.New(ioutil.Discard, "", log.LstdFlags)
 	}
 
-	if this.task.User == nil {
-		err = errors.New("'user' must not be nil")
-		return
-	}
 	if this.task.DNSProvider == nil {
 		err = errors.New("'dnsProvider' must not be nil")
 		return
@@ -66,33 +72,22 @@ func (this *Request) runDNS() (certData []byte, keyData []byte, err error) {
 		return
 	}
 
-	config := lego.NewConfig(this.task.User)
-	config.Certificate.KeyType = certcrypto.RSA2048
-
-	client, err := lego.NewClient(config)
-	if err != nil {
-		return nil, nil, err
-	}
-
-	// 注册用户
-	resource := this.task.User.GetRegistration()
-	if resource != nil {
-		resource, err = client.Registration.QueryRegistration()
-		if err != nil {
-			return nil, nil, err
-		}
-	} else {
-		resource, err := client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
-		if err != nil {
-			return nil, nil, err
-		}
-		err = this.task.User.Register(resource)
+	// 在创建订单之前检查CNAME委托记录
+	var dnsProvider = NewDNSProvider(this.task.DNSProvider)
+	if this.task.DNSDelegation != nil {
+		err = this.task.DNSDelegation.Check(this.task.Domains)
 		if err != nil {
 			return nil, nil, err
 		}
+		dnsProvider = NewDelegatedDNSProvider(this.task.DNSProvider, this.task.DNSDelegation)
+	}
+
+	client, err := this.newClient()
+	if err != nil {
+		return nil, nil, err
 	}
 
-	err = client.Challenge.SetDNS01Provider(NewDNSProvider(this.task.DNSProvider))
+	err = client.Challenge.SetDNS01Provider(dnsProvider)
 	if err != nil {
 		return nil, nil, err
 	}
@@ -115,38 +110,45 @@ func (this *Request) runHTTP() (certData []byte, keyData []byte, err error) {
 		acmelog.Logger = log.New(ioutil.Discard, "", log.LstdFlags)
 	}
 
-	if this.task.User == nil {
-		err = errors.New("'user' must not be nil")
-		return
+	client, err := this.newClient()
+	if err != nil {
+		return nil, nil, err
 	}
 
-	config := lego.NewConfig(this.task.User)
-	config.Certificate.KeyType = certcrypto.RSA2048
+	err = client.Challenge.SetHTTP01Provider(NewHTTPProvider(this.onAuth))
+	if err != nil {
+		return nil, nil, err
+	}
 
-	client, err := lego.NewClient(config)
+	// 申请证书
+	request := certificate.ObtainRequest{
+		Domains: this.task.Domains,
+		Bundle:  true,
+	}
+	certResource, err := client.Certificate.Obtain(request)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	// 注册用户
-	resource := this.task.User.GetRegistration()
-	if resource != nil {
-		resource, err = client.Registration.QueryRegistration()
-		if err != nil {
-			return nil, nil, err
-		}
-	} else {
-		resource, err := client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
-		if err != nil {
-			return nil, nil, err
-		}
-		err = this.task.User.Register(resource)
-		if err != nil {
-			return nil, nil, err
-		}
+	return certResource.Certificate, certResource.PrivateKey, nil
+}
+
+func (this *Request) runTLSALPN() (certData []byte, keyData []byte, err error) {
+	if !this.debug {
+		acmelog.Logger = log.New(ioutil.Discard, "", log.LstdFlags)
 	}
 
-	err = client.Challenge.SetHTTP01Provider(NewHTTPProvider(this.onAuth))
+	if this.onTLSALPNAuth == nil {
+		err = errors.New("'onTLSALPNAuth' must not be nil")
+		return
+	}
+
+	client, err := this.newClient()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = client.Challenge.SetTLSALPN01Provider(NewTLSALPNProvider(this.onTLSALPNAuth, this.onTLSALPNCleanUp))
 	if err != nil {
 		return nil, nil, err
 	}
@@ -163,3 +165,48 @@ func (this *Request) runHTTP() (certData []byte, keyData []byte, err error) {
 
 	return certResource.Certificate, certResource.PrivateKey, nil
 }
+
+// 创建客户端并注册用户
+func (this *Request) newClient() (*lego.Client, error) {
+	var user = this.task.User
+	if user == nil {
+		return nil, errors.New("'user' must not be nil")
+	}
+
+	config := lego.NewConfig(user)
+	config.CADirURL = user.GetCA()
+	config.Certificate.KeyType = toCertCryptoKeyType(this.task.KeyType)
+
+	client, err := lego.NewClient(config)
+	if err != nil {
+		return nil, err
+	}
+
+	// 注册用户
+	resource := user.GetRegistration()
+	if resource != nil {
+		_, err = client.Registration.QueryRegistration()
+		if err != nil {
+			return nil, err
+		}
+		return client, nil
+	}
+
+	if len(user.eabKeyId) > 0 {
+		resource, err = client.Registration.RegisterWithExternalAccountBinding(registration.RegisterEABOptions{
+			TermsOfServiceAgreed: true,
+			Kid:                  user.eabKeyId,
+			HmacEncoded:          user.eabHMACKey,
+		})
+	} else {
+		resource, err = client.Registration.Register(registration.RegisterOptions{TermsOfServiceAgreed: true})
+	}
+	if err != nil {
+		return nil, err
+	}
+	err = user.Register(resource)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
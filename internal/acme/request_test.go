@@ -8,6 +8,7 @@ import (
 	_ "github.com/iwind/TeaGo/bootstrap"
 	"github.com/iwind/TeaGo/dbs"
 	"github.com/iwind/TeaGo/maps"
+	"os"
 	"testing"
 )
 
@@ -86,6 +87,44 @@ func TestRequest_Run_HTTP(t *testing.T) {
 	t.Log(string(keyData))
 }
 
+// 使用本地的Pebble测试，启动Pebble后设置环境变量PEBBLE_URL（比如https://localhost:14000/dir），
+// 并将LEGO_CA_CERTIFICATES设置为Pebble的根证书（test/certs/pebble.minica.pem）
+// 测试EAB时需要在Pebble配置中开启externalAccountBindingRequired，并设置PEBBLE_EAB_KID和PEBBLE_EAB_HMAC
+func TestRequest_Pebble(t *testing.T) {
+	var caURL = os.Getenv("PEBBLE_URL")
+	if len(caURL) == 0 {
+		t.Skip("'PEBBLE_URL' is not set")
+	}
+
+	privateKey, err := ParsePrivateKeyFromBase64("MIGHAgEAMBMGByqGSM49AgEGCCqGSM49AwEHBG0wawIBAQQgD3xxDXP4YVqHCfub21Yi3QL1Kvgow23J8CKJ7vU3L4+hRANCAARRl5ZKAlgGRc5RETSMYFCTXvjnePDgjALWgtgfClQGLB2rGyRecJvlesAM6Q7LQrDxVxvxdSQQmPGRqJGiBtjd")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var registered = false
+	user := NewUser("test@example.com", privateKey, func(resource *registration.Resource) error {
+		registered = true
+		t.Log("registered:", resource.URI)
+		return nil
+	})
+	user.SetCA(caURL)
+	user.SetEAB(os.Getenv("PEBBLE_EAB_KID"), os.Getenv("PEBBLE_EAB_HMAC"))
+
+	req := NewRequest(&Task{
+		User:     user,
+		AuthType: AuthTypeHTTP,
+		Domains:  []string{"example.com"},
+	})
+	req.Debug()
+	_, err = req.newClient()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !registered {
+		t.Fatal("user should be registered")
+	}
+}
+
 func testDNSPodProvider() (dnsclients.ProviderInterface, error) {
 	db, err := dbs.Default()
 	if err != nil {
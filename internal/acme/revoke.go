@@ -0,0 +1,102 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package acme
+
+import (
+	"encoding/base64"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	legoacme "github.com/go-acme/lego/v4/acme"
+	"github.com/go-acme/lego/v4/acme/api"
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/lego"
+	"github.com/iwind/TeaGo/maps"
+)
+
+// RevokeReason 证书吊销原因，参考RFC 5280 5.3.1
+type RevokeReason = uint
+
+const (
+	RevokeReasonUnspecified          RevokeReason = 0 // 未指定
+	RevokeReasonKeyCompromise        RevokeReason = 1 // 私钥泄露
+	RevokeReasonAffiliationChanged   RevokeReason = 3 // 从属关系变更
+	RevokeReasonSuperseded           RevokeReason = 4 // 已被替换
+	RevokeReasonCessationOfOperation RevokeReason = 5 // 停止使用
+)
+
+// FindAllRevokeReasons 所有可用的吊销原因
+// ACME服务一般只接受这几个原因代码
+func FindAllRevokeReasons() []maps.Map {
+	return []maps.Map{
+		{
+			"name": "未指定",
+			"code": RevokeReasonUnspecified,
+		},
+		{
+			"name": "私钥泄露",
+			"code": RevokeReasonKeyCompromise,
+		},
+		{
+			"name": "从属关系变更",
+			"code": RevokeReasonAffiliationChanged,
+		},
+		{
+			"name": "已被替换",
+			"code": RevokeReasonSuperseded,
+		},
+		{
+			"name": "停止使用",
+			"code": RevokeReasonCessationOfOperation,
+		},
+	}
+}
+
+// FindRevokeReasonName 查找吊销原因名称
+func FindRevokeReasonName(reason RevokeReason) string {
+	for _, r := range FindAllRevokeReasons() {
+		if r.GetInt("code") == int(reason) {
+			return r.GetString("name")
+		}
+	}
+	return ""
+}
+
+// IsValidRevokeReason 判断吊销原因是否可用
+func IsValidRevokeReason(reason RevokeReason) bool {
+	return len(FindRevokeReasonName(reason)) > 0
+}
+
+// RevokeCert 使用账号私钥吊销证书
+// certData 为PEM格式的证书，如果包含证书链，只吊销第一个证书
+func RevokeCert(user *User, certData []byte, reason RevokeReason) error {
+	if user == nil {
+		return errors.New("'user' must not be nil")
+	}
+	if !IsValidRevokeReason(reason) {
+		return errors.New("invalid revoke reason")
+	}
+	resource := user.GetRegistration()
+	if resource == nil || len(resource.URI) == 0 {
+		return errors.New("the acme user has not been registered")
+	}
+
+	certs, err := certcrypto.ParsePEMBundle(certData)
+	if err != nil {
+		return err
+	}
+	if len(certs) == 0 {
+		return errors.New("no certificate found")
+	}
+	if certs[0].IsCA {
+		return errors.New("can not revoke a CA certificate")
+	}
+
+	config := lego.NewConfig(user)
+	core, err := api.New(config.HTTPClient, config.UserAgent, user.GetCA(), resource.URI, user.GetPrivateKey())
+	if err != nil {
+		return err
+	}
+	return core.Certificates.Revoke(legoacme.RevokeCertMessage{
+		Certificate: base64.RawURLEncoding.EncodeToString(certs[0].Raw),
+		Reason:      &reason,
+	})
+}
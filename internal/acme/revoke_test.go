@@ -0,0 +1,18 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package acme
+
+import "testing"
+
+func TestIsValidRevokeReason(t *testing.T) {
+	for _, reason := range []RevokeReason{RevokeReasonUnspecified, RevokeReasonKeyCompromise, RevokeReasonSuperseded} {
+		if !IsValidRevokeReason(reason) {
+			t.Fatal("expect valid reason:", reason)
+		}
+	}
+	for _, reason := range []RevokeReason{2, 6, 100} {
+		if IsValidRevokeReason(reason) {
+			t.Fatal("expect invalid reason:", reason)
+		}
+	}
+}
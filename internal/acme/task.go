@@ -5,16 +5,19 @@ import "github.com/TeaOSLab/EdgeAPI/internal/dnsclients"
 type AuthType = string
 
 const (
-	AuthTypeDNS  AuthType = "dns"
-	AuthTypeHTTP AuthType = "http"
+	AuthTypeDNS     AuthType = "dns"
+	AuthTypeHTTP    AuthType = "http"
+	AuthTypeTLSALPN AuthType = "tls-alpn"
 )
 
 type Task struct {
 	User     *User
 	AuthType AuthType
 	Domains  []string
+	KeyType  KeyType // 证书私钥类型，双证书需要分别创建任务
 
 	// DNS相关
-	DNSProvider dnsclients.ProviderInterface
-	DNSDomain   string
+	DNSProvider   dnsclients.ProviderInterface
+	DNSDomain     string
+	DNSDelegation *DNSDelegation // 不为空时表示使用CNAME委托验证，DNSProvider为我们控制的域名所在的服务商
 }
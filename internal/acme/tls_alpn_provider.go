@@ -0,0 +1,45 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package acme
+
+import (
+	"github.com/go-acme/lego/v4/challenge/tlsalpn01"
+)
+
+// TLSALPNCallback 需要在节点上部署TLS-ALPN-01认证证书时的回调
+type TLSALPNCallback func(domain string, certData []byte, keyData []byte) error
+
+// TLSALPNCleanUpCallback 认证结束后需要从节点上删除认证证书时的回调
+type TLSALPNCleanUpCallback func(domain string) error
+
+// TLSALPNProvider TLS-ALPN-01认证
+// 认证证书由API节点生成，再通过回调分发到边缘节点，由边缘节点响应acme-tls/1协议的请求
+type TLSALPNProvider struct {
+	onPresent TLSALPNCallback
+	onCleanUp TLSALPNCleanUpCallback
+}
+
+func NewTLSALPNProvider(onPresent TLSALPNCallback, onCleanUp TLSALPNCleanUpCallback) *TLSALPNProvider {
+	return &TLSALPNProvider{
+		onPresent: onPresent,
+		onCleanUp: onCleanUp,
+	}
+}
+
+func (this *TLSALPNProvider) Present(domain, token, keyAuth string) error {
+	certData, keyData, err := tlsalpn01.ChallengeBlocks(domain, keyAuth)
+	if err != nil {
+		return err
+	}
+	if this.onPresent != nil {
+		return this.onPresent(domain, certData, keyData)
+	}
+	return nil
+}
+
+func (this *TLSALPNProvider) CleanUp(domain, token, keyAuth string) error {
+	if this.onCleanUp != nil {
+		return this.onCleanUp(domain)
+	}
+	return nil
+}
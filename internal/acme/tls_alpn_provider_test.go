@@ -0,0 +1,45 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package acme
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+)
+
+func TestTLSALPNProvider_Present(t *testing.T) {
+	var presentedDomain = ""
+	var cleanedDomain = ""
+	var provider = NewTLSALPNProvider(func(domain string, certData []byte, keyData []byte) error {
+		presentedDomain = domain
+
+		cert, err := tls.X509KeyPair(certData, keyData)
+		if err != nil {
+			t.Fatal(err)
+		}
+		x509Cert, err := x509.ParseCertificate(cert.Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(x509Cert.DNSNames) != 1 || x509Cert.DNSNames[0] != domain {
+			t.Fatal("unexpected dns names:", x509Cert.DNSNames)
+		}
+		return nil
+	}, func(domain string) error {
+		cleanedDomain = domain
+		return nil
+	})
+
+	err := provider.Present("example.com", "token", "keyAuth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = provider.CleanUp("example.com", "token", "keyAuth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if presentedDomain != "example.com" || cleanedDomain != "example.com" {
+		t.Fatal("callbacks not called")
+	}
+}
@@ -11,6 +11,10 @@ type User struct {
 	resource     *registration.Resource
 	key          crypto.PrivateKey
 	registerFunc func(resource *registration.Resource) error
+
+	caURL      string // CA目录地址
+	eabKeyId   string // External Account Binding的Key ID
+	eabHMACKey string // External Account Binding的HMAC Key，Base64URL编码
 }
 
 func NewUser(email string, key crypto.PrivateKey, registerFunc func(resource *registration.Resource) error) *User {
@@ -47,3 +51,22 @@ func (this *User) Register(resource *registration.Resource) error {
 	this.resource = resource
 	return this.registerFunc(resource)
 }
+
+// SetCA 设置CA目录地址，为空时使用Let's Encrypt
+func (this *User) SetCA(caURL string) {
+	this.caURL = caURL
+}
+
+// GetCA 获取CA目录地址
+func (this *User) GetCA() string {
+	if len(this.caURL) == 0 {
+		return DefaultCAURL
+	}
+	return this.caURL
+}
+
+// SetEAB 设置External Account Binding，只在注册时使用
+func (this *User) SetEAB(keyId string, hmacKey string) {
+	this.eabKeyId = keyId
+	this.eabHMACKey = hmacKey
+}
@@ -18,9 +18,16 @@ type APIConfig struct {
 	NodeId string `yaml:"nodeId" json:"nodeId"`
 	Secret string `yaml:"secret" json:"secret"`
 
+	NSTransfer *NSTransferConfig `yaml:"nsTransfer,omitempty" json:"nsTransfer"` // EdgeDNS域名区域传送
+
 	numberId int64 // 数字ID
 }
 
+// NSTransferConfig EdgeDNS域名区域传送（AXFR/IXFR）服务配置
+type NSTransferConfig struct {
+	Listen []string `yaml:"listen" json:"listen"` // 监听地址，比如 :5353，同时监听TCP和UDP
+}
+
 // 获取共享配置
 func SharedAPIConfig() (*APIConfig, error) {
 	sharedLocker.Lock()
@@ -0,0 +1,106 @@
+package acme
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"time"
+)
+
+// 认证证书的最长有效期，超过此时间的认证证书不再下发给节点
+const acmeChallengeCertMaxSeconds = 3600
+
+type ACMEChallengeCertDAO dbs.DAO
+
+func NewACMEChallengeCertDAO() *ACMEChallengeCertDAO {
+	return dbs.NewDAO(&ACMEChallengeCertDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeACMEChallengeCerts",
+			Model:  new(ACMEChallengeCert),
+			PkName: "id",
+		},
+	}).(*ACMEChallengeCertDAO)
+}
+
+var SharedACMEChallengeCertDAO *ACMEChallengeCertDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedACMEChallengeCertDAO = NewACMEChallengeCertDAO()
+	})
+}
+
+// CreateChallengeCert 创建认证证书
+func (this *ACMEChallengeCertDAO) CreateChallengeCert(tx *dbs.Tx, taskId int64, domain string, certData []byte, keyData []byte) error {
+	// 同一个任务同一个域名只保留一个认证证书
+	err := this.DeleteChallengeCert(tx, taskId, domain)
+	if err != nil {
+		return err
+	}
+
+	op := NewACMEChallengeCertOperator()
+	op.TaskId = taskId
+	op.Domain = domain
+	op.CertData = certData
+	op.KeyData = keyData
+	return this.Save(tx, op)
+}
+
+// DeleteChallengeCert 删除认证证书
+func (this *ACMEChallengeCertDAO) DeleteChallengeCert(tx *dbs.Tx, taskId int64, domain string) error {
+	_, err := this.Query(tx).
+		Attr("taskId", taskId).
+		Attr("domain", domain).
+		Delete()
+	return err
+}
+
+// FindAllAvailableChallengeCerts 查找所有正在使用的认证证书
+func (this *ACMEChallengeCertDAO) FindAllAvailableChallengeCerts(tx *dbs.Tx) (result []*ACMEChallengeCert, err error) {
+	_, err = this.Query(tx).
+		Gt("createdAt", time.Now().Unix()-acmeChallengeCertMaxSeconds).
+		DescPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// NotifyUpdate 通知使用此域名的集群中的节点更新认证证书
+// 返回通知的集群IDs，用来等待节点更新
+func (this *ACMEChallengeCertDAO) NotifyUpdate(tx *dbs.Tx, domain string) (clusterIds []int64, err error) {
+	clusterIds, err = models.SharedServerDAO.FindAllEnabledClusterIdsWithServerName(tx, domain)
+	if err != nil {
+		return nil, err
+	}
+	for _, clusterId := range clusterIds {
+		// 立即分解成节点任务，以免认证超时
+		err = models.SharedNodeTaskDAO.CreateClusterTask(tx, clusterId, models.NodeTaskTypeACMEChallengeChanged)
+		if err != nil {
+			return nil, err
+		}
+		err = models.SharedNodeTaskDAO.ExtractClusterTask(tx, clusterId, models.NodeTaskTypeACMEChallengeChanged)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return clusterIds, nil
+}
+
+// WaitNodesUpdated 等待集群中的节点更新认证证书
+// 超时后不返回错误，由ACME服务器验证结果决定是否成功
+func (this *ACMEChallengeCertDAO) WaitNodesUpdated(tx *dbs.Tx, clusterIds []int64, timeout time.Duration) error {
+	var deadline = time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		exists, err := models.SharedNodeTaskDAO.ExistsDoingNodeTasksWithType(tx, clusterIds, models.NodeTaskTypeACMEChallengeChanged)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return nil
+		}
+		time.Sleep(1 * time.Second)
+	}
+	return nil
+}
@@ -0,0 +1,24 @@
+package acme
+
+// ACME TLS-ALPN-01认证证书
+type ACMEChallengeCert struct {
+	Id        uint64 `field:"id"`        // ID
+	TaskId    uint64 `field:"taskId"`    // 任务ID
+	Domain    string `field:"domain"`    // 域名
+	CertData  string `field:"certData"`  // 证书内容
+	KeyData   string `field:"keyData"`   // 私钥内容
+	CreatedAt uint64 `field:"createdAt"` // 创建时间
+}
+
+type ACMEChallengeCertOperator struct {
+	Id        interface{} // ID
+	TaskId    interface{} // 任务ID
+	Domain    interface{} // 域名
+	CertData  interface{} // 证书内容
+	KeyData   interface{} // 私钥内容
+	CreatedAt interface{} // 创建时间
+}
+
+func NewACMEChallengeCertOperator() *ACMEChallengeCertOperator {
+	return &ACMEChallengeCertOperator{}
+}
@@ -0,0 +1,100 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package acme
+
+import (
+	"encoding/json"
+	"github.com/TeaOSLab/EdgeAPI/internal/acme"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/rands"
+)
+
+// ACMEDNSDelegationSettingCode CNAME委托验证设置代号
+const ACMEDNSDelegationSettingCode = "acmeDNSDelegation"
+
+// ACMEDNSDelegationConfig CNAME委托验证设置
+// 使用委托验证的任务会把TXT记录写入到此域名中
+type ACMEDNSDelegationConfig struct {
+	DNSProviderId int64  `json:"dnsProviderId"` // DNS服务商账号
+	DNSDomain     string `json:"dnsDomain"`     // 我们控制的域名
+}
+
+// FindDNSDelegationConfig 读取CNAME委托验证设置
+func (this *ACMETaskDAO) FindDNSDelegationConfig(tx *dbs.Tx) (*ACMEDNSDelegationConfig, error) {
+	valueJSON, err := models.SharedSysSettingDAO.ReadSetting(tx, ACMEDNSDelegationSettingCode)
+	if err != nil {
+		return nil, err
+	}
+	if len(valueJSON) == 0 {
+		return nil, nil
+	}
+	var config = &ACMEDNSDelegationConfig{}
+	err = json.Unmarshal(valueJSON, config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// UpdateDNSDelegationConfig 修改CNAME委托验证设置
+func (this *ACMETaskDAO) UpdateDNSDelegationConfig(tx *dbs.Tx, config *ACMEDNSDelegationConfig) error {
+	if config == nil {
+		return errors.New("'config' should not be nil")
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return models.SharedSysSettingDAO.UpdateSetting(tx, ACMEDNSDelegationSettingCode, configJSON)
+}
+
+// UpdateACMETaskDNSDelegation 设置任务是否使用CNAME委托验证
+// 启用时生成委托令牌，已有的令牌保持不变，以免用户需要重新添加CNAME记录
+func (this *ACMETaskDAO) UpdateACMETaskDNSDelegation(tx *dbs.Tx, taskId int64, isDelegated bool) error {
+	if taskId <= 0 {
+		return errors.New("invalid taskId")
+	}
+
+	if !isDelegated {
+		return this.Query(tx).
+			Pk(taskId).
+			Set("dnsDelegationToken", "").
+			UpdateQuickly()
+	}
+
+	token, err := this.Query(tx).
+		Pk(taskId).
+		Result("dnsDelegationToken").
+		FindStringCol("")
+	if err != nil {
+		return err
+	}
+	if len(token) > 0 {
+		return nil
+	}
+	return this.Query(tx).
+		Pk(taskId).
+		Set("dnsDelegationToken", rands.HexString(32)).
+		UpdateQuickly()
+}
+
+// ComposeDNSDelegation 组合任务的CNAME委托验证信息
+// 任务没有使用委托验证时返回nil
+func (this *ACMETaskDAO) ComposeDNSDelegation(tx *dbs.Tx, task *ACMETask) (delegation *acme.DNSDelegation, config *ACMEDNSDelegationConfig, err error) {
+	if task == nil || len(task.DnsDelegationToken) == 0 {
+		return nil, nil, nil
+	}
+	config, err = this.FindDNSDelegationConfig(tx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if config == nil || config.DNSProviderId <= 0 || len(config.DNSDomain) == 0 {
+		return nil, nil, errors.New("the domain for CNAME delegation has not been set")
+	}
+	return &acme.DNSDelegation{
+		Token: task.DnsDelegationToken,
+		Zone:  config.DNSDomain,
+	}, config, nil
+}
@@ -0,0 +1,112 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package acme
+
+import (
+	"encoding/json"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/iwind/TeaGo/dbs"
+	"math"
+)
+
+// ACMERenewalSettingCode 自动续期设置代号
+const ACMERenewalSettingCode = "acmeRenewalConfig"
+
+// ACMERenewalConfig 自动续期设置
+type ACMERenewalConfig struct {
+	RenewRatio       float64 `json:"renewRatio"`       // 剩余有效期占总有效期的比例小于此值时开始续期
+	MaxRetries       int     `json:"maxRetries"`       // 连续失败多少次后不再自动重试
+	RetryBaseSeconds int64   `json:"retryBaseSeconds"` // 第一次重试的间隔，之后每次加倍
+	RetryMaxSeconds  int64   `json:"retryMaxSeconds"`  // 最大重试间隔
+	Concurrent       int     `json:"concurrent"`       // 同时续期的任务数，以免超出CA的频率限制
+}
+
+// DefaultACMERenewalConfig 默认的自动续期设置
+// 对于有效期为90天的证书，在到期前30天左右开始续期
+func DefaultACMERenewalConfig() *ACMERenewalConfig {
+	return &ACMERenewalConfig{
+		RenewRatio:       1.0 / 3,
+		MaxRetries:       10,
+		RetryBaseSeconds: 600,
+		RetryMaxSeconds:  86400,
+		Concurrent:       2,
+	}
+}
+
+// Normalize 修正无效的设置
+func (this *ACMERenewalConfig) Normalize() {
+	var defaultConfig = DefaultACMERenewalConfig()
+	if this.RenewRatio <= 0 || this.RenewRatio >= 1 {
+		this.RenewRatio = defaultConfig.RenewRatio
+	}
+	if this.MaxRetries <= 0 {
+		this.MaxRetries = defaultConfig.MaxRetries
+	}
+	if this.RetryBaseSeconds <= 0 {
+		this.RetryBaseSeconds = defaultConfig.RetryBaseSeconds
+	}
+	if this.RetryMaxSeconds < this.RetryBaseSeconds {
+		this.RetryMaxSeconds = this.RetryBaseSeconds
+	}
+	if this.Concurrent <= 0 {
+		this.Concurrent = defaultConfig.Concurrent
+	}
+}
+
+// RetryDelay 计算第N次失败后到下次重试的间隔
+// jitter 为0到1之间的随机数，用来在基础间隔上下浮动20%，以免大量任务同时重试
+func (this *ACMERenewalConfig) RetryDelay(retries int, jitter float64) int64 {
+	if retries < 1 {
+		retries = 1
+	}
+	var delay = float64(this.RetryBaseSeconds) * math.Pow(2, float64(retries-1))
+	if delay > float64(this.RetryMaxSeconds) {
+		delay = float64(this.RetryMaxSeconds)
+	}
+	if jitter < 0 {
+		jitter = 0
+	} else if jitter > 1 {
+		jitter = 1
+	}
+	return int64(delay * (0.8 + 0.4*jitter))
+}
+
+// ACMERenewResult 单次自动续期结果
+type ACMERenewResult struct {
+	IsOk        bool   // 是否成功
+	Error       string // 错误信息
+	CertId      int64  // 证书ID
+	Attempt     int    // 第几次尝试
+	NextRetryAt int64  // 下次重试时间，0表示不再重试
+}
+
+// FindRenewalConfig 读取自动续期设置
+func (this *ACMETaskDAO) FindRenewalConfig(tx *dbs.Tx) (*ACMERenewalConfig, error) {
+	var config = DefaultACMERenewalConfig()
+	valueJSON, err := models.SharedSysSettingDAO.ReadSetting(tx, ACMERenewalSettingCode)
+	if err != nil {
+		return nil, err
+	}
+	if len(valueJSON) > 0 {
+		err = json.Unmarshal(valueJSON, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	config.Normalize()
+	return config, nil
+}
+
+// UpdateRenewalConfig 修改自动续期设置
+func (this *ACMETaskDAO) UpdateRenewalConfig(tx *dbs.Tx, config *ACMERenewalConfig) error {
+	if config == nil {
+		return errors.New("'config' should not be nil")
+	}
+	config.Normalize()
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	return models.SharedSysSettingDAO.UpdateSetting(tx, ACMERenewalSettingCode, configJSON)
+}
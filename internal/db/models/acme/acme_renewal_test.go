@@ -0,0 +1,41 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package acme
+
+import (
+	"testing"
+)
+
+func TestACMERenewalConfig_Normalize(t *testing.T) {
+	var config = &ACMERenewalConfig{
+		RenewRatio:       2,
+		RetryBaseSeconds: 600,
+		RetryMaxSeconds:  60,
+	}
+	config.Normalize()
+	if config.RenewRatio >= 1 || config.MaxRetries != 10 || config.RetryMaxSeconds != 600 || config.Concurrent != 2 {
+		t.Fatalf("unexpected config: %#v", config)
+	}
+}
+
+func TestACMERenewalConfig_RetryDelay(t *testing.T) {
+	var config = DefaultACMERenewalConfig()
+	for _, testCase := range []struct {
+		retries  int
+		jitter   float64
+		expected int64
+	}{
+		{0, 0.5, 600},
+		{1, 0.5, 600},
+		{2, 0.5, 1200},
+		{3, 0.5, 2400},
+		{3, 0, 1920},
+		{3, 1, 2880},
+		{20, 0.5, 86400},
+	} {
+		var delay = config.RetryDelay(testCase.retries, testCase.jitter)
+		if delay != testCase.expected {
+			t.Fatal("retries:", testCase.retries, "jitter:", testCase.jitter, "expect", testCase.expected, "but got", delay)
+		}
+	}
+}
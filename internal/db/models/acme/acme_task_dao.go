@@ -9,12 +9,12 @@ import (
 	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients"
 	"github.com/TeaOSLab/EdgeAPI/internal/errors"
 	"github.com/TeaOSLab/EdgeCommon/pkg/serverconfigs/sslconfigs"
-	"github.com/go-acme/lego/v4/registration"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/iwind/TeaGo/Tea"
 	"github.com/iwind/TeaGo/dbs"
 	"github.com/iwind/TeaGo/logs"
 	"github.com/iwind/TeaGo/types"
+	"math/rand"
 	"time"
 )
 
@@ -162,7 +162,11 @@ func (this *ACMETaskDAO) ListEnabledACMETasks(tx *dbs.Tx, adminId int64, userId
 }
 
 // 创建任务
-func (this *ACMETaskDAO) CreateACMETask(tx *dbs.Tx, adminId int64, userId int64, authType acme.AuthType, acmeUserId int64, dnsProviderId int64, dnsDomain string, domains []string, autoRenew bool) (int64, error) {
+func (this *ACMETaskDAO) CreateACMETask(tx *dbs.Tx, adminId int64, userId int64, authType acme.AuthType, acmeUserId int64, dnsProviderId int64, dnsDomain string, domains []string, autoRenew bool, keyType acme.KeyType) (int64, error) {
+	if !acme.IsValidKeyType(keyType) {
+		return 0, errors.New("invalid key type '" + keyType + "'")
+	}
+
 	op := NewACMETaskOperator()
 	op.AdminId = adminId
 	op.UserId = userId
@@ -182,6 +186,7 @@ func (this *ACMETaskDAO) CreateACMETask(tx *dbs.Tx, adminId int64, userId int64,
 	}
 
 	op.AutoRenew = autoRenew
+	op.KeyType = keyType
 	op.IsOn = true
 	op.State = ACMETaskStateEnabled
 	err := this.Save(tx, op)
@@ -192,10 +197,13 @@ func (this *ACMETaskDAO) CreateACMETask(tx *dbs.Tx, adminId int64, userId int64,
 }
 
 // 修改任务
-func (this *ACMETaskDAO) UpdateACMETask(tx *dbs.Tx, acmeTaskId int64, acmeUserId int64, dnsProviderId int64, dnsDomain string, domains []string, autoRenew bool) error {
+func (this *ACMETaskDAO) UpdateACMETask(tx *dbs.Tx, acmeTaskId int64, acmeUserId int64, dnsProviderId int64, dnsDomain string, domains []string, autoRenew bool, keyType acme.KeyType) error {
 	if acmeTaskId <= 0 {
 		return errors.New("invalid acmeTaskId")
 	}
+	if !acme.IsValidKeyType(keyType) {
+		return errors.New("invalid key type '" + keyType + "'")
+	}
 
 	op := NewACMETaskOperator()
 	op.Id = acmeTaskId
@@ -214,6 +222,12 @@ func (this *ACMETaskDAO) UpdateACMETask(tx *dbs.Tx, acmeTaskId int64, acmeUserId
 	}
 
 	op.AutoRenew = autoRenew
+	op.KeyType = keyType
+
+	// 修改设置后重新开始自动续期
+	op.RenewRetries = 0
+	op.NextRenewAt = 0
+
 	err := this.Save(tx, op)
 	return err
 }
@@ -239,12 +253,33 @@ func (this *ACMETaskDAO) UpdateACMETaskCert(tx *dbs.Tx, taskId int64, certId int
 	return err
 }
 
+// UpdateACMETaskDualCert 设置任务关联的ECDSA证书
+func (this *ACMETaskDAO) UpdateACMETaskDualCert(tx *dbs.Tx, taskId int64, certId int64) error {
+	if taskId <= 0 {
+		return errors.New("invalid taskId")
+	}
+
+	op := NewACMETaskOperator()
+	op.Id = taskId
+	op.DualCertId = certId
+	err := this.Save(tx, op)
+	return err
+}
+
 // 执行任务并记录日志
 func (this *ACMETaskDAO) RunTask(tx *dbs.Tx, taskId int64) (isOk bool, errMsg string, resultCertId int64) {
 	isOk, errMsg, resultCertId = this.runTaskWithoutLog(tx, taskId)
 
+	// 手动执行成功后重新开始计算自动续期失败次数
+	if isOk {
+		err := this.updateRenewState(tx, taskId, 0, 0)
+		if err != nil {
+			logs.Error(err)
+		}
+	}
+
 	// 记录日志
-	err := SharedACMETaskLogDAO.CreateACMETaskLog(tx, taskId, isOk, errMsg)
+	err := SharedACMETaskLogDAO.CreateACMETaskLog(tx, taskId, isOk, errMsg, ACMETaskLogTypeManual, 0, 0)
 	if err != nil {
 		logs.Error(err)
 	}
@@ -252,19 +287,121 @@ func (this *ACMETaskDAO) RunTask(tx *dbs.Tx, taskId int64) (isOk bool, errMsg st
 	return
 }
 
-// 执行任务但并不记录日志
-func (this *ACMETaskDAO) runTaskWithoutLog(tx *dbs.Tx, taskId int64) (isOk bool, errMsg string, resultCertId int64) {
+// RevokeTaskCert 吊销任务生成的证书并记录日志
+// 有双证书时同时吊销ECDSA证书
+func (this *ACMETaskDAO) RevokeTaskCert(tx *dbs.Tx, taskId int64, reason acme.RevokeReason) (isOk bool, errMsg string) {
+	isOk, errMsg = this.revokeTaskCertWithoutLog(tx, taskId, reason)
+
+	// 记录日志
+	err := SharedACMETaskLogDAO.CreateACMETaskLog(tx, taskId, isOk, errMsg, ACMETaskLogTypeRevoke, 0, 0)
+	if err != nil {
+		logs.Error(err)
+	}
+
+	return
+}
+
+// FindAllRenewingACMETaskIds 查找需要自动续期的任务
+// 证书剩余有效期占总有效期的比例小于设置的值，并且已到重试时间
+func (this *ACMETaskDAO) FindAllRenewingACMETaskIds(tx *dbs.Tx, config *ACMERenewalConfig, size int64) (result []int64, err error) {
+	ones, err := this.Query(tx).
+		State(ACMETaskStateEnabled).
+		Attr("isOn", true).
+		Attr("autoRenew", true).
+		Gt("certId", 0).
+		Lt("renewRetries", config.MaxRetries).
+		Lte("nextRenewAt", time.Now().Unix()).
+		Where("certId IN (SELECT id FROM "+models.SharedSSLCertDAO.Table+" WHERE state=1 AND timeEndAt-(timeEndAt-timeBeginAt)*:renewRatio<=UNIX_TIMESTAMP())").
+		Param("renewRatio", config.RenewRatio).
+		ResultPk().
+		Asc("nextRenewAt").
+		AscPk().
+		Limit(size).
+		FindAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, one := range ones {
+		result = append(result, int64(one.(*ACMETask).Id))
+	}
+	return
+}
+
+// RenewTask 自动续期并记录日志
+// 失败后按照指数退避计算下次重试时间，超过最大重试次数后不再自动重试
+func (this *ACMETaskDAO) RenewTask(tx *dbs.Tx, taskId int64, config *ACMERenewalConfig) (*ACMERenewResult, error) {
+	task, err := this.FindEnabledACMETask(tx, taskId)
+	if err != nil {
+		return nil, err
+	}
+	if task == nil {
+		return nil, errors.New("can not find task '" + types.String(taskId) + "'")
+	}
+
+	// 先推迟下次续期时间，以免其他API节点重复执行
+	var attempt = int(task.RenewRetries) + 1
+	err = this.updateRenewState(tx, taskId, int(task.RenewRetries), time.Now().Unix()+config.RetryMaxSeconds)
+	if err != nil {
+		return nil, err
+	}
+
+	var result = &ACMERenewResult{
+		Attempt: attempt,
+	}
+	result.IsOk, result.Error, result.CertId = this.runTaskWithoutLog(tx, taskId)
+	if result.IsOk {
+		err = this.updateRenewState(tx, taskId, 0, 0)
+	} else {
+		if attempt < config.MaxRetries {
+			result.NextRetryAt = time.Now().Unix() + config.RetryDelay(attempt, rand.Float64())
+		}
+		err = this.updateRenewState(tx, taskId, attempt, result.NextRetryAt)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = SharedACMETaskLogDAO.CreateACMETaskLog(tx, taskId, result.IsOk, result.Error, ACMETaskLogTypeRenew, attempt, result.NextRetryAt)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// 修改自动续期失败次数和下次续期时间
+func (this *ACMETaskDAO) updateRenewState(tx *dbs.Tx, taskId int64, retries int, nextRenewAt int64) error {
+	return this.Query(tx).
+		Pk(taskId).
+		Set("renewRetries", retries).
+		Set("nextRenewAt", nextRenewAt).
+		UpdateQuickly()
+}
+
+// 吊销证书但并不记录日志
+func (this *ACMETaskDAO) revokeTaskCertWithoutLog(tx *dbs.Tx, taskId int64, reason acme.RevokeReason) (isOk bool, errMsg string) {
+	if !acme.IsValidRevokeReason(reason) {
+		errMsg = "不支持的吊销原因：" + types.String(reason)
+		return
+	}
+
 	task, err := this.FindEnabledACMETask(tx, taskId)
 	if err != nil {
 		errMsg = "查询任务信息时出错：" + err.Error()
 		return
 	}
 	if task == nil {
-		errMsg = "找不到要执行的任务"
+		errMsg = "找不到要吊销证书的任务"
 		return
 	}
-	if task.IsOn != 1 {
-		errMsg = "任务没有启用"
+
+	var certIds = []int64{}
+	for _, certId := range []uint64{task.CertId, task.DualCertId} {
+		if certId > 0 {
+			certIds = append(certIds, int64(certId))
+		}
+	}
+	if len(certIds) == 0 {
+		errMsg = "任务还没有生成证书"
 		return
 	}
 
@@ -278,35 +415,89 @@ func (this *ACMETaskDAO) runTaskWithoutLog(tx *dbs.Tx, taskId int64) (isOk bool,
 		errMsg = "找不到ACME用户"
 		return
 	}
-
-	privateKey, err := acme.ParsePrivateKeyFromBase64(user.PrivateKey)
+	remoteUser, err := SharedACMEUserDAO.ComposeRemoteUser(tx, user)
 	if err != nil {
-		errMsg = "解析私钥时出错：" + err.Error()
+		errMsg = "初始化ACME用户时出错：" + err.Error()
 		return
 	}
 
-	remoteUser := acme.NewUser(user.Email, privateKey, func(resource *registration.Resource) error {
-		resourceJSON, err := json.Marshal(resource)
+	for _, certId := range certIds {
+		cert, err := models.SharedSSLCertDAO.FindEnabledSSLCert(tx, certId)
 		if err != nil {
-			return err
+			errMsg = "查询证书时出错：" + err.Error()
+			return
+		}
+		if cert == nil || cert.RevokedAt > 0 {
+			continue
 		}
 
-		err = SharedACMEUserDAO.UpdateACMEUserRegistration(tx, int64(user.Id), resourceJSON)
-		return err
-	})
+		err = acme.RevokeCert(remoteUser, []byte(cert.CertData), reason)
+		if err != nil {
+			errMsg = "吊销证书 '" + cert.Name + "' 时出错：" + err.Error()
+			return
+		}
 
-	if len(user.Registration) > 0 {
-		err = remoteUser.SetRegistration([]byte(user.Registration))
+		err = models.SharedSSLCertDAO.UpdateCertRevoked(tx, certId, uint8(reason))
 		if err != nil {
-			errMsg = "设置注册信息时出错：" + err.Error()
+			errMsg = "证书已吊销，但是修改数据库中的证书信息时出错：" + err.Error()
 			return
 		}
 	}
 
+	isOk = true
+	return
+}
+
+// 执行任务但并不记录日志
+func (this *ACMETaskDAO) runTaskWithoutLog(tx *dbs.Tx, taskId int64) (isOk bool, errMsg string, resultCertId int64) {
+	task, err := this.FindEnabledACMETask(tx, taskId)
+	if err != nil {
+		errMsg = "查询任务信息时出错：" + err.Error()
+		return
+	}
+	if task == nil {
+		errMsg = "找不到要执行的任务"
+		return
+	}
+	if task.IsOn != 1 {
+		errMsg = "任务没有启用"
+		return
+	}
+
+	// ACME用户
+	user, err := SharedACMEUserDAO.FindEnabledACMEUser(tx, int64(task.AcmeUserId))
+	if err != nil {
+		errMsg = "查询ACME用户时出错：" + err.Error()
+		return
+	}
+	if user == nil {
+		errMsg = "找不到ACME用户"
+		return
+	}
+
+	remoteUser, err := SharedACMEUserDAO.ComposeRemoteUser(tx, user)
+	if err != nil {
+		errMsg = "初始化ACME用户时出错：" + err.Error()
+		return
+	}
+
 	var acmeTask *acme.Task = nil
 	if task.AuthType == acme.AuthTypeDNS {
+		// CNAME委托验证时使用我们控制的域名
+		var dnsProviderId = int64(task.DnsProviderId)
+		var dnsDomain = task.DnsDomain
+		delegation, delegationConfig, err := this.ComposeDNSDelegation(tx, task)
+		if err != nil {
+			errMsg = "读取CNAME委托验证设置时出错：" + err.Error()
+			return
+		}
+		if delegation != nil {
+			dnsProviderId = delegationConfig.DNSProviderId
+			dnsDomain = delegationConfig.DNSDomain
+		}
+
 		// DNS服务商
-		dnsProvider, err := dns.SharedDNSProviderDAO.FindEnabledDNSProvider(tx, int64(task.DnsProviderId))
+		dnsProvider, err := dns.SharedDNSProviderDAO.FindEnabledDNSProvider(tx, dnsProviderId)
 		if err != nil {
 			errMsg = "查找DNS服务商账号信息时出错：" + err.Error()
 			return
@@ -332,11 +523,12 @@ func (this *ACMETaskDAO) runTaskWithoutLog(tx *dbs.Tx, taskId int64) (isOk bool,
 		}
 
 		acmeTask = &acme.Task{
-			User:        remoteUser,
-			AuthType:    acme.AuthTypeDNS,
-			DNSProvider: providerInterface,
-			DNSDomain:   task.DnsDomain,
-			Domains:     task.DecodeDomains(),
+			User:          remoteUser,
+			AuthType:      acme.AuthTypeDNS,
+			DNSProvider:   providerInterface,
+			DNSDomain:     dnsDomain,
+			DNSDelegation: delegation,
+			Domains:       task.DecodeDomains(),
 		}
 	} else if task.AuthType == acme.AuthTypeHTTP {
 		acmeTask = &acme.Task{
@@ -344,41 +536,135 @@ func (this *ACMETaskDAO) runTaskWithoutLog(tx *dbs.Tx, taskId int64) (isOk bool,
 			AuthType: acme.AuthTypeHTTP,
 			Domains:  task.DecodeDomains(),
 		}
+	} else if task.AuthType == acme.AuthTypeTLSALPN {
+		acmeTask = &acme.Task{
+			User:     remoteUser,
+			AuthType: acme.AuthTypeTLSALPN,
+			Domains:  task.DecodeDomains(),
+		}
 	}
 
-	acmeRequest := acme.NewRequest(acmeTask)
-	acmeRequest.OnAuth(func(domain, token, keyAuth string) {
-		err := SharedACMEAuthenticationDAO.CreateAuth(tx, taskId, domain, token, keyAuth)
+	if acmeTask == nil {
+		errMsg = "不支持的认证类型 '" + task.AuthType + "'"
+		return
+	}
+
+	// 按私钥类型分别申请证书，双证书时第一个为RSA证书，第二个为ECDSA证书
+	var keyTypes = acme.SplitKeyType(task.KeyType)
+	var dualCertId int64
+	for index, keyType := range keyTypes {
+		acmeTask.KeyType = keyType
+
+		acmeRequest := acme.NewRequest(acmeTask)
+		acmeRequest.OnAuth(func(domain, token, keyAuth string) {
+			err := SharedACMEAuthenticationDAO.CreateAuth(tx, taskId, domain, token, keyAuth)
+			if err != nil {
+				logs.Println("[ACME]write authentication to database error: " + err.Error())
+			}
+		})
+		acmeRequest.OnTLSALPNAuth(func(domain string, certData []byte, keyData []byte) error {
+			return this.presentChallengeCert(tx, taskId, domain, certData, keyData)
+		}, func(domain string) error {
+			return this.cleanUpChallengeCert(tx, taskId, domain)
+		})
+		certData, keyData, err := acmeRequest.Run()
 		if err != nil {
-			logs.Println("[ACME]write authentication to database error: " + err.Error())
+			errMsg = "证书生成失败：" + err.Error()
+			return
 		}
-	})
-	certData, keyData, err := acmeRequest.Run()
+
+		if index == 0 {
+			resultCertId, errMsg = this.saveTaskCert(tx, task, false, certData, keyData)
+		} else {
+			dualCertId, errMsg = this.saveTaskCert(tx, task, true, certData, keyData)
+		}
+		if len(errMsg) > 0 {
+			return
+		}
+	}
+
+	// 双证书需要放在同一个SSL策略中
+	if dualCertId > 0 {
+		err = models.SharedSSLPolicyDAO.AddCertToPoliciesWithCertId(tx, resultCertId, dualCertId)
+		if err == nil {
+			err = models.SharedSSLPolicyDAO.AddCertToPoliciesWithCertId(tx, dualCertId, resultCertId)
+		}
+		if err != nil {
+			errMsg = "证书生成成功，但是将证书加入SSL策略时出错：" + err.Error()
+			return
+		}
+	}
+
+	isOk = true
+	return
+}
+
+// 将TLS-ALPN-01认证证书分发到节点
+func (this *ACMETaskDAO) presentChallengeCert(tx *dbs.Tx, taskId int64, domain string, certData []byte, keyData []byte) error {
+	err := SharedACMEChallengeCertDAO.CreateChallengeCert(tx, taskId, domain, certData, keyData)
 	if err != nil {
-		errMsg = "证书生成失败：" + err.Error()
-		return
+		return err
+	}
+	clusterIds, err := SharedACMEChallengeCertDAO.NotifyUpdate(tx, domain)
+	if err != nil {
+		return err
 	}
+	if len(clusterIds) == 0 {
+		return errors.New("can not find cluster serving domain '" + domain + "'")
+	}
+
+	// 等待节点加载认证证书后再让ACME服务器验证
+	return SharedACMEChallengeCertDAO.WaitNodesUpdated(tx, clusterIds, 30*time.Second)
+}
+
+// 认证结束后从节点上删除TLS-ALPN-01认证证书
+func (this *ACMETaskDAO) cleanUpChallengeCert(tx *dbs.Tx, taskId int64, domain string) error {
+	err := SharedACMEChallengeCertDAO.DeleteChallengeCert(tx, taskId, domain)
+	if err != nil {
+		return err
+	}
+	_, err = SharedACMEChallengeCertDAO.NotifyUpdate(tx, domain)
+	return err
+}
+
+// 保存任务生成的证书
+// isDual 表示是否为双证书中的ECDSA证书
+func (this *ACMETaskDAO) saveTaskCert(tx *dbs.Tx, task *ACMETask, isDual bool, certData []byte, keyData []byte) (certId int64, errMsg string) {
+	var taskId = int64(task.Id)
 
 	// 分析证书
 	sslConfig := &sslconfigs.SSLCertConfig{
 		CertData: certData,
 		KeyData:  keyData,
 	}
-	err = sslConfig.Init()
+	err := sslConfig.Init()
 	if err != nil {
 		errMsg = "证书生成成功，但是分析证书信息时发生错误：" + err.Error()
 		return
 	}
 
-	// 保存证书
-	resultCertId = int64(task.CertId)
-	if resultCertId > 0 {
-		cert, err := models.SharedSSLCertDAO.FindEnabledSSLCert(tx, resultCertId)
+	// 修改已有的证书
+	certId = int64(task.CertId)
+	if isDual {
+		certId = int64(task.DualCertId)
+	}
+	if certId > 0 {
+		cert, err := models.SharedSSLCertDAO.FindEnabledSSLCert(tx, certId)
 		if err != nil {
 			errMsg = "证书生成成功，但查询已绑定的证书时出错：" + err.Error()
 			return
 		}
-		if cert == nil {
+		if cert != nil {
+			err = models.SharedSSLCertDAO.UpdateCert(tx, certId, cert.IsOn == 1, cert.Name, cert.Description, cert.ServerName, cert.IsCA == 1, certData, keyData, sslConfig.TimeBeginAt, sslConfig.TimeEndAt, sslConfig.DNSNames, sslConfig.CommonNames)
+			if err != nil {
+				errMsg = "证书生成成功，但是修改数据库中的证书信息时出错：" + err.Error()
+				return
+			}
+			return
+		}
+
+		// ECDSA证书被删除时重新创建
+		if !isDual {
 			errMsg = "证书已被管理员或用户删除"
 
 			// 禁用
@@ -389,33 +675,34 @@ func (this *ACMETaskDAO) runTaskWithoutLog(tx *dbs.Tx, taskId int64) (isOk bool,
 
 			return
 		}
+	}
 
-		err = models.SharedSSLCertDAO.UpdateCert(tx, resultCertId, cert.IsOn == 1, cert.Name, cert.Description, cert.ServerName, cert.IsCA == 1, certData, keyData, sslConfig.TimeBeginAt, sslConfig.TimeEndAt, sslConfig.DNSNames, sslConfig.CommonNames)
-		if err != nil {
-			errMsg = "证书生成成功，但是修改数据库中的证书信息时出错：" + err.Error()
-			return
-		}
-	} else {
-		resultCertId, err = models.SharedSSLCertDAO.CreateCert(tx, int64(task.AdminId), int64(task.UserId), true, task.DnsDomain+"免费证书", "免费申请的证书", "", false, certData, keyData, sslConfig.TimeBeginAt, sslConfig.TimeEndAt, sslConfig.DNSNames, sslConfig.CommonNames)
-		if err != nil {
-			errMsg = "证书生成成功，但是保存到数据库失败：" + err.Error()
-			return
-		}
-
-		err = models.SharedSSLCertDAO.UpdateCertACME(tx, resultCertId, int64(task.Id))
-		if err != nil {
-			errMsg = "证书生成成功，修改证书ACME信息时出错：" + err.Error()
-			return
-		}
+	// 创建新的证书
+	var name = task.DnsDomain + "免费证书"
+	if isDual {
+		name += "（ECDSA）"
+	}
+	certId, err = models.SharedSSLCertDAO.CreateCert(tx, int64(task.AdminId), int64(task.UserId), true, name, "免费申请的证书", "", false, certData, keyData, sslConfig.TimeBeginAt, sslConfig.TimeEndAt, sslConfig.DNSNames, sslConfig.CommonNames)
+	if err != nil {
+		errMsg = "证书生成成功，但是保存到数据库失败：" + err.Error()
+		return
+	}
 
-		// 设置成功
-		err = SharedACMETaskDAO.UpdateACMETaskCert(tx, taskId, resultCertId)
-		if err != nil {
-			errMsg = "证书生成成功，设置任务关联的证书时出错：" + err.Error()
-			return
-		}
+	err = models.SharedSSLCertDAO.UpdateCertACME(tx, certId, taskId)
+	if err != nil {
+		errMsg = "证书生成成功，修改证书ACME信息时出错：" + err.Error()
+		return
 	}
 
-	isOk = true
+	// 设置成功
+	if isDual {
+		err = SharedACMETaskDAO.UpdateACMETaskDualCert(tx, taskId, certId)
+	} else {
+		err = SharedACMETaskDAO.UpdateACMETaskCert(tx, taskId, certId)
+	}
+	if err != nil {
+		errMsg = "证书生成成功，设置任务关联的证书时出错：" + err.Error()
+		return
+	}
 	return
 }
@@ -6,6 +6,14 @@ import (
 	"github.com/iwind/TeaGo/dbs"
 )
 
+type ACMETaskLogType = string
+
+const (
+	ACMETaskLogTypeManual ACMETaskLogType = "manual" // 手动执行
+	ACMETaskLogTypeRenew  ACMETaskLogType = "renew"  // 自动续期
+	ACMETaskLogTypeRevoke ACMETaskLogType = "revoke" // 吊销证书
+)
+
 type ACMETaskLogDAO dbs.DAO
 
 func NewACMETaskLogDAO() *ACMETaskLogDAO {
@@ -28,11 +36,15 @@ func init() {
 }
 
 // 生成日志
-func (this *ACMETaskLogDAO) CreateACMETaskLog(tx *dbs.Tx, taskId int64, isOk bool, errMsg string) error {
+// attempt 为自动续期时第几次尝试，nextRetryAt 为失败后下次重试的时间
+func (this *ACMETaskLogDAO) CreateACMETaskLog(tx *dbs.Tx, taskId int64, isOk bool, errMsg string, logType ACMETaskLogType, attempt int, nextRetryAt int64) error {
 	op := NewACMETaskLogOperator()
 	op.TaskId = taskId
 	op.Error = errMsg
 	op.IsOk = isOk
+	op.Type = logType
+	op.Attempt = attempt
+	op.NextRetryAt = nextRetryAt
 	err := this.Save(tx, op)
 	return err
 }
@@ -48,3 +60,22 @@ func (this *ACMETaskLogDAO) FindLatestACMETasKLog(tx *dbs.Tx, taskId int64) (*AC
 	}
 	return one.(*ACMETaskLog), nil
 }
+
+// CountACMETaskLogs 计算任务的执行日志数量
+func (this *ACMETaskLogDAO) CountACMETaskLogs(tx *dbs.Tx, taskId int64) (int64, error) {
+	return this.Query(tx).
+		Attr("taskId", taskId).
+		Count()
+}
+
+// ListACMETaskLogs 列出单页任务的执行日志
+func (this *ACMETaskLogDAO) ListACMETaskLogs(tx *dbs.Tx, taskId int64, offset int64, size int64) (result []*ACMETaskLog, err error) {
+	_, err = this.Query(tx).
+		Attr("taskId", taskId).
+		Offset(offset).
+		Limit(size).
+		DescPk().
+		Slice(&result).
+		FindAll()
+	return
+}
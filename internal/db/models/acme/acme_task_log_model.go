@@ -2,19 +2,25 @@ package acme
 
 // ACME任务运行日志
 type ACMETaskLog struct {
-	Id        uint64 `field:"id"`        // ID
-	TaskId    uint64 `field:"taskId"`    // 任务ID
-	IsOk      uint8  `field:"isOk"`      // 是否成功
-	Error     string `field:"error"`     // 错误信息
-	CreatedAt uint64 `field:"createdAt"` // 运行时间
+	Id          uint64 `field:"id"`          // ID
+	TaskId      uint64 `field:"taskId"`      // 任务ID
+	IsOk        uint8  `field:"isOk"`        // 是否成功
+	Error       string `field:"error"`       // 错误信息
+	Type        string `field:"type"`        // 执行方式：manual, renew
+	Attempt     uint32 `field:"attempt"`     // 第几次尝试续期
+	NextRetryAt uint64 `field:"nextRetryAt"` // 下次重试时间，0表示不再重试
+	CreatedAt   uint64 `field:"createdAt"`   // 运行时间
 }
 
 type ACMETaskLogOperator struct {
-	Id        interface{} // ID
-	TaskId    interface{} // 任务ID
-	IsOk      interface{} // 是否成功
-	Error     interface{} // 错误信息
-	CreatedAt interface{} // 运行时间
+	Id          interface{} // ID
+	TaskId      interface{} // 任务ID
+	IsOk        interface{} // 是否成功
+	Error       interface{} // 错误信息
+	Type        interface{} // 执行方式：manual, renew
+	Attempt     interface{} // 第几次尝试续期
+	NextRetryAt interface{} // 下次重试时间，0表示不再重试
+	CreatedAt   interface{} // 运行时间
 }
 
 func NewACMETaskLogOperator() *ACMETaskLogOperator {
@@ -6,11 +6,15 @@ import (
 	"crypto/rand"
 	"crypto/x509"
 	"encoding/base64"
+	"encoding/json"
+	"github.com/TeaOSLab/EdgeAPI/internal/acme"
 	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/go-acme/lego/v4/registration"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/iwind/TeaGo/Tea"
 	"github.com/iwind/TeaGo/dbs"
 	"github.com/iwind/TeaGo/types"
+	"time"
 )
 
 const (
@@ -70,18 +74,17 @@ func (this *ACMEUserDAO) FindEnabledACMEUser(tx *dbs.Tx, id int64) (*ACMEUser, e
 }
 
 // 创建用户
-func (this *ACMEUserDAO) CreateACMEUser(tx *dbs.Tx, adminId int64, userId int64, email string, description string) (int64, error) {
-	// 生成私钥
-	privateKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+func (this *ACMEUserDAO) CreateACMEUser(tx *dbs.Tx, adminId int64, userId int64, email string, description string, caURL string, eabKeyId string, eabHMACKey string) (int64, error) {
+	err := acme.ValidateCA(caURL, eabKeyId, eabHMACKey)
 	if err != nil {
 		return 0, err
 	}
 
-	privateKeyData, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	// 生成私钥
+	_, privateKeyText, err := this.generatePrivateKey()
 	if err != nil {
 		return 0, err
 	}
-	privateKeyText := base64.StdEncoding.EncodeToString(privateKeyData)
 
 	op := NewACMEUserOperator()
 	op.AdminId = adminId
@@ -89,6 +92,9 @@ func (this *ACMEUserDAO) CreateACMEUser(tx *dbs.Tx, adminId int64, userId int64,
 	op.Email = email
 	op.Description = description
 	op.PrivateKey = privateKeyText
+	op.CaURL = caURL
+	op.EabKeyId = eabKeyId
+	op.EabHMACKey = eabHMACKey
 	op.State = ACMEUserStateEnabled
 	err = this.Save(tx, op)
 	if err != nil {
@@ -109,6 +115,36 @@ func (this *ACMEUserDAO) UpdateACMEUser(tx *dbs.Tx, acmeUserId int64, descriptio
 	return err
 }
 
+// UpdateACMEUserCA 修改用户的CA目录地址和EAB
+// CA改变后原有的注册信息不再有效，需要重新注册
+func (this *ACMEUserDAO) UpdateACMEUserCA(tx *dbs.Tx, acmeUserId int64, caURL string, eabKeyId string, eabHMACKey string) error {
+	if acmeUserId <= 0 {
+		return errors.New("invalid acmeUserId")
+	}
+	err := acme.ValidateCA(caURL, eabKeyId, eabHMACKey)
+	if err != nil {
+		return err
+	}
+
+	user, err := this.FindEnabledACMEUser(tx, acmeUserId)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return nil
+	}
+
+	op := NewACMEUserOperator()
+	op.Id = acmeUserId
+	op.CaURL = caURL
+	op.EabKeyId = eabKeyId
+	op.EabHMACKey = eabHMACKey
+	if user.CaURL != caURL || user.EabKeyId != eabKeyId {
+		op.Registration = dbs.SQL("NULL")
+	}
+	return this.Save(tx, op)
+}
+
 // 修改用户ACME注册信息
 func (this *ACMEUserDAO) UpdateACMEUserRegistration(tx *dbs.Tx, acmeUserId int64, registrationJSON []byte) error {
 	if acmeUserId <= 0 {
@@ -121,6 +157,98 @@ func (this *ACMEUserDAO) UpdateACMEUserRegistration(tx *dbs.Tx, acmeUserId int64
 	return err
 }
 
+// RolloverACMEUserKey 更换用户私钥
+// 先保存待更换的私钥，再通知ACME服务，成功后才启用新的私钥，失败时清除待更换的私钥
+// 如果上次更换中途被中断，则继续使用上次保存的私钥，以免ACME服务已经更换而本地丢失私钥
+func (this *ACMEUserDAO) RolloverACMEUserKey(tx *dbs.Tx, acmeUserId int64) error {
+	user, err := this.FindEnabledACMEUser(tx, acmeUserId)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return errors.New("can not find acme user '" + types.String(acmeUserId) + "'")
+	}
+
+	var newKey interface{}
+	var newKeyText = user.PendingPrivateKey
+	var isResumed = len(newKeyText) > 0
+	if isResumed {
+		newKey, err = acme.ParsePrivateKeyFromBase64(newKeyText)
+		if err != nil {
+			return errors.New("parse pending private key failed: " + err.Error())
+		}
+	} else {
+		newKey, newKeyText, err = this.generatePrivateKey()
+		if err != nil {
+			return err
+		}
+		err = this.Query(tx).
+			Pk(acmeUserId).
+			Set("pendingPrivateKey", newKeyText).
+			UpdateQuickly()
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(user.Registration) > 0 {
+		remoteUser, err := this.ComposeRemoteUser(tx, user)
+		if err != nil {
+			return err
+		}
+		err = acme.RolloverAccountKey(remoteUser, newKey)
+		if err != nil {
+			// 上次中断时保存的私钥可能已经在ACME服务中生效，所以不能清除
+			if !isResumed {
+				clearErr := this.Query(tx).
+					Pk(acmeUserId).
+					Set("pendingPrivateKey", "").
+					UpdateQuickly()
+				if clearErr != nil {
+					return clearErr
+				}
+			}
+			return err
+		}
+	}
+
+	op := NewACMEUserOperator()
+	op.Id = acmeUserId
+	op.PrivateKey = newKeyText
+	op.PendingPrivateKey = ""
+	op.KeyRolledAt = time.Now().Unix()
+	return this.Save(tx, op)
+}
+
+// ComposeRemoteUser 构造用于请求ACME服务的用户
+func (this *ACMEUserDAO) ComposeRemoteUser(tx *dbs.Tx, user *ACMEUser) (*acme.User, error) {
+	privateKey, err := acme.ParsePrivateKeyFromBase64(user.PrivateKey)
+	if err != nil {
+		return nil, errors.New("parse private key failed: " + err.Error())
+	}
+
+	remoteUser := acme.NewUser(user.Email, privateKey, func(resource *registration.Resource) error {
+		resourceJSON, err := json.Marshal(resource)
+		if err != nil {
+			return err
+		}
+
+		err = this.UpdateACMEUserRegistration(tx, int64(user.Id), resourceJSON)
+		return err
+	})
+
+	remoteUser.SetCA(user.CaURL)
+	remoteUser.SetEAB(user.EabKeyId, user.EabHMACKey)
+
+	if len(user.Registration) > 0 {
+		err = remoteUser.SetRegistration([]byte(user.Registration))
+		if err != nil {
+			return nil, errors.New("decode registration failed: " + err.Error())
+		}
+	}
+	return remoteUser, nil
+}
+
 // 计算用户数量
 func (this *ACMEUserDAO) CountACMEUsersWithAdminId(tx *dbs.Tx, adminId int64, userId int64) (int64, error) {
 	query := this.Query(tx)
@@ -197,3 +325,17 @@ func (this *ACMEUserDAO) CheckACMEUser(tx *dbs.Tx, acmeUserId int64, adminId int
 		State(ACMEUserStateEnabled).
 		Exist()
 }
+
+// 生成新的账号私钥
+func (this *ACMEUserDAO) generatePrivateKey() (privateKey *ecdsa.PrivateKey, base64Text string, err error) {
+	privateKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, "", err
+	}
+
+	privateKeyData, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	if err != nil {
+		return nil, "", err
+	}
+	return privateKey, base64.StdEncoding.EncodeToString(privateKeyData), nil
+}
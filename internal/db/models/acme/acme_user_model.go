@@ -2,27 +2,37 @@ package acme
 
 //
 type ACMEUser struct {
-	Id           uint64 `field:"id"`           // ID
-	AdminId      uint32 `field:"adminId"`      // 管理员ID
-	UserId       uint32 `field:"userId"`       // 用户ID
-	PrivateKey   string `field:"privateKey"`   // 私钥
-	Email        string `field:"email"`        // E-mail
-	CreatedAt    uint64 `field:"createdAt"`    // 创建时间
-	State        uint8  `field:"state"`        // 状态
-	Description  string `field:"description"`  // 备注介绍
-	Registration string `field:"registration"` // 注册信息
+	Id                uint64 `field:"id"`                // ID
+	AdminId           uint32 `field:"adminId"`           // 管理员ID
+	UserId            uint32 `field:"userId"`            // 用户ID
+	PrivateKey        string `field:"privateKey"`        // 私钥
+	PendingPrivateKey string `field:"pendingPrivateKey"` // 更换中的私钥
+	Email             string `field:"email"`             // E-mail
+	CreatedAt         uint64 `field:"createdAt"`         // 创建时间
+	State             uint8  `field:"state"`             // 状态
+	Description       string `field:"description"`       // 备注介绍
+	Registration      string `field:"registration"`      // 注册信息
+	CaURL             string `field:"caURL"`             // CA目录地址，为空表示使用默认地址
+	EabKeyId          string `field:"eabKeyId"`          // EAB Key ID
+	EabHMACKey        string `field:"eabHMACKey"`        // EAB HMAC Key
+	KeyRolledAt       uint64 `field:"keyRolledAt"`       // 最后更换私钥时间
 }
 
 type ACMEUserOperator struct {
-	Id           interface{} // ID
-	AdminId      interface{} // 管理员ID
-	UserId       interface{} // 用户ID
-	PrivateKey   interface{} // 私钥
-	Email        interface{} // E-mail
-	CreatedAt    interface{} // 创建时间
-	State        interface{} // 状态
-	Description  interface{} // 备注介绍
-	Registration interface{} // 注册信息
+	Id                interface{} // ID
+	AdminId           interface{} // 管理员ID
+	UserId            interface{} // 用户ID
+	PrivateKey        interface{} // 私钥
+	PendingPrivateKey interface{} // 更换中的私钥
+	Email             interface{} // E-mail
+	CreatedAt         interface{} // 创建时间
+	State             interface{} // 状态
+	Description       interface{} // 备注介绍
+	Registration      interface{} // 注册信息
+	CaURL             interface{} // CA目录地址，为空表示使用默认地址
+	EabKeyId          interface{} // EAB Key ID
+	EabHMACKey        interface{} // EAB HMAC Key
+	KeyRolledAt       interface{} // 最后更换私钥时间
 }
 
 func NewACMEUserOperator() *ACMEUserOperator {
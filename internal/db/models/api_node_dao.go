@@ -261,6 +261,18 @@ func (this *APINodeDAO) UpdateAPINodeStatus(tx *dbs.Tx, apiNodeId int64, statusJ
 	return err
 }
 
+// UpdateAPINodeRequireClientCert 设置API节点是否要求节点使用客户端证书
+func (this *APINodeDAO) UpdateAPINodeRequireClientCert(tx *dbs.Tx, apiNodeId int64, requireClientCert bool) error {
+	if apiNodeId <= 0 {
+		return errors.New("invalid apiNodeId")
+	}
+	_, err := this.Query(tx).
+		Pk(apiNodeId).
+		Set("requireClientCert", requireClientCert).
+		Update()
+	return err
+}
+
 // 生成唯一ID
 func (this *APINodeDAO) genUniqueId(tx *dbs.Tx) (string, error) {
 	for {
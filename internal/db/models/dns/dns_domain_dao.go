@@ -166,6 +166,67 @@ func (this *DNSDomainDAO) UpdateDomainRoutes(tx *dbs.Tx, domainId int64, routesJ
 	return err
 }
 
+// FindAllEnabledAndOnDomains 查找所有启用的域名
+func (this *DNSDomainDAO) FindAllEnabledAndOnDomains(tx *dbs.Tx) (result []*DNSDomain, err error) {
+	_, err = this.Query(tx).
+		State(DNSDomainStateEnabled).
+		Attr("isOn", true).
+		Gt("providerId", 0).
+		AscPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// UpdateDomainDrifts 保存记录偏差检查结果
+func (this *DNSDomainDAO) UpdateDomainDrifts(tx *dbs.Tx, domainId int64, drifts []*DNSDomainDrift) error {
+	if domainId <= 0 {
+		return errors.New("invalid domainId")
+	}
+	if drifts == nil {
+		drifts = []*DNSDomainDrift{}
+	}
+	driftJSON, err := json.Marshal(drifts)
+	if err != nil {
+		return err
+	}
+	op := NewDNSDomainOperator()
+	op.Id = domainId
+	op.Drift = driftJSON
+	op.DriftCheckedAt = time.Now().Unix()
+	return this.Save(tx, op)
+}
+
+// UpdateDomainDriftAutoHeal 设置是否自动修复记录偏差
+func (this *DNSDomainDAO) UpdateDomainDriftAutoHeal(tx *dbs.Tx, domainId int64, autoHeal bool) error {
+	if domainId <= 0 {
+		return errors.New("invalid domainId")
+	}
+	op := NewDNSDomainOperator()
+	op.Id = domainId
+	op.DriftAutoHeal = autoHeal
+	return this.Save(tx, op)
+}
+
+// UpdateDomainMirror 设置域名的镜像服务商
+func (this *DNSDomainDAO) UpdateDomainMirror(tx *dbs.Tx, domainId int64, mirrorProviderId int64, mirrorRoutes map[string]string) error {
+	if domainId <= 0 {
+		return errors.New("invalid domainId")
+	}
+	if mirrorRoutes == nil {
+		mirrorRoutes = map[string]string{}
+	}
+	mirrorRoutesJSON, err := json.Marshal(mirrorRoutes)
+	if err != nil {
+		return err
+	}
+	op := NewDNSDomainOperator()
+	op.Id = domainId
+	op.MirrorProviderId = mirrorProviderId
+	op.MirrorRoutes = mirrorRoutesJSON
+	return this.Save(tx, op)
+}
+
 // FindDomainRoutes 查找域名线路
 func (this *DNSDomainDAO) FindDomainRoutes(tx *dbs.Tx, domainId int64) ([]*dnstypes.Route, error) {
 	routes, err := this.Query(tx).
@@ -0,0 +1,40 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package dns
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"sort"
+	"strings"
+)
+
+// DNSDomainDrift 集群解析记录和DNS服务商中实际记录之间的偏差
+type DNSDomainDrift struct {
+	ClusterId   int64               `json:"clusterId"`
+	ChangeSet   *dnstypes.ChangeSet `json:"changeSet"`   // 修复偏差需要执行的变更
+	IsOutOfBand bool                `json:"isOutOfBand"` // 是否为在DNS服务商中直接修改记录引起的
+	IsHealed    bool                `json:"isHealed"`    // 是否已自动修复
+	Error       string              `json:"error"`       // 自动修复时的错误信息
+	CreatedAt   int64               `json:"createdAt"`
+}
+
+// Fingerprint 偏差特征，用来判断两次检查发现的是否为同一个偏差
+func (this *DNSDomainDrift) Fingerprint() string {
+	if this.ChangeSet == nil {
+		return ""
+	}
+	var keys = []string{}
+	for _, change := range this.ChangeSet.Changes {
+		if change.Record == nil {
+			continue
+		}
+		keys = append(keys, change.Action+"@"+DNSRecordKey(change.Record))
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, "\n")
+}
+
+// DNSRecordKey 记录的唯一标识，忽略ID和域名值末尾的点（.）符号
+func DNSRecordKey(record *dnstypes.Record) string {
+	return record.Type + "|" + record.Name + "|" + record.Route + "|" + strings.TrimSuffix(record.Value, ".")
+}
@@ -2,35 +2,45 @@ package dns
 
 // 管理的域名
 type DNSDomain struct {
-	Id            uint32 `field:"id"`            // ID
-	AdminId       uint32 `field:"adminId"`       // 管理员ID
-	UserId        uint32 `field:"userId"`        // 用户ID
-	ProviderId    uint32 `field:"providerId"`    // 服务商ID
-	IsOn          uint8  `field:"isOn"`          // 是否可用
-	Name          string `field:"name"`          // 域名
-	CreatedAt     uint64 `field:"createdAt"`     // 创建时间
-	DataUpdatedAt uint64 `field:"dataUpdatedAt"` // 数据更新时间
-	DataError     string `field:"dataError"`     // 数据更新错误
-	Data          string `field:"data"`          // 原始数据信息
-	Records       string `field:"records"`       // 所有解析记录
-	Routes        string `field:"routes"`        // 线路数据
-	State         uint8  `field:"state"`         // 状态
+	Id               uint32 `field:"id"`               // ID
+	AdminId          uint32 `field:"adminId"`          // 管理员ID
+	UserId           uint32 `field:"userId"`           // 用户ID
+	ProviderId       uint32 `field:"providerId"`       // 服务商ID
+	IsOn             uint8  `field:"isOn"`             // 是否可用
+	Name             string `field:"name"`             // 域名
+	CreatedAt        uint64 `field:"createdAt"`        // 创建时间
+	DataUpdatedAt    uint64 `field:"dataUpdatedAt"`    // 数据更新时间
+	DataError        string `field:"dataError"`        // 数据更新错误
+	Data             string `field:"data"`             // 原始数据信息
+	Records          string `field:"records"`          // 所有解析记录
+	Routes           string `field:"routes"`           // 线路数据
+	DriftAutoHeal    uint8  `field:"driftAutoHeal"`    // 是否自动修复记录偏差
+	Drift            string `field:"drift"`            // 记录偏差
+	DriftCheckedAt   uint64 `field:"driftCheckedAt"`   // 记录偏差检查时间
+	MirrorProviderId uint32 `field:"mirrorProviderId"` // 镜像服务商ID
+	MirrorRoutes     string `field:"mirrorRoutes"`     // 镜像服务商线路映射
+	State            uint8  `field:"state"`            // 状态
 }
 
 type DNSDomainOperator struct {
-	Id            interface{} // ID
-	AdminId       interface{} // 管理员ID
-	UserId        interface{} // 用户ID
-	ProviderId    interface{} // 服务商ID
-	IsOn          interface{} // 是否可用
-	Name          interface{} // 域名
-	CreatedAt     interface{} // 创建时间
-	DataUpdatedAt interface{} // 数据更新时间
-	DataError     interface{} // 数据更新错误
-	Data          interface{} // 原始数据信息
-	Records       interface{} // 所有解析记录
-	Routes        interface{} // 线路数据
-	State         interface{} // 状态
+	Id               interface{} // ID
+	AdminId          interface{} // 管理员ID
+	UserId           interface{} // 用户ID
+	ProviderId       interface{} // 服务商ID
+	IsOn             interface{} // 是否可用
+	Name             interface{} // 域名
+	CreatedAt        interface{} // 创建时间
+	DataUpdatedAt    interface{} // 数据更新时间
+	DataError        interface{} // 数据更新错误
+	Data             interface{} // 原始数据信息
+	Records          interface{} // 所有解析记录
+	Routes           interface{} // 线路数据
+	DriftAutoHeal    interface{} // 是否自动修复记录偏差
+	Drift            interface{} // 记录偏差
+	DriftCheckedAt   interface{} // 记录偏差检查时间
+	MirrorProviderId interface{} // 镜像服务商ID
+	MirrorRoutes     interface{} // 镜像服务商线路映射
+	State            interface{} // 状态
 }
 
 func NewDNSDomainOperator() *DNSDomainOperator {
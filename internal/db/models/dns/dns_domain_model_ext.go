@@ -45,3 +45,29 @@ func (this *DNSDomain) DecodeRecords() ([]*dnstypes.Record, error) {
 	}
 	return result, nil
 }
+
+// DecodeDrifts 获取最近一次检查发现的记录偏差
+func (this *DNSDomain) DecodeDrifts() ([]*DNSDomainDrift, error) {
+	if len(this.Drift) == 0 || this.Drift == "null" {
+		return nil, nil
+	}
+	result := []*DNSDomainDrift{}
+	err := json.Unmarshal([]byte(this.Drift), &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// DecodeMirrorRoutes 获取镜像服务商线路映射
+func (this *DNSDomain) DecodeMirrorRoutes() (map[string]string, error) {
+	result := map[string]string{}
+	if len(this.MirrorRoutes) == 0 || this.MirrorRoutes == "null" {
+		return result, nil
+	}
+	err := json.Unmarshal([]byte(this.MirrorRoutes), &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
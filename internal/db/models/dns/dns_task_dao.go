@@ -1,6 +1,8 @@
 package dns
 
 import (
+	"encoding/json"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
 	"github.com/TeaOSLab/EdgeAPI/internal/errors"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/iwind/TeaGo/Tea"
@@ -18,6 +20,9 @@ const (
 	DNSTaskTypeDomainChange  DNSTaskType = "domainChange"
 )
 
+// DNSTaskMaxChangeSets 每个任务最多保留的变更集数量
+const DNSTaskMaxChangeSets = 10
+
 type DNSTaskDAO dbs.DAO
 
 func NewDNSTaskDAO() *DNSTaskDAO {
@@ -151,3 +156,39 @@ func (this *DNSTaskDAO) UpdateDNSTaskDone(tx *dbs.Tx, taskId int64) error {
 	op.Error = ""
 	return this.Save(tx, op)
 }
+
+// AddDNSTaskChangeSet 记录任务执行的变更集
+func (this *DNSTaskDAO) AddDNSTaskChangeSet(tx *dbs.Tx, taskId int64, changeSet *dnstypes.ChangeSet) error {
+	if taskId <= 0 {
+		return errors.New("invalid taskId")
+	}
+	if changeSet == nil {
+		return nil
+	}
+	changeSetsString, err := this.Query(tx).
+		Pk(taskId).
+		Result("changeSets").
+		FindStringCol("")
+	if err != nil {
+		return err
+	}
+	task := &DNSTask{ChangeSets: changeSetsString}
+	changeSets, err := task.DecodeChangeSets()
+	if err != nil {
+		// 旧数据无法解析时直接覆盖
+		changeSets = nil
+	}
+	changeSets = append(changeSets, changeSet)
+	if len(changeSets) > DNSTaskMaxChangeSets {
+		changeSets = changeSets[len(changeSets)-DNSTaskMaxChangeSets:]
+	}
+	changeSetsJSON, err := json.Marshal(changeSets)
+	if err != nil {
+		return err
+	}
+
+	op := NewDNSTaskOperator()
+	op.Id = taskId
+	op.ChangeSets = changeSetsJSON
+	return this.Save(tx, op)
+}
@@ -1 +1,19 @@
 package dns
+
+import (
+	"encoding/json"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+)
+
+// DecodeChangeSets 解析已执行的变更集
+func (this *DNSTask) DecodeChangeSets() ([]*dnstypes.ChangeSet, error) {
+	if len(this.ChangeSets) == 0 || this.ChangeSets == "null" {
+		return nil, nil
+	}
+	result := []*dnstypes.ChangeSet{}
+	err := json.Unmarshal([]byte(this.ChangeSets), &result)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
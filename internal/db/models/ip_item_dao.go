@@ -71,6 +71,11 @@ func (this *IPItemDAO) DisableIPItem(tx *dbs.Tx, id int64) error {
 	if err != nil {
 		return err
 	}
+
+	err = SharedSyncTombstoneDAO.CreateTombstone(tx, SyncTombstoneKindIPItem, id, version)
+	if err != nil {
+		return err
+	}
 	return this.NotifyUpdate(tx, id)
 }
 
@@ -25,19 +25,24 @@ const (
 type MessageType = string
 
 const (
-	MessageTypeHealthCheckFailed          MessageType = "HealthCheckFailed"          // 节点健康检查失败
-	MessageTypeHealthCheckNodeUp          MessageType = "HealthCheckNodeUp"          // 因健康检查节点上线
-	MessageTypeHealthCheckNodeDown        MessageType = "HealthCheckNodeDown"        // 因健康检查节点下线
-	MessageTypeNodeInactive               MessageType = "NodeInactive"               // 节点不活跃
-	MessageTypeNodeActive                 MessageType = "NodeActive"                 // 节点活跃
-	MessageTypeClusterDNSSyncFailed       MessageType = "ClusterDNSSyncFailed"       // DNS同步失败
-	MessageTypeSSLCertExpiring            MessageType = "SSLCertExpiring"            // SSL证书即将过期
-	MessageTypeSSLCertACMETaskFailed      MessageType = "SSLCertACMETaskFailed"      // SSL证书任务执行失败
-	MessageTypeSSLCertACMETaskSuccess     MessageType = "SSLCertACMETaskSuccess"     // SSL证书任务执行成功
-	MessageTypeLogCapacityOverflow        MessageType = "LogCapacityOverflow"        // 日志超出最大限制
-	MessageTypeServerNamesAuditingSuccess MessageType = "ServerNamesAuditingSuccess" // 服务域名审核成功
-	MessageTypeServerNamesAuditingFailed  MessageType = "ServerNamesAuditingFailed"  // 服务域名审核失败
-	MessageTypeThresholdSatisfied         MessageType = "ThresholdSatisfied"         // 满足阈值
+	MessageTypeHealthCheckFailed           MessageType = "HealthCheckFailed"           // 节点健康检查失败
+	MessageTypeHealthCheckNodeUp           MessageType = "HealthCheckNodeUp"           // 因健康检查节点上线
+	MessageTypeHealthCheckNodeDown         MessageType = "HealthCheckNodeDown"         // 因健康检查节点下线
+	MessageTypeNodeInactive                MessageType = "NodeInactive"                // 节点不活跃
+	MessageTypeNodeActive                  MessageType = "NodeActive"                  // 节点活跃
+	MessageTypeClusterDNSSyncFailed        MessageType = "ClusterDNSSyncFailed"        // DNS同步失败
+	MessageTypeClusterDNSDrifted           MessageType = "ClusterDNSDrifted"           // DNS记录和期望的不一致
+	MessageTypeClusterDNSDriftHealed       MessageType = "ClusterDNSDriftHealed"       // DNS记录偏差已自动修复
+	MessageTypeClusterDNSFailover          MessageType = "ClusterDNSFailover"          // DNS已切换到备用目标
+	MessageTypeClusterDNSFailoverRecovered MessageType = "ClusterDNSFailoverRecovered" // DNS已从故障转移中恢复
+	MessageTypeSSLCertExpiring             MessageType = "SSLCertExpiring"             // SSL证书即将过期
+	MessageTypeSSLCertACMETaskFailed       MessageType = "SSLCertACMETaskFailed"       // SSL证书任务执行失败
+	MessageTypeSSLCertACMETaskSuccess      MessageType = "SSLCertACMETaskSuccess"      // SSL证书任务执行成功
+	MessageTypeSSLCertRevoked              MessageType = "SSLCertRevoked"              // SSL证书已被吊销
+	MessageTypeLogCapacityOverflow         MessageType = "LogCapacityOverflow"         // 日志超出最大限制
+	MessageTypeServerNamesAuditingSuccess  MessageType = "ServerNamesAuditingSuccess"  // 服务域名审核成功
+	MessageTypeServerNamesAuditingFailed   MessageType = "ServerNamesAuditingFailed"   // 服务域名审核失败
+	MessageTypeThresholdSatisfied          MessageType = "ThresholdSatisfied"          // 满足阈值
 )
 
 type MessageDAO dbs.DAO
@@ -0,0 +1,200 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package nameservers
+
+import (
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/miekg/dns"
+	"strings"
+	"time"
+)
+
+// 每批日志中每个域名每天最多单独统计的记录名和类型组合数，超出的合并到 NSQueryNameOther 中
+const nsStatMaxQueriesPerBatch = 1000
+
+// NSAccessLogStatItem 用于统计的单条访问日志信息
+type NSAccessLogStatItem struct {
+	DomainId   int64   // 域名ID
+	Timestamp  int64   // 查询时间
+	Name       string  // 查询的域名
+	Type       string  // 查询的类型
+	Rcode      int     // 响应代码
+	RouteIds   []int64 // 匹配的线路，为空表示默认线路
+	CountryId  int64   // 国家/区域ID
+	ProvinceId int64   // 省份ID
+}
+
+// NSQueryCounter 查询计数
+type NSQueryCounter struct {
+	CountRequests int64
+	CountNXDOMAIN int64
+	CountSERVFAIL int64
+}
+
+func (this *NSQueryCounter) add(rcode int) {
+	this.CountRequests++
+	switch rcode {
+	case dns.RcodeNameError:
+		this.CountNXDOMAIN++
+	case dns.RcodeServerFailure:
+		this.CountSERVFAIL++
+	}
+}
+
+type nsMinuteKey struct {
+	domainId int64
+	minute   string
+}
+
+type nsDayKey struct {
+	domainId int64
+	day      string
+}
+
+type nsQueryKey struct {
+	domainId int64
+	day      string
+	name     string
+	qtype    string
+}
+
+type nsRouteKey struct {
+	domainId int64
+	day      string
+	routeId  int64
+}
+
+type nsRegionKey struct {
+	domainId   int64
+	day        string
+	countryId  int64
+	provinceId int64
+}
+
+// NSAccessLogStat 一批访问日志的汇总
+// 在写入访问日志时先在内存中合并，再批量累加到统计表中，这样看板就不需要扫描原始日志
+type NSAccessLogStat struct {
+	minutely     map[nsMinuteKey]*NSQueryCounter
+	queries      map[nsQueryKey]*NSQueryCounter
+	countQueries map[nsDayKey]int // 每个域名每天的记录名和类型组合数
+	routes       map[nsRouteKey]int64
+	regions      map[nsRegionKey]int64
+}
+
+func NewNSAccessLogStat() *NSAccessLogStat {
+	return &NSAccessLogStat{
+		minutely:     map[nsMinuteKey]*NSQueryCounter{},
+		queries:      map[nsQueryKey]*NSQueryCounter{},
+		countQueries: map[nsDayKey]int{},
+		routes:       map[nsRouteKey]int64{},
+		regions:      map[nsRegionKey]int64{},
+	}
+}
+
+// Add 添加一条访问日志
+// 不属于任何域名的查询不计入统计
+func (this *NSAccessLogStat) Add(item *NSAccessLogStatItem) {
+	if item.DomainId <= 0 {
+		return
+	}
+
+	var t = time.Unix(item.Timestamp, 0)
+	var minute = t.Format("200601021504")
+	var day = minute[:8]
+
+	// 分钟
+	var minuteKey = nsMinuteKey{domainId: item.DomainId, minute: minute}
+	counter, ok := this.minutely[minuteKey]
+	if !ok {
+		counter = &NSQueryCounter{}
+		this.minutely[minuteKey] = counter
+	}
+	counter.add(item.Rcode)
+
+	// 记录名和类型
+	var name = normalizeStatName(item.Name)
+	var qtype = strings.ToUpper(item.Type)
+	if len(name) > 0 && len(qtype) > 0 && len(qtype) <= 16 {
+		var queryKey = nsQueryKey{domainId: item.DomainId, day: day, name: name, qtype: qtype}
+		counter, ok = this.queries[queryKey]
+		if !ok {
+			// 防止大量随机的记录名占用过多内存
+			var dayKey = nsDayKey{domainId: item.DomainId, day: day}
+			if this.countQueries[dayKey] >= nsStatMaxQueriesPerBatch {
+				queryKey.name = NSQueryNameOther
+				counter, ok = this.queries[queryKey]
+			}
+			if !ok {
+				counter = &NSQueryCounter{}
+				this.queries[queryKey] = counter
+				this.countQueries[dayKey]++
+			}
+		}
+		counter.add(item.Rcode)
+	}
+
+	// 线路
+	if len(item.RouteIds) == 0 {
+		this.routes[nsRouteKey{domainId: item.DomainId, day: day, routeId: 0}]++
+	} else {
+		for _, routeId := range item.RouteIds {
+			this.routes[nsRouteKey{domainId: item.DomainId, day: day, routeId: routeId}]++
+		}
+	}
+
+	// 区域
+	this.regions[nsRegionKey{domainId: item.DomainId, day: day, countryId: item.CountryId, provinceId: item.ProvinceId}]++
+}
+
+// IsEmpty 是否没有任何统计数据
+func (this *NSAccessLogStat) IsEmpty() bool {
+	return len(this.minutely) == 0
+}
+
+// Save 累加到统计表中
+func (this *NSAccessLogStat) Save(tx *dbs.Tx) error {
+	for key, counter := range this.minutely {
+		err := SharedNSDomainMinutelyStatDAO.IncreaseStat(tx, key.domainId, key.minute, counter)
+		if err != nil {
+			return err
+		}
+	}
+	for key, counter := range this.queries {
+		err := SharedNSQueryDailyStatDAO.IncreaseStat(tx, key.domainId, key.day, key.name, key.qtype, counter)
+		if err != nil {
+			return err
+		}
+	}
+	for key, count := range this.routes {
+		err := SharedNSRouteDailyStatDAO.IncreaseStat(tx, key.domainId, key.day, key.routeId, count)
+		if err != nil {
+			return err
+		}
+	}
+	for key, count := range this.regions {
+		err := SharedNSRegionDailyStatDAO.IncreaseStat(tx, key.domainId, key.day, key.countryId, key.provinceId, count)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 格式化查询的域名
+func normalizeStatName(name string) string {
+	name = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+	if len(name) > 255 {
+		name = name[:255]
+	}
+	return name
+}
+
+// 限制统计查询的域名范围
+func filterStatQuery(query *dbs.Query, userId int64, domainId int64) {
+	if domainId > 0 {
+		query.Attr("domainId", domainId)
+	} else if userId > 0 {
+		query.Where("domainId IN (SELECT id FROM "+SharedNSDomainDAO.Table+" WHERE userId=:userId AND state=1)").
+			Param("userId", userId)
+	}
+}
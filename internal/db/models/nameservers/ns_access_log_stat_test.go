@@ -0,0 +1,108 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package nameservers
+
+import (
+	"github.com/miekg/dns"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNSAccessLogStat_Add(t *testing.T) {
+	var timestamp = time.Date(2021, 6, 1, 10, 20, 30, 0, time.Local).Unix()
+
+	var stat = NewNSAccessLogStat()
+	stat.Add(&NSAccessLogStatItem{
+		DomainId:  1,
+		Timestamp: timestamp,
+		Name:      "WWW.Example.com.",
+		Type:      "a",
+		Rcode:     dns.RcodeSuccess,
+		CountryId: 1,
+	})
+	stat.Add(&NSAccessLogStatItem{
+		DomainId:  1,
+		Timestamp: timestamp + 10,
+		Name:      "www.example.com",
+		Type:      "A",
+		Rcode:     dns.RcodeNameError,
+		RouteIds:  []int64{2, 3},
+		CountryId: 1,
+	})
+	stat.Add(&NSAccessLogStatItem{
+		DomainId:  1,
+		Timestamp: timestamp + 60,
+		Name:      "mail.example.com",
+		Type:      "MX",
+		Rcode:     dns.RcodeServerFailure,
+	})
+	stat.Add(&NSAccessLogStatItem{
+		DomainId:  0,
+		Timestamp: timestamp,
+		Name:      "unknown.com",
+		Type:      "A",
+	})
+
+	if len(stat.minutely) != 2 {
+		t.Fatal("expect 2 minutes, but got", len(stat.minutely))
+	}
+	var counter = stat.minutely[nsMinuteKey{domainId: 1, minute: "202106011020"}]
+	if counter == nil || counter.CountRequests != 2 || counter.CountNXDOMAIN != 1 || counter.CountSERVFAIL != 0 {
+		t.Fatalf("unexpected counter: %#v", counter)
+	}
+
+	counter = stat.queries[nsQueryKey{domainId: 1, day: "20210601", name: "www.example.com", qtype: "A"}]
+	if counter == nil || counter.CountRequests != 2 {
+		t.Fatalf("unexpected query counter: %#v", counter)
+	}
+	counter = stat.queries[nsQueryKey{domainId: 1, day: "20210601", name: "mail.example.com", qtype: "MX"}]
+	if counter == nil || counter.CountSERVFAIL != 1 {
+		t.Fatalf("unexpected query counter: %#v", counter)
+	}
+
+	if stat.routes[nsRouteKey{domainId: 1, day: "20210601", routeId: 0}] != 2 ||
+		stat.routes[nsRouteKey{domainId: 1, day: "20210601", routeId: 2}] != 1 ||
+		stat.routes[nsRouteKey{domainId: 1, day: "20210601", routeId: 3}] != 1 {
+		t.Fatalf("unexpected routes: %#v", stat.routes)
+	}
+
+	if stat.regions[nsRegionKey{domainId: 1, day: "20210601", countryId: 1}] != 2 ||
+		stat.regions[nsRegionKey{domainId: 1, day: "20210601"}] != 1 {
+		t.Fatalf("unexpected regions: %#v", stat.regions)
+	}
+}
+
+func TestNSAccessLogStat_Add_MaxQueries(t *testing.T) {
+	var timestamp = time.Date(2021, 6, 1, 10, 20, 30, 0, time.Local).Unix()
+
+	var stat = NewNSAccessLogStat()
+	for i := 0; i < nsStatMaxQueriesPerBatch+10; i++ {
+		stat.Add(&NSAccessLogStatItem{
+			DomainId:  1,
+			Timestamp: timestamp,
+			Name:      "r" + strconv.Itoa(i) + ".example.com",
+			Type:      "A",
+		})
+	}
+
+	// 已经统计的记录名仍然单独计数
+	stat.Add(&NSAccessLogStatItem{
+		DomainId:  1,
+		Timestamp: timestamp,
+		Name:      "r0.example.com",
+		Type:      "A",
+	})
+
+	if len(stat.queries) != nsStatMaxQueriesPerBatch+1 {
+		t.Fatal("expect", nsStatMaxQueriesPerBatch+1, "queries, but got", len(stat.queries))
+	}
+	var counter = stat.queries[nsQueryKey{domainId: 1, day: "20210601", name: NSQueryNameOther, qtype: "A"}]
+	if counter == nil || counter.CountRequests != 10 {
+		t.Fatalf("unexpected other counter: %#v", counter)
+	}
+	counter = stat.queries[nsQueryKey{domainId: 1, day: "20210601", name: "r0.example.com", qtype: "A"}]
+	if counter == nil || counter.CountRequests != 2 {
+		t.Fatalf("unexpected query counter: %#v", counter)
+	}
+}
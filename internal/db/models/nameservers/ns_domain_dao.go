@@ -1,6 +1,7 @@
 package nameservers
 
 import (
+	"encoding/json"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
 	"github.com/TeaOSLab/EdgeAPI/internal/errors"
 	_ "github.com/go-sql-driver/mysql"
@@ -55,7 +56,10 @@ func (this *NSDomainDAO) DisableNSDomain(tx *dbs.Tx, domainId int64) error {
 		Set("state", NSDomainStateDisabled).
 		Set("version", version).
 		Update()
-	return err
+	if err != nil {
+		return err
+	}
+	return models.SharedSyncTombstoneDAO.CreateTombstone(tx, models.SyncTombstoneKindNSDomain, domainId, version)
 }
 
 // FindEnabledNSDomain 查找启用中的条目
@@ -194,3 +198,178 @@ func (this *NSDomainDAO) FindDomainIdWithName(tx *dbs.Tx, clusterId int64, name
 		ResultPk().
 		FindInt64Col(0)
 }
+
+// CheckUserDomain 检查域名是否属于某个用户
+func (this *NSDomainDAO) CheckUserDomain(tx *dbs.Tx, userId int64, domainId int64) error {
+	if userId <= 0 || domainId <= 0 {
+		return models.ErrNotFound
+	}
+	ok, err := this.Query(tx).
+		Pk(domainId).
+		Attr("userId", userId).
+		State(NSDomainStateEnabled).
+		Exist()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return models.ErrNotFound
+	}
+	return nil
+}
+
+// FindDomainDNSSECConfig 查找域名的DNSSEC配置
+func (this *NSDomainDAO) FindDomainDNSSECConfig(tx *dbs.Tx, domainId int64) (*NSDomainDNSSECConfig, error) {
+	configJSON, err := this.Query(tx).
+		Pk(domainId).
+		Result("dnssec").
+		FindStringCol("")
+	if err != nil {
+		return nil, err
+	}
+	domain := &NSDomain{Dnssec: configJSON}
+	return domain.DecodeDNSSECConfig()
+}
+
+// UpdateDomainDNSSECConfig 修改域名的DNSSEC配置
+func (this *NSDomainDAO) UpdateDomainDNSSECConfig(tx *dbs.Tx, domainId int64, config *NSDomainDNSSECConfig) error {
+	if domainId <= 0 {
+		return errors.New("invalid domainId")
+	}
+	if config == nil {
+		config = DefaultNSDomainDNSSECConfig()
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	version, err := this.IncreaseVersion(tx)
+	if err != nil {
+		return err
+	}
+
+	op := NewNSDomainOperator()
+	op.Id = domainId
+	op.Dnssec = configJSON
+	op.Version = version
+	return this.Save(tx, op)
+}
+
+// FindAllEnabledDNSSECDomains 查找所有启用了DNSSEC的域名
+func (this *NSDomainDAO) FindAllEnabledDNSSECDomains(tx *dbs.Tx) (result []*NSDomain, err error) {
+	_, err = this.Query(tx).
+		State(NSDomainStateEnabled).
+		Attr("isOn", true).
+		Where("JSON_EXTRACT(dnssec, '$.isOn')").
+		AscPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// FindDomainTransferConfig 查找域名的区域传送配置
+func (this *NSDomainDAO) FindDomainTransferConfig(tx *dbs.Tx, domainId int64) (*NSDomainTransferConfig, error) {
+	configJSON, err := this.Query(tx).
+		Pk(domainId).
+		Result("transfer").
+		FindStringCol("")
+	if err != nil {
+		return nil, err
+	}
+	domain := &NSDomain{Transfer: configJSON}
+	return domain.DecodeTransferConfig()
+}
+
+// UpdateDomainTransferConfig 修改域名的区域传送配置
+func (this *NSDomainDAO) UpdateDomainTransferConfig(tx *dbs.Tx, domainId int64, config *NSDomainTransferConfig) error {
+	if domainId <= 0 {
+		return errors.New("invalid domainId")
+	}
+	if config == nil {
+		config = DefaultNSDomainTransferConfig()
+	}
+
+	// SOA和NS记录可能有变化，需要增加序列号
+	serialVersion, err := SharedNSRecordDAO.IncreaseVersion(tx)
+	if err != nil {
+		return err
+	}
+	config.SerialVersion = serialVersion
+
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+
+	op := NewNSDomainOperator()
+	op.Id = domainId
+	op.Transfer = configJSON
+	return this.Save(tx, op)
+}
+
+// CheckTransferTSIGKeys 检查区域传送使用的TSIG密钥名是否已经被其他域名使用
+// 包括暂时停用的域名和区域传送，以免重新启用后和其他域名冲突
+func (this *NSDomainDAO) CheckTransferTSIGKeys(tx *dbs.Tx, domainId int64, config *NSDomainTransferConfig) error {
+	if config == nil || len(config.TSIGKeys) == 0 {
+		return nil
+	}
+
+	var domains = []*NSDomain{}
+	_, err := this.Query(tx).
+		State(NSDomainStateEnabled).
+		Neq("id", domainId).
+		Where("JSON_LENGTH(transfer, '$.tsigKeys')>0").
+		Result("id", "name", "transfer").
+		Slice(&domains).
+		FindAll()
+	if err != nil {
+		return err
+	}
+	for _, domain := range domains {
+		otherConfig, err := domain.DecodeTransferConfig()
+		if err != nil {
+			return errors.New("decode transfer config of domain '" + domain.Name + "' failed: " + err.Error())
+		}
+		for _, key := range config.TSIGKeys {
+			if otherConfig.FindTSIGKey(key.Name) != nil {
+				return errors.New("tsig key name '" + key.Name + "' is already used by another domain")
+			}
+		}
+	}
+	return nil
+}
+
+// FindAllEnabledTransferDomains 查找所有启用了区域传送的域名
+func (this *NSDomainDAO) FindAllEnabledTransferDomains(tx *dbs.Tx) (result []*NSDomain, err error) {
+	_, err = this.Query(tx).
+		State(NSDomainStateEnabled).
+		Attr("isOn", true).
+		Where("JSON_EXTRACT(transfer, '$.isOn')").
+		AscPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// FindDomainTransferSerial 计算区域传送使用的SOA序列号
+// 使用域名中记录的最新版本，修改区域传送配置时也会增加序列号
+func (this *NSDomainDAO) FindDomainTransferSerial(tx *dbs.Tx, domainId int64, config *NSDomainTransferConfig) (uint32, error) {
+	version, err := SharedNSRecordDAO.FindDomainLatestVersion(tx, domainId)
+	if err != nil {
+		return 0, err
+	}
+	if config != nil && config.SerialVersion > version {
+		version = config.SerialVersion
+	}
+	return uint32(version), nil
+}
+
+// UpdateDomainNotifiedSerial 修改最后发送NOTIFY的序列号
+// 不修改域名版本，避免触发节点同步
+func (this *NSDomainDAO) UpdateDomainNotifiedSerial(tx *dbs.Tx, domainId int64, serial uint32) error {
+	return this.Query(tx).
+		Pk(domainId).
+		Set("notifiedSerial", serial).
+		UpdateQuickly()
+}
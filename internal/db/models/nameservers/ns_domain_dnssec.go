@@ -0,0 +1,81 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package nameservers
+
+import (
+	"encoding/hex"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/dnssecutils"
+)
+
+// NSDomainDNSSECConfig 域名DNSSEC配置
+type NSDomainDNSSECConfig struct {
+	IsOn            bool   `json:"isOn"`
+	Algorithm       string `json:"algorithm"`       // 签名算法，参考 dnssecutils.AlgorithmNames
+	NSEC3           bool   `json:"nsec3"`           // 是否使用NSEC3代替NSEC
+	NSEC3Iterations uint16 `json:"nsec3Iterations"` // NSEC3迭代次数
+	NSEC3Salt       string `json:"nsec3Salt"`       // NSEC3盐值，十六进制格式
+	TTL             uint32 `json:"ttl"`             // DNSKEY、NSEC和NSEC3记录的TTL
+	SignatureDays   int    `json:"signatureDays"`   // 签名有效期
+	ZSKLifetimeDays int    `json:"zskLifetimeDays"` // ZSK使用期限，到期后自动轮换，0表示不自动轮换
+	PublishHours    int    `json:"publishHours"`    // 新的ZSK提前发布的时间
+	RetireHours     int    `json:"retireHours"`     // 旧的ZSK退役后保留的时间
+}
+
+// DefaultNSDomainDNSSECConfig 默认的DNSSEC配置
+func DefaultNSDomainDNSSECConfig() *NSDomainDNSSECConfig {
+	return &NSDomainDNSSECConfig{
+		IsOn:            false,
+		Algorithm:       dnssecutils.DefaultAlgorithm,
+		TTL:             3600,
+		SignatureDays:   14,
+		ZSKLifetimeDays: 30,
+		PublishHours:    24,
+		RetireHours:     24,
+	}
+}
+
+// Init 校验并初始化
+func (this *NSDomainDNSSECConfig) Init() error {
+	if len(this.Algorithm) == 0 {
+		this.Algorithm = dnssecutils.DefaultAlgorithm
+	}
+	_, ok := dnssecutils.AlgorithmNames[this.Algorithm]
+	if !ok {
+		return errors.New("unsupported algorithm '" + this.Algorithm + "'")
+	}
+	if len(this.NSEC3Salt) > 0 {
+		_, err := hex.DecodeString(this.NSEC3Salt)
+		if err != nil || len(this.NSEC3Salt) > 510 {
+			return errors.New("invalid nsec3 salt '" + this.NSEC3Salt + "'")
+		}
+	}
+	if this.TTL == 0 {
+		this.TTL = 3600
+	}
+	if this.SignatureDays <= 0 {
+		this.SignatureDays = 14
+	}
+	if this.PublishHours <= 0 {
+		this.PublishHours = 24
+	}
+	if this.RetireHours <= 0 {
+		this.RetireHours = 24
+	}
+	if this.ZSKLifetimeDays < 0 {
+		this.ZSKLifetimeDays = 0
+	}
+	if this.ZSKLifetimeDays > 0 && this.ZSKLifetimeDays*24 <= this.PublishHours {
+		return errors.New("zsk lifetime should be greater than publish hours")
+	}
+	return nil
+}
+
+// RolloverOptions 密钥轮换选项
+func (this *NSDomainDNSSECConfig) RolloverOptions() *dnssecutils.RolloverOptions {
+	return &dnssecutils.RolloverOptions{
+		ZSKLifetimeSeconds: int64(this.ZSKLifetimeDays) * 86400,
+		PublishSeconds:     int64(this.PublishHours) * 3600,
+		RetireSeconds:      int64(this.RetireHours) * 3600,
+	}
+}
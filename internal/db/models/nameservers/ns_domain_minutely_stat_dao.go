@@ -0,0 +1,75 @@
+package nameservers
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/maps"
+)
+
+type NSDomainMinutelyStatDAO dbs.DAO
+
+func NewNSDomainMinutelyStatDAO() *NSDomainMinutelyStatDAO {
+	return dbs.NewDAO(&NSDomainMinutelyStatDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeNSDomainMinutelyStats",
+			Model:  new(NSDomainMinutelyStat),
+			PkName: "id",
+		},
+	}).(*NSDomainMinutelyStatDAO)
+}
+
+var SharedNSDomainMinutelyStatDAO *NSDomainMinutelyStatDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedNSDomainMinutelyStatDAO = NewNSDomainMinutelyStatDAO()
+	})
+}
+
+// IncreaseStat 增加数量
+func (this *NSDomainMinutelyStatDAO) IncreaseStat(tx *dbs.Tx, domainId int64, minute string, counter *NSQueryCounter) error {
+	if len(minute) != 12 {
+		return errors.New("invalid minute '" + minute + "'")
+	}
+	return this.Query(tx).
+		Param("countRequests", counter.CountRequests).
+		Param("countNXDOMAIN", counter.CountNXDOMAIN).
+		Param("countSERVFAIL", counter.CountSERVFAIL).
+		InsertOrUpdateQuickly(maps.Map{
+			"domainId":      domainId,
+			"minute":        minute,
+			"countRequests": counter.CountRequests,
+			"countNXDOMAIN": counter.CountNXDOMAIN,
+			"countSERVFAIL": counter.CountSERVFAIL,
+		}, maps.Map{
+			"countRequests": dbs.SQL("countRequests+:countRequests"),
+			"countNXDOMAIN": dbs.SQL("countNXDOMAIN+:countNXDOMAIN"),
+			"countSERVFAIL": dbs.SQL("countSERVFAIL+:countSERVFAIL"),
+		})
+}
+
+// FindStats 查询某个时间段内每分钟的统计
+// domainId为0时汇总用户所有域名，userId也为0时汇总所有域名
+func (this *NSDomainMinutelyStatDAO) FindStats(tx *dbs.Tx, userId int64, domainId int64, minuteFrom string, minuteTo string) (result []*NSDomainMinutelyStat, err error) {
+	query := this.Query(tx).
+		Between("minute", minuteFrom, minuteTo)
+	filterStatQuery(query, userId, domainId)
+	_, err = query.
+		Group("minute").
+		Result("minute, SUM(countRequests) AS countRequests, SUM(countNXDOMAIN) AS countNXDOMAIN, SUM(countSERVFAIL) AS countSERVFAIL").
+		Asc("minute").
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// DeleteStatsBeforeMinute 删除某个时间之前的统计
+func (this *NSDomainMinutelyStatDAO) DeleteStatsBeforeMinute(tx *dbs.Tx, minute string) error {
+	_, err := this.Query(tx).
+		Lt("minute", minute).
+		Delete()
+	return err
+}
@@ -0,0 +1,24 @@
+package nameservers
+
+// NSDomainMinutelyStat 域名查询统计（按分钟）
+type NSDomainMinutelyStat struct {
+	Id            uint64 `field:"id"`            // ID
+	DomainId      uint32 `field:"domainId"`      // 域名ID
+	Minute        string `field:"minute"`        // YYYYMMDDHHII
+	CountRequests uint64 `field:"countRequests"` // 查询数
+	CountNXDOMAIN uint64 `field:"countNXDOMAIN"` // NXDOMAIN数
+	CountSERVFAIL uint64 `field:"countSERVFAIL"` // SERVFAIL数
+}
+
+type NSDomainMinutelyStatOperator struct {
+	Id            interface{} // ID
+	DomainId      interface{} // 域名ID
+	Minute        interface{} // YYYYMMDDHHII
+	CountRequests interface{} // 查询数
+	CountNXDOMAIN interface{} // NXDOMAIN数
+	CountSERVFAIL interface{} // SERVFAIL数
+}
+
+func NewNSDomainMinutelyStatOperator() *NSDomainMinutelyStatOperator {
+	return &NSDomainMinutelyStatOperator{}
+}
@@ -1 +1,29 @@
 package nameservers
+
+import "encoding/json"
+
+// DecodeDNSSECConfig 解析DNSSEC配置
+func (this *NSDomain) DecodeDNSSECConfig() (*NSDomainDNSSECConfig, error) {
+	var config = DefaultNSDomainDNSSECConfig()
+	if len(this.Dnssec) == 0 || this.Dnssec == "null" {
+		return config, nil
+	}
+	err := json.Unmarshal([]byte(this.Dnssec), config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// DecodeTransferConfig 解析区域传送配置
+func (this *NSDomain) DecodeTransferConfig() (*NSDomainTransferConfig, error) {
+	var config = DefaultNSDomainTransferConfig()
+	if len(this.Transfer) == 0 || this.Transfer == "null" {
+		return config, nil
+	}
+	err := json.Unmarshal([]byte(this.Transfer), config)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
@@ -0,0 +1,209 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package nameservers
+
+import (
+	"encoding/base64"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/miekg/dns"
+	"net"
+	"strings"
+)
+
+// NSTSIGKey 区域传送使用的TSIG密钥
+type NSTSIGKey struct {
+	Name      string `json:"name"`      // 密钥名，所有域名中的密钥名不能重复
+	Algorithm string `json:"algorithm"` // 算法，比如hmac-sha256.
+	Secret    string `json:"secret"`    // Base64编码的密钥
+}
+
+// NSDomainTransferConfig 域名区域传送（AXFR/IXFR）配置
+// 只传送默认线路的记录，DNSSEC自动生成的记录不会传送，需要从服务器自行签名
+type NSDomainTransferConfig struct {
+	IsOn          bool         `json:"isOn"`
+	AllowIPs      []string     `json:"allowIPs"`      // 允许传送的客户端IP或者CIDR
+	TSIGKeys      []*NSTSIGKey `json:"tsigKeys"`      // 允许使用的TSIG密钥，设置后请求必须使用其中一个密钥签名
+	NotifyAddrs   []string     `json:"notifyAddrs"`   // 版本变化时发送NOTIFY的地址，格式为ip:port
+	NotifyTSIGKey string       `json:"notifyTSIGKey"` // 发送NOTIFY时使用的TSIG密钥名
+	NSHosts       []string     `json:"nsHosts"`       // 域名服务器主机名，用于SOA和NS记录
+	Email         string       `json:"email"`         // 管理员邮箱，用于SOA记录
+	Refresh       uint32       `json:"refresh"`       // SOA刷新时间
+	Retry         uint32       `json:"retry"`         // SOA重试时间
+	Expire        uint32       `json:"expire"`        // SOA过期时间
+	MinTTL        uint32       `json:"minTTL"`        // SOA否定缓存时间
+	TTL           uint32       `json:"ttl"`           // SOA和NS记录的TTL
+	SerialVersion int64        `json:"serialVersion"` // 修改配置时的记录版本，用来保证修改后SOA序列号增加
+
+	allowNets []*net.IPNet
+}
+
+// DefaultNSDomainTransferConfig 默认的区域传送配置
+func DefaultNSDomainTransferConfig() *NSDomainTransferConfig {
+	return &NSDomainTransferConfig{
+		IsOn:    false,
+		Refresh: 3600,
+		Retry:   600,
+		Expire:  604800,
+		MinTTL:  300,
+		TTL:     3600,
+	}
+}
+
+// Init 校验并初始化
+func (this *NSDomainTransferConfig) Init() error {
+	// IP
+	this.allowNets = []*net.IPNet{}
+	for _, ip := range this.AllowIPs {
+		ip = strings.TrimSpace(ip)
+		if !strings.Contains(ip, "/") {
+			if strings.Contains(ip, ":") {
+				ip += "/128"
+			} else {
+				ip += "/32"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(ip)
+		if err != nil {
+			return errors.New("invalid allow ip '" + ip + "'")
+		}
+		this.allowNets = append(this.allowNets, ipNet)
+	}
+
+	// TSIG
+	for _, key := range this.TSIGKeys {
+		if len(key.Name) == 0 {
+			return errors.New("tsig key name should not be empty")
+		}
+		key.Name = dns.Fqdn(strings.ToLower(key.Name))
+		if len(key.Algorithm) == 0 {
+			key.Algorithm = dns.HmacSHA256
+		}
+		key.Algorithm = dns.Fqdn(strings.ToLower(key.Algorithm))
+		switch key.Algorithm {
+		case dns.HmacMD5, dns.HmacSHA1, dns.HmacSHA256, dns.HmacSHA512:
+		default:
+			return errors.New("unsupported tsig algorithm '" + key.Algorithm + "'")
+		}
+		_, err := base64.StdEncoding.DecodeString(key.Secret)
+		if err != nil {
+			return errors.New("invalid secret of tsig key '" + key.Name + "'")
+		}
+	}
+	if len(this.NotifyTSIGKey) > 0 {
+		this.NotifyTSIGKey = dns.Fqdn(strings.ToLower(this.NotifyTSIGKey))
+		if this.FindTSIGKey(this.NotifyTSIGKey) == nil {
+			return errors.New("can not find notify tsig key '" + this.NotifyTSIGKey + "'")
+		}
+	}
+
+	// NOTIFY
+	for index, addr := range this.NotifyAddrs {
+		_, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			addr = net.JoinHostPort(addr, "53")
+			_, _, err = net.SplitHostPort(addr)
+			if err != nil {
+				return errors.New("invalid notify address '" + this.NotifyAddrs[index] + "'")
+			}
+			this.NotifyAddrs[index] = addr
+		}
+	}
+
+	// SOA
+	for index, host := range this.NSHosts {
+		if _, ok := dns.IsDomainName(host); !ok {
+			return errors.New("invalid ns host '" + host + "'")
+		}
+		this.NSHosts[index] = dns.Fqdn(strings.ToLower(host))
+	}
+	if this.Refresh == 0 {
+		this.Refresh = 3600
+	}
+	if this.Retry == 0 {
+		this.Retry = 600
+	}
+	if this.Expire == 0 {
+		this.Expire = 604800
+	}
+	if this.MinTTL == 0 {
+		this.MinTTL = 300
+	}
+	if this.TTL == 0 {
+		this.TTL = 3600
+	}
+
+	return nil
+}
+
+// AllowIP 检查客户端IP是否允许传送
+func (this *NSDomainTransferConfig) AllowIP(ip net.IP) bool {
+	for _, ipNet := range this.allowNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// FindTSIGKey 根据名称查找TSIG密钥
+func (this *NSDomainTransferConfig) FindTSIGKey(name string) *NSTSIGKey {
+	name = dns.Fqdn(strings.ToLower(name))
+	for _, key := range this.TSIGKeys {
+		if key.Name == name {
+			return key
+		}
+	}
+	return nil
+}
+
+// ComposeSOA 组合SOA记录
+func (this *NSDomainTransferConfig) ComposeSOA(domainName string, serial uint32) *dns.SOA {
+	var zone = dns.Fqdn(strings.ToLower(domainName))
+	var mname = "ns1." + zone
+	if len(this.NSHosts) > 0 {
+		mname = this.NSHosts[0]
+	}
+	var rname = "hostmaster." + zone
+	if len(this.Email) > 0 {
+		// admin@example.com => admin.example.com.
+		var pieces = strings.SplitN(this.Email, "@", 2)
+		if len(pieces) == 2 {
+			rname = dns.Fqdn(strings.ReplaceAll(pieces[0], ".", "\\.") + "." + pieces[1])
+		} else {
+			rname = dns.Fqdn(this.Email)
+		}
+	}
+	return &dns.SOA{
+		Hdr: dns.RR_Header{
+			Name:   zone,
+			Rrtype: dns.TypeSOA,
+			Class:  dns.ClassINET,
+			Ttl:    this.TTL,
+		},
+		Ns:      mname,
+		Mbox:    rname,
+		Serial:  serial,
+		Refresh: this.Refresh,
+		Retry:   this.Retry,
+		Expire:  this.Expire,
+		Minttl:  this.MinTTL,
+	}
+}
+
+// ComposeNS 组合域名本身的NS记录
+func (this *NSDomainTransferConfig) ComposeNS(domainName string) []dns.RR {
+	var zone = dns.Fqdn(strings.ToLower(domainName))
+	var result = []dns.RR{}
+	for _, host := range this.NSHosts {
+		result = append(result, &dns.NS{
+			Hdr: dns.RR_Header{
+				Name:   zone,
+				Rrtype: dns.TypeNS,
+				Class:  dns.ClassINET,
+				Ttl:    this.TTL,
+			},
+			Ns: host,
+		})
+	}
+	return result
+}
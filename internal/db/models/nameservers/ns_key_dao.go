@@ -0,0 +1,109 @@
+package nameservers
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/dnssecutils"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"time"
+)
+
+const (
+	NSKeyStateEnabled  = 1 // 已启用
+	NSKeyStateDisabled = 0 // 已禁用
+)
+
+type NSKeyDAO dbs.DAO
+
+func NewNSKeyDAO() *NSKeyDAO {
+	return dbs.NewDAO(&NSKeyDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeNSKeys",
+			Model:  new(NSKey),
+			PkName: "id",
+		},
+	}).(*NSKeyDAO)
+}
+
+var SharedNSKeyDAO *NSKeyDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedNSKeyDAO = NewNSKeyDAO()
+	})
+}
+
+// DisableNSKey 禁用条目
+func (this *NSKeyDAO) DisableNSKey(tx *dbs.Tx, keyId int64) error {
+	_, err := this.Query(tx).
+		Pk(keyId).
+		Set("state", NSKeyStateDisabled).
+		Update()
+	return err
+}
+
+// DisableDomainKeys 禁用某个域名的所有密钥
+func (this *NSKeyDAO) DisableDomainKeys(tx *dbs.Tx, domainId int64) error {
+	return this.Query(tx).
+		Attr("domainId", domainId).
+		State(NSKeyStateEnabled).
+		Set("state", NSKeyStateDisabled).
+		UpdateQuickly()
+}
+
+// CreateKey 为域名生成并保存新的密钥
+func (this *NSKeyDAO) CreateKey(tx *dbs.Tx, domainId int64, domainName string, role dnssecutils.KeyRole, algorithm string, status dnssecutils.KeyStatus, ttl uint32) (int64, error) {
+	if domainId <= 0 {
+		return 0, errors.New("invalid domainId")
+	}
+	publicKey, privateKey, keyTag, err := dnssecutils.GenerateKey(domainName, role, algorithm, ttl)
+	if err != nil {
+		return 0, err
+	}
+
+	var now = time.Now().Unix()
+	op := NewNSKeyOperator()
+	op.DomainId = domainId
+	op.Role = role
+	op.Algorithm = algorithm
+	op.KeyTag = keyTag
+	op.PublicKey = publicKey
+	op.PrivateKey = privateKey
+	op.Status = status
+	op.CreatedAt = now
+	if status == dnssecutils.KeyStatusActive {
+		op.ActivatedAt = now
+	}
+	op.State = NSKeyStateEnabled
+	return this.SaveInt64(tx, op)
+}
+
+// UpdateKeyStatus 修改密钥状态
+func (this *NSKeyDAO) UpdateKeyStatus(tx *dbs.Tx, keyId int64, status dnssecutils.KeyStatus) error {
+	if keyId <= 0 {
+		return errors.New("invalid keyId")
+	}
+	op := NewNSKeyOperator()
+	op.Id = keyId
+	op.Status = status
+	switch status {
+	case dnssecutils.KeyStatusActive:
+		op.ActivatedAt = time.Now().Unix()
+	case dnssecutils.KeyStatusRetired:
+		op.RetiredAt = time.Now().Unix()
+	}
+	return this.Save(tx, op)
+}
+
+// FindAllEnabledDomainKeys 查找域名的所有密钥
+func (this *NSKeyDAO) FindAllEnabledDomainKeys(tx *dbs.Tx, domainId int64) (result []*NSKey, err error) {
+	_, err = this.Query(tx).
+		Attr("domainId", domainId).
+		State(NSKeyStateEnabled).
+		AscPk().
+		Slice(&result).
+		FindAll()
+	return
+}
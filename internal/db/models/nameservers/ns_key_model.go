@@ -0,0 +1,36 @@
+package nameservers
+
+// NSKey DNSSEC密钥
+type NSKey struct {
+	Id          uint64 `field:"id"`          // ID
+	DomainId    uint32 `field:"domainId"`    // 域名ID
+	Role        string `field:"role"`        // 角色：ksk, zsk
+	Algorithm   string `field:"algorithm"`   // 算法
+	KeyTag      uint32 `field:"keyTag"`      // 密钥标签
+	PublicKey   string `field:"publicKey"`   // DNSKEY记录
+	PrivateKey  string `field:"privateKey"`  // 私钥
+	Status      string `field:"status"`      // 状态：published, active, retired
+	CreatedAt   uint64 `field:"createdAt"`   // 创建时间
+	ActivatedAt uint64 `field:"activatedAt"` // 开始签名时间
+	RetiredAt   uint64 `field:"retiredAt"`   // 停止签名时间
+	State       uint8  `field:"state"`       // 状态
+}
+
+type NSKeyOperator struct {
+	Id          interface{} // ID
+	DomainId    interface{} // 域名ID
+	Role        interface{} // 角色：ksk, zsk
+	Algorithm   interface{} // 算法
+	KeyTag      interface{} // 密钥标签
+	PublicKey   interface{} // DNSKEY记录
+	PrivateKey  interface{} // 私钥
+	Status      interface{} // 状态：published, active, retired
+	CreatedAt   interface{} // 创建时间
+	ActivatedAt interface{} // 开始签名时间
+	RetiredAt   interface{} // 停止签名时间
+	State       interface{} // 状态
+}
+
+func NewNSKeyOperator() *NSKeyOperator {
+	return &NSKeyOperator{}
+}
@@ -0,0 +1,22 @@
+package nameservers
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/dnssecutils"
+)
+
+// DecodeKey 解析用于签名的密钥
+func (this *NSKey) DecodeKey() (*dnssecutils.Key, error) {
+	return dnssecutils.ParseKey(this.Role, this.Status, this.PublicKey, this.PrivateKey)
+}
+
+// DecodeKeyState 密钥状态信息
+func (this *NSKey) DecodeKeyState() *dnssecutils.KeyState {
+	return &dnssecutils.KeyState{
+		Id:          int64(this.Id),
+		Role:        this.Role,
+		Status:      this.Status,
+		CreatedAt:   int64(this.CreatedAt),
+		ActivatedAt: int64(this.ActivatedAt),
+		RetiredAt:   int64(this.RetiredAt),
+	}
+}
@@ -0,0 +1,143 @@
+package nameservers
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/maps"
+)
+
+// NSQueryNameOther 超出统计数量限制的记录名统一使用此名称
+const NSQueryNameOther = "(other)"
+
+// 每个域名每天最多单独统计的记录名和类型组合数
+const nsStatMaxQueriesPerDay = 10000
+
+type NSQueryDailyStatDAO dbs.DAO
+
+func NewNSQueryDailyStatDAO() *NSQueryDailyStatDAO {
+	return dbs.NewDAO(&NSQueryDailyStatDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeNSQueryDailyStats",
+			Model:  new(NSQueryDailyStat),
+			PkName: "id",
+		},
+	}).(*NSQueryDailyStatDAO)
+}
+
+var SharedNSQueryDailyStatDAO *NSQueryDailyStatDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedNSQueryDailyStatDAO = NewNSQueryDailyStatDAO()
+	})
+}
+
+// IncreaseStat 增加数量
+// 每个域名每天的记录名和类型组合数超出限制后，新的记录名合并到 NSQueryNameOther 中
+func (this *NSQueryDailyStatDAO) IncreaseStat(tx *dbs.Tx, domainId int64, day string, name string, queryType string, counter *NSQueryCounter) error {
+	if len(day) != 8 {
+		return errors.New("invalid day '" + day + "'")
+	}
+
+	// 先累加已有的统计
+	rows, err := this.Query(tx).
+		Attr("domainId", domainId).
+		Attr("day", day).
+		Attr("name", name).
+		Attr("type", queryType).
+		Param("countRequests", counter.CountRequests).
+		Param("countNXDOMAIN", counter.CountNXDOMAIN).
+		Param("countSERVFAIL", counter.CountSERVFAIL).
+		Set("countRequests", dbs.SQL("countRequests+:countRequests")).
+		Set("countNXDOMAIN", dbs.SQL("countNXDOMAIN+:countNXDOMAIN")).
+		Set("countSERVFAIL", dbs.SQL("countSERVFAIL+:countSERVFAIL")).
+		Update()
+	if err != nil {
+		return err
+	}
+	if rows > 0 {
+		return nil
+	}
+
+	if name != NSQueryNameOther {
+		count, err := this.Query(tx).
+			Attr("domainId", domainId).
+			Attr("day", day).
+			Count()
+		if err != nil {
+			return err
+		}
+		if count >= nsStatMaxQueriesPerDay {
+			name = NSQueryNameOther
+		}
+	}
+
+	return this.Query(tx).
+		Param("countRequests", counter.CountRequests).
+		Param("countNXDOMAIN", counter.CountNXDOMAIN).
+		Param("countSERVFAIL", counter.CountSERVFAIL).
+		InsertOrUpdateQuickly(maps.Map{
+			"domainId":      domainId,
+			"day":           day,
+			"name":          name,
+			"type":          queryType,
+			"countRequests": counter.CountRequests,
+			"countNXDOMAIN": counter.CountNXDOMAIN,
+			"countSERVFAIL": counter.CountSERVFAIL,
+		}, maps.Map{
+			"countRequests": dbs.SQL("countRequests+:countRequests"),
+			"countNXDOMAIN": dbs.SQL("countNXDOMAIN+:countNXDOMAIN"),
+			"countSERVFAIL": dbs.SQL("countSERVFAIL+:countSERVFAIL"),
+		})
+}
+
+// FindTopNames 查询数量最多的记录名和类型
+// orderField 可以是countRequests、countNXDOMAIN或countSERVFAIL
+func (this *NSQueryDailyStatDAO) FindTopNames(tx *dbs.Tx, userId int64, domainId int64, dayFrom string, dayTo string, orderField string, size int64) (result []*NSQueryDailyStat, err error) {
+	switch orderField {
+	case "countRequests", "countNXDOMAIN", "countSERVFAIL":
+	default:
+		orderField = "countRequests"
+	}
+
+	query := this.Query(tx).
+		Between("day", dayFrom, dayTo)
+	filterStatQuery(query, userId, domainId)
+	_, err = query.
+		Gt(orderField, 0).
+		Group("domainId").
+		Group("name").
+		Group("type").
+		Result("domainId, name, type, SUM(countRequests) AS countRequests, SUM(countNXDOMAIN) AS countNXDOMAIN, SUM(countSERVFAIL) AS countSERVFAIL").
+		Desc(orderField).
+		Limit(size).
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// FindTopTypes 查询数量最多的类型
+func (this *NSQueryDailyStatDAO) FindTopTypes(tx *dbs.Tx, userId int64, domainId int64, dayFrom string, dayTo string, size int64) (result []*NSQueryDailyStat, err error) {
+	query := this.Query(tx).
+		Between("day", dayFrom, dayTo)
+	filterStatQuery(query, userId, domainId)
+	_, err = query.
+		Group("type").
+		Result("type, SUM(countRequests) AS countRequests, SUM(countNXDOMAIN) AS countNXDOMAIN, SUM(countSERVFAIL) AS countSERVFAIL").
+		Desc("countRequests").
+		Limit(size).
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// DeleteStatsBeforeDay 删除某天之前的统计
+func (this *NSQueryDailyStatDAO) DeleteStatsBeforeDay(tx *dbs.Tx, day string) error {
+	_, err := this.Query(tx).
+		Lt("day", day).
+		Delete()
+	return err
+}
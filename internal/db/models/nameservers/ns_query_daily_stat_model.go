@@ -0,0 +1,28 @@
+package nameservers
+
+// NSQueryDailyStat 域名查询记录名和类型统计（按天）
+type NSQueryDailyStat struct {
+	Id            uint64 `field:"id"`            // ID
+	DomainId      uint32 `field:"domainId"`      // 域名ID
+	Day           string `field:"day"`           // YYYYMMDD
+	Name          string `field:"name"`          // 查询的域名
+	Type          string `field:"type"`          // 查询的类型
+	CountRequests uint64 `field:"countRequests"` // 查询数
+	CountNXDOMAIN uint64 `field:"countNXDOMAIN"` // NXDOMAIN数
+	CountSERVFAIL uint64 `field:"countSERVFAIL"` // SERVFAIL数
+}
+
+type NSQueryDailyStatOperator struct {
+	Id            interface{} // ID
+	DomainId      interface{} // 域名ID
+	Day           interface{} // YYYYMMDD
+	Name          interface{} // 查询的域名
+	Type          interface{} // 查询的类型
+	CountRequests interface{} // 查询数
+	CountNXDOMAIN interface{} // NXDOMAIN数
+	CountSERVFAIL interface{} // SERVFAIL数
+}
+
+func NewNSQueryDailyStatOperator() *NSQueryDailyStatOperator {
+	return &NSQueryDailyStatOperator{}
+}
@@ -0,0 +1,73 @@
+package nameservers
+
+import (
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"time"
+)
+
+type NSRecordChangeDAO dbs.DAO
+
+func NewNSRecordChangeDAO() *NSRecordChangeDAO {
+	return dbs.NewDAO(&NSRecordChangeDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeNSRecordChanges",
+			Model:  new(NSRecordChange),
+			PkName: "id",
+		},
+	}).(*NSRecordChangeDAO)
+}
+
+var SharedNSRecordChangeDAO *NSRecordChangeDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedNSRecordChangeDAO = NewNSRecordChangeDAO()
+	})
+}
+
+// CreateChange 记录变更
+// 只记录默认线路上生效的记录，用于增量区域传送（IXFR）
+func (this *NSRecordChangeDAO) CreateChange(tx *dbs.Tx, domainId int64, version int64, isDeleted bool, name string, recordType string, value string, ttl int32) error {
+	op := NewNSRecordChangeOperator()
+	op.DomainId = domainId
+	op.Version = version
+	op.IsDeleted = isDeleted
+	op.Name = name
+	op.Type = recordType
+	op.Value = value
+	op.Ttl = ttl
+	op.CreatedAt = time.Now().Unix()
+	return this.Save(tx, op)
+}
+
+// FindAllChangesAfterVersion 查找某个版本之后的变更
+func (this *NSRecordChangeDAO) FindAllChangesAfterVersion(tx *dbs.Tx, domainId int64, version int64) (result []*NSRecordChange, err error) {
+	_, err = this.Query(tx).
+		Attr("domainId", domainId).
+		Gt("version", version).
+		Asc("version").
+		AscPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// FindDomainMinVersion 查找域名保留的最早的变更版本
+func (this *NSRecordChangeDAO) FindDomainMinVersion(tx *dbs.Tx, domainId int64) (int64, error) {
+	return this.Query(tx).
+		Attr("domainId", domainId).
+		Result("version").
+		Asc("version").
+		FindInt64Col(0)
+}
+
+// DeleteChangesBefore 删除某个时间之前的变更
+func (this *NSRecordChangeDAO) DeleteChangesBefore(tx *dbs.Tx, timestamp int64) error {
+	_, err := this.Query(tx).
+		Lt("createdAt", timestamp).
+		Delete()
+	return err
+}
@@ -0,0 +1,30 @@
+package nameservers
+
+// NSRecordChange DNS记录变更日志
+type NSRecordChange struct {
+	Id        uint64 `field:"id"`        // ID
+	DomainId  uint32 `field:"domainId"`  // 域名ID
+	Version   uint64 `field:"version"`   // 记录版本
+	IsDeleted uint8  `field:"isDeleted"` // 是否为删除操作
+	Name      string `field:"name"`      // 记录名
+	Type      string `field:"type"`      // 类型
+	Value     string `field:"value"`     // 值
+	Ttl       uint32 `field:"ttl"`       // TTL（秒）
+	CreatedAt uint64 `field:"createdAt"` // 创建时间
+}
+
+type NSRecordChangeOperator struct {
+	Id        interface{} // ID
+	DomainId  interface{} // 域名ID
+	Version   interface{} // 记录版本
+	IsDeleted interface{} // 是否为删除操作
+	Name      interface{} // 记录名
+	Type      interface{} // 类型
+	Value     interface{} // 值
+	Ttl       interface{} // TTL（秒）
+	CreatedAt interface{} // 创建时间
+}
+
+func NewNSRecordChangeOperator() *NSRecordChangeOperator {
+	return &NSRecordChangeOperator{}
+}
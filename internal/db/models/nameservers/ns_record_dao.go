@@ -4,11 +4,14 @@ import (
 	"encoding/json"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
 	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/dnssecutils"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/zoneutils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/dnsconfigs"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/iwind/TeaGo/Tea"
 	"github.com/iwind/TeaGo/dbs"
 	"strconv"
+	"time"
 )
 
 const (
@@ -48,6 +51,11 @@ func (this *NSRecordDAO) EnableNSRecord(tx *dbs.Tx, id uint64) error {
 
 // DisableNSRecord 禁用条目
 func (this *NSRecordDAO) DisableNSRecord(tx *dbs.Tx, id int64) error {
+	oldRecord, err := this.FindEnabledNSRecord(tx, id)
+	if err != nil {
+		return err
+	}
+
 	version, err := this.IncreaseVersion(tx)
 	if err != nil {
 		return err
@@ -58,7 +66,19 @@ func (this *NSRecordDAO) DisableNSRecord(tx *dbs.Tx, id int64) error {
 		Set("state", NSRecordStateDisabled).
 		Set("version", version).
 		Update()
-	return err
+	if err != nil {
+		return err
+	}
+
+	err = models.SharedSyncTombstoneDAO.CreateTombstone(tx, models.SyncTombstoneKindNSRecord, id, version)
+	if err != nil {
+		return err
+	}
+
+	if oldRecord != nil {
+		return this.createChange(tx, oldRecord, version, true)
+	}
+	return nil
 }
 
 // FindEnabledNSRecord 查找启用中的条目
@@ -83,6 +103,11 @@ func (this *NSRecordDAO) FindNSRecordName(tx *dbs.Tx, id int64) (string, error)
 
 // CreateRecord 创建记录
 func (this *NSRecordDAO) CreateRecord(tx *dbs.Tx, domainId int64, description string, name string, dnsType dnsconfigs.RecordType, value string, ttl int32, routeIds []int64) (int64, error) {
+	err := this.CheckRecord(tx, domainId, 0, name, dnsType, value, ttl, routeIds)
+	if err != nil {
+		return 0, err
+	}
+
 	version, err := this.IncreaseVersion(tx)
 	if err != nil {
 		return 0, err
@@ -96,20 +121,36 @@ func (this *NSRecordDAO) CreateRecord(tx *dbs.Tx, domainId int64, description st
 	op.Value = value
 	op.Ttl = ttl
 
-	if len(routeIds) == 0 {
-		op.RouteIds = "[]"
-	} else {
-		routeIds, err := json.Marshal(routeIds)
+	var routeIdsJSON = []byte("[]")
+	if len(routeIds) > 0 {
+		routeIdsJSON, err = json.Marshal(routeIds)
 		if err != nil {
 			return 0, err
 		}
-		op.RouteIds = routeIds
 	}
+	op.RouteIds = routeIdsJSON
 
 	op.IsOn = true
 	op.State = NSRecordStateEnabled
 	op.Version = version
-	return this.SaveInt64(tx, op)
+	recordId, err := this.SaveInt64(tx, op)
+	if err != nil {
+		return 0, err
+	}
+
+	err = this.createChange(tx, &NSRecord{
+		DomainId: uint32(domainId),
+		IsOn:     1,
+		Name:     name,
+		Type:     dnsType,
+		Value:    value,
+		Ttl:      uint32(ttl),
+		RouteIds: string(routeIdsJSON),
+	}, version, false)
+	if err != nil {
+		return 0, err
+	}
+	return recordId, nil
 }
 
 func (this *NSRecordDAO) UpdateRecord(tx *dbs.Tx, recordId int64, description string, name string, dnsType dnsconfigs.RecordType, value string, ttl int32, routeIds []int64) error {
@@ -117,6 +158,19 @@ func (this *NSRecordDAO) UpdateRecord(tx *dbs.Tx, recordId int64, description st
 		return errors.New("invalid recordId")
 	}
 
+	oldRecord, err := this.FindEnabledNSRecord(tx, recordId)
+	if err != nil {
+		return err
+	}
+	if oldRecord == nil {
+		return errors.New("can not find record '" + strconv.FormatInt(recordId, 10) + "'")
+	}
+
+	err = this.CheckRecord(tx, int64(oldRecord.DomainId), recordId, name, dnsType, value, ttl, routeIds)
+	if err != nil {
+		return err
+	}
+
 	version, err := this.IncreaseVersion(tx)
 	if err != nil {
 		return err
@@ -130,24 +184,80 @@ func (this *NSRecordDAO) UpdateRecord(tx *dbs.Tx, recordId int64, description st
 	op.Value = value
 	op.Ttl = ttl
 
-	if len(routeIds) == 0 {
-		op.RouteIds = "[]"
-	} else {
-		routeIds, err := json.Marshal(routeIds)
+	var routeIdsJSON = []byte("[]")
+	if len(routeIds) > 0 {
+		routeIdsJSON, err = json.Marshal(routeIds)
 		if err != nil {
 			return err
 		}
-		op.RouteIds = routeIds
 	}
+	op.RouteIds = routeIdsJSON
 
 	op.Version = version
 
-	return this.Save(tx, op)
+	err = this.Save(tx, op)
+	if err != nil {
+		return err
+	}
+
+	err = this.createChange(tx, oldRecord, version, true)
+	if err != nil {
+		return err
+	}
+	var newRecord = *oldRecord
+	newRecord.Name = name
+	newRecord.Type = dnsType
+	newRecord.Value = value
+	newRecord.Ttl = uint32(ttl)
+	newRecord.RouteIds = string(routeIdsJSON)
+	return this.createChange(tx, &newRecord, version, false)
+}
+
+// CheckRecord 校验记录，包括记录名、记录值、TTL，以及和同一个域名中其他记录的冲突
+// recordId 为修改的记录ID，创建时为0
+func (this *NSRecordDAO) CheckRecord(tx *dbs.Tx, domainId int64, recordId int64, name string, dnsType dnsconfigs.RecordType, value string, ttl int32, routeIds []int64) error {
+	domainName, err := SharedNSDomainDAO.FindNSDomainName(tx, domainId)
+	if err != nil {
+		return err
+	}
+	if len(domainName) == 0 {
+		return errors.New("can not find domain '" + strconv.FormatInt(domainId, 10) + "'")
+	}
+
+	var record = &zoneutils.Record{
+		Name:     name,
+		Type:     dnsType,
+		Value:    value,
+		TTL:      ttl,
+		RouteIds: routeIds,
+	}
+	validationErr := zoneutils.ValidateRecord(record, &zoneutils.ValidateOptions{Origin: domainName})
+	if validationErr != nil {
+		return validationErr
+	}
+
+	oldRecords, err := this.FindAllEnabledDomainRecords(tx, domainId)
+	if err != nil {
+		return err
+	}
+	var others = []*zoneutils.Record{}
+	for _, oldRecord := range oldRecords {
+		if int64(oldRecord.Id) == recordId {
+			continue
+		}
+		others = append(others, oldRecord.ToZoneRecord())
+	}
+	validationErr = zoneutils.ValidateRecordConflicts(record, others)
+	if validationErr != nil {
+		return validationErr
+	}
+	return nil
 }
 
 func (this *NSRecordDAO) CountAllEnabledRecords(tx *dbs.Tx, domainId int64, dnsType dnsconfigs.RecordType, keyword string, routeId int64) (int64, error) {
 	query := this.Query(tx).
 		Attr("domainId", domainId).
+		Attr("isGenerated", 0).
 		State(NSRecordStateEnabled)
 	if len(dnsType) > 0 {
 		query.Attr("type", dnsType)
@@ -165,6 +275,7 @@ func (this *NSRecordDAO) CountAllEnabledRecords(tx *dbs.Tx, domainId int64, dnsT
 func (this *NSRecordDAO) ListEnabledRecords(tx *dbs.Tx, domainId int64, dnsType dnsconfigs.RecordType, keyword string, routeId int64, offset int64, size int64) (result []*NSRecord, err error) {
 	query := this.Query(tx).
 		Attr("domainId", domainId).
+		Attr("isGenerated", 0).
 		State(NSRecordStateEnabled)
 	if len(dnsType) > 0 {
 		query.Attr("type", dnsType)
@@ -210,6 +321,7 @@ func (this *NSRecordDAO) FindEnabledRecordWithName(tx *dbs.Tx, domainId int64, r
 	record, err := this.Query(tx).
 		State(NSRecordStateEnabled).
 		Attr("domainId", domainId).
+		Attr("isGenerated", 0).
 		Attr("name", recordName).
 		Attr("type", recordType).
 		Find()
@@ -218,3 +330,162 @@ func (this *NSRecordDAO) FindEnabledRecordWithName(tx *dbs.Tx, domainId int64, r
 	}
 	return record.(*NSRecord), nil
 }
+
+// CheckUserRecord 检查记录是否属于某个用户
+func (this *NSRecordDAO) CheckUserRecord(tx *dbs.Tx, userId int64, recordId int64) error {
+	if userId <= 0 || recordId <= 0 {
+		return models.ErrNotFound
+	}
+	domainId, err := this.Query(tx).
+		Pk(recordId).
+		State(NSRecordStateEnabled).
+		Result("domainId").
+		FindInt64Col(0)
+	if err != nil {
+		return err
+	}
+	return SharedNSDomainDAO.CheckUserDomain(tx, userId, domainId)
+}
+
+// FindAllEnabledDomainRecords 查找域名中所有非自动生成的记录
+func (this *NSRecordDAO) FindAllEnabledDomainRecords(tx *dbs.Tx, domainId int64) (result []*NSRecord, err error) {
+	_, err = this.Query(tx).
+		Attr("domainId", domainId).
+		Attr("isGenerated", 0).
+		State(NSRecordStateEnabled).
+		AscPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// FindAllEnabledRecordsToSign 查找域名中需要签名的记录
+func (this *NSRecordDAO) FindAllEnabledRecordsToSign(tx *dbs.Tx, domainId int64) (result []*NSRecord, err error) {
+	_, err = this.Query(tx).
+		Attr("domainId", domainId).
+		Attr("isGenerated", 0).
+		Attr("isOn", true).
+		State(NSRecordStateEnabled).
+		AscPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// FindAllEnabledGeneratedRecords 查找域名中自动生成的记录
+func (this *NSRecordDAO) FindAllEnabledGeneratedRecords(tx *dbs.Tx, domainId int64) (result []*NSRecord, err error) {
+	_, err = this.Query(tx).
+		Attr("domainId", domainId).
+		Attr("isGenerated", 1).
+		State(NSRecordStateEnabled).
+		AscPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// FindDomainLatestVersion 查找域名中非自动生成记录的最新版本，包括已删除的记录
+func (this *NSRecordDAO) FindDomainLatestVersion(tx *dbs.Tx, domainId int64) (int64, error) {
+	return this.Query(tx).
+		Attr("domainId", domainId).
+		Attr("isGenerated", 0).
+		Result("version").
+		Desc("version").
+		FindInt64Col(0)
+}
+
+// SyncGeneratedRecords 同步自动生成的记录
+// 只创建新增的记录和删除不再需要的记录，没有变化的记录保持原有版本，以免节点重复更新
+func (this *NSRecordDAO) SyncGeneratedRecords(tx *dbs.Tx, domainId int64, records []*dnssecutils.Record) (changed bool, err error) {
+	oldRecords, err := this.FindAllEnabledGeneratedRecords(tx, domainId)
+	if err != nil {
+		return false, err
+	}
+
+	var recordKey = func(name string, recordType string, value string, ttl int64, routeIds []int64) string {
+		routeIdsJSON, _ := json.Marshal(routeIds)
+		return name + "|" + recordType + "|" + value + "|" + strconv.FormatInt(ttl, 10) + "|" + string(routeIdsJSON)
+	}
+
+	var oldRecordMap = map[string]*NSRecord{}
+	for _, oldRecord := range oldRecords {
+		oldRecordMap[recordKey(oldRecord.Name, oldRecord.Type, oldRecord.Value, int64(oldRecord.Ttl), oldRecord.DecodeRouteIds())] = oldRecord
+	}
+
+	var keptIds = map[uint64]bool{}
+	for _, record := range records {
+		var routeIds = record.RouteIds
+		if routeIds == nil {
+			routeIds = []int64{}
+		}
+		key := recordKey(record.Name, record.Type, record.Value, int64(record.TTL), routeIds)
+		oldRecord, ok := oldRecordMap[key]
+		if ok && !keptIds[oldRecord.Id] {
+			keptIds[oldRecord.Id] = true
+			continue
+		}
+
+		version, err := this.IncreaseVersion(tx)
+		if err != nil {
+			return false, err
+		}
+		routeIdsJSON, err := json.Marshal(routeIds)
+		if err != nil {
+			return false, err
+		}
+
+		op := NewNSRecordOperator()
+		op.DomainId = domainId
+		op.Name = record.Name
+		op.Type = record.Type
+		op.Value = record.Value
+		op.Ttl = record.TTL
+		op.RouteIds = routeIdsJSON
+		op.IsGenerated = true
+		op.IsOn = true
+		op.CreatedAt = time.Now().Unix()
+		op.State = NSRecordStateEnabled
+		op.Version = version
+		err = this.Save(tx, op)
+		if err != nil {
+			return false, err
+		}
+		changed = true
+	}
+
+	for _, oldRecord := range oldRecords {
+		if keptIds[oldRecord.Id] {
+			continue
+		}
+		err = this.DisableNSRecord(tx, int64(oldRecord.Id))
+		if err != nil {
+			return false, err
+		}
+		changed = true
+	}
+
+	return
+}
+
+// DisableGeneratedRecords 删除域名中所有自动生成的记录
+func (this *NSRecordDAO) DisableGeneratedRecords(tx *dbs.Tx, domainId int64) error {
+	records, err := this.FindAllEnabledGeneratedRecords(tx, domainId)
+	if err != nil {
+		return err
+	}
+	for _, record := range records {
+		err = this.DisableNSRecord(tx, int64(record.Id))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 记录变更日志，只记录默认线路上生效的非自动生成记录
+func (this *NSRecordDAO) createChange(tx *dbs.Tx, record *NSRecord, version int64, isDeleted bool) error {
+	if record.IsGenerated == 1 || record.IsOn == 0 || len(record.DecodeRouteIds()) > 0 {
+		return nil
+	}
+	return SharedNSRecordChangeDAO.CreateChange(tx, int64(record.DomainId), version, isDeleted, record.Name, record.Type, record.Value, int32(record.Ttl))
+}
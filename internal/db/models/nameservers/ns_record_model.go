@@ -12,6 +12,7 @@ type NSRecord struct {
 	Ttl         uint32 `field:"ttl"`         // TTL（秒）
 	Weight      uint32 `field:"weight"`      // 权重
 	RouteIds    string `field:"routeIds"`    // 线路
+	IsGenerated uint8  `field:"isGenerated"` // 是否为自动生成的记录
 	CreatedAt   uint64 `field:"createdAt"`   // 创建时间
 	Version     uint64 `field:"version"`     //
 	State       uint8  `field:"state"`       // 状态
@@ -28,6 +29,7 @@ type NSRecordOperator struct {
 	Ttl         interface{} // TTL（秒）
 	Weight      interface{} // 权重
 	RouteIds    interface{} // 线路
+	IsGenerated interface{} // 是否为自动生成的记录
 	CreatedAt   interface{} // 创建时间
 	Version     interface{} //
 	State       interface{} // 状态
@@ -1,6 +1,9 @@
 package nameservers
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/zoneutils"
+)
 
 func (this *NSRecord) DecodeRouteIds() []int64 {
 	routeIds := []int64{}
@@ -9,3 +12,14 @@ func (this *NSRecord) DecodeRouteIds() []int64 {
 	}
 	return routeIds
 }
+
+// ToZoneRecord 转换为区域文件中的记录
+func (this *NSRecord) ToZoneRecord() *zoneutils.Record {
+	return &zoneutils.Record{
+		Name:     this.Name,
+		Type:     this.Type,
+		Value:    this.Value,
+		TTL:      int32(this.Ttl),
+		RouteIds: this.DecodeRouteIds(),
+	}
+}
@@ -0,0 +1,72 @@
+package nameservers
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/maps"
+)
+
+type NSRegionDailyStatDAO dbs.DAO
+
+func NewNSRegionDailyStatDAO() *NSRegionDailyStatDAO {
+	return dbs.NewDAO(&NSRegionDailyStatDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeNSRegionDailyStats",
+			Model:  new(NSRegionDailyStat),
+			PkName: "id",
+		},
+	}).(*NSRegionDailyStatDAO)
+}
+
+var SharedNSRegionDailyStatDAO *NSRegionDailyStatDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedNSRegionDailyStatDAO = NewNSRegionDailyStatDAO()
+	})
+}
+
+// IncreaseStat 增加数量
+func (this *NSRegionDailyStatDAO) IncreaseStat(tx *dbs.Tx, domainId int64, day string, countryId int64, provinceId int64, count int64) error {
+	if len(day) != 8 {
+		return errors.New("invalid day '" + day + "'")
+	}
+	return this.Query(tx).
+		Param("countRequests", count).
+		InsertOrUpdateQuickly(maps.Map{
+			"domainId":      domainId,
+			"day":           day,
+			"countryId":     countryId,
+			"provinceId":    provinceId,
+			"countRequests": count,
+		}, maps.Map{
+			"countRequests": dbs.SQL("countRequests+:countRequests"),
+		})
+}
+
+// FindRegionStats 查询每个区域的查询数
+func (this *NSRegionDailyStatDAO) FindRegionStats(tx *dbs.Tx, userId int64, domainId int64, dayFrom string, dayTo string, size int64) (result []*NSRegionDailyStat, err error) {
+	query := this.Query(tx).
+		Between("day", dayFrom, dayTo)
+	filterStatQuery(query, userId, domainId)
+	_, err = query.
+		Group("countryId").
+		Group("provinceId").
+		Result("countryId, provinceId, SUM(countRequests) AS countRequests").
+		Desc("countRequests").
+		Limit(size).
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// DeleteStatsBeforeDay 删除某天之前的统计
+func (this *NSRegionDailyStatDAO) DeleteStatsBeforeDay(tx *dbs.Tx, day string) error {
+	_, err := this.Query(tx).
+		Lt("day", day).
+		Delete()
+	return err
+}
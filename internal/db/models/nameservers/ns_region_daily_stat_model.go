@@ -0,0 +1,24 @@
+package nameservers
+
+// NSRegionDailyStat 域名查询区域统计（按天）
+type NSRegionDailyStat struct {
+	Id            uint64 `field:"id"`            // ID
+	DomainId      uint32 `field:"domainId"`      // 域名ID
+	Day           string `field:"day"`           // YYYYMMDD
+	CountryId     uint32 `field:"countryId"`     // 国家/区域ID
+	ProvinceId    uint32 `field:"provinceId"`    // 省份ID
+	CountRequests uint64 `field:"countRequests"` // 查询数
+}
+
+type NSRegionDailyStatOperator struct {
+	Id            interface{} // ID
+	DomainId      interface{} // 域名ID
+	Day           interface{} // YYYYMMDD
+	CountryId     interface{} // 国家/区域ID
+	ProvinceId    interface{} // 省份ID
+	CountRequests interface{} // 查询数
+}
+
+func NewNSRegionDailyStatOperator() *NSRegionDailyStatOperator {
+	return &NSRegionDailyStatOperator{}
+}
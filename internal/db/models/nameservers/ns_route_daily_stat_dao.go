@@ -0,0 +1,69 @@
+package nameservers
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/maps"
+)
+
+type NSRouteDailyStatDAO dbs.DAO
+
+func NewNSRouteDailyStatDAO() *NSRouteDailyStatDAO {
+	return dbs.NewDAO(&NSRouteDailyStatDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeNSRouteDailyStats",
+			Model:  new(NSRouteDailyStat),
+			PkName: "id",
+		},
+	}).(*NSRouteDailyStatDAO)
+}
+
+var SharedNSRouteDailyStatDAO *NSRouteDailyStatDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedNSRouteDailyStatDAO = NewNSRouteDailyStatDAO()
+	})
+}
+
+// IncreaseStat 增加数量
+func (this *NSRouteDailyStatDAO) IncreaseStat(tx *dbs.Tx, domainId int64, day string, routeId int64, count int64) error {
+	if len(day) != 8 {
+		return errors.New("invalid day '" + day + "'")
+	}
+	return this.Query(tx).
+		Param("countRequests", count).
+		InsertOrUpdateQuickly(maps.Map{
+			"domainId":      domainId,
+			"day":           day,
+			"routeId":       routeId,
+			"countRequests": count,
+		}, maps.Map{
+			"countRequests": dbs.SQL("countRequests+:countRequests"),
+		})
+}
+
+// FindRouteStats 查询每个线路的查询数
+func (this *NSRouteDailyStatDAO) FindRouteStats(tx *dbs.Tx, userId int64, domainId int64, dayFrom string, dayTo string) (result []*NSRouteDailyStat, err error) {
+	query := this.Query(tx).
+		Between("day", dayFrom, dayTo)
+	filterStatQuery(query, userId, domainId)
+	_, err = query.
+		Group("routeId").
+		Result("routeId, SUM(countRequests) AS countRequests").
+		Desc("countRequests").
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// DeleteStatsBeforeDay 删除某天之前的统计
+func (this *NSRouteDailyStatDAO) DeleteStatsBeforeDay(tx *dbs.Tx, day string) error {
+	_, err := this.Query(tx).
+		Lt("day", day).
+		Delete()
+	return err
+}
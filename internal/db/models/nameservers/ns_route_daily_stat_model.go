@@ -0,0 +1,22 @@
+package nameservers
+
+// NSRouteDailyStat 域名查询线路统计（按天）
+type NSRouteDailyStat struct {
+	Id            uint64 `field:"id"`            // ID
+	DomainId      uint32 `field:"domainId"`      // 域名ID
+	Day           string `field:"day"`           // YYYYMMDD
+	RouteId       uint32 `field:"routeId"`       // 线路ID，0表示默认线路
+	CountRequests uint64 `field:"countRequests"` // 查询数
+}
+
+type NSRouteDailyStatOperator struct {
+	Id            interface{} // ID
+	DomainId      interface{} // 域名ID
+	Day           interface{} // YYYYMMDD
+	RouteId       interface{} // 线路ID，0表示默认线路
+	CountRequests interface{} // 查询数
+}
+
+func NewNSRouteDailyStatOperator() *NSRouteDailyStatOperator {
+	return &NSRouteDailyStatOperator{}
+}
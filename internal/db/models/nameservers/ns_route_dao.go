@@ -55,7 +55,10 @@ func (this *NSRouteDAO) DisableNSRoute(tx *dbs.Tx, routeId int64) error {
 		Set("state", NSRouteStateDisabled).
 		Set("version", version).
 		Update()
-	return err
+	if err != nil {
+		return err
+	}
+	return models.SharedSyncTombstoneDAO.CreateTombstone(tx, models.SyncTombstoneKindNSRoute, routeId, version)
 }
 
 // FindEnabledNSRoute 查找启用中的条目
@@ -0,0 +1,143 @@
+package models
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/sslutils"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/types"
+	"time"
+)
+
+const (
+	NodeCAStateEnabled  = 1 // 已启用
+	NodeCAStateDisabled = 0 // 已禁用
+)
+
+const (
+	nodeCALife        = 10 * 365 * 24 * time.Hour // CA有效期
+	nodeCARenewBefore = 365 * 24 * time.Hour      // 剩余有效期小于此值时生成新的CA，旧的CA在过期前仍然可以用来校验证书
+)
+
+type NodeCADAO dbs.DAO
+
+func NewNodeCADAO() *NodeCADAO {
+	return dbs.NewDAO(&NodeCADAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeNodeCAs",
+			Model:  new(NodeCA),
+			PkName: "id",
+		},
+	}).(*NodeCADAO)
+}
+
+var SharedNodeCADAO *NodeCADAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedNodeCADAO = NewNodeCADAO()
+	})
+}
+
+// FindActiveNodeCA 查找用来签发证书的CA，如果没有则自动生成
+func (this *NodeCADAO) FindActiveNodeCA(tx *dbs.Tx) (*NodeCA, error) {
+	ca, err := this.findActiveNodeCA(tx)
+	if err != nil || ca != nil {
+		return ca, err
+	}
+
+	// 多个API节点可能同时需要生成CA，加锁以免生成多个，没有获得锁的等待其他节点生成
+	const lockerKey = "node_ca_creating"
+	for i := 0; i < 30; i++ {
+		ok, err := SharedSysLockerDAO.Lock(nil, lockerKey, 30)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return this.createActiveNodeCA(tx, lockerKey)
+		}
+
+		time.Sleep(1 * time.Second)
+		ca, err = this.findActiveNodeCA(tx)
+		if err != nil || ca != nil {
+			return ca, err
+		}
+	}
+	return nil, errors.New("wait for node CA timeout")
+}
+
+// 获得锁之后生成CA
+func (this *NodeCADAO) createActiveNodeCA(tx *dbs.Tx, lockerKey string) (*NodeCA, error) {
+	defer func() {
+		_ = SharedSysLockerDAO.Unlock(nil, lockerKey)
+	}()
+
+	// 再次检查，其他节点可能已经生成
+	ca, err := this.findActiveNodeCA(tx)
+	if err != nil || ca != nil {
+		return ca, err
+	}
+
+	caId, err := this.CreateNodeCA(tx)
+	if err != nil {
+		return nil, err
+	}
+	one, err := this.Query(tx).
+		Pk(caId).
+		Find()
+	if err != nil || one == nil {
+		return nil, err
+	}
+	return one.(*NodeCA), nil
+}
+
+// 查找可用的CA
+func (this *NodeCADAO) findActiveNodeCA(tx *dbs.Tx) (*NodeCA, error) {
+	one, err := this.Query(tx).
+		State(NodeCAStateEnabled).
+		Gt("expiresAt", time.Now().Add(nodeCARenewBefore).Unix()).
+		DescPk().
+		Find()
+	if err != nil || one == nil {
+		return nil, err
+	}
+	return one.(*NodeCA), nil
+}
+
+// CreateNodeCA 生成新的CA
+func (this *NodeCADAO) CreateNodeCA(tx *dbs.Tx) (int64, error) {
+	certData, keyData, err := sslutils.GenerateNodeCA("GoEdge Node CA "+time.Now().Format("20060102"), nodeCALife)
+	if err != nil {
+		return 0, err
+	}
+
+	op := NewNodeCAOperator()
+	op.CertData = certData
+	op.KeyData = keyData
+	op.ExpiresAt = time.Now().Add(nodeCALife).Unix()
+	op.State = NodeCAStateEnabled
+	err = this.Save(tx, op)
+	if err != nil {
+		return 0, err
+	}
+	return types.Int64(op.Id), nil
+}
+
+// FindAllValidNodeCACertData 查找所有未过期的CA证书，用来校验节点的客户端证书
+func (this *NodeCADAO) FindAllValidNodeCACertData(tx *dbs.Tx) (result [][]byte, err error) {
+	ones, err := this.Query(tx).
+		State(NodeCAStateEnabled).
+		Gt("expiresAt", time.Now().Unix()).
+		Result("certData").
+		AscPk().
+		FindAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, one := range ones {
+		result = append(result, []byte(one.(*NodeCA).CertData))
+	}
+	return
+}
@@ -0,0 +1,24 @@
+package models
+
+// NodeCA 节点内置CA
+type NodeCA struct {
+	Id        uint32 `field:"id"`        // ID
+	CertData  string `field:"certData"`  // 证书内容
+	KeyData   string `field:"keyData"`   // 私钥内容
+	ExpiresAt uint64 `field:"expiresAt"` // 过期时间
+	State     uint8  `field:"state"`     // 状态
+	CreatedAt uint64 `field:"createdAt"` // 创建时间
+}
+
+type NodeCAOperator struct {
+	Id        interface{} // ID
+	CertData  interface{} // 证书内容
+	KeyData   interface{} // 私钥内容
+	ExpiresAt interface{} // 过期时间
+	State     interface{} // 状态
+	CreatedAt interface{} // 创建时间
+}
+
+func NewNodeCAOperator() *NodeCAOperator {
+	return &NodeCAOperator{}
+}
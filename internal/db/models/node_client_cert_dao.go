@@ -0,0 +1,185 @@
+package models
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/sslutils"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"sync"
+	"time"
+)
+
+const (
+	NodeClientCertStateEnabled  = 1 // 已启用
+	NodeClientCertStateDisabled = 0 // 已禁用
+)
+
+const (
+	NodeClientCertLife         = 30 * 24 * time.Hour // 客户端证书有效期
+	NodeClientCertRotateBefore = 10 * 24 * time.Hour // 剩余有效期小于此值时签发新证书
+)
+
+// 吊销的证书序列号缓存时间
+const nodeClientCertRevokedCacheSeconds = 60
+
+type NodeClientCertDAO dbs.DAO
+
+func NewNodeClientCertDAO() *NodeClientCertDAO {
+	return dbs.NewDAO(&NodeClientCertDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeNodeClientCerts",
+			Model:  new(NodeClientCert),
+			PkName: "id",
+		},
+	}).(*NodeClientCertDAO)
+}
+
+var SharedNodeClientCertDAO *NodeClientCertDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedNodeClientCertDAO = NewNodeClientCertDAO()
+	})
+}
+
+var nodeClientCertRevokedMap = map[string]bool{} // serial => true
+var nodeClientCertRevokedUpdatedAt int64
+var nodeClientCertRevokedLocker = &sync.RWMutex{}
+
+// IssueNodeClientCert 为节点签发新的客户端证书
+// 节点以前的证书会被标记为已替换，但在过期之前仍然可以使用，以便节点平滑切换
+func (this *NodeClientCertDAO) IssueNodeClientCert(tx *dbs.Tx, role string, nodeId int64, uniqueId string) (*sslutils.NodeClientCert, error) {
+	if len(role) == 0 || nodeId <= 0 || len(uniqueId) == 0 {
+		return nil, errors.New("invalid node")
+	}
+
+	ca, err := SharedNodeCADAO.FindActiveNodeCA(tx)
+	if err != nil {
+		return nil, err
+	}
+	if ca == nil {
+		return nil, errors.New("can not find node ca")
+	}
+
+	clientCert, err := sslutils.IssueNodeClientCert([]byte(ca.CertData), []byte(ca.KeyData), role, uniqueId, NodeClientCertLife)
+	if err != nil {
+		return nil, err
+	}
+
+	op := NewNodeClientCertOperator()
+	op.CaId = ca.Id
+	op.Role = role
+	op.NodeId = nodeId
+	op.UniqueId = uniqueId
+	op.Serial = clientCert.Serial
+	op.CertData = clientCert.CertData
+	op.ExpiresAt = clientCert.ExpiresAt
+	op.State = NodeClientCertStateEnabled
+	err = this.Save(tx, op)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = this.Query(tx).
+		Attr("role", role).
+		Attr("nodeId", nodeId).
+		Neq("id", op.Id).
+		Attr("rotatedAt", 0).
+		Set("rotatedAt", time.Now().Unix()).
+		Update()
+	if err != nil {
+		return nil, err
+	}
+
+	return clientCert, nil
+}
+
+// FindLatestNodeClientCert 查找节点当前使用的证书
+func (this *NodeClientCertDAO) FindLatestNodeClientCert(tx *dbs.Tx, role string, nodeId int64) (*NodeClientCert, error) {
+	one, err := this.Query(tx).
+		Attr("role", role).
+		Attr("nodeId", nodeId).
+		State(NodeClientCertStateEnabled).
+		Attr("rotatedAt", 0).
+		Attr("revokedAt", 0).
+		Gt("expiresAt", time.Now().Unix()).
+		DescPk().
+		Find()
+	if err != nil || one == nil {
+		return nil, err
+	}
+	return one.(*NodeClientCert), nil
+}
+
+// CheckNodeClientCertRotating 检查节点是否需要签发新证书
+func (this *NodeClientCertDAO) CheckNodeClientCertRotating(tx *dbs.Tx, role string, nodeId int64) (bool, error) {
+	cert, err := this.FindLatestNodeClientCert(tx, role, nodeId)
+	if err != nil {
+		return false, err
+	}
+	if cert == nil {
+		return true, nil
+	}
+	return int64(cert.ExpiresAt) < time.Now().Add(NodeClientCertRotateBefore).Unix(), nil
+}
+
+// RevokeNodeClientCerts 吊销节点所有的证书
+func (this *NodeClientCertDAO) RevokeNodeClientCerts(tx *dbs.Tx, role string, nodeId int64) error {
+	_, err := this.Query(tx).
+		Attr("role", role).
+		Attr("nodeId", nodeId).
+		Attr("revokedAt", 0).
+		Set("revokedAt", time.Now().Unix()).
+		Update()
+	if err != nil {
+		return err
+	}
+
+	// 让缓存立即失效
+	nodeClientCertRevokedLocker.Lock()
+	nodeClientCertRevokedUpdatedAt = 0
+	nodeClientCertRevokedLocker.Unlock()
+	return nil
+}
+
+// CheckNodeClientCertRevokedCacheable 检查证书是否已经被吊销
+// 吊销列表会缓存一段时间，其他API节点上吊销的证书最多延迟 nodeClientCertRevokedCacheSeconds 秒生效
+func (this *NodeClientCertDAO) CheckNodeClientCertRevokedCacheable(tx *dbs.Tx, serial string) (bool, error) {
+	nodeClientCertRevokedLocker.RLock()
+	if time.Now().Unix()-nodeClientCertRevokedUpdatedAt < nodeClientCertRevokedCacheSeconds {
+		var isRevoked = nodeClientCertRevokedMap[serial]
+		nodeClientCertRevokedLocker.RUnlock()
+		return isRevoked, nil
+	}
+	nodeClientCertRevokedLocker.RUnlock()
+
+	ones, err := this.Query(tx).
+		Gt("revokedAt", 0).
+		Gt("expiresAt", time.Now().Unix()).
+		Result("serial").
+		FindAll()
+	if err != nil {
+		return false, err
+	}
+	var revokedMap = map[string]bool{}
+	for _, one := range ones {
+		revokedMap[one.(*NodeClientCert).Serial] = true
+	}
+
+	nodeClientCertRevokedLocker.Lock()
+	nodeClientCertRevokedMap = revokedMap
+	nodeClientCertRevokedUpdatedAt = time.Now().Unix()
+	nodeClientCertRevokedLocker.Unlock()
+
+	return revokedMap[serial], nil
+}
+
+// DeleteExpiredNodeClientCerts 删除过期的证书记录
+func (this *NodeClientCertDAO) DeleteExpiredNodeClientCerts(tx *dbs.Tx, beforeTime int64) error {
+	_, err := this.Query(tx).
+		Lt("expiresAt", beforeTime).
+		Delete()
+	return err
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/iwind/TeaGo/bootstrap"
+	"github.com/iwind/TeaGo/dbs"
+	"testing"
+)
+
+func TestNodeClientCertDAO_IssueNodeClientCert(t *testing.T) {
+	dbs.NotifyReady()
+
+	var tx *dbs.Tx
+	clientCert, err := SharedNodeClientCertDAO.IssueNodeClientCert(tx, "node", 1, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log("serial:", clientCert.Serial, "expiresAt:", clientCert.ExpiresAt)
+
+	isRotating, err := SharedNodeClientCertDAO.CheckNodeClientCertRotating(tx, "node", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log("isRotating:", isRotating)
+}
@@ -0,0 +1,36 @@
+package models
+
+// NodeClientCert 节点客户端证书
+type NodeClientCert struct {
+	Id        uint64 `field:"id"`        // ID
+	CaId      uint32 `field:"caId"`      // 签发的CA ID
+	Role      string `field:"role"`      // 节点角色
+	NodeId    uint32 `field:"nodeId"`    // 节点ID
+	UniqueId  string `field:"uniqueId"`  // 节点唯一ID
+	Serial    string `field:"serial"`    // 证书序列号
+	CertData  string `field:"certData"`  // 证书内容
+	ExpiresAt uint64 `field:"expiresAt"` // 过期时间
+	RotatedAt uint64 `field:"rotatedAt"` // 被新证书替换的时间
+	RevokedAt uint64 `field:"revokedAt"` // 吊销时间
+	State     uint8  `field:"state"`     // 状态
+	CreatedAt uint64 `field:"createdAt"` // 创建时间
+}
+
+type NodeClientCertOperator struct {
+	Id        interface{} // ID
+	CaId      interface{} // 签发的CA ID
+	Role      interface{} // 节点角色
+	NodeId    interface{} // 节点ID
+	UniqueId  interface{} // 节点唯一ID
+	Serial    interface{} // 证书序列号
+	CertData  interface{} // 证书内容
+	ExpiresAt interface{} // 过期时间
+	RotatedAt interface{} // 被新证书替换的时间
+	RevokedAt interface{} // 吊销时间
+	State     interface{} // 状态
+	CreatedAt interface{} // 创建时间
+}
+
+func NewNodeClientCertOperator() *NodeClientCertOperator {
+	return &NodeClientCertOperator{}
+}
@@ -617,6 +617,94 @@ func (this *NodeClusterDAO) UpdateClusterTOA(tx *dbs.Tx, clusterId int64, toaJSO
 	return this.NotifyUpdate(tx, clusterId)
 }
 
+// FindClusterDNSFailoverConfig 查找集群的DNS故障转移策略
+func (this *NodeClusterDAO) FindClusterDNSFailoverConfig(tx *dbs.Tx, clusterId int64) (*NodeClusterDNSFailoverConfig, error) {
+	failover, err := this.Query(tx).
+		Pk(clusterId).
+		Result("dnsFailover").
+		FindStringCol("")
+	if err != nil {
+		return nil, err
+	}
+	if !IsNotNull(failover) {
+		return DefaultNodeClusterDNSFailoverConfig(), nil
+	}
+
+	config := DefaultNodeClusterDNSFailoverConfig()
+	err = json.Unmarshal([]byte(failover), config)
+	if err != nil {
+		return nil, err
+	}
+	err = config.Init()
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// UpdateClusterDNSFailoverConfig 修改集群的DNS故障转移策略
+func (this *NodeClusterDAO) UpdateClusterDNSFailoverConfig(tx *dbs.Tx, clusterId int64, config *NodeClusterDNSFailoverConfig) error {
+	if clusterId <= 0 {
+		return errors.New("invalid clusterId")
+	}
+	if config == nil {
+		return errors.New("invalid config")
+	}
+	err := config.Init()
+	if err != nil {
+		return err
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return err
+	}
+	op := NewNodeClusterOperator()
+	op.Id = clusterId
+	op.DnsFailover = configJSON
+	err = this.Save(tx, op)
+	if err != nil {
+		return err
+	}
+	return this.NotifyDNSUpdate(tx, clusterId)
+}
+
+// FindClusterDNSFailoverState 查找集群的DNS故障转移状态
+func (this *NodeClusterDAO) FindClusterDNSFailoverState(tx *dbs.Tx, clusterId int64) (*NodeClusterDNSFailoverState, error) {
+	stateString, err := this.Query(tx).
+		Pk(clusterId).
+		Result("dnsFailoverState").
+		FindStringCol("")
+	if err != nil {
+		return nil, err
+	}
+	state := &NodeClusterDNSFailoverState{}
+	if IsNotNull(stateString) {
+		err = json.Unmarshal([]byte(stateString), state)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if state.Routes == nil {
+		state.Routes = map[string]*NodeClusterDNSFailoverRouteState{}
+	}
+	return state, nil
+}
+
+// UpdateClusterDNSFailoverState 修改集群的DNS故障转移状态
+func (this *NodeClusterDAO) UpdateClusterDNSFailoverState(tx *dbs.Tx, clusterId int64, state *NodeClusterDNSFailoverState) error {
+	if clusterId <= 0 {
+		return errors.New("invalid clusterId")
+	}
+	stateJSON, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	op := NewNodeClusterOperator()
+	op.Id = clusterId
+	op.DnsFailoverState = stateJSON
+	return this.Save(tx, op)
+}
+
 // CountAllEnabledNodeClustersWithHTTPCachePolicyId 计算使用某个缓存策略的集群数量
 func (this *NodeClusterDAO) CountAllEnabledNodeClustersWithHTTPCachePolicyId(tx *dbs.Tx, httpCachePolicyId int64) (int64, error) {
 	return this.Query(tx).
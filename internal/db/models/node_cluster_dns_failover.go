@@ -0,0 +1,88 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package models
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"net"
+	"strings"
+)
+
+// NodeClusterDNSFailoverConfig 集群DNS故障转移策略
+// 某个线路中的健康节点数量低于阈值时，将此线路的解析切换到备用IP或者备用集群域名，
+// 健康节点数量恢复到 RecoverNodes 并且持续 RecoverSeconds 秒后再切换回来，以免反复切换
+type NodeClusterDNSFailoverConfig struct {
+	IsOn           bool     `json:"isOn"`
+	MinNodes       int      `json:"minNodes"`       // 健康节点数量低于此值时启用故障转移，默认为1，即所有节点都不可用时
+	RecoverNodes   int      `json:"recoverNodes"`   // 健康节点数量恢复到此值时切回，不能小于MinNodes
+	RecoverSeconds int64    `json:"recoverSeconds"` // 恢复后需要持续的时间
+	StandbyIPs     []string `json:"standbyIPs"`     // 备用IP
+	BackupCNAME    string   `json:"backupCNAME"`    // 备用集群域名，设置后优先于备用IP
+}
+
+// DefaultNodeClusterDNSFailoverConfig 默认的故障转移策略
+func DefaultNodeClusterDNSFailoverConfig() *NodeClusterDNSFailoverConfig {
+	return &NodeClusterDNSFailoverConfig{
+		IsOn:           false,
+		MinNodes:       1,
+		RecoverNodes:   1,
+		RecoverSeconds: 300,
+	}
+}
+
+// Init 校验并初始化
+func (this *NodeClusterDNSFailoverConfig) Init() error {
+	if this.MinNodes <= 0 {
+		this.MinNodes = 1
+	}
+	if this.RecoverNodes < this.MinNodes {
+		this.RecoverNodes = this.MinNodes
+	}
+	if this.RecoverSeconds < 0 {
+		this.RecoverSeconds = 0
+	}
+
+	for _, ip := range this.StandbyIPs {
+		if net.ParseIP(ip) == nil {
+			return errors.New("invalid standby ip '" + ip + "'")
+		}
+	}
+
+	this.BackupCNAME = strings.TrimSpace(this.BackupCNAME)
+	if len(this.BackupCNAME) > 0 && !strings.HasSuffix(this.BackupCNAME, ".") {
+		this.BackupCNAME += "."
+	}
+
+	if this.IsOn && len(this.StandbyIPs) == 0 && len(this.BackupCNAME) == 0 {
+		return errors.New("standby ips or backup cname should be set")
+	}
+	return nil
+}
+
+// HasTargets 是否设置了可以切换的目标
+func (this *NodeClusterDNSFailoverConfig) HasTargets() bool {
+	return len(this.StandbyIPs) > 0 || len(this.BackupCNAME) > 0
+}
+
+// NodeClusterDNSFailoverState 集群DNS故障转移状态
+type NodeClusterDNSFailoverState struct {
+	Routes map[string]*NodeClusterDNSFailoverRouteState `json:"routes"` // route => state
+}
+
+// NodeClusterDNSFailoverRouteState 单个线路的故障转移状态
+type NodeClusterDNSFailoverRouteState struct {
+	IsFailover        bool  `json:"isFailover"`        // 是否已切换到备用目标
+	CountHealthyNodes int   `json:"countHealthyNodes"` // 健康节点数量
+	RecoveringAt      int64 `json:"recoveringAt"`      // 开始恢复的时间，0表示没有在恢复中
+	UpdatedAt         int64 `json:"updatedAt"`         // 最后切换时间
+}
+
+// HasFailover 是否有线路处于故障转移状态
+func (this *NodeClusterDNSFailoverState) HasFailover() bool {
+	for _, routeState := range this.Routes {
+		if routeState.IsFailover {
+			return true
+		}
+	}
+	return false
+}
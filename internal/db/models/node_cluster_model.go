@@ -20,6 +20,8 @@ type NodeCluster struct {
 	DnsName              string `field:"dnsName"`              // DNS名称
 	DnsDomainId          uint32 `field:"dnsDomainId"`          // 域名ID
 	Dns                  string `field:"dns"`                  // DNS配置
+	DnsFailover          string `field:"dnsFailover"`          // DNS故障转移策略
+	DnsFailoverState     string `field:"dnsFailoverState"`     // DNS故障转移状态
 	Toa                  string `field:"toa"`                  // TOA配置
 	CachePolicyId        uint32 `field:"cachePolicyId"`        // 缓存策略ID
 	HttpFirewallPolicyId uint32 `field:"httpFirewallPolicyId"` // WAF策略ID
@@ -46,6 +48,8 @@ type NodeClusterOperator struct {
 	DnsName              interface{} // DNS名称
 	DnsDomainId          interface{} // 域名ID
 	Dns                  interface{} // DNS配置
+	DnsFailover          interface{} // DNS故障转移策略
+	DnsFailoverState     interface{} // DNS故障转移状态
 	Toa                  interface{} // TOA配置
 	CachePolicyId        interface{} // 缓存策略ID
 	HttpFirewallPolicyId interface{} // WAF策略ID
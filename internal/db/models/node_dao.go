@@ -818,6 +818,19 @@ func (this *NodeDAO) FindAllEnabledNodesDNSWithClusterId(tx *dbs.Tx, clusterId i
 	return
 }
 
+// FindAllEnabledAndOnNodesDNSWithClusterId 获取一个集群的所有启用的节点DNS信息，包括已下线的节点
+func (this *NodeDAO) FindAllEnabledAndOnNodesDNSWithClusterId(tx *dbs.Tx, clusterId int64) (result []*Node, err error) {
+	_, err = this.Query(tx).
+		State(NodeStateEnabled).
+		Attr("clusterId", clusterId).
+		Attr("isOn", true).
+		Result("id", "name", "dnsRoutes", "isOn", "isUp").
+		DescPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
 // CountAllEnabledNodesDNSWithClusterId 计算一个集群的节点DNS数量
 func (this *NodeDAO) CountAllEnabledNodesDNSWithClusterId(tx *dbs.Tx, clusterId int64) (result int64, err error) {
 	return this.Query(tx).
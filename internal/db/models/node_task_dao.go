@@ -13,9 +13,10 @@ import (
 type NodeTaskType = string
 
 const (
-	NodeTaskTypeConfigChanged      NodeTaskType = "configChanged"
-	NodeTaskTypeIPItemChanged      NodeTaskType = "ipItemChanged"
-	NodeTaskTypeNodeVersionChanged NodeTaskType = "nodeVersionChanged"
+	NodeTaskTypeConfigChanged        NodeTaskType = "configChanged"
+	NodeTaskTypeIPItemChanged        NodeTaskType = "ipItemChanged"
+	NodeTaskTypeNodeVersionChanged   NodeTaskType = "nodeVersionChanged"
+	NodeTaskTypeACMEChallengeChanged NodeTaskType = "acmeChallengeChanged" // ACME TLS-ALPN-01认证证书变化
 )
 
 type NodeTaskDAO dbs.DAO
@@ -226,6 +227,19 @@ func (this *NodeTaskDAO) ExistsDoingNodeTasks(tx *dbs.Tx) (bool, error) {
 		Exist()
 }
 
+// ExistsDoingNodeTasksWithType 检查一组集群中是否有某个类型的正在执行的任务
+func (this *NodeTaskDAO) ExistsDoingNodeTasksWithType(tx *dbs.Tx, clusterIds []int64, taskType NodeTaskType) (bool, error) {
+	if len(clusterIds) == 0 {
+		return false, nil
+	}
+	return this.Query(tx).
+		Attr("clusterId", clusterIds).
+		Attr("type", taskType).
+		Attr("isDone", 0).
+		Gt("nodeId", 0).
+		Exist()
+}
+
 // 是否有错误的任务
 func (this *NodeTaskDAO) ExistsErrorNodeTasks(tx *dbs.Tx) (bool, error) {
 	return this.Query(tx).
@@ -1025,6 +1025,45 @@ func (this *ServerDAO) FindAllEnabledServerIdsWithSSLPolicyIds(tx *dbs.Tx, sslPo
 	return
 }
 
+// FindAllEnabledSSLPolicyIds 查找所有启用的服务正在使用的SSL策略ID
+func (this *ServerDAO) FindAllEnabledSSLPolicyIds(tx *dbs.Tx) (policyIds []int64, err error) {
+	ones, err := this.Query(tx).
+		State(ServerStateEnabled).
+		Attr("isOn", true).
+		Result("https", "tls").
+		Where("(JSON_EXTRACT(https, '$.sslPolicyRef.sslPolicyId')>0 OR JSON_EXTRACT(tls, '$.sslPolicyRef.sslPolicyId')>0)").
+		FindAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, one := range ones {
+		var server = one.(*Server)
+
+		if IsNotNull(server.Https) {
+			httpsConfig := &serverconfigs.HTTPSProtocolConfig{}
+			err = json.Unmarshal([]byte(server.Https), httpsConfig)
+			if err != nil {
+				return nil, err
+			}
+			if httpsConfig.IsOn && httpsConfig.SSLPolicyRef != nil && httpsConfig.SSLPolicyRef.SSLPolicyId > 0 && !lists.ContainsInt64(policyIds, httpsConfig.SSLPolicyRef.SSLPolicyId) {
+				policyIds = append(policyIds, httpsConfig.SSLPolicyRef.SSLPolicyId)
+			}
+		}
+
+		if IsNotNull(server.Tls) {
+			tlsConfig := &serverconfigs.TLSProtocolConfig{}
+			err = json.Unmarshal([]byte(server.Tls), tlsConfig)
+			if err != nil {
+				return nil, err
+			}
+			if tlsConfig.IsOn && tlsConfig.SSLPolicyRef != nil && tlsConfig.SSLPolicyRef.SSLPolicyId > 0 && !lists.ContainsInt64(policyIds, tlsConfig.SSLPolicyRef.SSLPolicyId) {
+				policyIds = append(policyIds, tlsConfig.SSLPolicyRef.SSLPolicyId)
+			}
+		}
+	}
+	return policyIds, nil
+}
+
 // CountEnabledServersWithWebIds 计算使用某个缓存策略的所有服务数量
 func (this *ServerDAO) CountEnabledServersWithWebIds(tx *dbs.Tx, webIds []int64) (count int64, err error) {
 	if len(webIds) == 0 {
@@ -1335,6 +1374,39 @@ func (this *ServerDAO) ExistServerNameInCluster(tx *dbs.Tx, clusterId int64, ser
 	return query.Exist()
 }
 
+// FindAllEnabledClusterIdsWithServerName 查找使用某个域名的服务所在的集群
+// 同时匹配上一级的泛域名，比如 a.example.com 也会匹配 *.example.com
+func (this *ServerDAO) FindAllEnabledClusterIdsWithServerName(tx *dbs.Tx, serverName string) ([]int64, error) {
+	var serverNames = []string{serverName}
+	var index = strings.Index(serverName, ".")
+	if index > 0 {
+		serverNames = append(serverNames, "*"+serverName[index:])
+	}
+
+	var query = this.Query(tx).
+		State(ServerStateEnabled).
+		Gt("clusterId", 0)
+	var conds = []string{}
+	for i, name := range serverNames {
+		var suffix = strconv.Itoa(i)
+		conds = append(conds, "JSON_CONTAINS(serverNames, :nameQuery"+suffix+")", "JSON_CONTAINS(serverNames, :subNameQuery"+suffix+")")
+		query.Param("nameQuery"+suffix, maps.Map{"name": name}.AsJSON())
+		query.Param("subNameQuery"+suffix, maps.Map{"subNames": name}.AsJSON())
+	}
+	ones, _, err := query.
+		Result("DISTINCT(clusterId) AS clusterId").
+		Where("(" + strings.Join(conds, " OR ") + ")").
+		FindOnes()
+	if err != nil {
+		return nil, err
+	}
+	var result = []int64{}
+	for _, one := range ones {
+		result = append(result, one.GetInt64("clusterId"))
+	}
+	return result, nil
+}
+
 // GenDNSName 生成DNS Name
 func (this *ServerDAO) GenDNSName(tx *dbs.Tx) (string, error) {
 	for {
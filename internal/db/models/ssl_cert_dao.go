@@ -3,6 +3,7 @@ package models
 import (
 	"encoding/json"
 	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/sslutils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/serverconfigs/sslconfigs"
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/iwind/TeaGo/Tea"
@@ -17,6 +18,9 @@ const (
 	SSLCertStateDisabled = 0 // 已禁用
 )
 
+// 获取OCSP响应失败后重试的间隔
+const sslCertOCSPRetrySeconds = 3600
+
 type SSLCertDAO dbs.DAO
 
 func NewSSLCertDAO() *SSLCertDAO {
@@ -133,8 +137,18 @@ func (this *SSLCertDAO) UpdateCert(tx *dbs.Tx, certId int64, isOn bool, name str
 	op.IsCA = isCA
 
 	// cert和key均为有重新上传才会修改
+	// 重新上传证书后清除吊销状态
 	if len(certData) > 0 {
 		op.CertData = certData
+		op.RevokedAt = 0
+		op.RevokedReason = 0
+
+		// 重新获取OCSP响应
+		op.Ocsp = ""
+		op.OcspUpdatedAt = 0
+		op.OcspExpiresAt = 0
+		op.OcspCheckedAt = 0
+		op.OcspError = ""
 	}
 	if len(keyData) > 0 {
 		op.KeyData = keyData
@@ -185,6 +199,12 @@ func (this *SSLCertDAO) ComposeCertConfig(tx *dbs.Tx, certId int64) (*sslconfigs
 	config.TimeBeginAt = int64(cert.TimeBeginAt)
 	config.TimeEndAt = int64(cert.TimeEndAt)
 
+	// 只下发尚未过期的OCSP响应
+	if len(cert.Ocsp) > 0 && int64(cert.OcspExpiresAt) > time.Now().Unix() {
+		config.OCSP = []byte(cert.Ocsp)
+		config.OCSPExpiresAt = int64(cert.OcspExpiresAt)
+	}
+
 	if IsNotNull(cert.DnsNames) {
 		dnsNames := []string{}
 		err := json.Unmarshal([]byte(cert.DnsNames), &dnsNames)
@@ -236,6 +256,25 @@ func (this *SSLCertDAO) CountCerts(tx *dbs.Tx, isCA bool, isAvailable bool, isEx
 	return query.Count()
 }
 
+// FindAllEnabledCACertData 查找所有可用的CA证书内容，用来检查和补全证书链
+func (this *SSLCertDAO) FindAllEnabledCACertData(tx *dbs.Tx, userId int64) (result [][]byte, err error) {
+	ones, err := this.Query(tx).
+		State(SSLCertStateEnabled).
+		Attr("isOn", true).
+		Attr("isCA", true).
+		Attr("userId", userId).
+		Result("certData").
+		AscPk().
+		FindAll()
+	if err != nil {
+		return nil, err
+	}
+	for _, one := range ones {
+		result = append(result, []byte(one.(*SSLCert).CertData))
+	}
+	return
+}
+
 // 列出符合条件的证书
 func (this *SSLCertDAO) ListCertIds(tx *dbs.Tx, isCA bool, isAvailable bool, isExpired bool, expiringDays int64, keyword string, userId int64, offset int64, size int64) (certIds []int64, err error) {
 	query := this.Query(tx).
@@ -294,6 +333,72 @@ func (this *SSLCertDAO) UpdateCertACME(tx *dbs.Tx, certId int64, acmeTaskId int6
 	return err
 }
 
+// UpdateCertRevoked 设置证书为已吊销
+func (this *SSLCertDAO) UpdateCertRevoked(tx *dbs.Tx, certId int64, reason uint8) error {
+	if certId <= 0 {
+		return errors.New("invalid certId")
+	}
+	op := NewSSLCertOperator()
+	op.Id = certId
+	op.RevokedAt = time.Now().Unix()
+	op.RevokedReason = reason
+	return this.Save(tx, op)
+}
+
+// FindAllOCSPUpdatingCerts 查找需要更新OCSP响应的证书
+// 只查找正在被策略使用的证书，没有响应或者响应已超过一半有效期时需要更新
+func (this *SSLCertDAO) FindAllOCSPUpdatingCerts(tx *dbs.Tx, size int64) (result []*SSLCert, err error) {
+	var now = time.Now().Unix()
+	_, err = this.Query(tx).
+		State(SSLCertStateEnabled).
+		Attr("isOn", true).
+		Attr("isCA", false).
+		Gt("timeEndAt", now).
+		Lt("ocspCheckedAt", now-sslCertOCSPRetrySeconds).
+		Where("(ocspExpiresAt+ocspUpdatedAt)/2<=:now").
+		Param("now", now).
+		Where("EXISTS (SELECT id FROM "+SharedSSLPolicyDAO.Table+" WHERE state=1 AND JSON_CONTAINS(certs, JSON_OBJECT('certId', "+this.Table+".id)))").
+		Result("id", "adminId", "userId", "name", "dnsNames", "certData", "acmeTaskId", "revokedAt").
+		Asc("ocspCheckedAt").
+		AscPk().
+		Limit(size).
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// UpdateCertOCSP 保存证书的OCSP响应
+func (this *SSLCertDAO) UpdateCertOCSP(tx *dbs.Tx, certId int64, resp *sslutils.OCSPResponse) error {
+	if certId <= 0 {
+		return errors.New("invalid certId")
+	}
+	op := NewSSLCertOperator()
+	op.Id = certId
+	op.Ocsp = resp.Raw
+	op.OcspUpdatedAt = resp.ThisUpdate
+	op.OcspExpiresAt = resp.NextUpdate
+	op.OcspCheckedAt = time.Now().Unix()
+	op.OcspError = ""
+	err := this.Save(tx, op)
+	if err != nil {
+		return err
+	}
+	return this.NotifyUpdate(tx, certId)
+}
+
+// UpdateCertOCSPError 记录获取OCSP响应时的错误
+// 保留原有的响应，直到它过期
+func (this *SSLCertDAO) UpdateCertOCSPError(tx *dbs.Tx, certId int64, errString string) error {
+	if len(errString) > 1024 {
+		errString = errString[:1024]
+	}
+	return this.Query(tx).
+		Pk(certId).
+		Set("ocspCheckedAt", time.Now().Unix()).
+		Set("ocspError", errString).
+		UpdateQuickly()
+}
+
 // 查找需要自动更新的任务
 // 这里我们只返回有限的字段以节省内存
 func (this *SSLCertDAO) FindAllExpiringCerts(tx *dbs.Tx, days int) (result []*SSLCert, err error) {
@@ -0,0 +1,99 @@
+package models
+
+import (
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/maps"
+	"time"
+)
+
+type SSLPolicyLintReportDAO dbs.DAO
+
+func NewSSLPolicyLintReportDAO() *SSLPolicyLintReportDAO {
+	return dbs.NewDAO(&SSLPolicyLintReportDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeSSLPolicyLintReports",
+			Model:  new(SSLPolicyLintReport),
+			PkName: "id",
+		},
+	}).(*SSLPolicyLintReportDAO)
+}
+
+var SharedSSLPolicyLintReportDAO *SSLPolicyLintReportDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedSSLPolicyLintReportDAO = NewSSLPolicyLintReportDAO()
+	})
+}
+
+// UpdatePolicyLintReport 保存策略的检查报告
+func (this *SSLPolicyLintReportDAO) UpdatePolicyLintReport(tx *dbs.Tx, policyId int64, grade string, issuesJSON []byte) error {
+	if policyId <= 0 {
+		return nil
+	}
+	if len(issuesJSON) == 0 {
+		issuesJSON = []byte("[]")
+	}
+	var checkedAt = time.Now().Unix()
+	_, _, err := this.Query(tx).
+		InsertOrUpdate(maps.Map{
+			"policyId":  policyId,
+			"grade":     grade,
+			"issues":    issuesJSON,
+			"checkedAt": checkedAt,
+		}, maps.Map{
+			"grade":     grade,
+			"issues":    issuesJSON,
+			"checkedAt": checkedAt,
+		})
+	return err
+}
+
+// FindPolicyLintReport 查找策略的检查报告
+func (this *SSLPolicyLintReportDAO) FindPolicyLintReport(tx *dbs.Tx, policyId int64) (*SSLPolicyLintReport, error) {
+	one, err := this.Query(tx).
+		Attr("policyId", policyId).
+		Find()
+	if err != nil || one == nil {
+		return nil, err
+	}
+	return one.(*SSLPolicyLintReport), nil
+}
+
+// CountAllPolicyLintReports 计算检查报告数量
+// grade 为空时表示所有等级
+func (this *SSLPolicyLintReportDAO) CountAllPolicyLintReports(tx *dbs.Tx, grade string) (int64, error) {
+	var query = this.Query(tx)
+	if len(grade) > 0 {
+		query.Attr("grade", grade)
+	}
+	return query.Count()
+}
+
+// ListPolicyLintReports 列出单页检查报告，等级较低的排在前面
+func (this *SSLPolicyLintReportDAO) ListPolicyLintReports(tx *dbs.Tx, grade string, offset int64, size int64) (result []*SSLPolicyLintReport, err error) {
+	var query = this.Query(tx)
+	if len(grade) > 0 {
+		query.Attr("grade", grade)
+	}
+	_, err = query.
+		Offset(offset).
+		Limit(size).
+		Desc("grade").
+		AscPk().
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// DeletePolicyLintReportsCheckedBefore 删除某个时间之前检查的报告
+// 用来清除已经不再被使用的策略的报告
+func (this *SSLPolicyLintReportDAO) DeletePolicyLintReportsCheckedBefore(tx *dbs.Tx, timestamp int64) error {
+	_, err := this.Query(tx).
+		Lt("checkedAt", timestamp).
+		Delete()
+	return err
+}
@@ -0,0 +1,26 @@
+package models
+
+import (
+	_ "github.com/go-sql-driver/mysql"
+	_ "github.com/iwind/TeaGo/bootstrap"
+	"github.com/iwind/TeaGo/dbs"
+	"testing"
+)
+
+func TestSSLPolicyLintReportDAO_UpdatePolicyLintReport(t *testing.T) {
+	dbs.NotifyReady()
+
+	var tx *dbs.Tx
+	err := SharedSSLPolicyLintReportDAO.UpdatePolicyLintReport(tx, 1, "A", []byte(`[{"code":"HSTS_DISABLED"}]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := SharedSSLPolicyLintReportDAO.FindPolicyLintReport(tx, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report == nil {
+		t.Fatal("report should not be nil")
+	}
+	t.Log("grade:", report.Grade, "issues:", report.Issues)
+}
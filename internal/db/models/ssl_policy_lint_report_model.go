@@ -0,0 +1,22 @@
+package models
+
+// SSLPolicyLintReport SSL策略检查报告
+type SSLPolicyLintReport struct {
+	Id        uint64 `field:"id"`        // ID
+	PolicyId  uint32 `field:"policyId"`  // SSL策略ID
+	Grade     string `field:"grade"`     // 等级
+	Issues    string `field:"issues"`    // 问题列表
+	CheckedAt uint64 `field:"checkedAt"` // 检查时间
+}
+
+type SSLPolicyLintReportOperator struct {
+	Id        interface{} // ID
+	PolicyId  interface{} // SSL策略ID
+	Grade     interface{} // 等级
+	Issues    interface{} // 问题列表
+	CheckedAt interface{} // 检查时间
+}
+
+func NewSSLPolicyLintReportOperator() *SSLPolicyLintReportOperator {
+	return &SSLPolicyLintReportOperator{}
+}
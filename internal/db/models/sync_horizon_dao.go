@@ -0,0 +1,66 @@
+package models
+
+import (
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/maps"
+	"time"
+)
+
+type SyncHorizonDAO dbs.DAO
+
+func NewSyncHorizonDAO() *SyncHorizonDAO {
+	return dbs.NewDAO(&SyncHorizonDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeSyncHorizons",
+			Model:  new(SyncHorizon),
+			PkName: "id",
+		},
+	}).(*SyncHorizonDAO)
+}
+
+var SharedSyncHorizonDAO *SyncHorizonDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedSyncHorizonDAO = NewSyncHorizonDAO()
+	})
+}
+
+// FindHorizon 查找某类数据的最小可用版本
+// 小于等于此版本的删除标记已经被清理，客户端的版本不大于此版本时需要完整同步
+func (this *SyncHorizonDAO) FindHorizon(tx *dbs.Tx, kind SyncTombstoneKind) (int64, error) {
+	return this.Query(tx).
+		Attr("kind", kind).
+		Result("version").
+		FindInt64Col(0)
+}
+
+// UpdateHorizon 提高最小可用版本，版本只会增加，不会减少
+func (this *SyncHorizonDAO) UpdateHorizon(tx *dbs.Tx, kind SyncTombstoneKind, version int64) error {
+	return this.Query(tx).
+		Param("version", version).
+		InsertOrUpdateQuickly(maps.Map{
+			"kind":      kind,
+			"version":   version,
+			"updatedAt": time.Now().Unix(),
+		}, maps.Map{
+			"version":   dbs.SQL("GREATEST(version, :version)"),
+			"updatedAt": time.Now().Unix(),
+		})
+}
+
+// CheckResyncRequired 检查客户端是否需要完整同步，同时返回当前的最小可用版本
+// 客户端版本为0表示首次同步，不需要检查；客户端版本等于最小可用版本时，已清理的删除操作客户端都已经同步过
+func (this *SyncHorizonDAO) CheckResyncRequired(tx *dbs.Tx, kind SyncTombstoneKind, clientVersion int64) (resyncRequired bool, horizon int64, err error) {
+	if clientVersion <= 0 {
+		return false, 0, nil
+	}
+	horizon, err = this.FindHorizon(tx, kind)
+	if err != nil {
+		return false, 0, err
+	}
+	return horizon > 0 && clientVersion < horizon, horizon, nil
+}
@@ -0,0 +1,20 @@
+package models
+
+// SyncHorizon 增量同步的最小可用版本
+type SyncHorizon struct {
+	Id        uint64 `field:"id"`        // ID
+	Kind      string `field:"kind"`      // 数据类型
+	Version   uint64 `field:"version"`   // 已清理的删除标记的最大版本
+	UpdatedAt uint64 `field:"updatedAt"` // 更新时间
+}
+
+type SyncHorizonOperator struct {
+	Id        interface{} // ID
+	Kind      interface{} // 数据类型
+	Version   interface{} // 已清理的删除标记的最大版本
+	UpdatedAt interface{} // 更新时间
+}
+
+func NewSyncHorizonOperator() *SyncHorizonOperator {
+	return &SyncHorizonOperator{}
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/iwind/TeaGo/Tea"
+	"github.com/iwind/TeaGo/dbs"
+	"time"
+)
+
+// SyncTombstoneKind 增量同步的数据类型
+type SyncTombstoneKind = string
+
+const (
+	SyncTombstoneKindNSDomain SyncTombstoneKind = "nsDomain"
+	SyncTombstoneKindNSRecord SyncTombstoneKind = "nsRecord"
+	SyncTombstoneKindNSRoute  SyncTombstoneKind = "nsRoute"
+	SyncTombstoneKindIPItem   SyncTombstoneKind = "ipItem"
+)
+
+type SyncTombstoneDAO dbs.DAO
+
+func NewSyncTombstoneDAO() *SyncTombstoneDAO {
+	return dbs.NewDAO(&SyncTombstoneDAO{
+		DAOObject: dbs.DAOObject{
+			DB:     Tea.Env,
+			Table:  "edgeSyncTombstones",
+			Model:  new(SyncTombstone),
+			PkName: "id",
+		},
+	}).(*SyncTombstoneDAO)
+}
+
+var SharedSyncTombstoneDAO *SyncTombstoneDAO
+
+func init() {
+	dbs.OnReady(func() {
+		SharedSyncTombstoneDAO = NewSyncTombstoneDAO()
+	})
+}
+
+// CreateTombstone 数据被删除时创建删除标记
+// 被删除的数据在保留期内仍然留在原表中，以便客户端通过版本号同步到删除操作
+func (this *SyncTombstoneDAO) CreateTombstone(tx *dbs.Tx, kind SyncTombstoneKind, itemId int64, version int64) error {
+	op := NewSyncTombstoneOperator()
+	op.Kind = kind
+	op.ItemId = itemId
+	op.Version = version
+	op.CreatedAt = time.Now().Unix()
+	return this.Save(tx, op)
+}
+
+// CreateMissingTombstones 为已经删除但没有删除标记的数据补充删除标记
+// 用于处理增加删除标记之前删除的数据，table为数据所在的表
+func (this *SyncTombstoneDAO) CreateMissingTombstones(tx *dbs.Tx, kind SyncTombstoneKind, table string) error {
+	_, err := this.Instance.Exec("INSERT INTO `"+this.Table+"` (`kind`, `itemId`, `version`, `createdAt`) "+
+		"SELECT ?, `id`, `version`, ? FROM `"+table+"` WHERE `state`=0 AND `id` NOT IN (SELECT `itemId` FROM `"+this.Table+"` WHERE `kind`=?)", kind, time.Now().Unix(), kind)
+	return err
+}
+
+// FindExpiredTombstones 查找某个时间之前创建的删除标记
+func (this *SyncTombstoneDAO) FindExpiredTombstones(tx *dbs.Tx, kind SyncTombstoneKind, beforeTime int64, size int64) (result []*SyncTombstone, err error) {
+	_, err = this.Query(tx).
+		Attr("kind", kind).
+		Lt("createdAt", beforeTime).
+		AscPk().
+		Limit(size).
+		Slice(&result).
+		FindAll()
+	return
+}
+
+// PurgeTombstone 清理删除标记和对应的已删除数据
+// 数据被恢复或者重新删除（版本号已改变）时只清理删除标记
+func (this *SyncTombstoneDAO) PurgeTombstone(tx *dbs.Tx, table string, tombstone *SyncTombstone) error {
+	_, err := this.Query(tx).
+		Table(table).
+		Attr("id", tombstone.ItemId).
+		Attr("state", 0).
+		Attr("version", tombstone.Version).
+		Delete()
+	if err != nil {
+		return err
+	}
+
+	_, err = this.Query(tx).
+		Pk(tombstone.Id).
+		Delete()
+	return err
+}
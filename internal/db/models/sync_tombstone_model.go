@@ -0,0 +1,22 @@
+package models
+
+// SyncTombstone 增量同步删除标记
+type SyncTombstone struct {
+	Id        uint64 `field:"id"`        // ID
+	Kind      string `field:"kind"`      // 数据类型：nsDomain, nsRecord, nsRoute, ipItem
+	ItemId    uint64 `field:"itemId"`    // 数据ID
+	Version   uint64 `field:"version"`   // 删除时的版本
+	CreatedAt uint64 `field:"createdAt"` // 创建时间
+}
+
+type SyncTombstoneOperator struct {
+	Id        interface{} // ID
+	Kind      interface{} // 数据类型：nsDomain, nsRecord, nsRoute, ipItem
+	ItemId    interface{} // 数据ID
+	Version   interface{} // 删除时的版本
+	CreatedAt interface{} // 创建时间
+}
+
+func NewSyncTombstoneOperator() *SyncTombstoneOperator {
+	return &SyncTombstoneOperator{}
+}
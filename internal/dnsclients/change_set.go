@@ -0,0 +1,141 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package dnsclients
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"strings"
+	"time"
+)
+
+// BatchProviderInterface 支持原子性批量修改记录的服务商
+type BatchProviderInterface interface {
+	// ApplyChanges 一次性执行所有变更，要么全部成功，要么全部失败
+	ApplyChanges(domain string, changes []*dnstypes.Change) error
+}
+
+// ApplyChangeSet 执行变更集
+// 如果服务商支持批量修改，则一次性提交；否则逐个执行，中途失败时会反向补偿已经执行的变更
+func ApplyChangeSet(provider ProviderInterface, changeSet *dnstypes.ChangeSet) error {
+	if changeSet.CreatedAt == 0 {
+		changeSet.CreatedAt = time.Now().Unix()
+	}
+	if changeSet.IsEmpty() {
+		changeSet.IsOk = true
+		return nil
+	}
+
+	for _, change := range changeSet.Changes {
+		if change.Record == nil || (change.Action == dnstypes.ChangeActionUpdate && change.NewRecord == nil) {
+			return errors.New("invalid change '" + change.Action + "'")
+		}
+	}
+
+	// 批量执行
+	batchProvider, ok := provider.(BatchProviderInterface)
+	if ok {
+		changeSet.IsBatch = true
+		err := batchProvider.ApplyChanges(changeSet.Domain, changeSet.Changes)
+		if err != nil {
+			changeSet.Error = err.Error()
+			changeSet.IsRolledBack = true
+			return err
+		}
+		for _, change := range changeSet.Changes {
+			change.IsApplied = true
+		}
+		changeSet.IsOk = true
+		return nil
+	}
+
+	// 逐个执行
+	for index, change := range changeSet.Changes {
+		err := applyChange(provider, changeSet.Domain, change)
+		if err != nil {
+			change.Error = err.Error()
+			changeSet.Error = err.Error()
+
+			rollbackErr := rollbackChanges(provider, changeSet.Domain, changeSet.Changes[:index])
+			if rollbackErr != nil {
+				return errors.New(err.Error() + "; rollback failed: " + rollbackErr.Error())
+			}
+			changeSet.IsRolledBack = true
+			return err
+		}
+		change.IsApplied = true
+	}
+
+	changeSet.IsOk = true
+	return nil
+}
+
+// 执行单个变更
+func applyChange(provider ProviderInterface, domain string, change *dnstypes.Change) error {
+	switch change.Action {
+	case dnstypes.ChangeActionCreate:
+		return provider.AddRecord(domain, change.Record)
+	case dnstypes.ChangeActionUpdate:
+		return provider.UpdateRecord(domain, change.Record, change.NewRecord)
+	case dnstypes.ChangeActionDelete:
+		return provider.DeleteRecord(domain, change.Record)
+	}
+	return errors.New("invalid change action '" + change.Action + "'")
+}
+
+// 反向补偿已经执行的变更
+// 回滚时会尽可能执行所有的补偿操作，并返回遇到的错误
+func rollbackChanges(provider ProviderInterface, domain string, changes []*dnstypes.Change) error {
+	var errorStrings = []string{}
+	for i := len(changes) - 1; i >= 0; i-- {
+		change := changes[i]
+		if !change.IsApplied {
+			continue
+		}
+
+		var err error
+		switch change.Action {
+		case dnstypes.ChangeActionCreate:
+			// 新创建的记录可能没有ID，需要重新查找
+			var record *dnstypes.Record
+			record, err = findSameRecord(provider, domain, change.Record)
+			if err == nil && record != nil {
+				err = provider.DeleteRecord(domain, record)
+			}
+		case dnstypes.ChangeActionUpdate:
+			var currentRecord = *change.NewRecord
+			currentRecord.Id = change.Record.Id
+			err = provider.UpdateRecord(domain, &currentRecord, change.Record)
+		case dnstypes.ChangeActionDelete:
+			err = provider.AddRecord(domain, change.Record)
+		}
+		if err != nil {
+			change.Error = "rollback failed: " + err.Error()
+			errorStrings = append(errorStrings, err.Error())
+			continue
+		}
+		change.IsRolledBack = true
+	}
+
+	if len(errorStrings) > 0 {
+		return errors.New(strings.Join(errorStrings, "; "))
+	}
+	return nil
+}
+
+// 从服务商中查找和某个记录相同的记录
+func findSameRecord(provider ProviderInterface, domain string, record *dnstypes.Record) (*dnstypes.Record, error) {
+	records, err := provider.GetRecords(domain)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		if r.Name == record.Name &&
+			r.Type == record.Type &&
+			(r.Route == record.Route || len(record.Route) == 0) &&
+			strings.TrimSuffix(r.Value, ".") == strings.TrimSuffix(record.Value, ".") {
+			return r, nil
+		}
+	}
+	return nil, nil
+}
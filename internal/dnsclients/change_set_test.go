@@ -0,0 +1,128 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package dnsclients
+
+import (
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/iwind/TeaGo/logs"
+	"github.com/iwind/TeaGo/maps"
+	"strconv"
+	"testing"
+)
+
+func TestApplyChangeSet(t *testing.T) {
+	provider := &testMemoryProvider{}
+	provider.records = []*dnstypes.Record{
+		{Id: "1", Name: "cdn", Type: dnstypes.RecordTypeA, Value: "192.168.1.1", Route: "default"},
+	}
+
+	changeSet := &dnstypes.ChangeSet{Domain: "example.com"}
+	changeSet.AddCreate(&dnstypes.Record{Name: "cdn", Type: dnstypes.RecordTypeA, Value: "192.168.1.2", Route: "default"})
+	changeSet.AddDelete(provider.records[0])
+	err := ApplyChangeSet(provider, changeSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changeSet.IsOk || len(provider.records) != 1 || provider.records[0].Value != "192.168.1.2" {
+		t.Fatal("apply failed")
+	}
+	logs.PrintAsJSON(changeSet, t)
+}
+
+func TestApplyChangeSet_Rollback(t *testing.T) {
+	provider := &testMemoryProvider{}
+	provider.records = []*dnstypes.Record{
+		{Id: "1", Name: "cdn", Type: dnstypes.RecordTypeA, Value: "192.168.1.1", Route: "default"},
+		{Id: "2", Name: "cdn", Type: dnstypes.RecordTypeA, Value: "192.168.1.2", Route: "default"},
+	}
+	provider.failOnValue = "192.168.1.5"
+
+	changeSet := &dnstypes.ChangeSet{Domain: "example.com"}
+	changeSet.AddDelete(provider.records[0])
+	changeSet.AddUpdate(provider.records[1], &dnstypes.Record{Name: "cdn", Type: dnstypes.RecordTypeA, Value: "192.168.1.3", Route: "default"})
+	changeSet.AddCreate(&dnstypes.Record{Name: "cdn", Type: dnstypes.RecordTypeA, Value: "192.168.1.4", Route: "default"})
+	changeSet.AddCreate(&dnstypes.Record{Name: "cdn", Type: dnstypes.RecordTypeA, Value: "192.168.1.5", Route: "default"})
+	err := ApplyChangeSet(provider, changeSet)
+	if err == nil {
+		t.Fatal("change set should fail")
+	}
+	logs.PrintAsJSON(changeSet, t)
+	logs.PrintAsJSON(provider.records, t)
+
+	if !changeSet.IsRolledBack {
+		t.Fatal("change set should be rolled back")
+	}
+	var values = []string{}
+	for _, record := range provider.records {
+		values = append(values, record.Value)
+	}
+	if len(values) != 2 || !(values[0] == "192.168.1.2" && values[1] == "192.168.1.1") {
+		t.Fatal("records should be restored, but got:", values)
+	}
+}
+
+// 用来测试的内存服务商
+type testMemoryProvider struct {
+	records     []*dnstypes.Record
+	failOnValue string
+	lastId      int
+}
+
+func (this *testMemoryProvider) Auth(params maps.Map) error {
+	return nil
+}
+
+func (this *testMemoryProvider) GetRecords(domain string) (records []*dnstypes.Record, err error) {
+	return this.records, nil
+}
+
+func (this *testMemoryProvider) GetRoutes(domain string) (routes []*dnstypes.Route, err error) {
+	return []*dnstypes.Route{{Name: "默认", Code: "default"}}, nil
+}
+
+func (this *testMemoryProvider) QueryRecord(domain string, name string, recordType dnstypes.RecordType) (*dnstypes.Record, error) {
+	for _, record := range this.records {
+		if record.Name == name && record.Type == recordType {
+			return record, nil
+		}
+	}
+	return nil, nil
+}
+
+func (this *testMemoryProvider) AddRecord(domain string, newRecord *dnstypes.Record) error {
+	if newRecord.Value == this.failOnValue {
+		return errors.New("add record failed")
+	}
+	this.lastId++
+	var record = *newRecord
+	record.Id = "new" + strconv.Itoa(this.lastId)
+	this.records = append(this.records, &record)
+	return nil
+}
+
+func (this *testMemoryProvider) UpdateRecord(domain string, record *dnstypes.Record, newRecord *dnstypes.Record) error {
+	for index, r := range this.records {
+		if r.Id == record.Id {
+			var updatedRecord = *newRecord
+			updatedRecord.Id = r.Id
+			this.records[index] = &updatedRecord
+			return nil
+		}
+	}
+	return errors.New("record not found")
+}
+
+func (this *testMemoryProvider) DeleteRecord(domain string, record *dnstypes.Record) error {
+	for index, r := range this.records {
+		if r.Id == record.Id {
+			this.records = append(this.records[:index], this.records[index+1:]...)
+			return nil
+		}
+	}
+	return errors.New("record not found")
+}
+
+func (this *testMemoryProvider) DefaultRoute() string {
+	return "default"
+}
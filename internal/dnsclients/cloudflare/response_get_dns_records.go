@@ -5,13 +5,26 @@ package cloudflare
 type GetDNSRecordsResponse struct {
 	BaseResponse
 
-	Result []struct {
-		Id       string `json:"id"`
-		Type     string `json:"type"`
-		Name     string `json:"name"`
-		Content  string `json:"content"`
-		Ttl      int    `json:"ttl"`
-		ZoneId   string `json:"zoneId"`
-		ZoneName string `json:"zoneName"`
-	} `json:"result"`
+	Result []*DNSRecord `json:"result"`
+}
+
+// DNSRecord 解析记录
+type DNSRecord struct {
+	Id       string `json:"id"`
+	Type     string `json:"type"`
+	Name     string `json:"name"`
+	Content  string `json:"content"`
+	Ttl      int    `json:"ttl"`
+	Priority int    `json:"priority"`
+	ZoneId   string `json:"zoneId"`
+	ZoneName string `json:"zoneName"`
+	Data     struct {
+		Priority int    `json:"priority"`
+		Weight   int    `json:"weight"`
+		Port     int    `json:"port"`
+		Target   string `json:"target"`
+		Flags    int    `json:"flags"`
+		Tag      string `json:"tag"`
+		Value    string `json:"value"`
+	} `json:"data"`
 }
@@ -0,0 +1,64 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package dnstypes
+
+type ChangeAction = string
+
+const (
+	ChangeActionCreate ChangeAction = "create"
+	ChangeActionUpdate ChangeAction = "update"
+	ChangeActionDelete ChangeAction = "delete"
+)
+
+// Change 单个记录变更
+type Change struct {
+	Action       ChangeAction `json:"action"`
+	Record       *Record      `json:"record"`       // 要创建的记录，或者要修改、删除的原记录
+	NewRecord    *Record      `json:"newRecord"`    // 修改后的记录，只在修改时使用
+	IsApplied    bool         `json:"isApplied"`    // 是否已执行
+	IsRolledBack bool         `json:"isRolledBack"` // 是否已回滚
+	Error        string       `json:"error"`        // 错误信息
+}
+
+// ChangeSet 变更集
+// 同一个变更集中的变更要么全部执行成功，要么全部回滚
+type ChangeSet struct {
+	Domain       string    `json:"domain"`
+	Changes      []*Change `json:"changes"`
+	CreatedAt    int64     `json:"createdAt"`
+	IsBatch      bool      `json:"isBatch"`      // 是否为服务商原生支持的批量执行
+	IsMirror     bool      `json:"isMirror"`     // 是否为同步到镜像服务商的变更
+	IsOk         bool      `json:"isOk"`         // 是否全部执行成功
+	IsRolledBack bool      `json:"isRolledBack"` // 是否已全部回滚
+	Error        string    `json:"error"`        // 错误信息
+}
+
+// AddCreate 添加创建记录变更
+func (this *ChangeSet) AddCreate(record *Record) {
+	this.Changes = append(this.Changes, &Change{
+		Action: ChangeActionCreate,
+		Record: record,
+	})
+}
+
+// AddUpdate 添加修改记录变更
+func (this *ChangeSet) AddUpdate(record *Record, newRecord *Record) {
+	this.Changes = append(this.Changes, &Change{
+		Action:    ChangeActionUpdate,
+		Record:    record,
+		NewRecord: newRecord,
+	})
+}
+
+// AddDelete 添加删除记录变更
+func (this *ChangeSet) AddDelete(record *Record) {
+	this.Changes = append(this.Changes, &Change{
+		Action: ChangeActionDelete,
+		Record: record,
+	})
+}
+
+// IsEmpty 判断是否没有变更
+func (this *ChangeSet) IsEmpty() bool {
+	return len(this.Changes) == 0
+}
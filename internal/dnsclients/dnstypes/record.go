@@ -1,5 +1,10 @@
 package dnstypes
 
+import (
+	"strconv"
+	"strings"
+)
+
 type RecordType = string
 
 const (
@@ -7,12 +12,95 @@ const (
 	RecordTypeAAAA  RecordType = "AAAA"
 	RecordTypeCNAME RecordType = "CNAME"
 	RecordTypeTXT   RecordType = "TXT"
+	RecordTypeMX    RecordType = "MX"
+	RecordTypeSRV   RecordType = "SRV"
+	RecordTypeCAA   RecordType = "CAA"
+	RecordTypeNS    RecordType = "NS"
 )
 
+// FindAllRecordTypes 所有支持的记录类型
+func FindAllRecordTypes() []RecordType {
+	return []RecordType{
+		RecordTypeA,
+		RecordTypeAAAA,
+		RecordTypeCNAME,
+		RecordTypeTXT,
+		RecordTypeMX,
+		RecordTypeSRV,
+		RecordTypeCAA,
+		RecordTypeNS,
+	}
+}
+
+// Record 解析记录
+// 对于MX记录，Value为邮件服务器域名，Priority为优先级；
+// 对于SRV记录，Value为目标域名，Priority、Weight、Port分别为优先级、权重和端口；
+// 对于CAA记录，Value为"flags tag value"格式，比如 0 issue "letsencrypt.org"
 type Record struct {
-	Id    string     `json:"id"`
-	Name  string     `json:"name"`
-	Type  RecordType `json:"type"`
-	Value string     `json:"value"`
-	Route string     `json:"route"`
+	Id       string     `json:"id"`
+	Name     string     `json:"name"`
+	Type     RecordType `json:"type"`
+	Value    string     `json:"value"`
+	Route    string     `json:"route"`
+	TTL      int32      `json:"ttl"`      // TTL（秒），0表示使用服务商默认值
+	Priority int32      `json:"priority"` // 优先级，用于MX、SRV
+	Weight   int32      `json:"weight"`   // 权重，用于SRV
+	Port     int32      `json:"port"`     // 端口，用于SRV
+}
+
+// ComposeValue 组合成区域文件格式的记录值
+// MX记录为"priority target"，SRV记录为"priority weight port target"，其他类型直接返回Value
+func (this *Record) ComposeValue() string {
+	switch this.Type {
+	case RecordTypeMX:
+		return strconv.Itoa(int(this.Priority)) + " " + this.Value
+	case RecordTypeSRV:
+		return strconv.Itoa(int(this.Priority)) + " " + strconv.Itoa(int(this.Weight)) + " " + strconv.Itoa(int(this.Port)) + " " + this.Value
+	}
+	return this.Value
+}
+
+// ParseValue 从区域文件格式的记录值中分析出Value、Priority等字段
+// 和 ComposeValue() 相对应，如果格式不正确则原样保留在Value中
+func (this *Record) ParseValue(value string) {
+	this.Value = value
+
+	fields := strings.Fields(value)
+	switch this.Type {
+	case RecordTypeMX:
+		if len(fields) != 2 {
+			return
+		}
+		priority, err := strconv.Atoi(fields[0])
+		if err != nil {
+			return
+		}
+		this.Priority = int32(priority)
+		this.Value = fields[1]
+	case RecordTypeSRV:
+		if len(fields) != 4 {
+			return
+		}
+		var numbers = []int{}
+		for _, field := range fields[:3] {
+			number, err := strconv.Atoi(field)
+			if err != nil {
+				return
+			}
+			numbers = append(numbers, number)
+		}
+		this.Priority = int32(numbers[0])
+		this.Weight = int32(numbers[1])
+		this.Port = int32(numbers[2])
+		this.Value = fields[3]
+	}
+}
+
+// IsDomainValue 记录值是否为域名
+func (this *Record) IsDomainValue() bool {
+	switch this.Type {
+	case RecordTypeCNAME, RecordTypeMX, RecordTypeSRV, RecordTypeNS:
+		return true
+	}
+	return false
 }
@@ -0,0 +1,44 @@
+package dnstypes
+
+import "testing"
+
+func TestRecord_ComposeValue(t *testing.T) {
+	for _, v := range []struct {
+		record *Record
+		value  string
+	}{
+		{&Record{Type: RecordTypeA, Value: "192.168.1.100"}, "192.168.1.100"},
+		{&Record{Type: RecordTypeMX, Value: "mail.example.com.", Priority: 10}, "10 mail.example.com."},
+		{&Record{Type: RecordTypeSRV, Value: "sip.example.com.", Priority: 10, Weight: 5, Port: 5060}, "10 5 5060 sip.example.com."},
+	} {
+		var value = v.record.ComposeValue()
+		if value != v.value {
+			t.Fatal("compose " + v.record.Type + " failed: expected '" + v.value + "', but got '" + value + "'")
+		}
+	}
+}
+
+func TestRecord_ParseValue(t *testing.T) {
+	{
+		record := &Record{Type: RecordTypeSRV}
+		record.ParseValue("10 5 5060 sip.example.com.")
+		if record.Priority != 10 || record.Weight != 5 || record.Port != 5060 || record.Value != "sip.example.com." {
+			t.Fatal("parse SRV failed:", record)
+		}
+	}
+	{
+		record := &Record{Type: RecordTypeMX}
+		record.ParseValue("20 mail.example.com.")
+		if record.Priority != 20 || record.Value != "mail.example.com." {
+			t.Fatal("parse MX failed:", record)
+		}
+	}
+	{
+		// 格式不正确时保留原值
+		record := &Record{Type: RecordTypeMX}
+		record.ParseValue("mail.example.com.")
+		if record.Priority != 0 || record.Value != "mail.example.com." {
+			t.Fatal("parse MX failed:", record)
+		}
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package dnsclients
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"strings"
+)
+
+// MirrorRouteMapping 主服务商线路到镜像服务商线路的映射
+// 没有映射的线路会使用镜像服务商的默认线路
+type MirrorRouteMapping map[string]string // primary route => secondary route
+
+// MapRoute 转换线路
+func (this MirrorRouteMapping) MapRoute(route string, secondary ProviderInterface) string {
+	mappedRoute, ok := this[route]
+	if ok && len(mappedRoute) > 0 {
+		return mappedRoute
+	}
+	return secondary.DefaultRoute()
+}
+
+// MirrorChangeSet 将主服务商中执行的变更集转换为镜像服务商中需要执行的变更集
+// 镜像服务商中的记录ID和主服务商不同，所以修改和删除时需要根据记录内容重新查找
+// 多个主服务商线路可能映射到同一个镜像服务商线路，所以修改和删除前需要确认主服务商中没有其他记录仍然对应此镜像记录
+func MirrorChangeSet(primary ProviderInterface, secondary ProviderInterface, changeSet *dnstypes.ChangeSet, routeMapping MirrorRouteMapping) (*dnstypes.ChangeSet, error) {
+	mirrorChangeSet := &dnstypes.ChangeSet{
+		Domain:   changeSet.Domain,
+		IsMirror: true,
+	}
+	if changeSet.IsEmpty() {
+		return mirrorChangeSet, nil
+	}
+
+	records, err := secondary.GetRecords(changeSet.Domain)
+	if err != nil {
+		return nil, err
+	}
+
+	// 变更后主服务商中的记录对应的镜像记录，只在需要时读取
+	var expectedKeys map[string]bool
+	var isExpected = func(record *dnstypes.Record) (bool, error) {
+		if expectedKeys == nil {
+			primaryRecords, err := primary.GetRecords(changeSet.Domain)
+			if err != nil {
+				return false, err
+			}
+			expectedKeys = map[string]bool{}
+			for _, primaryRecord := range primaryRecords {
+				expectedKeys[mirrorRecordKey(mirrorRecord(primaryRecord, secondary, routeMapping))] = true
+			}
+		}
+		return expectedKeys[mirrorRecordKey(record)], nil
+	}
+
+	for _, change := range changeSet.Changes {
+		if !change.IsApplied || change.Record == nil {
+			continue
+		}
+		record := mirrorRecord(change.Record, secondary, routeMapping)
+		existRecord := findMirrorRecord(records, record)
+
+		switch change.Action {
+		case dnstypes.ChangeActionCreate:
+			if existRecord == nil {
+				mirrorChangeSet.AddCreate(record)
+				records = append(records, record)
+			}
+		case dnstypes.ChangeActionUpdate:
+			if change.NewRecord == nil {
+				continue
+			}
+			newRecord := mirrorRecord(change.NewRecord, secondary, routeMapping)
+			if existRecord != nil {
+				isShared, err := isExpected(existRecord)
+				if err != nil {
+					return nil, err
+				}
+				if !isShared {
+					mirrorChangeSet.AddUpdate(existRecord, newRecord)
+					continue
+				}
+			}
+			if findMirrorRecord(records, newRecord) == nil {
+				mirrorChangeSet.AddCreate(newRecord)
+				records = append(records, newRecord)
+			}
+		case dnstypes.ChangeActionDelete:
+			if existRecord == nil {
+				continue
+			}
+			isShared, err := isExpected(existRecord)
+			if err != nil {
+				return nil, err
+			}
+			if !isShared {
+				mirrorChangeSet.AddDelete(existRecord)
+			}
+		}
+	}
+
+	return mirrorChangeSet, nil
+}
+
+// DiffMirrorRecords 对比主服务商和镜像服务商中的记录，返回镜像服务商中需要执行的变更
+// 域名本身的NS记录由各自的服务商管理，不参与对比
+func DiffMirrorRecords(domain string, primaryRecords []*dnstypes.Record, secondary ProviderInterface, secondaryRecords []*dnstypes.Record, routeMapping MirrorRouteMapping) *dnstypes.ChangeSet {
+	changeSet := &dnstypes.ChangeSet{
+		Domain:   domain,
+		IsMirror: true,
+	}
+
+	var expectedKeys = map[string]bool{}
+	for _, record := range primaryRecords {
+		if isProviderOwnedRecord(record) {
+			continue
+		}
+		mirroredRecord := mirrorRecord(record, secondary, routeMapping)
+		key := mirrorRecordKey(mirroredRecord)
+		if expectedKeys[key] {
+			continue
+		}
+		expectedKeys[key] = true
+		if findMirrorRecord(secondaryRecords, mirroredRecord) == nil {
+			changeSet.AddCreate(mirroredRecord)
+		}
+	}
+
+	for _, record := range secondaryRecords {
+		if isProviderOwnedRecord(record) {
+			continue
+		}
+		if !expectedKeys[mirrorRecordKey(record)] {
+			changeSet.AddDelete(record)
+		}
+	}
+
+	return changeSet
+}
+
+// 生成镜像服务商中使用的记录
+func mirrorRecord(record *dnstypes.Record, secondary ProviderInterface, routeMapping MirrorRouteMapping) *dnstypes.Record {
+	var result = *record
+	result.Id = ""
+	result.Route = routeMapping.MapRoute(record.Route, secondary)
+	return &result
+}
+
+// 在记录列表中查找内容相同的记录
+func findMirrorRecord(records []*dnstypes.Record, record *dnstypes.Record) *dnstypes.Record {
+	key := mirrorRecordKey(record)
+	for _, r := range records {
+		if mirrorRecordKey(r) == key {
+			return r
+		}
+	}
+	return nil
+}
+
+// 记录对比用的标识，忽略ID、TTL和域名值末尾的点（.）符号
+func mirrorRecordKey(record *dnstypes.Record) string {
+	return record.Type + "|" + strings.ToLower(record.Name) + "|" + record.Route + "|" + strings.TrimSuffix(record.ComposeValue(), ".")
+}
+
+// 是否为服务商自己管理的记录
+func isProviderOwnedRecord(record *dnstypes.Record) bool {
+	return record.Type == dnstypes.RecordTypeNS && (record.Name == "@" || len(record.Name) == 0)
+}
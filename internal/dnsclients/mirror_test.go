@@ -0,0 +1,131 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package dnsclients
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/iwind/TeaGo/logs"
+	"testing"
+)
+
+func TestMirrorChangeSet(t *testing.T) {
+	primary := &testMemoryProvider{}
+	primary.records = []*dnstypes.Record{
+		{Id: "1", Name: "cdn", Type: dnstypes.RecordTypeA, Value: "192.168.1.1", Route: "telecom"},
+	}
+	secondary := &testMemoryProvider{}
+	secondary.records = []*dnstypes.Record{
+		{Id: "100", Name: "cdn", Type: dnstypes.RecordTypeA, Value: "192.168.1.1", Route: "ct"},
+	}
+	routeMapping := MirrorRouteMapping{"telecom": "ct"}
+
+	changeSet := &dnstypes.ChangeSet{Domain: "example.com"}
+	changeSet.AddDelete(primary.records[0])
+	changeSet.AddCreate(&dnstypes.Record{Name: "cdn", Type: dnstypes.RecordTypeA, Value: "192.168.1.2", Route: "telecom"})
+	changeSet.AddCreate(&dnstypes.Record{Name: "cdn", Type: dnstypes.RecordTypeA, Value: "192.168.1.3", Route: "unicom"})
+	err := ApplyChangeSet(primary, changeSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mirrorChangeSet, err := MirrorChangeSet(primary, secondary, changeSet, routeMapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ApplyChangeSet(secondary, mirrorChangeSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs.PrintAsJSON(secondary.records, t)
+
+	if len(secondary.records) != 2 {
+		t.Fatal("expected 2 records, but got", len(secondary.records))
+	}
+	if secondary.records[0].Value != "192.168.1.2" || secondary.records[0].Route != "ct" {
+		t.Fatal("route should be mapped")
+	}
+	if secondary.records[1].Route != "default" {
+		t.Fatal("unmapped route should use default route")
+	}
+
+	// 同步后应该一致
+	diffChangeSet := DiffMirrorRecords("example.com", primary.records, secondary, secondary.records, routeMapping)
+	if !diffChangeSet.IsEmpty() {
+		logs.PrintAsJSON(diffChangeSet, t)
+		t.Fatal("records should be consistent")
+	}
+}
+
+func TestMirrorChangeSet_SharedRoute(t *testing.T) {
+	// 两条主服务商线路都映射到镜像服务商的默认线路
+	primary := &testMemoryProvider{}
+	primary.records = []*dnstypes.Record{
+		{Id: "1", Name: "cdn", Type: dnstypes.RecordTypeA, Value: "192.168.1.1", Route: "telecom"},
+		{Id: "2", Name: "cdn", Type: dnstypes.RecordTypeA, Value: "192.168.1.1", Route: "unicom"},
+		{Id: "3", Name: "www", Type: dnstypes.RecordTypeA, Value: "192.168.1.2", Route: "telecom"},
+		{Id: "4", Name: "www", Type: dnstypes.RecordTypeA, Value: "192.168.1.2", Route: "unicom"},
+	}
+	secondary := &testMemoryProvider{}
+	secondary.records = []*dnstypes.Record{
+		{Id: "100", Name: "cdn", Type: dnstypes.RecordTypeA, Value: "192.168.1.1", Route: "default"},
+		{Id: "101", Name: "www", Type: dnstypes.RecordTypeA, Value: "192.168.1.2", Route: "default"},
+	}
+
+	changeSet := &dnstypes.ChangeSet{Domain: "example.com"}
+	changeSet.AddDelete(primary.records[0])
+	var newRecord = *primary.records[2]
+	newRecord.Value = "192.168.1.3"
+	changeSet.AddUpdate(primary.records[2], &newRecord)
+	err := ApplyChangeSet(primary, changeSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mirrorChangeSet, err := MirrorChangeSet(primary, secondary, changeSet, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = ApplyChangeSet(secondary, mirrorChangeSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs.PrintAsJSON(secondary.records, t)
+
+	// 仍然被unicom线路使用的记录不能被删除或修改
+	if len(secondary.records) != 3 {
+		t.Fatal("expected 3 records, but got", len(secondary.records))
+	}
+	diffChangeSet := DiffMirrorRecords("example.com", primary.records, secondary, secondary.records, nil)
+	if !diffChangeSet.IsEmpty() {
+		logs.PrintAsJSON(diffChangeSet, t)
+		t.Fatal("records should be consistent")
+	}
+}
+
+func TestDiffMirrorRecords(t *testing.T) {
+	secondary := &testMemoryProvider{}
+	primaryRecords := []*dnstypes.Record{
+		{Id: "1", Name: "@", Type: dnstypes.RecordTypeNS, Value: "ns1.primary.com.", Route: "default"},
+		{Id: "2", Name: "www", Type: dnstypes.RecordTypeCNAME, Value: "cdn.example.com.", Route: "default"},
+		{Id: "3", Name: "@", Type: dnstypes.RecordTypeMX, Value: "mail.example.com.", Priority: 10, Route: "default"},
+	}
+	secondaryRecords := []*dnstypes.Record{
+		{Id: "10", Name: "@", Type: dnstypes.RecordTypeNS, Value: "ns1.secondary.com", Route: "default"},
+		{Id: "20", Name: "www", Type: dnstypes.RecordTypeCNAME, Value: "cdn.example.com", Route: "default"},
+		{Id: "30", Name: "@", Type: dnstypes.RecordTypeMX, Value: "mail.example.com", Priority: 20, Route: "default"},
+		{Id: "40", Name: "old", Type: dnstypes.RecordTypeA, Value: "192.168.1.1", Route: "default"},
+	}
+	changeSet := DiffMirrorRecords("example.com", primaryRecords, secondary, secondaryRecords, nil)
+	logs.PrintAsJSON(changeSet, t)
+
+	var actions = []string{}
+	for _, change := range changeSet.Changes {
+		actions = append(actions, change.Action+":"+change.Record.Name+":"+change.Record.Type)
+	}
+	if len(actions) != 3 {
+		t.Fatal("expected 3 changes, but got", actions)
+	}
+	if actions[0] != "create:@:MX" || actions[1] != "delete:@:MX" || actions[2] != "delete:old:A" {
+		t.Fatal("unexpected changes:", actions)
+	}
+}
@@ -48,18 +48,24 @@ func (this *AliDNSProvider) GetRecords(domain string) (records []*dnstypes.Recor
 			return nil, err
 		}
 		for _, record := range resp.DomainRecords.Record {
-			// 修正Record
-			if record.Type == dnstypes.RecordTypeCNAME && !strings.HasSuffix(record.Value, ".") {
-				record.Value += "."
-			}
-
-			records = append(records, &dnstypes.Record{
+			dnsRecord := &dnstypes.Record{
 				Id:    record.RecordId,
 				Name:  record.RR,
 				Type:  record.Type,
-				Value: record.Value,
 				Route: record.Line,
-			})
+				TTL:   int32(record.TTL),
+			}
+			dnsRecord.ParseValue(record.Value)
+			if dnsRecord.Type == dnstypes.RecordTypeMX {
+				dnsRecord.Priority = int32(record.Priority)
+			}
+
+			// 修正Record
+			if dnsRecord.IsDomainValue() && !strings.HasSuffix(dnsRecord.Value, ".") {
+				dnsRecord.Value += "."
+			}
+
+			records = append(records, dnsRecord)
 		}
 
 		pageNumber++
@@ -109,9 +115,15 @@ func (this *AliDNSProvider) AddRecord(domain string, newRecord *dnstypes.Record)
 	req := alidns.CreateAddDomainRecordRequest()
 	req.RR = newRecord.Name
 	req.Type = newRecord.Type
-	req.Value = newRecord.Value
+	req.Value = this.composeValue(newRecord)
 	req.DomainName = domain
 	req.Line = newRecord.Route
+	if newRecord.TTL > 0 {
+		req.TTL = requests.NewInteger(int(newRecord.TTL))
+	}
+	if newRecord.Type == dnstypes.RecordTypeMX {
+		req.Priority = requests.NewInteger(int(newRecord.Priority))
+	}
 
 	resp := alidns.CreateAddDomainRecordResponse()
 	err := this.doAPI(req, resp)
@@ -131,8 +143,14 @@ func (this *AliDNSProvider) UpdateRecord(domain string, record *dnstypes.Record,
 	req.RecordId = record.Id
 	req.RR = newRecord.Name
 	req.Type = newRecord.Type
-	req.Value = newRecord.Value
+	req.Value = this.composeValue(newRecord)
 	req.Line = newRecord.Route
+	if newRecord.TTL > 0 {
+		req.TTL = requests.NewInteger(int(newRecord.TTL))
+	}
+	if newRecord.Type == dnstypes.RecordTypeMX {
+		req.Priority = requests.NewInteger(int(newRecord.Priority))
+	}
 
 	resp := alidns.CreateUpdateDomainRecordResponse()
 	err := this.doAPI(req, resp)
@@ -154,6 +172,15 @@ func (this *AliDNSProvider) DefaultRoute() string {
 	return "default"
 }
 
+// 组合记录值
+// MX记录的优先级需要单独设置，SRV记录的值格式为"priority weight port target"
+func (this *AliDNSProvider) composeValue(record *dnstypes.Record) string {
+	if record.Type == dnstypes.RecordTypeMX {
+		return record.Value
+	}
+	return record.ComposeValue()
+}
+
 // 执行请求
 func (this *AliDNSProvider) doAPI(req requests.AcsRequest, resp responses.AcsResponse) error {
 	req.SetScheme("https")
@@ -81,20 +81,7 @@ func (this *CloudFlareProvider) GetRecords(domain string) (records []*dnstypes.R
 		}
 
 		for _, record := range resp.Result {
-			// 修正Record
-			if record.Type == dnstypes.RecordTypeCNAME && !strings.HasSuffix(record.Content, ".") {
-				record.Content += "."
-			}
-
-			record.Name = strings.TrimSuffix(record.Name, "."+domain)
-
-			records = append(records, &dnstypes.Record{
-				Id:    record.Id,
-				Name:  record.Name,
-				Type:  record.Type,
-				Value: record.Content,
-				Route: CloudFlareDefaultRoute,
-			})
+			records = append(records, this.convertRecord(domain, record))
 		}
 	}
 
@@ -129,22 +116,7 @@ func (this *CloudFlareProvider) QueryRecord(domain string, name string, recordTy
 		return nil, nil
 	}
 
-	record := resp.Result[0]
-
-	// 修正Record
-	if record.Type == dnstypes.RecordTypeCNAME && !strings.HasSuffix(record.Content, ".") {
-		record.Content += "."
-	}
-
-	record.Name = strings.TrimSuffix(record.Name, "."+domain)
-
-	return &dnstypes.Record{
-		Id:    record.Id,
-		Name:  record.Name,
-		Type:  record.Type,
-		Value: record.Content,
-		Route: CloudFlareDefaultRoute,
-	}, nil
+	return this.convertRecord(domain, resp.Result[0]), nil
 }
 
 // AddRecord 设置记录
@@ -155,12 +127,7 @@ func (this *CloudFlareProvider) AddRecord(domain string, newRecord *dnstypes.Rec
 	}
 
 	resp := new(cloudflare.CreateDNSRecordResponse)
-	err = this.doAPI(http.MethodPost, "zones/"+zoneId+"/dns_records", nil, maps.Map{
-		"type":    newRecord.Type,
-		"name":    newRecord.Name + "." + domain,
-		"content": newRecord.Value,
-		"ttl":     1,
-	}, resp)
+	err = this.doAPI(http.MethodPost, "zones/"+zoneId+"/dns_records", nil, this.composeRecordParams(domain, newRecord), resp)
 	if err != nil {
 		return err
 	}
@@ -175,12 +142,7 @@ func (this *CloudFlareProvider) UpdateRecord(domain string, record *dnstypes.Rec
 	}
 
 	resp := new(cloudflare.UpdateDNSRecordResponse)
-	return this.doAPI(http.MethodPut, "zones/"+zoneId+"/dns_records/"+record.Id, nil, maps.Map{
-		"type":    newRecord.Type,
-		"name":    newRecord.Name + "." + domain,
-		"content": newRecord.Value,
-		"ttl":     1,
-	}, resp)
+	return this.doAPI(http.MethodPut, "zones/"+zoneId+"/dns_records/"+record.Id, nil, this.composeRecordParams(domain, newRecord), resp)
 }
 
 // DeleteRecord 删除记录
@@ -203,6 +165,99 @@ func (this *CloudFlareProvider) DefaultRoute() string {
 	return CloudFlareDefaultRoute
 }
 
+// 组合记录参数
+func (this *CloudFlareProvider) composeRecordParams(domain string, record *dnstypes.Record) maps.Map {
+	var ttl = 1 // 1表示自动
+	if record.TTL > 0 {
+		ttl = int(record.TTL)
+	}
+	var params = maps.Map{
+		"type":    record.Type,
+		"name":    record.Name + "." + domain,
+		"content": record.Value,
+		"ttl":     ttl,
+	}
+
+	switch record.Type {
+	case dnstypes.RecordTypeMX:
+		params["priority"] = record.Priority
+	case dnstypes.RecordTypeSRV:
+		// 记录名格式为 _service._proto[.name]
+		var pieces = strings.SplitN(record.Name, ".", 3)
+		var service, proto, name = "", "", domain
+		if len(pieces) > 0 {
+			service = pieces[0]
+		}
+		if len(pieces) > 1 {
+			proto = pieces[1]
+		}
+		if len(pieces) > 2 {
+			name = pieces[2] + "." + domain
+		}
+		params["data"] = maps.Map{
+			"service":  service,
+			"proto":    proto,
+			"name":     name,
+			"priority": record.Priority,
+			"weight":   record.Weight,
+			"port":     record.Port,
+			"target":   strings.TrimSuffix(record.Value, "."),
+		}
+		delete(params, "content")
+	case dnstypes.RecordTypeCAA:
+		// 记录值格式为 flags tag "value"
+		var pieces = strings.SplitN(strings.TrimSpace(record.Value), " ", 3)
+		if len(pieces) == 3 {
+			flags, err := strconv.Atoi(pieces[0])
+			if err == nil {
+				params["data"] = maps.Map{
+					"flags": flags,
+					"tag":   pieces[1],
+					"value": strings.Trim(strings.TrimSpace(pieces[2]), "\""),
+				}
+				delete(params, "content")
+			}
+		}
+	}
+
+	return params
+}
+
+// 转换记录
+func (this *CloudFlareProvider) convertRecord(domain string, record *cloudflare.DNSRecord) *dnstypes.Record {
+	var result = &dnstypes.Record{
+		Id:    record.Id,
+		Name:  strings.TrimSuffix(record.Name, "."+domain),
+		Type:  record.Type,
+		Value: record.Content,
+		Route: CloudFlareDefaultRoute,
+	}
+	if record.Ttl > 1 { // 1表示自动
+		result.TTL = int32(record.Ttl)
+	}
+
+	switch record.Type {
+	case dnstypes.RecordTypeMX:
+		result.Priority = int32(record.Priority)
+	case dnstypes.RecordTypeSRV:
+		result.Priority = int32(record.Data.Priority)
+		result.Weight = int32(record.Data.Weight)
+		result.Port = int32(record.Data.Port)
+		result.Value = record.Data.Target
+	case dnstypes.RecordTypeCAA:
+		if len(record.Data.Tag) > 0 {
+			result.Value = strconv.Itoa(record.Data.Flags) + " " + record.Data.Tag + " " + strconv.Quote(record.Data.Value)
+		}
+	}
+
+	// 修正Record
+	if result.IsDomainValue() && !strings.HasSuffix(result.Value, ".") {
+		result.Value += "."
+	}
+
+	return result
+}
+
 // 执行API
 func (this *CloudFlareProvider) doAPI(method string, apiPath string, args map[string]string, bodyMap maps.Map, respPtr cloudflare.ResponseInterface) error {
 	apiURL := CloudFlareAPIEndpoint + strings.TrimLeft(apiPath, "/")
@@ -4,6 +4,7 @@ package dnsclients
 
 import (
 	"encoding/json"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/cloudflare"
 	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
 	"github.com/TeaOSLab/EdgeAPI/internal/errors"
 	"github.com/iwind/TeaGo/dbs"
@@ -142,6 +143,54 @@ func TestCloudFlareProvider_DeleteRecord(t *testing.T) {
 	t.Log("ok")
 }
 
+func TestCloudFlareProvider_ComposeRecordParams(t *testing.T) {
+	var provider = &CloudFlareProvider{}
+
+	{
+		var params = provider.composeRecordParams("example.com", &dnstypes.Record{
+			Name:     "_sip._tcp",
+			Type:     dnstypes.RecordTypeSRV,
+			Value:    "sip.example.com.",
+			Priority: 10,
+			Weight:   5,
+			Port:     5060,
+		})
+		logs.PrintAsJSON(params, t)
+		var data = params.GetMap("data")
+		if data.GetString("service") != "_sip" || data.GetString("proto") != "_tcp" || data.GetInt("port") != 5060 || data.GetString("target") != "sip.example.com" {
+			t.Fatal("invalid SRV params")
+		}
+	}
+
+	{
+		var params = provider.composeRecordParams("example.com", &dnstypes.Record{
+			Name:  "www",
+			Type:  dnstypes.RecordTypeCAA,
+			Value: `0 issue "letsencrypt.org"`,
+		})
+		logs.PrintAsJSON(params, t)
+		if params.Has("content") {
+			t.Fatal("CAA params should not contain 'content'")
+		}
+		var data = params.GetMap("data")
+		if data.GetInt("flags") != 0 || data.GetString("tag") != "issue" || data.GetString("value") != "letsencrypt.org" {
+			t.Fatal("invalid CAA params")
+		}
+
+		// 读取记录时再转换回来
+		var record = &cloudflare.DNSRecord{
+			Name: "www.example.com",
+			Type: dnstypes.RecordTypeCAA,
+		}
+		record.Data.Tag = data.GetString("tag")
+		record.Data.Value = data.GetString("value")
+		var result = provider.convertRecord("example.com", record)
+		if result.Value != `0 issue "letsencrypt.org"` {
+			t.Fatal("invalid CAA record value:", result.Value)
+		}
+	}
+}
+
 func testCloudFlareProvider() (ProviderInterface, error) {
 	db, err := dbs.Default()
 	if err != nil {
@@ -54,13 +54,18 @@ func (this *DNSPodProvider) GetRecords(domain string) (records []*dnstypes.Recor
 		recordSlice := recordsResp.GetSlice("records")
 		for _, record := range recordSlice {
 			recordMap := maps.NewMap(record)
-			records = append(records, &dnstypes.Record{
+			dnsRecord := &dnstypes.Record{
 				Id:    recordMap.GetString("id"),
 				Name:  recordMap.GetString("name"),
 				Type:  recordMap.GetString("type"),
-				Value: recordMap.GetString("value"),
 				Route: recordMap.GetString("line"),
-			})
+				TTL:   recordMap.GetInt32("ttl"),
+			}
+			dnsRecord.ParseValue(recordMap.GetString("value"))
+			if dnsRecord.Type == dnstypes.RecordTypeMX {
+				dnsRecord.Priority = recordMap.GetInt32("mx")
+			}
+			records = append(records, dnsRecord)
 		}
 
 		// 检查是否到头
@@ -127,17 +132,9 @@ func (this *DNSPodProvider) AddRecord(domain string, newRecord *dnstypes.Record)
 		return errors.New("invalid new record")
 	}
 
-	// 在CHANGE记录后面加入点
-	if newRecord.Type == dnstypes.RecordTypeCNAME && !strings.HasSuffix(newRecord.Value, ".") {
-		newRecord.Value += "."
-	}
-	_, err := this.post("/Record.Create", map[string]string{
-		"domain":      domain,
-		"sub_domain":  newRecord.Name,
-		"record_type": newRecord.Type,
-		"value":       newRecord.Value,
-		"record_line": newRecord.Route,
-	})
+	_, err := this.post("/Record.Create", this.composeRecordParams(map[string]string{
+		"domain": domain,
+	}, newRecord))
 	return err
 }
 
@@ -150,18 +147,10 @@ func (this *DNSPodProvider) UpdateRecord(domain string, record *dnstypes.Record,
 		return errors.New("invalid new record")
 	}
 
-	// 在CHANGE记录后面加入点
-	if newRecord.Type == dnstypes.RecordTypeCNAME && !strings.HasSuffix(newRecord.Value, ".") {
-		newRecord.Value += "."
-	}
-	_, err := this.post("/Record.Modify", map[string]string{
-		"domain":      domain,
-		"record_id":   record.Id,
-		"sub_domain":  newRecord.Name,
-		"record_type": newRecord.Type,
-		"value":       newRecord.Value,
-		"record_line": newRecord.Route,
-	})
+	_, err := this.post("/Record.Modify", this.composeRecordParams(map[string]string{
+		"domain":    domain,
+		"record_id": record.Id,
+	}, newRecord))
 	return err
 }
 
@@ -179,6 +168,30 @@ func (this *DNSPodProvider) DeleteRecord(domain string, record *dnstypes.Record)
 	return err
 }
 
+// 组合记录参数
+func (this *DNSPodProvider) composeRecordParams(params map[string]string, newRecord *dnstypes.Record) map[string]string {
+	// 在域名类型的记录值后面加入点
+	if newRecord.IsDomainValue() && !strings.HasSuffix(newRecord.Value, ".") {
+		newRecord.Value += "."
+	}
+
+	params["sub_domain"] = newRecord.Name
+	params["record_type"] = newRecord.Type
+	params["record_line"] = newRecord.Route
+	if newRecord.TTL > 0 {
+		params["ttl"] = types.String(newRecord.TTL)
+	}
+
+	switch newRecord.Type {
+	case dnstypes.RecordTypeMX:
+		params["value"] = newRecord.Value
+		params["mx"] = types.String(newRecord.Priority)
+	default:
+		params["value"] = newRecord.ComposeValue()
+	}
+	return params
+}
+
 // 发送请求
 func (this *DNSPodProvider) post(path string, params map[string]string) (maps.Map, error) {
 	apiHost := "https://dnsapi.cn"
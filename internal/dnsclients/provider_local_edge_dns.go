@@ -56,23 +56,7 @@ func (this *LocalEdgeDNSProvider) GetRecords(domain string) (records []*dnstypes
 			break
 		}
 		for _, record := range result {
-			if record.Type == dnstypes.RecordTypeCNAME && !strings.HasSuffix(record.Value, ".") {
-				record.Value += "."
-			}
-
-			routeIds := record.DecodeRouteIds()
-			var routeIdString = ""
-			if len(routeIds) > 0 {
-				routeIdString = fmt.Sprintf("%d", routeIds[0])
-			}
-
-			records = append(records, &dnstypes.Record{
-				Id:    fmt.Sprintf("%d", record.Id),
-				Name:  record.Name,
-				Type:  record.Type,
-				Value: record.Value,
-				Route: routeIdString,
-			})
+			records = append(records, this.convertRecord(record))
 		}
 
 		offset += size
@@ -126,19 +110,7 @@ func (this *LocalEdgeDNSProvider) QueryRecord(domain string, name string, record
 		return nil, nil
 	}
 
-	routeIds := record.DecodeRouteIds()
-	var routeIdString = ""
-	if len(routeIds) > 0 {
-		routeIdString = fmt.Sprintf("%d", routeIds[0])
-	}
-
-	return &dnstypes.Record{
-		Id:    fmt.Sprintf("%d", record.Id),
-		Name:  record.Name,
-		Type:  record.Type,
-		Value: record.Value,
-		Route: routeIdString,
-	}, nil
+	return this.convertRecord(record), nil
 }
 
 // AddRecord 设置记录
@@ -160,7 +132,7 @@ func (this *LocalEdgeDNSProvider) AddRecord(domain string, newRecord *dnstypes.R
 		}
 	}
 
-	_, err = nameservers.SharedNSRecordDAO.CreateRecord(tx, domainId, "", newRecord.Name, newRecord.Type, newRecord.Value, this.ttl, routeIds)
+	_, err = nameservers.SharedNSRecordDAO.CreateRecord(tx, domainId, "", newRecord.Name, newRecord.Type, newRecord.ComposeValue(), this.recordTTL(newRecord), routeIds)
 	if err != nil {
 		return err
 	}
@@ -188,7 +160,7 @@ func (this *LocalEdgeDNSProvider) UpdateRecord(domain string, record *dnstypes.R
 	}
 
 	if len(record.Id) > 0 {
-		err = nameservers.SharedNSRecordDAO.UpdateRecord(tx, types.Int64(record.Id), "", newRecord.Name, newRecord.Type, newRecord.Value, this.ttl, routeIds)
+		err = nameservers.SharedNSRecordDAO.UpdateRecord(tx, types.Int64(record.Id), "", newRecord.Name, newRecord.Type, newRecord.ComposeValue(), this.recordTTL(newRecord), routeIds)
 		if err != nil {
 			return err
 		}
@@ -198,7 +170,7 @@ func (this *LocalEdgeDNSProvider) UpdateRecord(domain string, record *dnstypes.R
 			return err
 		}
 		if realRecord != nil {
-			err = nameservers.SharedNSRecordDAO.UpdateRecord(tx, types.Int64(realRecord.Id), "", newRecord.Name, newRecord.Type, newRecord.Value, this.ttl, routeIds)
+			err = nameservers.SharedNSRecordDAO.UpdateRecord(tx, types.Int64(realRecord.Id), "", newRecord.Name, newRecord.Type, newRecord.ComposeValue(), this.recordTTL(newRecord), routeIds)
 			if err != nil {
 				return err
 			}
@@ -244,3 +216,33 @@ func (this *LocalEdgeDNSProvider) DeleteRecord(domain string, record *dnstypes.R
 func (this *LocalEdgeDNSProvider) DefaultRoute() string {
 	return ""
 }
+
+// 转换记录
+func (this *LocalEdgeDNSProvider) convertRecord(record *nameservers.NSRecord) *dnstypes.Record {
+	routeIds := record.DecodeRouteIds()
+	var routeIdString = ""
+	if len(routeIds) > 0 {
+		routeIdString = fmt.Sprintf("%d", routeIds[0])
+	}
+
+	var result = &dnstypes.Record{
+		Id:    fmt.Sprintf("%d", record.Id),
+		Name:  record.Name,
+		Type:  record.Type,
+		Route: routeIdString,
+		TTL:   int32(record.Ttl),
+	}
+	result.ParseValue(record.Value)
+	if result.IsDomainValue() && !strings.HasSuffix(result.Value, ".") {
+		result.Value += "."
+	}
+	return result
+}
+
+// 记录的TTL
+func (this *LocalEdgeDNSProvider) recordTTL(record *dnstypes.Record) int32 {
+	if record.TTL > 0 {
+		return record.TTL
+	}
+	return this.ttl
+}
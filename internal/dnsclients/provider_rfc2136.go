@@ -0,0 +1,425 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package dnsclients
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/iwind/TeaGo/maps"
+	"github.com/iwind/TeaGo/types"
+	"github.com/miekg/dns"
+	"net"
+	"strings"
+	"time"
+)
+
+const RFC2136DefaultRoute = "default"
+
+// RFC2136Provider 通过RFC 2136动态更新协议管理的DNS服务（BIND、Knot、PowerDNS等）
+// 记录修改使用带有TSIG签名的UPDATE消息，记录读取使用AXFR
+type RFC2136Provider struct {
+	BaseProvider
+
+	server    string // 服务器地址，格式为 host:port
+	keyName   string // TSIG密钥名称
+	secret    string // TSIG密钥，Base64编码
+	algorithm string // TSIG算法
+	ttl       uint32 // 新记录的TTL
+}
+
+// Auth 认证
+// 参数：
+//   - server 服务器地址
+//   - keyName TSIG密钥名称
+//   - secret TSIG密钥
+//   - algorithm TSIG算法，默认为hmac-sha256
+//   - ttl 记录TTL，默认为600
+func (this *RFC2136Provider) Auth(params maps.Map) error {
+	this.server = params.GetString("server")
+	if len(this.server) == 0 {
+		return errors.New("'server' should not be empty")
+	}
+	_, _, err := net.SplitHostPort(this.server)
+	if err != nil {
+		this.server = net.JoinHostPort(this.server, "53")
+	}
+
+	this.keyName = params.GetString("keyName")
+	if len(this.keyName) == 0 {
+		return errors.New("'keyName' should not be empty")
+	}
+	this.keyName = dns.Fqdn(strings.ToLower(this.keyName))
+
+	this.secret = params.GetString("secret")
+	if len(this.secret) == 0 {
+		return errors.New("'secret' should not be empty")
+	}
+
+	algorithm, ok := rfc2136Algorithms[strings.ToLower(strings.TrimSuffix(params.GetString("algorithm"), "."))]
+	if !ok {
+		return errors.New("unsupported tsig algorithm '" + params.GetString("algorithm") + "'")
+	}
+	this.algorithm = algorithm
+
+	this.ttl = 600
+	ttl := params.GetInt("ttl")
+	if ttl > 0 {
+		this.ttl = uint32(ttl)
+	}
+
+	return nil
+}
+
+// GetRecords 获取域名解析记录列表
+func (this *RFC2136Provider) GetRecords(domain string) (records []*dnstypes.Record, err error) {
+	zone := dns.Fqdn(domain)
+
+	msg := new(dns.Msg)
+	msg.SetAxfr(zone)
+	msg.SetTsig(this.keyName, this.algorithm, 300, time.Now().Unix())
+
+	transfer := &dns.Transfer{
+		DialTimeout:  10 * time.Second,
+		ReadTimeout:  30 * time.Second,
+		WriteTimeout: 10 * time.Second,
+		TsigSecret:   map[string]string{this.keyName: this.secret},
+	}
+	envelopes, err := transfer.In(msg, this.server)
+	if err != nil {
+		return nil, err
+	}
+	for envelope := range envelopes {
+		if envelope.Error != nil {
+			return nil, envelope.Error
+		}
+		for _, rr := range envelope.RR {
+			record := this.convertRR(zone, rr)
+			if record == nil {
+				continue
+			}
+
+			// 区域传输时SOA会在首尾出现两次
+			if record.Type == "SOA" {
+				continue
+			}
+			records = append(records, record)
+		}
+	}
+	return
+}
+
+// GetRoutes 读取域名支持的线路数据
+func (this *RFC2136Provider) GetRoutes(domain string) (routes []*dnstypes.Route, err error) {
+	routes = []*dnstypes.Route{
+		{Name: "默认", Code: RFC2136DefaultRoute},
+	}
+	return
+}
+
+// QueryRecord 查询单个记录
+func (this *RFC2136Provider) QueryRecord(domain string, name string, recordType dnstypes.RecordType) (*dnstypes.Record, error) {
+	records, err := this.GetRecords(domain)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		if record.Name == name && record.Type == recordType {
+			return record, nil
+		}
+	}
+	return nil, nil
+}
+
+// AddRecord 设置记录
+func (this *RFC2136Provider) AddRecord(domain string, newRecord *dnstypes.Record) error {
+	if newRecord == nil {
+		return errors.New("invalid new record")
+	}
+
+	zone := dns.Fqdn(domain)
+	rr, err := this.composeRR(zone, newRecord)
+	if err != nil {
+		return err
+	}
+
+	msg := new(dns.Msg)
+	msg.SetUpdate(zone)
+	msg.Insert([]dns.RR{rr})
+	return this.exchange(msg)
+}
+
+// UpdateRecord 修改记录
+// 删除旧记录和添加新记录在同一个UPDATE消息中完成，服务器会保证其原子性
+func (this *RFC2136Provider) UpdateRecord(domain string, record *dnstypes.Record, newRecord *dnstypes.Record) error {
+	if record == nil {
+		return errors.New("invalid record")
+	}
+	if newRecord == nil {
+		return errors.New("invalid new record")
+	}
+
+	zone := dns.Fqdn(domain)
+	msg := new(dns.Msg)
+	msg.SetUpdate(zone)
+
+	err := this.composeRemove(zone, msg, record)
+	if err != nil {
+		return err
+	}
+
+	newRR, err := this.composeRR(zone, newRecord)
+	if err != nil {
+		return err
+	}
+	msg.Insert([]dns.RR{newRR})
+
+	return this.exchange(msg)
+}
+
+// DeleteRecord 删除记录
+func (this *RFC2136Provider) DeleteRecord(domain string, record *dnstypes.Record) error {
+	if record == nil {
+		return errors.New("invalid record to delete")
+	}
+
+	zone := dns.Fqdn(domain)
+	msg := new(dns.Msg)
+	msg.SetUpdate(zone)
+
+	err := this.composeRemove(zone, msg, record)
+	if err != nil {
+		return err
+	}
+
+	return this.exchange(msg)
+}
+
+// DefaultRoute 默认线路
+func (this *RFC2136Provider) DefaultRoute() string {
+	return RFC2136DefaultRoute
+}
+
+// ApplyChanges 在同一个UPDATE消息中执行所有变更
+func (this *RFC2136Provider) ApplyChanges(domain string, changes []*dnstypes.Change) error {
+	zone := dns.Fqdn(domain)
+	msg := new(dns.Msg)
+	msg.SetUpdate(zone)
+
+	for _, change := range changes {
+		switch change.Action {
+		case dnstypes.ChangeActionCreate:
+			rr, err := this.composeRR(zone, change.Record)
+			if err != nil {
+				return err
+			}
+			msg.Insert([]dns.RR{rr})
+		case dnstypes.ChangeActionUpdate:
+			err := this.composeRemove(zone, msg, change.Record)
+			if err != nil {
+				return err
+			}
+			rr, err := this.composeRR(zone, change.NewRecord)
+			if err != nil {
+				return err
+			}
+			msg.Insert([]dns.RR{rr})
+		case dnstypes.ChangeActionDelete:
+			err := this.composeRemove(zone, msg, change.Record)
+			if err != nil {
+				return err
+			}
+		default:
+			return errors.New("invalid change action '" + change.Action + "'")
+		}
+	}
+
+	return this.exchange(msg)
+}
+
+// 发送UPDATE消息
+func (this *RFC2136Provider) exchange(msg *dns.Msg) error {
+	msg.SetTsig(this.keyName, this.algorithm, 300, time.Now().Unix())
+
+	client := &dns.Client{
+		Net:        "tcp",
+		Timeout:    10 * time.Second,
+		TsigSecret: map[string]string{this.keyName: this.secret},
+	}
+	resp, _, err := client.Exchange(msg, this.server)
+	if err != nil {
+		return err
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return errors.New("update failed: " + dns.RcodeToString[resp.Rcode])
+	}
+	return nil
+}
+
+// 在UPDATE消息中加入删除记录的指令
+// 如果记录没有值，则删除同名同类型的所有记录
+func (this *RFC2136Provider) composeRemove(zone string, msg *dns.Msg, record *dnstypes.Record) error {
+	if len(record.Value) == 0 {
+		rrType, ok := dns.StringToType[strings.ToUpper(record.Type)]
+		if !ok {
+			return errors.New("unsupported record type '" + record.Type + "'")
+		}
+		msg.RemoveRRset([]dns.RR{&dns.ANY{
+			Hdr: dns.RR_Header{
+				Name:   this.fqdn(zone, record.Name),
+				Rrtype: rrType,
+				Class:  dns.ClassINET,
+			},
+		}})
+		return nil
+	}
+
+	rr, err := this.composeRR(zone, record)
+	if err != nil {
+		return err
+	}
+	msg.Remove([]dns.RR{rr})
+	return nil
+}
+
+// 将记录转换为资源记录
+func (this *RFC2136Provider) composeRR(zone string, record *dnstypes.Record) (dns.RR, error) {
+	var recordType = strings.ToUpper(record.Type)
+	var header = dns.RR_Header{
+		Name:  this.fqdn(zone, record.Name),
+		Class: dns.ClassINET,
+		Ttl:   this.ttl,
+	}
+	if record.TTL > 0 {
+		header.Ttl = uint32(record.TTL)
+	}
+
+	switch recordType {
+	case dnstypes.RecordTypeA:
+		ip := net.ParseIP(record.Value).To4()
+		if ip == nil {
+			return nil, errors.New("invalid A record value '" + record.Value + "'")
+		}
+		header.Rrtype = dns.TypeA
+		return &dns.A{Hdr: header, A: ip}, nil
+	case dnstypes.RecordTypeAAAA:
+		ip := net.ParseIP(record.Value)
+		if ip == nil || ip.To4() != nil {
+			return nil, errors.New("invalid AAAA record value '" + record.Value + "'")
+		}
+		header.Rrtype = dns.TypeAAAA
+		return &dns.AAAA{Hdr: header, AAAA: ip}, nil
+	case dnstypes.RecordTypeCNAME:
+		header.Rrtype = dns.TypeCNAME
+		return &dns.CNAME{Hdr: header, Target: dns.Fqdn(record.Value)}, nil
+	case dnstypes.RecordTypeTXT:
+		header.Rrtype = dns.TypeTXT
+		return &dns.TXT{Hdr: header, Txt: this.splitTXT(record.Value)}, nil
+	case dnstypes.RecordTypeMX:
+		header.Rrtype = dns.TypeMX
+		return &dns.MX{Hdr: header, Preference: uint16(record.Priority), Mx: dns.Fqdn(record.Value)}, nil
+	case dnstypes.RecordTypeSRV:
+		header.Rrtype = dns.TypeSRV
+		return &dns.SRV{
+			Hdr:      header,
+			Priority: uint16(record.Priority),
+			Weight:   uint16(record.Weight),
+			Port:     uint16(record.Port),
+			Target:   dns.Fqdn(record.Value),
+		}, nil
+	case dnstypes.RecordTypeNS:
+		header.Rrtype = dns.TypeNS
+		return &dns.NS{Hdr: header, Ns: dns.Fqdn(record.Value)}, nil
+	}
+
+	// 其他类型（比如CAA）使用区域文件格式解析
+	rr, err := dns.NewRR(header.Name + " " + types.String(header.Ttl) + " IN " + recordType + " " + record.Value)
+	if err != nil {
+		return nil, errors.New("invalid " + recordType + " record value '" + record.Value + "': " + err.Error())
+	}
+	if rr == nil {
+		return nil, errors.New("invalid " + recordType + " record value '" + record.Value + "'")
+	}
+	return rr, nil
+}
+
+// 将资源记录转换为记录
+func (this *RFC2136Provider) convertRR(zone string, rr dns.RR) *dnstypes.Record {
+	header := rr.Header()
+	if header.Class != dns.ClassINET {
+		return nil
+	}
+
+	var record = &dnstypes.Record{
+		Id:    "",
+		Name:  this.relativeName(zone, header.Name),
+		Type:  dns.TypeToString[header.Rrtype],
+		Route: RFC2136DefaultRoute,
+		TTL:   int32(header.Ttl),
+	}
+
+	switch v := rr.(type) {
+	case *dns.A:
+		record.Value = v.A.String()
+	case *dns.AAAA:
+		record.Value = v.AAAA.String()
+	case *dns.CNAME:
+		record.Value = v.Target
+	case *dns.TXT:
+		record.Value = strings.Join(v.Txt, "")
+	case *dns.MX:
+		record.Value = v.Mx
+		record.Priority = int32(v.Preference)
+	case *dns.SRV:
+		record.Value = v.Target
+		record.Priority = int32(v.Priority)
+		record.Weight = int32(v.Weight)
+		record.Port = int32(v.Port)
+	case *dns.NS:
+		record.Value = v.Ns
+	default:
+		record.Value = strings.TrimSpace(strings.TrimPrefix(rr.String(), header.String()))
+	}
+
+	return record
+}
+
+// 组合完整的域名
+func (this *RFC2136Provider) fqdn(zone string, name string) string {
+	if len(name) == 0 || name == "@" {
+		return zone
+	}
+	return dns.Fqdn(name + "." + strings.TrimSuffix(zone, "."))
+}
+
+// 从完整域名中取得子域名
+func (this *RFC2136Provider) relativeName(zone string, name string) string {
+	name = strings.ToLower(name)
+	zone = strings.ToLower(zone)
+	if name == zone {
+		return "@"
+	}
+	return strings.TrimSuffix(name, "."+zone)
+}
+
+// TXT记录中单个字符串最长为255个字节
+func (this *RFC2136Provider) splitTXT(value string) []string {
+	var result = []string{}
+	for len(value) > 255 {
+		result = append(result, value[:255])
+		value = value[255:]
+	}
+	result = append(result, value)
+	return result
+}
+
+// 支持的TSIG算法
+var rfc2136Algorithms = map[string]string{
+	"":                         dns.HmacSHA256,
+	"hmac-md5":                 dns.HmacMD5,
+	"hmac-md5.sig-alg.reg.int": dns.HmacMD5,
+	"hmac-sha1":                dns.HmacSHA1,
+	"hmac-sha224":              dns.HmacSHA224,
+	"hmac-sha256":              dns.HmacSHA256,
+	"hmac-sha384":              dns.HmacSHA384,
+	"hmac-sha512":              dns.HmacSHA512,
+}
@@ -0,0 +1,328 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package dnsclients
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/iwind/TeaGo/logs"
+	"github.com/iwind/TeaGo/maps"
+	"github.com/miekg/dns"
+	"net"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testRFC2136KeyName = "edge-key."
+const testRFC2136Secret = "c2VjcmV0LWtleS1mb3ItdGVzdGluZw=="
+
+func TestRFC2136Provider_Records(t *testing.T) {
+	server := newTestRFC2136Server(t, "example.com.")
+	defer server.Stop()
+
+	provider := &RFC2136Provider{}
+	err := provider.Auth(maps.Map{
+		"server":    server.Addr(),
+		"keyName":   testRFC2136KeyName,
+		"secret":    testRFC2136Secret,
+		"algorithm": "hmac-sha256",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = provider.AddRecord("example.com", &dnstypes.Record{
+		Name:  "www",
+		Type:  dnstypes.RecordTypeA,
+		Value: "192.168.1.100",
+		Route: provider.DefaultRoute(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = provider.AddRecord("example.com", &dnstypes.Record{
+		Name:  "cdn",
+		Type:  dnstypes.RecordTypeCNAME,
+		Value: "www.example.com",
+		Route: provider.DefaultRoute(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := provider.GetRecords("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs.PrintAsJSON(records, t)
+	if len(records) != 2 {
+		t.Fatal("expected 2 records, but got", len(records))
+	}
+
+	record, err := provider.QueryRecord("example.com", "www", dnstypes.RecordTypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record == nil || record.Value != "192.168.1.100" {
+		t.Fatal("query record failed")
+	}
+
+	err = provider.UpdateRecord("example.com", record, &dnstypes.Record{
+		Name:  "www",
+		Type:  dnstypes.RecordTypeA,
+		Value: "192.168.1.101",
+		Route: provider.DefaultRoute(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err = provider.QueryRecord("example.com", "www", dnstypes.RecordTypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record == nil || record.Value != "192.168.1.101" {
+		t.Fatal("update record failed")
+	}
+
+	err = provider.DeleteRecord("example.com", record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, err = provider.QueryRecord("example.com", "www", dnstypes.RecordTypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record != nil {
+		t.Fatal("delete record failed")
+	}
+}
+
+func TestRFC2136Provider_RecordTypes(t *testing.T) {
+	server := newTestRFC2136Server(t, "example.com.")
+	defer server.Stop()
+
+	provider := &RFC2136Provider{}
+	err := provider.Auth(maps.Map{
+		"server":  server.Addr(),
+		"keyName": testRFC2136KeyName,
+		"secret":  testRFC2136Secret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, record := range []*dnstypes.Record{
+		{Name: "@", Type: dnstypes.RecordTypeMX, Value: "mail.example.com.", Priority: 10, TTL: 300},
+		{Name: "_sip._tcp", Type: dnstypes.RecordTypeSRV, Value: "sip.example.com.", Priority: 10, Weight: 5, Port: 5060},
+		{Name: "@", Type: dnstypes.RecordTypeCAA, Value: `0 issue "letsencrypt.org"`},
+	} {
+		err = provider.AddRecord("example.com", record)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	records, err := provider.GetRecords("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs.PrintAsJSON(records, t)
+	if len(records) != 3 {
+		t.Fatal("expected 3 records, but got", len(records))
+	}
+	if records[0].Priority != 10 || records[0].TTL != 300 {
+		t.Fatal("invalid MX record")
+	}
+	if records[1].Port != 5060 || records[1].Value != "sip.example.com." {
+		t.Fatal("invalid SRV record")
+	}
+	if records[2].Value != `0 issue "letsencrypt.org"` {
+		t.Fatal("invalid CAA record")
+	}
+}
+
+func TestRFC2136Provider_ApplyChanges(t *testing.T) {
+	server := newTestRFC2136Server(t, "example.com.")
+	defer server.Stop()
+
+	provider := &RFC2136Provider{}
+	err := provider.Auth(maps.Map{
+		"server":  server.Addr(),
+		"keyName": testRFC2136KeyName,
+		"secret":  testRFC2136Secret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = provider.AddRecord("example.com", &dnstypes.Record{Name: "cdn", Type: dnstypes.RecordTypeA, Value: "192.168.1.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changeSet := &dnstypes.ChangeSet{Domain: "example.com"}
+	changeSet.AddDelete(&dnstypes.Record{Name: "cdn", Type: dnstypes.RecordTypeA, Value: "192.168.1.1"})
+	changeSet.AddCreate(&dnstypes.Record{Name: "cdn", Type: dnstypes.RecordTypeA, Value: "192.168.1.2"})
+	changeSet.AddCreate(&dnstypes.Record{Name: "cdn", Type: dnstypes.RecordTypeA, Value: "192.168.1.3"})
+	err = ApplyChangeSet(provider, changeSet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changeSet.IsBatch {
+		t.Fatal("change set should be applied in batch")
+	}
+
+	records, err := provider.GetRecords("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs.PrintAsJSON(records, t)
+	if len(records) != 2 {
+		t.Fatal("expected 2 records, but got", len(records))
+	}
+}
+
+func TestRFC2136Provider_BadKey(t *testing.T) {
+	server := newTestRFC2136Server(t, "example.com.")
+	defer server.Stop()
+
+	provider := &RFC2136Provider{}
+	err := provider.Auth(maps.Map{
+		"server":  server.Addr(),
+		"keyName": testRFC2136KeyName,
+		"secret":  "d3Jvbmcta2V5",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = provider.AddRecord("example.com", &dnstypes.Record{
+		Name:  "www",
+		Type:  dnstypes.RecordTypeA,
+		Value: "192.168.1.100",
+	})
+	if err == nil {
+		t.Fatal("update with wrong key should fail")
+	}
+	t.Log("expected error:", err)
+}
+
+// 用来测试的DNS服务器，只支持UPDATE和AXFR
+type testRFC2136Server struct {
+	zone     string
+	listener net.Listener
+	server   *dns.Server
+
+	rrs    []dns.RR
+	locker sync.Mutex
+}
+
+func newTestRFC2136Server(t *testing.T, zone string) *testRFC2136Server {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &testRFC2136Server{
+		zone:     zone,
+		listener: listener,
+	}
+	s.server = &dns.Server{
+		Listener:   listener,
+		Handler:    s,
+		TsigSecret: map[string]string{testRFC2136KeyName: testRFC2136Secret},
+		MsgAcceptFunc: func(dh dns.Header) dns.MsgAcceptAction {
+			return dns.MsgAccept // 默认的MsgAcceptFunc会拒绝UPDATE消息
+		},
+	}
+	go func() {
+		_ = s.server.ActivateAndServe()
+	}()
+	time.Sleep(100 * time.Millisecond)
+	return s
+}
+
+func (this *testRFC2136Server) Addr() string {
+	return this.listener.Addr().String()
+}
+
+func (this *testRFC2136Server) Stop() {
+	_ = this.server.Shutdown()
+}
+
+func (this *testRFC2136Server) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+
+	if req.IsTsig() == nil || w.TsigStatus() != nil {
+		resp.SetRcode(req, dns.RcodeNotAuth)
+		this.write(w, req, resp)
+		return
+	}
+
+	if req.Opcode == dns.OpcodeUpdate {
+		this.locker.Lock()
+		for _, rr := range req.Ns {
+			switch rr.Header().Class {
+			case dns.ClassINET:
+				this.rrs = append(this.rrs, rr)
+			case dns.ClassNONE, dns.ClassANY:
+				this.remove(rr)
+			}
+		}
+		this.locker.Unlock()
+		this.write(w, req, resp)
+		return
+	}
+
+	if len(req.Question) > 0 && req.Question[0].Qtype == dns.TypeAXFR {
+		soa := &dns.SOA{
+			Hdr:     dns.RR_Header{Name: this.zone, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: 3600},
+			Ns:      "ns1." + this.zone,
+			Mbox:    "admin." + this.zone,
+			Serial:  1,
+			Refresh: 3600,
+			Retry:   600,
+			Expire:  86400,
+			Minttl:  600,
+		}
+		this.locker.Lock()
+		rrs := append([]dns.RR{soa}, this.rrs...)
+		this.locker.Unlock()
+		rrs = append(rrs, soa)
+
+		resp.Answer = rrs
+		this.write(w, req, resp)
+		return
+	}
+
+	resp.SetRcode(req, dns.RcodeNotImplemented)
+	this.write(w, req, resp)
+}
+
+func (this *testRFC2136Server) remove(rr dns.RR) {
+	var header = rr.Header()
+	var result = []dns.RR{}
+	for _, existRR := range this.rrs {
+		existHeader := existRR.Header()
+		if existHeader.Name == header.Name && existHeader.Rrtype == header.Rrtype {
+			// ClassANY删除整个记录集，ClassNONE只删除值相同的记录
+			if header.Class == dns.ClassANY {
+				continue
+			}
+			copyRR := dns.Copy(rr)
+			copyRR.Header().Class = dns.ClassINET
+			copyRR.Header().Ttl = existHeader.Ttl
+			if dns.IsDuplicate(copyRR, existRR) {
+				continue
+			}
+		}
+		result = append(result, existRR)
+	}
+	this.rrs = result
+}
+
+func (this *testRFC2136Server) write(w dns.ResponseWriter, req *dns.Msg, resp *dns.Msg) {
+	if req.IsTsig() != nil && w.TsigStatus() == nil {
+		resp.SetTsig(req.IsTsig().Hdr.Name, req.IsTsig().Algorithm, 300, time.Now().Unix())
+	}
+	_ = w.WriteMsg(resp)
+}
@@ -3,57 +3,438 @@
 package dnsclients
 
 import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	teaconst "github.com/TeaOSLab/EdgeAPI/internal/const"
 	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
 	"github.com/iwind/TeaGo/maps"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
+var userEdgeDNSHTTPClient = &http.Client{
+	Timeout: 10 * time.Second,
+}
+
+var userEdgeDNSInsecureHTTPClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: true,
+		},
+	},
+}
+
+// UserEdgeDNSProvider 通过API连接的EdgeDNS
+// 使用用户的AccessKey调用另外一个系统的API HTTP接口，记录和线路对应为对方系统中的NSRecord和NSRoute
 type UserEdgeDNSProvider struct {
+	host        string // API HTTP地址，类似于 https://api.example.com:8003
+	accessKeyId string
+	accessKey   string
+	insecure    bool  // 是否忽略HTTPS证书错误
+	ttl         int32 // 默认TTL
+
+	accessToken          string
+	accessTokenExpiresAt int64
+
+	domainIds map[string]int64 // domain => nsDomainId
+}
+
+// 远程EdgeDNS中的记录
+type userEdgeDNSRecord struct {
+	Id       int64  `json:"id"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Value    string `json:"value"`
+	Ttl      int32  `json:"ttl"`
+	NsRoutes []struct {
+		Id int64 `json:"id"`
+	} `json:"nsRoutes"`
 }
 
 // Auth 认证
+// 参数：
+//   - host API HTTP地址
+//   - accessKeyId
+//   - accessKey
+//   - insecure 是否忽略HTTPS证书错误
+//   - ttl
 func (this *UserEdgeDNSProvider) Auth(params maps.Map) error {
-	// TODO
+	this.host = strings.TrimRight(strings.TrimSpace(params.GetString("host")), "/")
+	if len(this.host) == 0 {
+		return errors.New("'host' should not be empty")
+	}
+	if !strings.HasPrefix(this.host, "http://") && !strings.HasPrefix(this.host, "https://") {
+		return errors.New("'host' should start with 'http://' or 'https://'")
+	}
+
+	this.accessKeyId = params.GetString("accessKeyId")
+	if len(this.accessKeyId) == 0 {
+		return errors.New("'accessKeyId' should not be empty")
+	}
+	this.accessKey = params.GetString("accessKey")
+	if len(this.accessKey) == 0 {
+		return errors.New("'accessKey' should not be empty")
+	}
+
+	this.insecure = params.GetBool("insecure")
+
+	this.ttl = params.GetInt32("ttl")
+	if this.ttl <= 0 {
+		this.ttl = 3600
+	}
+
+	this.accessToken = ""
+	this.accessTokenExpiresAt = 0
+	this.domainIds = map[string]int64{}
+
 	return nil
 }
 
 // GetRecords 获取域名解析记录列表
 func (this *UserEdgeDNSProvider) GetRecords(domain string) (records []*dnstypes.Record, err error) {
-	// TODO
+	domainId, err := this.findDomainId(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := 0
+	size := 1000
+	for {
+		var resp = struct {
+			NsRecords []*userEdgeDNSRecord `json:"nsRecords"`
+		}{}
+		err = this.doAPI("NSRecordService", "ListEnabledNSRecords", maps.Map{
+			"nsDomainId": domainId,
+			"offset":     offset,
+			"size":       size,
+		}, &resp)
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.NsRecords) == 0 {
+			break
+		}
+		for _, record := range resp.NsRecords {
+			records = append(records, this.convertRecord(record))
+		}
+		offset += size
+	}
+
 	return
 }
 
 // GetRoutes 读取域名支持的线路数据
 func (this *UserEdgeDNSProvider) GetRoutes(domain string) (routes []*dnstypes.Route, err error) {
-	// TODO
+	_, err = this.findDomainId(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp = struct {
+		NsRoutes []struct {
+			Id   int64  `json:"id"`
+			Name string `json:"name"`
+		} `json:"nsRoutes"`
+	}{}
+	err = this.doAPI("NSRouteService", "FindAllEnabledNSRoutes", maps.Map{}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	for _, route := range resp.NsRoutes {
+		routes = append(routes, &dnstypes.Route{
+			Name: route.Name,
+			Code: strconv.FormatInt(route.Id, 10),
+		})
+	}
 	return
 }
 
 // QueryRecord 查询单个记录
 func (this *UserEdgeDNSProvider) QueryRecord(domain string, name string, recordType dnstypes.RecordType) (*dnstypes.Record, error) {
-	// TODO
-	return nil, nil
+	record, err := this.findRecord(domain, name, recordType)
+	if err != nil {
+		return nil, err
+	}
+	if record == nil {
+		return nil, nil
+	}
+	return this.convertRecord(record), nil
 }
 
 // AddRecord 设置记录
 func (this *UserEdgeDNSProvider) AddRecord(domain string, newRecord *dnstypes.Record) error {
-	// TODO
-	return nil
+	domainId, err := this.findDomainId(domain)
+	if err != nil {
+		return err
+	}
+
+	return this.doAPI("NSRecordService", "CreateNSRecord", maps.Map{
+		"nsDomainId": domainId,
+		"name":       newRecord.Name,
+		"type":       newRecord.Type,
+		"value":      newRecord.ComposeValue(),
+		"ttl":        this.recordTTL(newRecord),
+		"nsRouteIds": this.routeIds(newRecord),
+	}, nil)
 }
 
 // UpdateRecord 修改记录
 func (this *UserEdgeDNSProvider) UpdateRecord(domain string, record *dnstypes.Record, newRecord *dnstypes.Record) error {
-	// TODO
-	return nil
+	recordId, err := this.findRecordId(domain, record)
+	if err != nil {
+		return err
+	}
+	if recordId <= 0 {
+		return nil
+	}
+
+	return this.doAPI("NSRecordService", "UpdateNSRecord", maps.Map{
+		"nsRecordId": recordId,
+		"name":       newRecord.Name,
+		"type":       newRecord.Type,
+		"value":      newRecord.ComposeValue(),
+		"ttl":        this.recordTTL(newRecord),
+		"nsRouteIds": this.routeIds(newRecord),
+	}, nil)
 }
 
 // DeleteRecord 删除记录
 func (this *UserEdgeDNSProvider) DeleteRecord(domain string, record *dnstypes.Record) error {
-	// TODO
-	return nil
+	recordId, err := this.findRecordId(domain, record)
+	if err != nil {
+		return err
+	}
+	if recordId <= 0 {
+		return nil
+	}
+
+	return this.doAPI("NSRecordService", "DeleteNSRecord", maps.Map{
+		"nsRecordId": recordId,
+	}, nil)
 }
 
 // DefaultRoute 默认线路
 func (this *UserEdgeDNSProvider) DefaultRoute() string {
-	// TODO
 	return ""
 }
+
+// 查找域名ID
+func (this *UserEdgeDNSProvider) findDomainId(domain string) (int64, error) {
+	domainId, ok := this.domainIds[domain]
+	if ok {
+		return domainId, nil
+	}
+
+	var resp = struct {
+		NsDomains []struct {
+			Id   int64  `json:"id"`
+			Name string `json:"name"`
+		} `json:"nsDomains"`
+	}{}
+	err := this.doAPI("NSDomainService", "ListEnabledNSDomains", maps.Map{
+		"keyword": domain,
+		"offset":  0,
+		"size":    100,
+	}, &resp)
+	if err != nil {
+		return 0, err
+	}
+	for _, nsDomain := range resp.NsDomains {
+		if strings.EqualFold(nsDomain.Name, domain) {
+			this.domainIds[domain] = nsDomain.Id
+			return nsDomain.Id, nil
+		}
+	}
+	return 0, errors.New("can not find domain '" + domain + "'")
+}
+
+// 根据名称和类型查找记录
+func (this *UserEdgeDNSProvider) findRecord(domain string, name string, recordType dnstypes.RecordType) (*userEdgeDNSRecord, error) {
+	domainId, err := this.findDomainId(domain)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp = struct {
+		NsRecords []*userEdgeDNSRecord `json:"nsRecords"`
+	}{}
+	err = this.doAPI("NSRecordService", "ListEnabledNSRecords", maps.Map{
+		"nsDomainId": domainId,
+		"type":       recordType,
+		"keyword":    name,
+		"offset":     0,
+		"size":       1000,
+	}, &resp)
+	if err != nil {
+		return nil, err
+	}
+	for _, record := range resp.NsRecords {
+		if record.Name == name && record.Type == recordType {
+			return record, nil
+		}
+	}
+	return nil, nil
+}
+
+// 查找记录ID
+func (this *UserEdgeDNSProvider) findRecordId(domain string, record *dnstypes.Record) (int64, error) {
+	if len(record.Id) > 0 {
+		recordId, err := strconv.ParseInt(record.Id, 10, 64)
+		if err == nil && recordId > 0 {
+			return recordId, nil
+		}
+	}
+
+	realRecord, err := this.findRecord(domain, record.Name, record.Type)
+	if err != nil {
+		return 0, err
+	}
+	if realRecord == nil {
+		return 0, nil
+	}
+	return realRecord.Id, nil
+}
+
+// 转换记录
+func (this *UserEdgeDNSProvider) convertRecord(record *userEdgeDNSRecord) *dnstypes.Record {
+	var route = ""
+	if len(record.NsRoutes) > 0 {
+		route = strconv.FormatInt(record.NsRoutes[0].Id, 10)
+	}
+
+	var result = &dnstypes.Record{
+		Id:    strconv.FormatInt(record.Id, 10),
+		Name:  record.Name,
+		Type:  record.Type,
+		Route: route,
+		TTL:   record.Ttl,
+	}
+	result.ParseValue(record.Value)
+	if result.IsDomainValue() && !strings.HasSuffix(result.Value, ".") {
+		result.Value += "."
+	}
+	return result
+}
+
+// 记录的线路ID
+func (this *UserEdgeDNSProvider) routeIds(record *dnstypes.Record) []int64 {
+	var routeIds = []int64{}
+	if len(record.Route) > 0 {
+		routeId, err := strconv.ParseInt(record.Route, 10, 64)
+		if err == nil && routeId > 0 {
+			routeIds = append(routeIds, routeId)
+		}
+	}
+	return routeIds
+}
+
+// 记录的TTL
+func (this *UserEdgeDNSProvider) recordTTL(record *dnstypes.Record) int32 {
+	if record.TTL > 0 {
+		return record.TTL
+	}
+	return this.ttl
+}
+
+// 获取AccessToken
+func (this *UserEdgeDNSProvider) getAccessToken() (string, error) {
+	// 提前一分钟刷新
+	if len(this.accessToken) > 0 && this.accessTokenExpiresAt > time.Now().Unix()+60 {
+		return this.accessToken, nil
+	}
+
+	var resp = struct {
+		Token     string `json:"token"`
+		ExpiresAt int64  `json:"expiresAt"`
+	}{}
+	err := this.post("APIAccessTokenService", "GetAPIAccessToken", "", maps.Map{
+		"type":        "user",
+		"accessKeyId": this.accessKeyId,
+		"accessKey":   this.accessKey,
+	}, &resp)
+	if err != nil {
+		return "", errors.New("get access token failed: " + err.Error())
+	}
+	if len(resp.Token) == 0 {
+		return "", errors.New("get access token failed: empty token")
+	}
+	this.accessToken = resp.Token
+	this.accessTokenExpiresAt = resp.ExpiresAt
+	return this.accessToken, nil
+}
+
+// 调用需要认证的API
+func (this *UserEdgeDNSProvider) doAPI(service string, method string, params maps.Map, respData interface{}) error {
+	token, err := this.getAccessToken()
+	if err != nil {
+		return err
+	}
+	return this.post(service, method, token, params, respData)
+}
+
+// 发送请求
+func (this *UserEdgeDNSProvider) post(service string, method string, accessToken string, params maps.Map, respData interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, this.host+"/"+service+"/"+method, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "GoEdge/"+teaconst.Version)
+	if len(accessToken) > 0 {
+		req.Header.Set("Edge-Access-Token", accessToken)
+	}
+
+	var client = userEdgeDNSHTTPClient
+	if this.insecure {
+		client = userEdgeDNSInsecureHTTPClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("status should be 200, but got '" + strconv.Itoa(resp.StatusCode) + "'")
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var result = struct {
+		Code    int             `json:"code"`
+		Message string          `json:"message"`
+		Data    json.RawMessage `json:"data"`
+	}{}
+	err = json.Unmarshal(body, &result)
+	if err != nil {
+		return errors.New("decode response failed: " + err.Error())
+	}
+	if result.Code != http.StatusOK {
+		// AccessToken失效后需要重新获取
+		if strings.Contains(result.Message, "access token") {
+			this.accessToken = ""
+			this.accessTokenExpiresAt = 0
+		}
+		return errors.New("call '" + service + "." + method + "' failed: " + result.Message)
+	}
+	if respData != nil && len(result.Data) > 0 {
+		err = json.Unmarshal(result.Data, respData)
+		if err != nil {
+			return errors.New("decode response data failed: " + err.Error())
+		}
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package dnsclients
+
+import (
+	"encoding/json"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/iwind/TeaGo/maps"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// 模拟API节点的HTTP接口
+func testUserEdgeDNSServer(t *testing.T, calls *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, req *http.Request) {
+		body, _ := ioutil.ReadAll(req.Body)
+		params := maps.Map{}
+		_ = json.Unmarshal(body, &params)
+		*calls = append(*calls, req.URL.Path)
+
+		var data interface{} = maps.Map{}
+		if req.URL.Path == "/APIAccessTokenService/GetAPIAccessToken" {
+			if params.GetString("accessKeyId") != "abc" || params.GetString("accessKey") != "123" {
+				_, _ = writer.Write(maps.Map{"code": 400, "message": "access key not found", "data": maps.Map{}}.AsJSON())
+				return
+			}
+			data = maps.Map{"token": "TOKEN", "expiresAt": time.Now().Unix() + 3600}
+		} else if req.Header.Get("Edge-Access-Token") != "TOKEN" {
+			_, _ = writer.Write(maps.Map{"code": 400, "message": "invalid access token", "data": maps.Map{}}.AsJSON())
+			return
+		}
+
+		switch req.URL.Path {
+		case "/NSDomainService/ListEnabledNSDomains":
+			data = maps.Map{"nsDomains": []maps.Map{{"id": 1, "name": "teaos.cn.example"}, {"id": 2, "name": "teaos.cn"}}}
+		case "/NSRecordService/ListEnabledNSRecords":
+			if params.GetInt("offset") > 0 {
+				data = maps.Map{"nsRecords": []maps.Map{}}
+			} else {
+				data = maps.Map{"nsRecords": []maps.Map{
+					{"id": 10, "name": "www", "type": "A", "value": "192.168.1.100", "ttl": 600, "nsRoutes": []maps.Map{{"id": 3}}},
+					{"id": 11, "name": "cdn", "type": "CNAME", "value": "www.teaos.cn", "ttl": 600},
+				}}
+			}
+		case "/NSRouteService/FindAllEnabledNSRoutes":
+			data = maps.Map{"nsRoutes": []maps.Map{{"id": 3, "name": "电信"}}}
+		case "/NSRecordService/CreateNSRecord":
+			if params.GetInt64("nsDomainId") != 2 {
+				t.Error("invalid domain id")
+			}
+			data = maps.Map{"nsRecordId": 12}
+		case "/NSRecordService/UpdateNSRecord", "/NSRecordService/DeleteNSRecord":
+			if params.GetInt64("nsRecordId") != 10 {
+				t.Error("invalid record id")
+			}
+		}
+		_, _ = writer.Write(maps.Map{"code": 200, "message": "ok", "data": data}.AsJSON())
+	}))
+}
+
+func TestUserEdgeDNSProvider(t *testing.T) {
+	var calls = []string{}
+	server := testUserEdgeDNSServer(t, &calls)
+	defer server.Close()
+
+	provider := &UserEdgeDNSProvider{}
+	err := provider.Auth(maps.Map{
+		"host":        server.URL,
+		"accessKeyId": "abc",
+		"accessKey":   "123",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := provider.GetRecords("teaos.cn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].Route != "3" || records[1].Value != "www.teaos.cn." {
+		t.Fatal("invalid records")
+	}
+
+	routes, err := provider.GetRoutes("teaos.cn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(routes) != 1 || routes[0].Code != "3" {
+		t.Fatal("invalid routes")
+	}
+
+	record, err := provider.QueryRecord("teaos.cn", "www", dnstypes.RecordTypeA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record == nil || record.Id != "10" {
+		t.Fatal("record should be found")
+	}
+
+	err = provider.AddRecord("teaos.cn", &dnstypes.Record{Name: "api", Type: dnstypes.RecordTypeA, Value: "192.168.1.101", Route: "3"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = provider.UpdateRecord("teaos.cn", &dnstypes.Record{Name: "www", Type: dnstypes.RecordTypeA}, &dnstypes.Record{Name: "www", Type: dnstypes.RecordTypeA, Value: "192.168.1.102"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = provider.DeleteRecord("teaos.cn", &dnstypes.Record{Id: "10", Name: "www", Type: dnstypes.RecordTypeA})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// AccessToken和域名ID只需要获取一次
+	var countTokenCalls = 0
+	var countDomainCalls = 0
+	for _, call := range calls {
+		switch call {
+		case "/APIAccessTokenService/GetAPIAccessToken":
+			countTokenCalls++
+		case "/NSDomainService/ListEnabledNSDomains":
+			countDomainCalls++
+		}
+	}
+	if countTokenCalls != 1 || countDomainCalls != 1 {
+		t.Fatal("token and domain id should be cached", calls)
+	}
+}
+
+func TestUserEdgeDNSProvider_InvalidAccessKey(t *testing.T) {
+	var calls = []string{}
+	server := testUserEdgeDNSServer(t, &calls)
+	defer server.Close()
+
+	provider := &UserEdgeDNSProvider{}
+	err := provider.Auth(maps.Map{
+		"host":        server.URL,
+		"accessKeyId": "abc",
+		"accessKey":   "456",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = provider.GetRecords("teaos.cn")
+	if err == nil {
+		t.Fatal("should fail with invalid access key")
+	}
+	t.Log(err)
+}
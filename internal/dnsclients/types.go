@@ -16,6 +16,7 @@ const (
 	ProviderTypeLocalEdgeDNS ProviderType = "localEdgeDNS" // 和当前系统集成的EdgeDNS
 	ProviderTypeUserEdgeDNS  ProviderType = "userEdgeDNS"  // 通过API连接的EdgeDNS
 	ProviderTypeCustomHTTP   ProviderType = "customHTTP"   // 自定义HTTP接口
+	ProviderTypeRFC2136      ProviderType = "rfc2136"      // 支持RFC 2136动态更新的DNS服务器
 )
 
 // FindAllProviderTypes 所有的服务商类型
@@ -50,20 +51,26 @@ func FindAllProviderTypes() []maps.Map {
 				"code":        ProviderTypeLocalEdgeDNS,
 				"description": "当前企业版提供的DNS服务。",
 			},
-			// TODO 需要实现用户使用AccessId/AccessKey来连接DNS服务
-			/**{
+			{
 				"name":        "用户EdgeDNS",
 				"code":        ProviderTypeUserEdgeDNS,
-				"description": "通过API连接企业版提供的DNS服务。",
-			},**/
+				"description": "通过API和AccessKey连接企业版提供的DNS服务。",
+			},
 		}...)
 	}
 
-	typeMaps = append(typeMaps, maps.Map{
-		"name":        "自定义HTTP DNS",
-		"code":        ProviderTypeCustomHTTP,
-		"description": "通过自定义的HTTP接口提供DNS服务。",
-	})
+	typeMaps = append(typeMaps, []maps.Map{
+		{
+			"name":        "RFC 2136动态更新",
+			"code":        ProviderTypeRFC2136,
+			"description": "通过RFC 2136动态更新协议和TSIG密钥管理BIND、Knot、PowerDNS等DNS服务器。",
+		},
+		{
+			"name":        "自定义HTTP DNS",
+			"code":        ProviderTypeCustomHTTP,
+			"description": "通过自定义的HTTP接口提供DNS服务。",
+		},
+	}...)
 	return typeMaps
 }
 
@@ -82,6 +89,8 @@ func FindProvider(providerType ProviderType) ProviderInterface {
 		return &UserEdgeDNSProvider{}
 	case ProviderTypeCustomHTTP:
 		return &CustomHTTPProvider{}
+	case ProviderTypeRFC2136:
+		return &RFC2136Provider{}
 	}
 	return nil
 }
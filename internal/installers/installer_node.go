@@ -112,6 +112,22 @@ func (this *NodeInstaller) Install(dir string, params interface{}, installStatus
 		}
 	}
 
+	// 写入客户端证书
+	if len(nodeParams.ClientCertData) > 0 && len(nodeParams.ClientKeyData) > 0 {
+		_, err = this.client.WriteFile(dir+"/edge-node/configs/api.client.pem", nodeParams.ClientCertData)
+		if err != nil {
+			return errors.New("write 'configs/api.client.pem': " + err.Error())
+		}
+		_, err = this.client.WriteFile(dir+"/edge-node/configs/api.client.key", nodeParams.ClientKeyData)
+		if err != nil {
+			return errors.New("write 'configs/api.client.key': " + err.Error())
+		}
+		err = this.client.Chmod(dir+"/edge-node/configs/api.client.key", 0600)
+		if err != nil {
+			return errors.New("chmod 'configs/api.client.key': " + err.Error())
+		}
+	}
+
 	// 测试
 	_, stderr, err = this.client.Exec(dir + "/edge-node/bin/edge-node test")
 	if err != nil {
@@ -10,6 +10,9 @@ type NodeParams struct {
 	NodeId      string
 	Secret      string
 	IsUpgrading bool // 是否为升级
+
+	ClientCertData []byte // 客户端证书
+	ClientKeyData  []byte // 客户端证书私钥
 }
 
 func (this *NodeParams) Validate() error {
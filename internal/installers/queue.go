@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
 	"github.com/TeaOSLab/EdgeAPI/internal/utils"
 	"github.com/TeaOSLab/EdgeAPI/internal/utils/numberutils"
 	"github.com/iwind/TeaGo/logs"
@@ -170,11 +171,20 @@ func (this *Queue) InstallNode(nodeId int64, installStatus *models.NodeInstallSt
 		}
 	}
 
+	// 签发客户端证书
+	clientCert, err := models.SharedNodeClientCertDAO.IssueNodeClientCert(nil, rpcutils.UserTypeNode, nodeId, node.UniqueId)
+	if err != nil {
+		installStatus.ErrorCode = "ISSUE_CLIENT_CERT_FAILED"
+		return errors.New("issue client cert failed: " + err.Error())
+	}
+
 	params := &NodeParams{
-		Endpoints:   apiEndpoints,
-		NodeId:      node.UniqueId,
-		Secret:      node.Secret,
-		IsUpgrading: isUpgrading,
+		Endpoints:      apiEndpoints,
+		NodeId:         node.UniqueId,
+		Secret:         node.Secret,
+		IsUpgrading:    isUpgrading,
+		ClientCertData: clientCert.CertData,
+		ClientKeyData:  clientCert.KeyData,
 	}
 
 	installer := &NodeInstaller{}
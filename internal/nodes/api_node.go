@@ -9,6 +9,7 @@ import (
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
 	"github.com/TeaOSLab/EdgeAPI/internal/events"
 	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
 	"github.com/TeaOSLab/EdgeAPI/internal/setup"
 	"github.com/TeaOSLab/EdgeAPI/internal/utils"
 	"github.com/go-yaml/yaml"
@@ -100,6 +101,9 @@ func (this *APINode) Start() {
 	// 状态变更计时器
 	go NewNodeStatusExecutor().Listen()
 
+	// 是否要求节点使用客户端证书
+	rpcutils.SetRequireNodeClientCert(apiNode.RequireClientCert == 1)
+
 	// 监听RPC服务
 	remotelogs.Println("API_NODE", "starting RPC server ...")
 
@@ -110,6 +114,11 @@ func (this *APINode) Start() {
 		return
 	}
 
+	// EdgeDNS区域传送
+	if config.NSTransfer != nil && len(config.NSTransfer.Listen) > 0 {
+		go NewNSTransferServer(config.NSTransfer.Listen).Start()
+	}
+
 	// 保持进程
 	select {}
 }
@@ -350,9 +359,7 @@ func (this *APINode) listenPorts(apiNode *models.APINode) (isListening bool) {
 					continue
 				}
 				go func() {
-					err := this.listenRPC(listener, &tls.Config{
-						Certificates: certs,
-					})
+					err := this.listenRPC(listener, this.clientAuthTLSConfig(certs))
 					if err != nil {
 						remotelogs.Error("API_NODE", "listening '"+addr+"' rpc: "+err.Error())
 						return
@@ -0,0 +1,62 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package nodes
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	"sync"
+	"time"
+)
+
+// 重新读取CA列表的间隔，以便新生成的CA能够及时生效
+const clientCAPoolRefreshSeconds = 60
+
+var sharedClientCAPool = &clientCAPool{}
+
+// 用来校验节点客户端证书的CA列表
+type clientCAPool struct {
+	pool      *x509.CertPool
+	updatedAt int64
+	locker    sync.Mutex
+}
+
+// Pool 读取CA列表
+func (this *clientCAPool) Pool() *x509.CertPool {
+	this.locker.Lock()
+	defer this.locker.Unlock()
+
+	if this.pool != nil && time.Now().Unix()-this.updatedAt < clientCAPoolRefreshSeconds {
+		return this.pool
+	}
+
+	certDataList, err := models.SharedNodeCADAO.FindAllValidNodeCACertData(nil)
+	if err != nil {
+		remotelogs.Error("API_NODE", "load node ca failed: "+err.Error())
+		return this.pool
+	}
+	var pool = x509.NewCertPool()
+	for _, certData := range certDataList {
+		pool.AppendCertsFromPEM(certData)
+	}
+	this.pool = pool
+	this.updatedAt = time.Now().Unix()
+	return pool
+}
+
+// 构造可以校验节点客户端证书的TLS配置
+// 客户端证书是可选的，是否强制要求由 rpcutils.ValidateNodeClientCert 根据节点角色决定
+func (this *APINode) clientAuthTLSConfig(certs []tls.Certificate) *tls.Config {
+	return &tls.Config{
+		Certificates: certs,
+		GetConfigForClient: func(info *tls.ClientHelloInfo) (*tls.Config, error) {
+			return &tls.Config{
+				Certificates: certs,
+				ClientAuth:   tls.VerifyClientCertIfGiven,
+				ClientCAs:    sharedClientCAPool.Pool(),
+			}, nil
+		},
+	}
+}
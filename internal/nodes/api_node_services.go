@@ -18,6 +18,7 @@ func (this *APINode) registerServices(server *grpc.Server) {
 	pb.RegisterNodeClusterServiceServer(server, &services.NodeClusterService{})
 	pb.RegisterNodeIPAddressServiceServer(server, &services.NodeIPAddressService{})
 	pb.RegisterAPINodeServiceServer(server, &services.APINodeService{})
+	pb.RegisterNodeClientCertServiceServer(server, &services.NodeClientCertService{})
 	pb.RegisterOriginServiceServer(server, &services.OriginService{})
 	pb.RegisterHTTPWebServiceServer(server, &services.HTTPWebService{})
 	pb.RegisterReverseProxyServiceServer(server, &services.ReverseProxyService{})
@@ -96,4 +97,5 @@ func (this *APINode) registerServices(server *grpc.Server) {
 	pb.RegisterNSRecordServiceServer(server, &nameservers.NSRecordService{})
 	pb.RegisterNSRouteServiceServer(server, &nameservers.NSRouteService{})
 	pb.RegisterNSAccessLogServiceServer(server, &nameservers.NSAccessLogService{})
+	pb.RegisterNSQueryStatServiceServer(server, &nameservers.NSQueryStatService{})
 }
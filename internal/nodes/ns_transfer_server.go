@@ -0,0 +1,441 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package nodes
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models/nameservers"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/zoneutils"
+	"github.com/miekg/dns"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 每个消息中最多包含的记录数
+const nsTransferRRsPerMessage = 100
+
+// NSTransferServer EdgeDNS域名区域传送服务
+// 支持AXFR和IXFR，IXFR使用记录变更日志计算差异，无法计算时使用AXFR的格式返回完整数据
+type NSTransferServer struct {
+	addrs   []string
+	servers []*dns.Server
+
+	domainMap  map[string]*nsTransferDomain // fqdn => domain
+	secrets    map[string]string            // 当前使用的TSIG密钥：name => secret
+	secretsKey string                       // 当前使用的TSIG密钥，用来检查是否需要重启服务
+	locker     sync.RWMutex
+}
+
+type nsTransferDomain struct {
+	domain *nameservers.NSDomain
+	config *nameservers.NSDomainTransferConfig
+}
+
+func NewNSTransferServer(addrs []string) *NSTransferServer {
+	return &NSTransferServer{
+		addrs:     addrs,
+		domainMap: map[string]*nsTransferDomain{},
+		secrets:   map[string]string{},
+	}
+}
+
+// Start 启动服务
+func (this *NSTransferServer) Start() {
+	err := this.reload()
+	if err != nil {
+		remotelogs.Error("NS_TRANSFER", "load domains failed: "+err.Error())
+	}
+
+	ticker := time.NewTicker(30 * time.Second)
+	for range ticker.C {
+		err = this.reload()
+		if err != nil {
+			remotelogs.Error("NS_TRANSFER", "load domains failed: "+err.Error())
+		}
+	}
+}
+
+// 重新加载域名配置
+func (this *NSTransferServer) reload() error {
+	domains, err := nameservers.SharedNSDomainDAO.FindAllEnabledTransferDomains(nil)
+	if err != nil {
+		return err
+	}
+
+	var domainMap = map[string]*nsTransferDomain{}
+	var secrets = map[string]string{}
+	var keyZones = map[string]string{}   // key name => zone
+	var conflictKeys = map[string]bool{} // 多个域名使用的密钥名
+	for _, domain := range domains {
+		config, err := domain.DecodeTransferConfig()
+		if err != nil {
+			remotelogs.Error("NS_TRANSFER", "decode transfer config of domain '"+domain.Name+"' failed: "+err.Error())
+			continue
+		}
+		err = config.Init()
+		if err != nil {
+			remotelogs.Error("NS_TRANSFER", "init transfer config of domain '"+domain.Name+"' failed: "+err.Error())
+			continue
+		}
+		var zone = dns.Fqdn(strings.ToLower(domain.Name))
+		_, ok := domainMap[zone]
+		if ok {
+			continue
+		}
+		domainMap[zone] = &nsTransferDomain{
+			domain: domain,
+			config: config,
+		}
+		for _, key := range config.TSIGKeys {
+			otherZone, ok := keyZones[key.Name]
+			if ok && otherZone != zone {
+				conflictKeys[key.Name] = true
+				continue
+			}
+			keyZones[key.Name] = zone
+			secrets[key.Name] = key.Secret
+		}
+	}
+
+	// 密钥名只能属于一个域名，有冲突的密钥不加载，以免使用其他域名的密钥传送区域数据
+	for name := range conflictKeys {
+		delete(secrets, name)
+	}
+
+	this.locker.Lock()
+	this.domainMap = domainMap
+	this.secrets = secrets
+	this.locker.Unlock()
+
+	// TSIG密钥在服务启动时设置，变化后需要重启服务
+	var secretPieces = []string{}
+	for name, secret := range secrets {
+		secretPieces = append(secretPieces, name+":"+secret)
+	}
+	sort.Strings(secretPieces)
+	var secretsKey = strings.Join(secretPieces, ",")
+	if len(this.servers) > 0 && secretsKey == this.secretsKey {
+		return nil
+	}
+	this.secretsKey = secretsKey
+	for name := range conflictKeys {
+		remotelogs.Error("NS_TRANSFER", "tsig key '"+name+"' is used by multiple domains, ignored")
+	}
+	this.restart(secrets)
+	return nil
+}
+
+// 重启服务
+func (this *NSTransferServer) restart(secrets map[string]string) {
+	for _, server := range this.servers {
+		_ = server.Shutdown()
+	}
+	this.servers = nil
+
+	for _, addr := range this.addrs {
+		for _, network := range []string{"tcp", "udp"} {
+			var server = &dns.Server{
+				Addr:       addr,
+				Net:        network,
+				Handler:    this,
+				TsigSecret: secrets,
+			}
+			this.servers = append(this.servers, server)
+
+			var started = make(chan bool, 1)
+			server.NotifyStartedFunc = func() {
+				started <- true
+			}
+			go func(server *dns.Server) {
+				err := server.ListenAndServe()
+				if err != nil {
+					remotelogs.Error("NS_TRANSFER", "listening '"+server.Net+"://"+server.Addr+"' failed: "+err.Error())
+					started <- false
+				}
+			}(server)
+			if <-started {
+				remotelogs.Println("NS_TRANSFER", "listening "+server.Net+"://"+server.Addr+" ...")
+			}
+		}
+	}
+}
+
+// ServeDNS 处理请求
+func (this *NSTransferServer) ServeDNS(writer dns.ResponseWriter, req *dns.Msg) {
+	if len(req.Question) != 1 {
+		this.writeError(writer, req, dns.RcodeFormatError)
+		return
+	}
+	var question = req.Question[0]
+	var zone = strings.ToLower(question.Name)
+
+	this.locker.RLock()
+	transferDomain, ok := this.domainMap[zone]
+	this.locker.RUnlock()
+	if !ok {
+		this.writeError(writer, req, dns.RcodeNotAuth)
+		return
+	}
+
+	if !this.checkClient(writer, req, transferDomain.config) {
+		this.writeError(writer, req, dns.RcodeRefused)
+		return
+	}
+
+	var err error
+	switch question.Qtype {
+	case dns.TypeSOA:
+		err = this.writeSOA(writer, req, transferDomain)
+	case dns.TypeAXFR:
+		if !this.isTCP(writer) {
+			this.writeError(writer, req, dns.RcodeFormatError)
+			return
+		}
+		err = this.writeAXFR(writer, req, transferDomain)
+	case dns.TypeIXFR:
+		// 通过UDP请求时只返回SOA，客户端需要再通过TCP请求
+		if !this.isTCP(writer) {
+			err = this.writeSOA(writer, req, transferDomain)
+		} else {
+			err = this.writeIXFR(writer, req, transferDomain)
+		}
+	default:
+		this.writeError(writer, req, dns.RcodeRefused)
+		return
+	}
+	if err != nil {
+		remotelogs.Error("NS_TRANSFER", "transfer domain '"+transferDomain.domain.Name+"' to '"+writer.RemoteAddr().String()+"' failed: "+err.Error())
+		this.writeError(writer, req, dns.RcodeServerFailure)
+	}
+}
+
+// 检查客户端是否有权限
+// 设置了TSIG密钥时请求必须使用其中一个密钥正确签名，设置了IP时客户端IP必须在允许的范围内，两者都没有设置时不允许传送
+// 服务使用所有域名的密钥校验签名，所以还需要确认校验签名的密钥属于当前域名
+func (this *NSTransferServer) checkClient(writer dns.ResponseWriter, req *dns.Msg, config *nameservers.NSDomainTransferConfig) bool {
+	if len(config.TSIGKeys) == 0 && len(config.AllowIPs) == 0 {
+		return false
+	}
+
+	if len(config.TSIGKeys) > 0 {
+		var tsig = req.IsTsig()
+		if tsig == nil || writer.TsigStatus() != nil {
+			return false
+		}
+		var key = config.FindTSIGKey(tsig.Hdr.Name)
+		if key == nil {
+			return false
+		}
+		this.locker.RLock()
+		secret, ok := this.secrets[key.Name]
+		this.locker.RUnlock()
+		if !ok || secret != key.Secret {
+			return false
+		}
+	}
+
+	if len(config.AllowIPs) > 0 {
+		host, _, err := net.SplitHostPort(writer.RemoteAddr().String())
+		if err != nil {
+			return false
+		}
+		var ip = net.ParseIP(host)
+		if ip == nil || !config.AllowIP(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// 返回SOA记录
+func (this *NSTransferServer) writeSOA(writer dns.ResponseWriter, req *dns.Msg, transferDomain *nsTransferDomain) error {
+	soa, err := this.composeSOA(transferDomain)
+	if err != nil {
+		return err
+	}
+	var resp = &dns.Msg{}
+	resp.SetReply(req)
+	resp.Authoritative = true
+	resp.Answer = []dns.RR{soa}
+	this.signReply(req, resp)
+	return writer.WriteMsg(resp)
+}
+
+// 返回完整区域数据
+func (this *NSTransferServer) writeAXFR(writer dns.ResponseWriter, req *dns.Msg, transferDomain *nsTransferDomain) error {
+	soa, err := this.composeSOA(transferDomain)
+	if err != nil {
+		return err
+	}
+	rrs, err := this.composeRRs(transferDomain)
+	if err != nil {
+		return err
+	}
+
+	var allRRs = []dns.RR{soa}
+	allRRs = append(allRRs, rrs...)
+	allRRs = append(allRRs, soa)
+	return this.transfer(writer, req, allRRs)
+}
+
+// 返回增量区域数据
+func (this *NSTransferServer) writeIXFR(writer dns.ResponseWriter, req *dns.Msg, transferDomain *nsTransferDomain) error {
+	var clientSerial uint32 = 0
+	for _, rr := range req.Ns {
+		clientSOA, ok := rr.(*dns.SOA)
+		if ok {
+			clientSerial = clientSOA.Serial
+			break
+		}
+	}
+
+	soa, err := this.composeSOA(transferDomain)
+	if err != nil {
+		return err
+	}
+
+	// 已经是最新版本
+	if clientSerial >= soa.Serial {
+		return this.transfer(writer, req, []dns.RR{soa})
+	}
+
+	// 变更日志不完整或者SOA、NS有变化时返回完整数据
+	var domainId = int64(transferDomain.domain.Id)
+	minVersion, err := nameservers.SharedNSRecordChangeDAO.FindDomainMinVersion(nil, domainId)
+	if err != nil {
+		return err
+	}
+	if clientSerial == 0 || minVersion == 0 || int64(clientSerial) < minVersion-1 || transferDomain.config.SerialVersion > int64(clientSerial) {
+		return this.writeAXFR(writer, req, transferDomain)
+	}
+
+	changes, err := nameservers.SharedNSRecordChangeDAO.FindAllChangesAfterVersion(nil, domainId, int64(clientSerial))
+	if err != nil {
+		return err
+	}
+	var zoneChanges = []*zoneutils.Change{}
+	for _, change := range changes {
+		zoneChanges = append(zoneChanges, &zoneutils.Change{
+			Version:   int64(change.Version),
+			IsDeleted: change.IsDeleted == 1,
+			Record: &zoneutils.Record{
+				Name:  change.Name,
+				Type:  change.Type,
+				Value: change.Value,
+				TTL:   int32(change.Ttl),
+			},
+		})
+	}
+	deletedRecords, addedRecords := zoneutils.DiffChanges(zoneChanges)
+
+	var oldSOA = *soa
+	oldSOA.Serial = clientSerial
+	var rrs = []dns.RR{soa, &oldSOA}
+	for _, record := range deletedRecords {
+		rr, err := zoneutils.ComposeRR(record, transferDomain.domain.Name, transferDomain.config.TTL)
+		if err != nil {
+			continue
+		}
+		rrs = append(rrs, rr)
+	}
+	rrs = append(rrs, soa)
+	for _, record := range addedRecords {
+		rr, err := zoneutils.ComposeRR(record, transferDomain.domain.Name, transferDomain.config.TTL)
+		if err != nil {
+			continue
+		}
+		rrs = append(rrs, rr)
+	}
+	rrs = append(rrs, soa)
+	return this.transfer(writer, req, rrs)
+}
+
+// 分段发送记录
+func (this *NSTransferServer) transfer(writer dns.ResponseWriter, req *dns.Msg, rrs []dns.RR) error {
+	var ch = make(chan *dns.Envelope)
+	var transfer = &dns.Transfer{}
+	var errCh = make(chan error, 1)
+	go func() {
+		errCh <- transfer.Out(writer, req, ch)
+	}()
+	for len(rrs) > 0 {
+		var size = nsTransferRRsPerMessage
+		if size > len(rrs) {
+			size = len(rrs)
+		}
+		ch <- &dns.Envelope{RR: rrs[:size]}
+		rrs = rrs[size:]
+	}
+	close(ch)
+	return <-errCh
+}
+
+// 组合SOA记录
+func (this *NSTransferServer) composeSOA(transferDomain *nsTransferDomain) (*dns.SOA, error) {
+	serial, err := nameservers.SharedNSDomainDAO.FindDomainTransferSerial(nil, int64(transferDomain.domain.Id), transferDomain.config)
+	if err != nil {
+		return nil, err
+	}
+	return transferDomain.config.ComposeSOA(transferDomain.domain.Name, serial), nil
+}
+
+// 组合域名中的记录，不包括SOA记录
+func (this *NSTransferServer) composeRRs(transferDomain *nsTransferDomain) ([]dns.RR, error) {
+	records, err := nameservers.SharedNSRecordDAO.FindAllEnabledDomainRecords(nil, int64(transferDomain.domain.Id))
+	if err != nil {
+		return nil, err
+	}
+
+	var config = transferDomain.config
+	var rrs = config.ComposeNS(transferDomain.domain.Name)
+	for _, record := range records {
+		if record.IsOn == 0 || len(record.DecodeRouteIds()) > 0 {
+			continue
+		}
+
+		// 设置了NS主机名时，使用配置中的NS记录
+		var isApex = len(record.Name) == 0 || record.Name == "@"
+		if isApex && record.Type == "NS" && len(config.NSHosts) > 0 {
+			continue
+		}
+
+		rr, err := zoneutils.ComposeRR(&zoneutils.Record{
+			Name:  record.Name,
+			Type:  record.Type,
+			Value: record.Value,
+			TTL:   int32(record.Ttl),
+		}, transferDomain.domain.Name, config.TTL)
+		if err != nil {
+			// 跳过无法解析的记录，以免影响其他记录的传送
+			remotelogs.Warn("NS_TRANSFER", "skip invalid record '"+record.Name+" "+record.Type+" "+record.Value+"' of domain '"+transferDomain.domain.Name+"': "+err.Error())
+			continue
+		}
+		rrs = append(rrs, rr)
+	}
+	return rrs, nil
+}
+
+// 返回错误
+func (this *NSTransferServer) writeError(writer dns.ResponseWriter, req *dns.Msg, rcode int) {
+	var resp = &dns.Msg{}
+	resp.SetRcode(req, rcode)
+	_ = writer.WriteMsg(resp)
+}
+
+// 对回复进行TSIG签名
+func (this *NSTransferServer) signReply(req *dns.Msg, resp *dns.Msg) {
+	var tsig = req.IsTsig()
+	if tsig != nil {
+		resp.SetTsig(tsig.Hdr.Name, tsig.Algorithm, tsig.Fudge, time.Now().Unix())
+	}
+}
+
+// 是否为TCP连接
+func (this *NSTransferServer) isTCP(writer dns.ResponseWriter) bool {
+	_, ok := writer.RemoteAddr().(*net.TCPAddr)
+	return ok
+}
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
 	"github.com/TeaOSLab/EdgeAPI/internal/rpc/services"
+	"github.com/TeaOSLab/EdgeAPI/internal/rpc/services/nameservers"
 	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
 	"github.com/iwind/TeaGo/maps"
 	"io/ioutil"
@@ -21,6 +22,10 @@ var servicesMap = map[string]reflect.Value{
 	"APIAccessTokenService": reflect.ValueOf(new(services.APIAccessTokenService)),
 	"HTTPAccessLogService":  reflect.ValueOf(new(services.HTTPAccessLogService)),
 	"IPItemService":         reflect.ValueOf(new(services.IPItemService)),
+	"NSDomainService":       reflect.ValueOf(new(nameservers.NSDomainService)),
+	"NSRecordService":       reflect.ValueOf(new(nameservers.NSRecordService)),
+	"NSRouteService":        reflect.ValueOf(new(nameservers.NSRouteService)),
+	"NSQueryStatService":    reflect.ValueOf(new(nameservers.NSQueryStatService)),
 }
 
 type RestServer struct{}
@@ -3,9 +3,16 @@ package nameservers
 import (
 	"context"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models/nameservers"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models/regions"
+	"github.com/TeaOSLab/EdgeAPI/internal/iplibrary"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
 	"github.com/TeaOSLab/EdgeAPI/internal/rpc/services"
 	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/miekg/dns"
+	"net"
 )
 
 // NSAccessLogService 访问日志相关服务
@@ -32,6 +39,12 @@ func (this *NSAccessLogService) CreateNSAccessLogs(ctx context.Context, req *pb.
 		return nil, err
 	}
 
+	// 汇总统计，日志已经写入，所以统计失败时不返回错误，以免节点重复提交
+	err = this.increaseStats(tx, req.NsAccessLogs)
+	if err != nil {
+		remotelogs.Error("NSAccessLogService", "increase stats failed: "+err.Error())
+	}
+
 	return &pb.CreateNSAccessLogsResponse{}, nil
 }
 
@@ -100,3 +113,64 @@ func (this *NSAccessLogService) FindNSAccessLog(ctx context.Context, req *pb.Fin
 	}
 	return &pb.FindNSAccessLogResponse{NsAccessLog: a}, nil
 }
+
+// 将访问日志汇总到统计表
+func (this *NSAccessLogService) increaseStats(tx *dbs.Tx, accessLogs []*pb.NSAccessLog) error {
+	var stat = nameservers.NewNSAccessLogStat()
+	for _, accessLog := range accessLogs {
+		if accessLog.NsDomainId <= 0 {
+			continue
+		}
+		countryId, provinceId, err := this.lookupRegion(tx, accessLog.RemoteAddr)
+		if err != nil {
+			return err
+		}
+		stat.Add(&nameservers.NSAccessLogStatItem{
+			DomainId:   accessLog.NsDomainId,
+			Timestamp:  accessLog.Timestamp,
+			Name:       accessLog.QuestionName,
+			Type:       accessLog.QuestionType,
+			Rcode:      this.findRcode(accessLog),
+			RouteIds:   accessLog.NsRouteIds,
+			CountryId:  countryId,
+			ProvinceId: provinceId,
+		})
+	}
+	if stat.IsEmpty() {
+		return nil
+	}
+	return stat.Save(tx)
+}
+
+// 查找客户端IP所在区域
+func (this *NSAccessLogService) lookupRegion(tx *dbs.Tx, remoteAddr string) (countryId int64, provinceId int64, err error) {
+	if iplibrary.SharedLibrary == nil || len(remoteAddr) == 0 {
+		return
+	}
+	ip, _, splitErr := net.SplitHostPort(remoteAddr)
+	if splitErr != nil {
+		ip = remoteAddr
+	}
+	result, err := iplibrary.SharedLibrary.Lookup(ip)
+	if err != nil || result == nil {
+		// 查不到区域的IP统计为未知区域
+		return 0, 0, nil
+	}
+	countryId, err = regions.SharedRegionCountryDAO.FindCountryIdWithNameCacheable(tx, result.Country)
+	if err != nil || countryId == 0 {
+		return
+	}
+	provinceId, err = regions.SharedRegionProvinceDAO.FindProvinceIdWithNameCacheable(tx, countryId, result.Province)
+	return
+}
+
+// 响应代码，兼容没有上报响应代码的旧版本节点
+func (this *NSAccessLogService) findRcode(accessLog *pb.NSAccessLog) int {
+	if accessLog.Rcode > 0 {
+		return int(accessLog.Rcode)
+	}
+	if len(accessLog.Error) > 0 {
+		return dns.RcodeServerFailure
+	}
+	return dns.RcodeSuccess
+}
@@ -4,11 +4,16 @@ package nameservers
 
 import (
 	"context"
+	"encoding/json"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models/nameservers"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
 	"github.com/TeaOSLab/EdgeAPI/internal/rpc/services"
 	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
+	"github.com/TeaOSLab/EdgeAPI/internal/tasks"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/dnssecutils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"github.com/iwind/TeaGo/maps"
 )
 
 // NSDomainService 域名相关服务
@@ -63,12 +68,18 @@ func (this *NSDomainService) DeleteNSDomain(ctx context.Context, req *pb.DeleteN
 
 // FindEnabledNSDomain 查找单个域名
 func (this *NSDomainService) FindEnabledNSDomain(ctx context.Context, req *pb.FindEnabledNSDomainRequest) (*pb.FindEnabledNSDomainResponse, error) {
-	_, err := this.ValidateAdmin(ctx, 0)
+	_, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
 	if err != nil {
 		return nil, err
 	}
 
 	var tx = this.NullTx()
+	if userId > 0 {
+		err = nameservers.SharedNSDomainDAO.CheckUserDomain(tx, userId, req.NsDomainId)
+		if err != nil {
+			return nil, err
+		}
+	}
 	domain, err := nameservers.SharedNSDomainDAO.FindEnabledNSDomain(tx, req.NsDomainId)
 	if err != nil {
 		return nil, err
@@ -121,11 +132,16 @@ func (this *NSDomainService) FindEnabledNSDomain(ctx context.Context, req *pb.Fi
 
 // CountAllEnabledNSDomains 计算域名数量
 func (this *NSDomainService) CountAllEnabledNSDomains(ctx context.Context, req *pb.CountAllEnabledNSDomainsRequest) (*pb.RPCCountResponse, error) {
-	_, err := this.ValidateAdmin(ctx, 0)
+	_, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
 	if err != nil {
 		return nil, err
 	}
 
+	// 用户只能查看自己的域名
+	if userId > 0 {
+		req.UserId = userId
+	}
+
 	var tx = this.NullTx()
 	count, err := nameservers.SharedNSDomainDAO.CountAllEnabledDomains(tx, req.NsClusterId, req.UserId, req.Keyword)
 	if err != nil {
@@ -136,11 +152,16 @@ func (this *NSDomainService) CountAllEnabledNSDomains(ctx context.Context, req *
 
 // ListEnabledNSDomains 列出单页域名
 func (this *NSDomainService) ListEnabledNSDomains(ctx context.Context, req *pb.ListEnabledNSDomainsRequest) (*pb.ListEnabledNSDomainsResponse, error) {
-	_, err := this.ValidateAdmin(ctx, 0)
+	_, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
 	if err != nil {
 		return nil, err
 	}
 
+	// 用户只能查看自己的域名
+	if userId > 0 {
+		req.UserId = userId
+	}
+
 	var tx = this.NullTx()
 	domains, err := nameservers.SharedNSDomainDAO.ListEnabledDomains(tx, req.NsClusterId, req.UserId, req.Keyword, req.Offset, req.Size)
 	if err != nil {
@@ -200,6 +221,16 @@ func (this *NSDomainService) ListNSDomainsAfterVersion(ctx context.Context, req
 
 	// 集群ID
 	var tx = this.NullTx()
+
+	// 客户端版本早于最小可用版本时，部分删除操作已经无法同步，需要完整同步
+	resyncRequired, horizonVersion, err := models.SharedSyncHorizonDAO.CheckResyncRequired(tx, models.SyncTombstoneKindNSDomain, req.Version)
+	if err != nil {
+		return nil, err
+	}
+	if resyncRequired {
+		return &pb.ListNSDomainsAfterVersionResponse{ResyncRequired: true, HorizonVersion: horizonVersion}, nil
+	}
+
 	domains, err := nameservers.SharedNSDomainDAO.ListDomainsAfterVersion(tx, req.Version, 2000)
 	if err != nil {
 		return nil, err
@@ -219,3 +250,213 @@ func (this *NSDomainService) ListNSDomainsAfterVersion(ctx context.Context, req
 	}
 	return &pb.ListNSDomainsAfterVersionResponse{NsDomains: pbDomains}, nil
 }
+
+// FindNSDomainDNSSEC 查找域名的DNSSEC配置和密钥
+func (this *NSDomainService) FindNSDomainDNSSEC(ctx context.Context, req *pb.FindNSDomainDNSSECRequest) (*pb.FindNSDomainDNSSECResponse, error) {
+	_, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	if userId > 0 {
+		err = nameservers.SharedNSDomainDAO.CheckUserDomain(tx, userId, req.NsDomainId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	config, err := nameservers.SharedNSDomainDAO.FindDomainDNSSECConfig(tx, req.NsDomainId)
+	if err != nil {
+		return nil, err
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := nameservers.SharedNSKeyDAO.FindAllEnabledDomainKeys(tx, req.NsDomainId)
+	if err != nil {
+		return nil, err
+	}
+	var keyMaps = []maps.Map{}
+	var dsRecords = []string{}
+	for _, key := range keys {
+		keyMaps = append(keyMaps, maps.Map{
+			"id":          key.Id,
+			"role":        key.Role,
+			"algorithm":   key.Algorithm,
+			"keyTag":      key.KeyTag,
+			"dnskey":      key.PublicKey,
+			"status":      key.Status,
+			"createdAt":   key.CreatedAt,
+			"activatedAt": key.ActivatedAt,
+			"retiredAt":   key.RetiredAt,
+		})
+
+		// 需要提交到注册商的DS记录
+		if key.Role == dnssecutils.KeyRoleKSK && key.Status != dnssecutils.KeyStatusRetired {
+			keyDSRecords, err := dnssecutils.ComposeDS(key.PublicKey)
+			if err != nil {
+				return nil, err
+			}
+			dsRecords = append(dsRecords, keyDSRecords...)
+		}
+	}
+	keysJSON, err := json.Marshal(keyMaps)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.FindNSDomainDNSSECResponse{
+		DnssecJSON: configJSON,
+		NsKeysJSON: keysJSON,
+		DsRecords:  dsRecords,
+	}, nil
+}
+
+// UpdateNSDomainDNSSEC 修改域名的DNSSEC配置
+func (this *NSDomainService) UpdateNSDomainDNSSEC(ctx context.Context, req *pb.UpdateNSDomainDNSSECRequest) (*pb.RPCSuccess, error) {
+	_, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	if userId > 0 {
+		err = nameservers.SharedNSDomainDAO.CheckUserDomain(tx, userId, req.NsDomainId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var config = nameservers.DefaultNSDomainDNSSECConfig()
+	if len(req.DnssecJSON) > 0 {
+		err = json.Unmarshal(req.DnssecJSON, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = config.Init()
+	if err != nil {
+		return nil, err
+	}
+
+	err = nameservers.SharedNSDomainDAO.UpdateDomainDNSSECConfig(tx, req.NsDomainId, config)
+	if err != nil {
+		return nil, err
+	}
+
+	// 关闭后删除所有签名和密钥
+	if !config.IsOn {
+		err = nameservers.SharedNSRecordDAO.DisableGeneratedRecords(tx, req.NsDomainId)
+		if err != nil {
+			return nil, err
+		}
+		err = nameservers.SharedNSKeyDAO.DisableDomainKeys(tx, req.NsDomainId)
+		if err != nil {
+			return nil, err
+		}
+		return this.Success()
+	}
+
+	// 立即签名
+	domain, err := nameservers.SharedNSDomainDAO.FindEnabledNSDomain(tx, req.NsDomainId)
+	if err != nil {
+		return nil, err
+	}
+	if domain != nil {
+		err = tasks.SharedNSDNSSECSigner.SignDomain(tx, domain, true)
+		if err != nil {
+			return nil, errors.New("sign domain failed: " + err.Error())
+		}
+	}
+
+	return this.Success()
+}
+
+// FindNSDomainTransfer 查找域名的区域传送配置
+func (this *NSDomainService) FindNSDomainTransfer(ctx context.Context, req *pb.FindNSDomainTransferRequest) (*pb.FindNSDomainTransferResponse, error) {
+	_, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	if userId > 0 {
+		err = nameservers.SharedNSDomainDAO.CheckUserDomain(tx, userId, req.NsDomainId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	config, err := nameservers.SharedNSDomainDAO.FindDomainTransferConfig(tx, req.NsDomainId)
+	if err != nil {
+		return nil, err
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := nameservers.SharedNSDomainDAO.FindDomainTransferSerial(tx, req.NsDomainId, config)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.FindNSDomainTransferResponse{
+		TransferJSON: configJSON,
+		Serial:       serial,
+	}, nil
+}
+
+// UpdateNSDomainTransfer 修改域名的区域传送配置
+func (this *NSDomainService) UpdateNSDomainTransfer(ctx context.Context, req *pb.UpdateNSDomainTransferRequest) (*pb.RPCSuccess, error) {
+	_, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	if userId > 0 {
+		err = nameservers.SharedNSDomainDAO.CheckUserDomain(tx, userId, req.NsDomainId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var config = nameservers.DefaultNSDomainTransferConfig()
+	if len(req.TransferJSON) > 0 {
+		err = json.Unmarshal(req.TransferJSON, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = config.Init()
+	if err != nil {
+		return nil, err
+	}
+
+	// 同一个传送服务中TSIG密钥名不能重复，加锁以免同时设置相同的密钥名
+	if len(config.TSIGKeys) > 0 {
+		ok, err := models.SharedSysLockerDAO.Lock(tx, "ns_domain_transfer_tsig_keys", 10)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, errors.New("another transfer config is being updated, please try again later")
+		}
+		defer func() {
+			_ = models.SharedSysLockerDAO.Unlock(tx, "ns_domain_transfer_tsig_keys")
+		}()
+
+		err = nameservers.SharedNSDomainDAO.CheckTransferTSIGKeys(tx, req.NsDomainId, config)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = nameservers.SharedNSDomainDAO.UpdateDomainTransferConfig(tx, req.NsDomainId, config)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
@@ -5,6 +5,7 @@ package nameservers
 import (
 	"context"
 	"encoding/json"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models/nameservers"
 	"github.com/TeaOSLab/EdgeAPI/internal/errors"
 	"github.com/TeaOSLab/EdgeAPI/internal/installers"
@@ -182,6 +183,12 @@ func (this *NSNodeService) DeleteNSNode(ctx context.Context, req *pb.DeleteNSNod
 		return nil, err
 	}
 
+	// 吊销客户端证书
+	err = models.SharedNodeClientCertDAO.RevokeNodeClientCerts(tx, rpcutils.UserTypeDNS, req.NsNodeId)
+	if err != nil {
+		return nil, err
+	}
+
 	return this.Success()
 }
 
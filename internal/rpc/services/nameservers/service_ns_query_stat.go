@@ -0,0 +1,275 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package nameservers
+
+import (
+	"context"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models/nameservers"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models/regions"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/rpc/services"
+	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"github.com/iwind/TeaGo/dbs"
+	timeutil "github.com/iwind/TeaGo/utils/time"
+	"regexp"
+	"time"
+)
+
+var nsStatDayReg = regexp.MustCompile(`^\d{8}$`)
+var nsStatMinuteReg = regexp.MustCompile(`^\d{12}$`)
+
+// 每次最多查询的分钟数
+const nsStatMaxMinutes = 24 * 60
+
+// NSQueryStatService 域名查询统计相关服务
+type NSQueryStatService struct {
+	services.BaseService
+}
+
+// FindNSDomainMinutelyStats 查询每分钟的查询数、QPS和错误数
+func (this *NSQueryStatService) FindNSDomainMinutelyStats(ctx context.Context, req *pb.FindNSDomainMinutelyStatsRequest) (*pb.FindNSDomainMinutelyStatsResponse, error) {
+	var tx = this.NullTx()
+	userId, err := this.validateStatRequest(ctx, tx, req.NsDomainId)
+	if err != nil {
+		return nil, err
+	}
+
+	// 默认为最近一小时
+	var minuteTo = req.MinuteTo
+	if len(minuteTo) == 0 {
+		minuteTo = timeutil.Format("YmdHi")
+	}
+	var minuteFrom = req.MinuteFrom
+	if len(minuteFrom) == 0 {
+		minuteFrom = timeutil.Format("YmdHi", time.Now().Add(-59*time.Minute))
+	}
+	minutes, err := this.rangeMinutes(minuteFrom, minuteTo)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := nameservers.SharedNSDomainMinutelyStatDAO.FindStats(tx, userId, req.NsDomainId, minutes[0], minutes[len(minutes)-1])
+	if err != nil {
+		return nil, err
+	}
+	var statMap = map[string]*nameservers.NSDomainMinutelyStat{} // minute => stat
+	for _, stat := range stats {
+		statMap[stat.Minute] = stat
+	}
+
+	var pbStats = []*pb.FindNSDomainMinutelyStatsResponse_Stat{}
+	for _, minute := range minutes {
+		var pbStat = &pb.FindNSDomainMinutelyStatsResponse_Stat{Minute: minute}
+		stat, ok := statMap[minute]
+		if ok {
+			pbStat.CountRequests = int64(stat.CountRequests)
+			pbStat.CountNXDOMAIN = int64(stat.CountNXDOMAIN)
+			pbStat.CountSERVFAIL = int64(stat.CountSERVFAIL)
+			pbStat.Qps = float32(stat.CountRequests) / 60
+		}
+		pbStats = append(pbStats, pbStat)
+	}
+	return &pb.FindNSDomainMinutelyStatsResponse{Stats: pbStats}, nil
+}
+
+// FindTopNSQueryNames 查询数量最多的记录名和类型
+func (this *NSQueryStatService) FindTopNSQueryNames(ctx context.Context, req *pb.FindTopNSQueryNamesRequest) (*pb.FindTopNSQueryNamesResponse, error) {
+	var tx = this.NullTx()
+	userId, err := this.validateStatRequest(ctx, tx, req.NsDomainId)
+	if err != nil {
+		return nil, err
+	}
+	dayFrom, dayTo, err := this.fixDays(req.DayFrom, req.DayTo)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := nameservers.SharedNSQueryDailyStatDAO.FindTopNames(tx, userId, req.NsDomainId, dayFrom, dayTo, req.OrderField, this.fixSize(req.Size))
+	if err != nil {
+		return nil, err
+	}
+	var pbStats = []*pb.FindTopNSQueryNamesResponse_Stat{}
+	for _, stat := range stats {
+		pbStats = append(pbStats, &pb.FindTopNSQueryNamesResponse_Stat{
+			NsDomainId:    int64(stat.DomainId),
+			Name:          stat.Name,
+			Type:          stat.Type,
+			CountRequests: int64(stat.CountRequests),
+			CountNXDOMAIN: int64(stat.CountNXDOMAIN),
+			CountSERVFAIL: int64(stat.CountSERVFAIL),
+		})
+	}
+	return &pb.FindTopNSQueryNamesResponse{Stats: pbStats}, nil
+}
+
+// FindTopNSQueryTypes 查询数量最多的类型
+func (this *NSQueryStatService) FindTopNSQueryTypes(ctx context.Context, req *pb.FindTopNSQueryTypesRequest) (*pb.FindTopNSQueryTypesResponse, error) {
+	var tx = this.NullTx()
+	userId, err := this.validateStatRequest(ctx, tx, req.NsDomainId)
+	if err != nil {
+		return nil, err
+	}
+	dayFrom, dayTo, err := this.fixDays(req.DayFrom, req.DayTo)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := nameservers.SharedNSQueryDailyStatDAO.FindTopTypes(tx, userId, req.NsDomainId, dayFrom, dayTo, this.fixSize(req.Size))
+	if err != nil {
+		return nil, err
+	}
+	var pbStats = []*pb.FindTopNSQueryTypesResponse_Stat{}
+	for _, stat := range stats {
+		pbStats = append(pbStats, &pb.FindTopNSQueryTypesResponse_Stat{
+			Type:          stat.Type,
+			CountRequests: int64(stat.CountRequests),
+			CountNXDOMAIN: int64(stat.CountNXDOMAIN),
+			CountSERVFAIL: int64(stat.CountSERVFAIL),
+		})
+	}
+	return &pb.FindTopNSQueryTypesResponse{Stats: pbStats}, nil
+}
+
+// FindNSRouteStats 查询每个线路的查询数
+func (this *NSQueryStatService) FindNSRouteStats(ctx context.Context, req *pb.FindNSRouteStatsRequest) (*pb.FindNSRouteStatsResponse, error) {
+	var tx = this.NullTx()
+	userId, err := this.validateStatRequest(ctx, tx, req.NsDomainId)
+	if err != nil {
+		return nil, err
+	}
+	dayFrom, dayTo, err := this.fixDays(req.DayFrom, req.DayTo)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := nameservers.SharedNSRouteDailyStatDAO.FindRouteStats(tx, userId, req.NsDomainId, dayFrom, dayTo)
+	if err != nil {
+		return nil, err
+	}
+	var pbStats = []*pb.FindNSRouteStatsResponse_Stat{}
+	for _, stat := range stats {
+		var routeName = ""
+		if stat.RouteId > 0 {
+			routeName, err = nameservers.SharedNSRouteDAO.FindNSRouteName(tx, int64(stat.RouteId))
+			if err != nil {
+				return nil, err
+			}
+		}
+		pbStats = append(pbStats, &pb.FindNSRouteStatsResponse_Stat{
+			NsRouteId:     int64(stat.RouteId),
+			NsRouteName:   routeName,
+			CountRequests: int64(stat.CountRequests),
+		})
+	}
+	return &pb.FindNSRouteStatsResponse{Stats: pbStats}, nil
+}
+
+// FindNSRegionStats 查询每个区域的查询数
+func (this *NSQueryStatService) FindNSRegionStats(ctx context.Context, req *pb.FindNSRegionStatsRequest) (*pb.FindNSRegionStatsResponse, error) {
+	var tx = this.NullTx()
+	userId, err := this.validateStatRequest(ctx, tx, req.NsDomainId)
+	if err != nil {
+		return nil, err
+	}
+	dayFrom, dayTo, err := this.fixDays(req.DayFrom, req.DayTo)
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := nameservers.SharedNSRegionDailyStatDAO.FindRegionStats(tx, userId, req.NsDomainId, dayFrom, dayTo, this.fixSize(req.Size))
+	if err != nil {
+		return nil, err
+	}
+	var pbStats = []*pb.FindNSRegionStatsResponse_Stat{}
+	for _, stat := range stats {
+		var pbStat = &pb.FindNSRegionStatsResponse_Stat{
+			CountryId:     int64(stat.CountryId),
+			ProvinceId:    int64(stat.ProvinceId),
+			CountRequests: int64(stat.CountRequests),
+		}
+		if stat.CountryId > 0 {
+			pbStat.CountryName, err = regions.SharedRegionCountryDAO.FindRegionCountryName(tx, int64(stat.CountryId))
+			if err != nil {
+				return nil, err
+			}
+		}
+		if stat.ProvinceId > 0 {
+			pbStat.ProvinceName, err = regions.SharedRegionProvinceDAO.FindRegionProvinceName(tx, int64(stat.ProvinceId))
+			if err != nil {
+				return nil, err
+			}
+		}
+		pbStats = append(pbStats, pbStat)
+	}
+	return &pb.FindNSRegionStatsResponse{Stats: pbStats}, nil
+}
+
+// 校验请求，用户只能查询自己的域名
+func (this *NSQueryStatService) validateStatRequest(ctx context.Context, tx *dbs.Tx, domainId int64) (userId int64, err error) {
+	_, userId, err = this.ValidateAdminAndUser(ctx, 0, 0)
+	if err != nil {
+		return 0, err
+	}
+	if userId > 0 && domainId > 0 {
+		err = nameservers.SharedNSDomainDAO.CheckUserDomain(tx, userId, domainId)
+		if err != nil {
+			return 0, err
+		}
+	}
+	return userId, nil
+}
+
+// 检查日期范围，默认为当天
+func (this *NSQueryStatService) fixDays(dayFrom string, dayTo string) (string, string, error) {
+	if len(dayTo) == 0 {
+		dayTo = timeutil.Format("Ymd")
+	}
+	if len(dayFrom) == 0 {
+		dayFrom = dayTo
+	}
+	if !nsStatDayReg.MatchString(dayFrom) || !nsStatDayReg.MatchString(dayTo) {
+		return "", "", errors.New("invalid day range")
+	}
+	if dayFrom > dayTo {
+		dayFrom, dayTo = dayTo, dayFrom
+	}
+	return dayFrom, dayTo, nil
+}
+
+// 检查数量限制
+func (this *NSQueryStatService) fixSize(size int64) int64 {
+	if size <= 0 {
+		return 10
+	}
+	if size > 100 {
+		return 100
+	}
+	return size
+}
+
+// 列出两个时间之间的所有分钟
+func (this *NSQueryStatService) rangeMinutes(minuteFrom string, minuteTo string) ([]string, error) {
+	if !nsStatMinuteReg.MatchString(minuteFrom) || !nsStatMinuteReg.MatchString(minuteTo) {
+		return nil, errors.New("invalid minute range")
+	}
+	timeFrom, err := time.ParseInLocation("200601021504", minuteFrom, time.Local)
+	if err != nil {
+		return nil, errors.New("invalid minute '" + minuteFrom + "'")
+	}
+	timeTo, err := time.ParseInLocation("200601021504", minuteTo, time.Local)
+	if err != nil {
+		return nil, errors.New("invalid minute '" + minuteTo + "'")
+	}
+	if timeFrom.After(timeTo) {
+		timeFrom, timeTo = timeTo, timeFrom
+	}
+	if timeTo.Sub(timeFrom) >= nsStatMaxMinutes*time.Minute {
+		return nil, errors.New("minute range should be less than one day")
+	}
+
+	var result = []string{}
+	for t := timeFrom; !t.After(timeTo); t = t.Add(time.Minute) {
+		result = append(result, t.Format("200601021504"))
+	}
+	return result, nil
+}
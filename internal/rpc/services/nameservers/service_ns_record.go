@@ -4,10 +4,16 @@ package nameservers
 
 import (
 	"context"
+	"encoding/json"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models/nameservers"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
 	"github.com/TeaOSLab/EdgeAPI/internal/rpc/services"
 	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/zoneutils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/maps"
 	"github.com/iwind/TeaGo/types"
 )
 
@@ -18,12 +24,22 @@ type NSRecordService struct {
 
 // CreateNSRecord 创建记录
 func (this *NSRecordService) CreateNSRecord(ctx context.Context, req *pb.CreateNSRecordRequest) (*pb.CreateNSRecordResponse, error) {
-	_, err := this.ValidateAdmin(ctx, 0)
+	_, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
 	if err != nil {
 		return nil, err
 	}
 
 	var tx = this.NullTx()
+	if userId > 0 {
+		err = nameservers.SharedNSDomainDAO.CheckUserDomain(tx, userId, req.NsDomainId)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = this.checkRouteIds(tx, userId, req.NsRouteIds)
+	if err != nil {
+		return nil, err
+	}
 	recordId, err := nameservers.SharedNSRecordDAO.CreateRecord(tx, req.NsDomainId, req.Description, req.Name, req.Type, req.Value, req.Ttl, req.NsRouteIds)
 	if err != nil {
 		return nil, err
@@ -33,12 +49,22 @@ func (this *NSRecordService) CreateNSRecord(ctx context.Context, req *pb.CreateN
 
 // UpdateNSRecord 修改记录
 func (this *NSRecordService) UpdateNSRecord(ctx context.Context, req *pb.UpdateNSRecordRequest) (*pb.RPCSuccess, error) {
-	_, err := this.ValidateAdmin(ctx, 0)
+	_, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
 	if err != nil {
 		return nil, err
 	}
 
 	var tx = this.NullTx()
+	if userId > 0 {
+		err = nameservers.SharedNSRecordDAO.CheckUserRecord(tx, userId, req.NsRecordId)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = this.checkRouteIds(tx, userId, req.NsRouteIds)
+	if err != nil {
+		return nil, err
+	}
 	err = nameservers.SharedNSRecordDAO.UpdateRecord(tx, req.NsRecordId, req.Description, req.Name, req.Type, req.Value, req.Ttl, req.NsRouteIds)
 	if err != nil {
 		return nil, err
@@ -48,12 +74,18 @@ func (this *NSRecordService) UpdateNSRecord(ctx context.Context, req *pb.UpdateN
 
 // DeleteNSRecord 删除记录
 func (this *NSRecordService) DeleteNSRecord(ctx context.Context, req *pb.DeleteNSRecordRequest) (*pb.RPCSuccess, error) {
-	_, err := this.ValidateAdmin(ctx, 0)
+	_, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
 	if err != nil {
 		return nil, err
 	}
 
 	var tx = this.NullTx()
+	if userId > 0 {
+		err = nameservers.SharedNSRecordDAO.CheckUserRecord(tx, userId, req.NsRecordId)
+		if err != nil {
+			return nil, err
+		}
+	}
 	err = nameservers.SharedNSRecordDAO.DisableNSRecord(tx, req.NsRecordId)
 	if err != nil {
 		return nil, err
@@ -63,12 +95,18 @@ func (this *NSRecordService) DeleteNSRecord(ctx context.Context, req *pb.DeleteN
 
 // CountAllEnabledNSRecords 计算记录数量
 func (this *NSRecordService) CountAllEnabledNSRecords(ctx context.Context, req *pb.CountAllEnabledNSRecordsRequest) (*pb.RPCCountResponse, error) {
-	_, err := this.ValidateAdmin(ctx, 0)
+	_, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
 	if err != nil {
 		return nil, err
 	}
 
 	var tx = this.NullTx()
+	if userId > 0 {
+		err = nameservers.SharedNSDomainDAO.CheckUserDomain(tx, userId, req.NsDomainId)
+		if err != nil {
+			return nil, err
+		}
+	}
 	count, err := nameservers.SharedNSRecordDAO.CountAllEnabledRecords(tx, req.NsDomainId, req.Type, req.Keyword, req.NsRouteId)
 	if err != nil {
 		return nil, err
@@ -78,12 +116,18 @@ func (this *NSRecordService) CountAllEnabledNSRecords(ctx context.Context, req *
 
 // ListEnabledNSRecords 读取单页记录
 func (this *NSRecordService) ListEnabledNSRecords(ctx context.Context, req *pb.ListEnabledNSRecordsRequest) (*pb.ListEnabledNSRecordsResponse, error) {
-	_, err := this.ValidateAdmin(ctx, 0)
+	_, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
 	if err != nil {
 		return nil, err
 	}
 
 	var tx = this.NullTx()
+	if userId > 0 {
+		err = nameservers.SharedNSDomainDAO.CheckUserDomain(tx, userId, req.NsDomainId)
+		if err != nil {
+			return nil, err
+		}
+	}
 	records, err := nameservers.SharedNSRecordDAO.ListEnabledRecords(tx, req.NsDomainId, req.Type, req.Keyword, req.NsRouteId, req.Offset, req.Size)
 	if err != nil {
 		return nil, err
@@ -125,12 +169,18 @@ func (this *NSRecordService) ListEnabledNSRecords(ctx context.Context, req *pb.L
 
 // FindEnabledNSRecord 查询单个记录信息
 func (this *NSRecordService) FindEnabledNSRecord(ctx context.Context, req *pb.FindEnabledNSRecordRequest) (*pb.FindEnabledNSRecordResponse, error) {
-	_, err := this.ValidateAdmin(ctx, 0)
+	_, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
 	if err != nil {
 		return nil, err
 	}
 
 	var tx = this.NullTx()
+	if userId > 0 {
+		err = nameservers.SharedNSRecordDAO.CheckUserRecord(tx, userId, req.NsRecordId)
+		if err != nil {
+			return nil, err
+		}
+	}
 	record, err := nameservers.SharedNSRecordDAO.FindEnabledNSRecord(tx, req.NsRecordId)
 	if err != nil {
 		return nil, err
@@ -184,6 +234,248 @@ func (this *NSRecordService) FindEnabledNSRecord(ctx context.Context, req *pb.Fi
 	}}, nil
 }
 
+// ImportNSRecordsFromZoneFile 从区域文件中导入记录
+func (this *NSRecordService) ImportNSRecordsFromZoneFile(ctx context.Context, req *pb.ImportNSRecordsFromZoneFileRequest) (*pb.ImportNSRecordsFromZoneFileResponse, error) {
+	_, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	if userId > 0 {
+		err = nameservers.SharedNSDomainDAO.CheckUserDomain(tx, userId, req.NsDomainId)
+		if err != nil {
+			return nil, err
+		}
+	}
+	domain, err := nameservers.SharedNSDomainDAO.FindEnabledNSDomain(tx, req.NsDomainId)
+	if err != nil {
+		return nil, err
+	}
+	if domain == nil {
+		return nil, errors.New("can not find domain '" + types.String(req.NsDomainId) + "'")
+	}
+
+	result, err := zoneutils.ParseZone(req.ZoneFileData, &zoneutils.ParseOptions{
+		Origin:     domain.Name,
+		DefaultTTL: 600,
+		WithRoutes: req.WithRoutes,
+	})
+	if err != nil {
+		return nil, errors.New("parse zone file failed: " + err.Error())
+	}
+
+	// 检查线路
+	var validRouteIds = map[int64]bool{}
+	var checkRoutes = func(routeIds []int64) (bool, error) {
+		for _, routeId := range routeIds {
+			valid, ok := validRouteIds[routeId]
+			if !ok {
+				route, err := nameservers.SharedNSRouteDAO.FindEnabledNSRoute(tx, routeId)
+				if err != nil {
+					return false, err
+				}
+				valid = route != nil && (userId <= 0 || route.UserId == 0 || int64(route.UserId) == userId)
+				validRouteIds[routeId] = valid
+			}
+			if !valid {
+				return false, nil
+			}
+		}
+		return true, nil
+	}
+
+	var skippedRecords = result.Skipped
+	var countImported int64 = 0
+	err = this.RunTx(func(tx *dbs.Tx) error {
+		for _, record := range result.Records {
+			ok, err := checkRoutes(record.RouteIds)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				skippedRecords = append(skippedRecords, &zoneutils.SkippedRecord{Name: record.Name, Type: record.Type, Value: record.Value, Reason: zoneutils.SkipReasonInvalidRoute})
+				continue
+			}
+
+			// 和已有记录重复或者不符合规则的记录
+			err = nameservers.SharedNSRecordDAO.CheckRecord(tx, req.NsDomainId, 0, record.Name, record.Type, record.Value, record.TTL, record.RouteIds)
+			if err != nil {
+				validationErr, ok := err.(*zoneutils.ValidationError)
+				if !ok {
+					return err
+				}
+				var reason = zoneutils.SkipReasonInvalid
+				if validationErr.Code == zoneutils.ValidationCodeDuplicated {
+					reason = zoneutils.SkipReasonDuplicated
+				}
+				skippedRecords = append(skippedRecords, &zoneutils.SkippedRecord{Name: record.Name, Type: record.Type, Value: record.Value, Reason: reason, Message: validationErr.Message})
+				continue
+			}
+
+			_, err = nameservers.SharedNSRecordDAO.CreateRecord(tx, req.NsDomainId, "", record.Name, record.Type, record.Value, record.TTL, record.RouteIds)
+			if err != nil {
+				return err
+			}
+			countImported++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	skippedRecordsJSON, err := json.Marshal(skippedRecords)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ImportNSRecordsFromZoneFileResponse{
+		CountImportedRecords: countImported,
+		SkippedRecordsJSON:   skippedRecordsJSON,
+	}, nil
+}
+
+// ExportNSRecordsToZoneFile 导出记录为区域文件
+func (this *NSRecordService) ExportNSRecordsToZoneFile(ctx context.Context, req *pb.ExportNSRecordsToZoneFileRequest) (*pb.ExportNSRecordsToZoneFileResponse, error) {
+	_, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	if userId > 0 {
+		err = nameservers.SharedNSDomainDAO.CheckUserDomain(tx, userId, req.NsDomainId)
+		if err != nil {
+			return nil, err
+		}
+	}
+	domain, err := nameservers.SharedNSDomainDAO.FindEnabledNSDomain(tx, req.NsDomainId)
+	if err != nil {
+		return nil, err
+	}
+	if domain == nil {
+		return nil, errors.New("can not find domain '" + types.String(req.NsDomainId) + "'")
+	}
+
+	records, err := nameservers.SharedNSRecordDAO.FindAllEnabledDomainRecords(tx, req.NsDomainId)
+	if err != nil {
+		return nil, err
+	}
+	var zoneRecords = []*zoneutils.Record{}
+	var routeNames = map[int64]string{}
+	for _, record := range records {
+		if record.IsOn == 0 {
+			continue
+		}
+		var routeIds = record.DecodeRouteIds()
+		for _, routeId := range routeIds {
+			_, ok := routeNames[routeId]
+			if ok {
+				continue
+			}
+			routeName, err := nameservers.SharedNSRouteDAO.FindNSRouteName(tx, routeId)
+			if err != nil {
+				return nil, err
+			}
+			routeNames[routeId] = routeName
+		}
+		zoneRecords = append(zoneRecords, &zoneutils.Record{
+			Name:     record.Name,
+			Type:     record.Type,
+			Value:    record.Value,
+			TTL:      types.Int32(record.Ttl),
+			RouteIds: routeIds,
+		})
+	}
+
+	var data = zoneutils.ComposeZone(zoneRecords, &zoneutils.ComposeOptions{
+		Origin:     domain.Name,
+		DefaultTTL: 600,
+		RouteNames: routeNames,
+	})
+	return &pb.ExportNSRecordsToZoneFileResponse{ZoneFileData: data}, nil
+}
+
+// AuditNSRecords 检查已有的记录是否符合校验规则
+// 指定域名时只检查此域名，否则检查所有域名，用户只能检查自己的域名
+func (this *NSRecordService) AuditNSRecords(ctx context.Context, req *pb.AuditNSRecordsRequest) (*pb.AuditNSRecordsResponse, error) {
+	_, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var tx = this.NullTx()
+	var domains = []*nameservers.NSDomain{}
+	if req.NsDomainId > 0 {
+		if userId > 0 {
+			err = nameservers.SharedNSDomainDAO.CheckUserDomain(tx, userId, req.NsDomainId)
+			if err != nil {
+				return nil, err
+			}
+		}
+		domain, err := nameservers.SharedNSDomainDAO.FindEnabledNSDomain(tx, req.NsDomainId)
+		if err != nil {
+			return nil, err
+		}
+		if domain == nil {
+			return nil, errors.New("can not find domain '" + types.String(req.NsDomainId) + "'")
+		}
+		domains = append(domains, domain)
+	} else {
+		var size int64 = 100
+		for offset := int64(0); ; offset += size {
+			pageDomains, err := nameservers.SharedNSDomainDAO.ListEnabledDomains(tx, 0, userId, "", offset, size)
+			if err != nil {
+				return nil, err
+			}
+			domains = append(domains, pageDomains...)
+			if int64(len(pageDomains)) < size {
+				break
+			}
+		}
+	}
+
+	var problemMaps = []maps.Map{}
+	var countRecords int64 = 0
+	for _, domain := range domains {
+		records, err := nameservers.SharedNSRecordDAO.FindAllEnabledDomainRecords(tx, int64(domain.Id))
+		if err != nil {
+			return nil, err
+		}
+		countRecords += int64(len(records))
+
+		var zoneRecords = []*zoneutils.Record{}
+		for _, record := range records {
+			zoneRecords = append(zoneRecords, record.ToZoneRecord())
+		}
+		for _, problem := range zoneutils.AuditRecords(zoneRecords, &zoneutils.ValidateOptions{Origin: domain.Name}) {
+			var record = records[problem.Index]
+			problemMaps = append(problemMaps, maps.Map{
+				"nsDomainId":   domain.Id,
+				"nsDomainName": domain.Name,
+				"nsRecordId":   record.Id,
+				"name":         record.Name,
+				"type":         record.Type,
+				"value":        record.Value,
+				"ttl":          record.Ttl,
+				"nsRouteIds":   record.DecodeRouteIds(),
+				"code":         problem.Error.Code,
+				"message":      problem.Error.Message,
+			})
+		}
+	}
+
+	problemsJSON, err := json.Marshal(problemMaps)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.AuditNSRecordsResponse{
+		CountNSDomains: int64(len(domains)),
+		CountNSRecords: countRecords,
+		ProblemsJSON:   problemsJSON,
+	}, nil
+}
+
 // ListNSRecordsAfterVersion 根据版本列出一组记录
 func (this *NSRecordService) ListNSRecordsAfterVersion(ctx context.Context, req *pb.ListNSRecordsAfterVersionRequest) (*pb.ListNSRecordsAfterVersionResponse, error) {
 	_, _, err := this.ValidateNodeId(ctx, rpcutils.UserTypeDNS)
@@ -193,6 +485,16 @@ func (this *NSRecordService) ListNSRecordsAfterVersion(ctx context.Context, req
 
 	// 集群ID
 	var tx = this.NullTx()
+
+	// 客户端版本早于最小可用版本时，部分删除操作已经无法同步，需要完整同步
+	resyncRequired, horizonVersion, err := models.SharedSyncHorizonDAO.CheckResyncRequired(tx, models.SyncTombstoneKindNSRecord, req.Version)
+	if err != nil {
+		return nil, err
+	}
+	if resyncRequired {
+		return &pb.ListNSRecordsAfterVersionResponse{ResyncRequired: true, HorizonVersion: horizonVersion}, nil
+	}
+
 	records, err := nameservers.SharedNSRecordDAO.ListRecordsAfterVersion(tx, req.Version, 2000)
 	if err != nil {
 		return nil, err
@@ -224,3 +526,17 @@ func (this *NSRecordService) ListNSRecordsAfterVersion(ctx context.Context, req
 	}
 	return &pb.ListNSRecordsAfterVersionResponse{NsRecords: pbRecords}, nil
 }
+
+// 检查线路是否存在，用户只能使用公共线路和自己的线路
+func (this *NSRecordService) checkRouteIds(tx *dbs.Tx, userId int64, routeIds []int64) error {
+	for _, routeId := range routeIds {
+		route, err := nameservers.SharedNSRouteDAO.FindEnabledNSRoute(tx, routeId)
+		if err != nil {
+			return err
+		}
+		if route == nil || (userId > 0 && route.UserId > 0 && int64(route.UserId) != userId) {
+			return errors.New("invalid route '" + types.String(routeId) + "'")
+		}
+	}
+	return nil
+}
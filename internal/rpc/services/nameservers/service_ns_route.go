@@ -4,6 +4,7 @@ package nameservers
 
 import (
 	"context"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models/nameservers"
 	"github.com/TeaOSLab/EdgeAPI/internal/rpc/services"
 	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
@@ -116,17 +117,25 @@ func (this *NSRouteService) FindEnabledNSRoute(ctx context.Context, req *pb.Find
 
 // FindAllEnabledNSRoutes 读取所有线路
 func (this *NSRouteService) FindAllEnabledNSRoutes(ctx context.Context, req *pb.FindAllEnabledNSRoutesRequest) (*pb.FindAllEnabledNSRoutesResponse, error) {
-	_, err := this.ValidateAdmin(ctx, 0)
+	_, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
 	if err != nil {
 		return nil, err
 	}
 	var tx = this.NullTx()
+	if userId > 0 {
+		req.UserId = 0
+	}
 	routes, err := nameservers.SharedNSRouteDAO.FindAllEnabledRoutes(tx, req.NsClusterId, req.NsDomainId, req.UserId)
 	if err != nil {
 		return nil, err
 	}
 	var pbRoutes = []*pb.NSRoute{}
 	for _, route := range routes {
+		// 用户只能使用公共线路和自己的线路
+		if userId > 0 && route.UserId > 0 && int64(route.UserId) != userId {
+			continue
+		}
+
 		// 集群
 		var pbCluster *pb.NSCluster
 		if route.ClusterId > 0 {
@@ -194,6 +203,16 @@ func (this *NSRouteService) ListNSRoutesAfterVersion(ctx context.Context, req *p
 
 	// 集群ID
 	var tx = this.NullTx()
+
+	// 客户端版本早于最小可用版本时，部分删除操作已经无法同步，需要完整同步
+	resyncRequired, horizonVersion, err := models.SharedSyncHorizonDAO.CheckResyncRequired(tx, models.SyncTombstoneKindNSRoute, req.Version)
+	if err != nil {
+		return nil, err
+	}
+	if resyncRequired {
+		return &pb.ListNSRoutesAfterVersionResponse{ResyncRequired: true, HorizonVersion: horizonVersion}, nil
+	}
+
 	routes, err := nameservers.SharedNSRouteDAO.ListRoutesAfterVersion(tx, req.Version, 2000)
 	if err != nil {
 		return nil, err
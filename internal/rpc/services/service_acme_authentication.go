@@ -33,3 +33,27 @@ func (this *ACMEAuthenticationService) FindACMEAuthenticationKeyWithToken(ctx co
 	}
 	return &pb.FindACMEAuthenticationKeyWithTokenResponse{Key: auth.Key}, nil
 }
+
+// FindAllACMEChallengeCerts 获取所有TLS-ALPN-01认证证书
+func (this *ACMEAuthenticationService) FindAllACMEChallengeCerts(ctx context.Context, req *pb.FindAllACMEChallengeCertsRequest) (*pb.FindAllACMEChallengeCertsResponse, error) {
+	_, err := this.ValidateNode(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+
+	certs, err := acme.SharedACMEChallengeCertDAO.FindAllAvailableChallengeCerts(tx)
+	if err != nil {
+		return nil, err
+	}
+	pbCerts := []*pb.ACMEChallengeCert{}
+	for _, cert := range certs {
+		pbCerts = append(pbCerts, &pb.ACMEChallengeCert{
+			Domain:   cert.Domain,
+			CertData: []byte(cert.CertData),
+			KeyData:  []byte(cert.KeyData),
+		})
+	}
+	return &pb.FindAllACMEChallengeCertsResponse{AcmeChallengeCerts: pbCerts}, nil
+}
@@ -7,7 +7,10 @@ import (
 	acmemodels "github.com/TeaOSLab/EdgeAPI/internal/db/models/acme"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models/dns"
 	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/types"
 )
 
 // ACME任务相关服务
@@ -143,10 +146,13 @@ func (this *ACMETaskService) ListEnabledACMETasks(ctx context.Context, req *pb.L
 		}
 		if taskLog != nil {
 			pbTaskLog = &pb.ACMETaskLog{
-				Id:        int64(taskLog.Id),
-				IsOk:      taskLog.IsOk == 1,
-				Error:     taskLog.Error,
-				CreatedAt: int64(taskLog.CreatedAt),
+				Id:          int64(taskLog.Id),
+				IsOk:        taskLog.IsOk == 1,
+				Error:       taskLog.Error,
+				CreatedAt:   int64(taskLog.CreatedAt),
+				Type:        taskLog.Type,
+				Attempt:     int32(taskLog.Attempt),
+				NextRetryAt: int64(taskLog.NextRetryAt),
 			}
 		}
 
@@ -162,6 +168,11 @@ func (this *ACMETaskService) ListEnabledACMETasks(ctx context.Context, req *pb.L
 			SslCert:           pbCert,
 			LatestACMETaskLog: pbTaskLog,
 			AuthType:          task.AuthType,
+			KeyType:           task.KeyType,
+			DualSslCertId:     int64(task.DualCertId),
+			DnsDelegated:      len(task.DnsDelegationToken) > 0,
+			RenewRetries:      int32(task.RenewRetries),
+			NextRenewAt:       int64(task.NextRenewAt),
 		})
 	}
 
@@ -180,10 +191,17 @@ func (this *ACMETaskService) CreateACMETask(ctx context.Context, req *pb.CreateA
 	}
 
 	tx := this.NullTx()
-	taskId, err := acmemodels.SharedACMETaskDAO.CreateACMETask(tx, adminId, userId, req.AuthType, req.AcmeUserId, req.DnsProviderId, req.DnsDomain, req.Domains, req.AutoRenew)
+	taskId, err := acmemodels.SharedACMETaskDAO.CreateACMETask(tx, adminId, userId, req.AuthType, req.AcmeUserId, req.DnsProviderId, req.DnsDomain, req.Domains, req.AutoRenew, req.KeyType)
 	if err != nil {
 		return nil, err
 	}
+
+	if req.AuthType == acme.AuthTypeDNS && req.DnsDelegated {
+		err = acmemodels.SharedACMETaskDAO.UpdateACMETaskDNSDelegation(tx, taskId, true)
+		if err != nil {
+			return nil, err
+		}
+	}
 	return &pb.CreateACMETaskResponse{AcmeTaskId: taskId}, nil
 }
 
@@ -204,7 +222,12 @@ func (this *ACMETaskService) UpdateACMETask(ctx context.Context, req *pb.UpdateA
 		return nil, this.PermissionError()
 	}
 
-	err = acmemodels.SharedACMETaskDAO.UpdateACMETask(tx, req.AcmeTaskId, req.AcmeUserId, req.DnsProviderId, req.DnsDomain, req.Domains, req.AutoRenew)
+	err = acmemodels.SharedACMETaskDAO.UpdateACMETask(tx, req.AcmeTaskId, req.AcmeUserId, req.DnsProviderId, req.DnsDomain, req.Domains, req.AutoRenew, req.KeyType)
+	if err != nil {
+		return nil, err
+	}
+
+	err = acmemodels.SharedACMETaskDAO.UpdateACMETaskDNSDelegation(tx, req.AcmeTaskId, req.DnsDelegated)
 	if err != nil {
 		return nil, err
 	}
@@ -261,6 +284,55 @@ func (this *ACMETaskService) RunACMETask(ctx context.Context, req *pb.RunACMETas
 	}, nil
 }
 
+// RevokeACMETaskCert 吊销任务生成的证书
+func (this *ACMETaskService) RevokeACMETaskCert(ctx context.Context, req *pb.RevokeACMETaskCertRequest) (*pb.RevokeACMETaskCertResponse, error) {
+	adminId, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+
+	canAccess, err := acmemodels.SharedACMETaskDAO.CheckACMETask(tx, adminId, userId, req.AcmeTaskId)
+	if err != nil {
+		return nil, err
+	}
+	if !canAccess {
+		return nil, this.PermissionError()
+	}
+
+	var reason = acme.RevokeReason(req.Reason)
+	isOk, msg := acmemodels.SharedACMETaskDAO.RevokeTaskCert(tx, req.AcmeTaskId, reason)
+	if isOk {
+		err = this.CreateAdminOrUserLog(tx, adminId, userId, "吊销ACME任务 "+types.String(req.AcmeTaskId)+" 的证书，原因："+acme.FindRevokeReasonName(reason), "ACMETaskService.RevokeACMETaskCert")
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &pb.RevokeACMETaskCertResponse{
+		IsOk:  isOk,
+		Error: msg,
+	}, nil
+}
+
+// FindAllACMERevokeReasons 查找所有可用的证书吊销原因
+func (this *ACMETaskService) FindAllACMERevokeReasons(ctx context.Context, req *pb.FindAllACMERevokeReasonsRequest) (*pb.FindAllACMERevokeReasonsResponse, error) {
+	_, _, err := this.ValidateAdminAndUser(ctx, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var pbReasons = []*pb.FindAllACMERevokeReasonsResponse_Reason{}
+	for _, reason := range acme.FindAllRevokeReasons() {
+		pbReasons = append(pbReasons, &pb.FindAllACMERevokeReasonsResponse_Reason{
+			Name: reason.GetString("name"),
+			Code: reason.GetInt32("code"),
+		})
+	}
+	return &pb.FindAllACMERevokeReasonsResponse{Reasons: pbReasons}, nil
+}
+
 // 查找单个任务信息
 func (this *ACMETaskService) FindEnabledACMETask(ctx context.Context, req *pb.FindEnabledACMETaskRequest) (*pb.FindEnabledACMETaskResponse, error) {
 	adminId, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
@@ -319,14 +391,247 @@ func (this *ACMETaskService) FindEnabledACMETask(ctx context.Context, req *pb.Fi
 	}
 
 	return &pb.FindEnabledACMETaskResponse{AcmeTask: &pb.ACMETask{
-		Id:          int64(task.Id),
-		IsOn:        task.IsOn == 1,
-		DnsDomain:   task.DnsDomain,
-		Domains:     task.DecodeDomains(),
-		CreatedAt:   int64(task.CreatedAt),
-		AutoRenew:   task.AutoRenew == 1,
-		DnsProvider: pbProvider,
-		AcmeUser:    pbACMEUser,
-		AuthType:    task.AuthType,
+		Id:            int64(task.Id),
+		IsOn:          task.IsOn == 1,
+		DnsDomain:     task.DnsDomain,
+		Domains:       task.DecodeDomains(),
+		CreatedAt:     int64(task.CreatedAt),
+		AutoRenew:     task.AutoRenew == 1,
+		DnsProvider:   pbProvider,
+		AcmeUser:      pbACMEUser,
+		AuthType:      task.AuthType,
+		KeyType:       task.KeyType,
+		DualSslCertId: int64(task.DualCertId),
+		DnsDelegated:  len(task.DnsDelegationToken) > 0,
+		RenewRetries:  int32(task.RenewRetries),
+		NextRenewAt:   int64(task.NextRenewAt),
 	}}, nil
 }
+
+// FindAllACMEKeyTypes 查找所有支持的证书私钥类型
+func (this *ACMETaskService) FindAllACMEKeyTypes(ctx context.Context, req *pb.FindAllACMEKeyTypesRequest) (*pb.FindAllACMEKeyTypesResponse, error) {
+	_, _, err := this.ValidateAdminAndUser(ctx, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var pbKeyTypes = []*pb.FindAllACMEKeyTypesResponse_KeyType{}
+	for _, keyType := range acme.FindAllKeyTypes() {
+		pbKeyTypes = append(pbKeyTypes, &pb.FindAllACMEKeyTypesResponse_KeyType{
+			Name:        keyType.GetString("name"),
+			Code:        keyType.GetString("code"),
+			Description: keyType.GetString("description"),
+		})
+	}
+	return &pb.FindAllACMEKeyTypesResponse{KeyTypes: pbKeyTypes}, nil
+}
+
+// CountACMETaskLogs 计算任务的执行日志数量
+func (this *ACMETaskService) CountACMETaskLogs(ctx context.Context, req *pb.CountACMETaskLogsRequest) (*pb.RPCCountResponse, error) {
+	adminId, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+
+	canAccess, err := acmemodels.SharedACMETaskDAO.CheckACMETask(tx, adminId, userId, req.AcmeTaskId)
+	if err != nil {
+		return nil, err
+	}
+	if !canAccess {
+		return nil, this.PermissionError()
+	}
+
+	count, err := acmemodels.SharedACMETaskLogDAO.CountACMETaskLogs(tx, req.AcmeTaskId)
+	if err != nil {
+		return nil, err
+	}
+	return this.SuccessCount(count)
+}
+
+// ListACMETaskLogs 列出单页任务的执行日志，包括每次自动续期的尝试
+func (this *ACMETaskService) ListACMETaskLogs(ctx context.Context, req *pb.ListACMETaskLogsRequest) (*pb.ListACMETaskLogsResponse, error) {
+	adminId, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+
+	canAccess, err := acmemodels.SharedACMETaskDAO.CheckACMETask(tx, adminId, userId, req.AcmeTaskId)
+	if err != nil {
+		return nil, err
+	}
+	if !canAccess {
+		return nil, this.PermissionError()
+	}
+
+	taskLogs, err := acmemodels.SharedACMETaskLogDAO.ListACMETaskLogs(tx, req.AcmeTaskId, req.Offset, req.Size)
+	if err != nil {
+		return nil, err
+	}
+	pbTaskLogs := []*pb.ACMETaskLog{}
+	for _, taskLog := range taskLogs {
+		pbTaskLogs = append(pbTaskLogs, &pb.ACMETaskLog{
+			Id:          int64(taskLog.Id),
+			IsOk:        taskLog.IsOk == 1,
+			Error:       taskLog.Error,
+			CreatedAt:   int64(taskLog.CreatedAt),
+			Type:        taskLog.Type,
+			Attempt:     int32(taskLog.Attempt),
+			NextRetryAt: int64(taskLog.NextRetryAt),
+		})
+	}
+	return &pb.ListACMETaskLogsResponse{AcmeTaskLogs: pbTaskLogs}, nil
+}
+
+// FindACMERenewalConfig 读取自动续期设置
+func (this *ACMETaskService) FindACMERenewalConfig(ctx context.Context, req *pb.FindACMERenewalConfigRequest) (*pb.FindACMERenewalConfigResponse, error) {
+	_, err := this.ValidateAdmin(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+	config, err := acmemodels.SharedACMETaskDAO.FindRenewalConfig(tx)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.FindACMERenewalConfigResponse{
+		RenewRatio:       float32(config.RenewRatio),
+		MaxRetries:       int32(config.MaxRetries),
+		RetryBaseSeconds: config.RetryBaseSeconds,
+		RetryMaxSeconds:  config.RetryMaxSeconds,
+		Concurrent:       int32(config.Concurrent),
+	}, nil
+}
+
+// UpdateACMERenewalConfig 修改自动续期设置
+func (this *ACMETaskService) UpdateACMERenewalConfig(ctx context.Context, req *pb.UpdateACMERenewalConfigRequest) (*pb.RPCSuccess, error) {
+	_, err := this.ValidateAdmin(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+	err = acmemodels.SharedACMETaskDAO.UpdateRenewalConfig(tx, &acmemodels.ACMERenewalConfig{
+		RenewRatio:       float64(req.RenewRatio),
+		MaxRetries:       int(req.MaxRetries),
+		RetryBaseSeconds: req.RetryBaseSeconds,
+		RetryMaxSeconds:  req.RetryMaxSeconds,
+		Concurrent:       int(req.Concurrent),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// FindACMETaskDNSDelegationRecords 查找任务需要用户添加的CNAME委托记录
+func (this *ACMETaskService) FindACMETaskDNSDelegationRecords(ctx context.Context, req *pb.FindACMETaskDNSDelegationRecordsRequest) (*pb.FindACMETaskDNSDelegationRecordsResponse, error) {
+	tx := this.NullTx()
+	delegation, task, err := this.findTaskDNSDelegation(ctx, tx, req.AcmeTaskId)
+	if err != nil {
+		return nil, err
+	}
+
+	var pbRecords = []*pb.FindACMETaskDNSDelegationRecordsResponse_Record{}
+	if delegation != nil {
+		for _, record := range delegation.Records(task.DecodeDomains()) {
+			pbRecords = append(pbRecords, &pb.FindACMETaskDNSDelegationRecordsResponse_Record{
+				Domain: record.Domain,
+				Name:   record.Name,
+				Type:   "CNAME",
+				Value:  record.Value,
+			})
+		}
+	}
+	return &pb.FindACMETaskDNSDelegationRecordsResponse{Records: pbRecords}, nil
+}
+
+// CheckACMETaskDNSDelegation 检查用户是否已正确添加CNAME委托记录
+func (this *ACMETaskService) CheckACMETaskDNSDelegation(ctx context.Context, req *pb.CheckACMETaskDNSDelegationRequest) (*pb.CheckACMETaskDNSDelegationResponse, error) {
+	tx := this.NullTx()
+	delegation, task, err := this.findTaskDNSDelegation(ctx, tx, req.AcmeTaskId)
+	if err != nil {
+		return nil, err
+	}
+	if delegation == nil {
+		return &pb.CheckACMETaskDNSDelegationResponse{IsOk: false, Error: "此任务没有使用CNAME委托验证"}, nil
+	}
+
+	err = delegation.Check(task.DecodeDomains())
+	if err != nil {
+		return &pb.CheckACMETaskDNSDelegationResponse{IsOk: false, Error: err.Error()}, nil
+	}
+	return &pb.CheckACMETaskDNSDelegationResponse{IsOk: true}, nil
+}
+
+// FindACMEDNSDelegationConfig 读取CNAME委托验证设置
+func (this *ACMETaskService) FindACMEDNSDelegationConfig(ctx context.Context, req *pb.FindACMEDNSDelegationConfigRequest) (*pb.FindACMEDNSDelegationConfigResponse, error) {
+	_, err := this.ValidateAdmin(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+	config, err := acmemodels.SharedACMETaskDAO.FindDNSDelegationConfig(tx)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return &pb.FindACMEDNSDelegationConfigResponse{}, nil
+	}
+	return &pb.FindACMEDNSDelegationConfigResponse{
+		DnsProviderId: config.DNSProviderId,
+		DnsDomain:     config.DNSDomain,
+	}, nil
+}
+
+// UpdateACMEDNSDelegationConfig 修改CNAME委托验证设置
+func (this *ACMETaskService) UpdateACMEDNSDelegationConfig(ctx context.Context, req *pb.UpdateACMEDNSDelegationConfigRequest) (*pb.RPCSuccess, error) {
+	_, err := this.ValidateAdmin(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+	err = acmemodels.SharedACMETaskDAO.UpdateDNSDelegationConfig(tx, &acmemodels.ACMEDNSDelegationConfig{
+		DNSProviderId: req.DnsProviderId,
+		DNSDomain:     req.DnsDomain,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// 检查权限并查找任务的CNAME委托验证信息
+func (this *ACMETaskService) findTaskDNSDelegation(ctx context.Context, tx *dbs.Tx, acmeTaskId int64) (*acme.DNSDelegation, *acmemodels.ACMETask, error) {
+	adminId, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	canAccess, err := acmemodels.SharedACMETaskDAO.CheckACMETask(tx, adminId, userId, acmeTaskId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !canAccess {
+		return nil, nil, this.PermissionError()
+	}
+
+	task, err := acmemodels.SharedACMETaskDAO.FindEnabledACMETask(tx, acmeTaskId)
+	if err != nil {
+		return nil, nil, err
+	}
+	if task == nil {
+		return nil, nil, errors.New("can not find task")
+	}
+	delegation, _, err := acmemodels.SharedACMETaskDAO.ComposeDNSDelegation(tx, task)
+	if err != nil {
+		return nil, nil, err
+	}
+	return delegation, task, nil
+}
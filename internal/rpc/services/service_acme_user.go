@@ -2,8 +2,10 @@ package services
 
 import (
 	"context"
+	"github.com/TeaOSLab/EdgeAPI/internal/acme"
 	acmemodels "github.com/TeaOSLab/EdgeAPI/internal/db/models/acme"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"github.com/iwind/TeaGo/types"
 )
 
 // 用户服务
@@ -21,7 +23,7 @@ func (this *ACMEUserService) CreateACMEUser(ctx context.Context, req *pb.CreateA
 
 	tx := this.NullTx()
 
-	acmeUserId, err := acmemodels.SharedACMEUserDAO.CreateACMEUser(tx, adminId, userId, req.Email, req.Description)
+	acmeUserId, err := acmemodels.SharedACMEUserDAO.CreateACMEUser(tx, adminId, userId, req.Email, req.Description, req.CaURL, req.EabKeyId, req.EabHMACKey)
 	if err != nil {
 		return nil, err
 	}
@@ -51,6 +53,42 @@ func (this *ACMEUserService) UpdateACMEUser(ctx context.Context, req *pb.UpdateA
 	if err != nil {
 		return nil, err
 	}
+
+	err = acmemodels.SharedACMEUserDAO.UpdateACMEUserCA(tx, req.AcmeUserId, req.CaURL, req.EabKeyId, req.EabHMACKey)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// RolloverACMEUserKey 更换用户私钥
+func (this *ACMEUserService) RolloverACMEUserKey(ctx context.Context, req *pb.RolloverACMEUserKeyRequest) (*pb.RPCSuccess, error) {
+	// 校验请求
+	adminId, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+
+	// 检查是否有权限
+	b, err := acmemodels.SharedACMEUserDAO.CheckACMEUser(tx, req.AcmeUserId, adminId, userId)
+	if err != nil {
+		return nil, err
+	}
+	if !b {
+		return nil, this.PermissionError()
+	}
+
+	err = acmemodels.SharedACMEUserDAO.RolloverACMEUserKey(tx, req.AcmeUserId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = this.CreateAdminOrUserLog(tx, adminId, userId, "更换ACME用户 "+types.String(req.AcmeUserId)+" 的私钥", "ACMEUserService.RolloverACMEUserKey")
+	if err != nil {
+		return nil, err
+	}
 	return this.Success()
 }
 
@@ -118,6 +156,9 @@ func (this *ACMEUserService) ListACMEUsers(ctx context.Context, req *pb.ListACME
 			Email:       user.Email,
 			Description: user.Description,
 			CreatedAt:   int64(user.CreatedAt),
+			CaURL:       user.CaURL,
+			EabKeyId:    user.EabKeyId,
+			HasEAB:      len(user.EabKeyId) > 0,
 		})
 	}
 	return &pb.ListACMEUsersResponse{AcmeUsers: result}, nil
@@ -154,6 +195,10 @@ func (this *ACMEUserService) FindEnabledACMEUser(ctx context.Context, req *pb.Fi
 		Email:       acmeUser.Email,
 		Description: acmeUser.Description,
 		CreatedAt:   int64(acmeUser.CreatedAt),
+		CaURL:       acmeUser.CaURL,
+		EabKeyId:    acmeUser.EabKeyId,
+		HasEAB:      len(acmeUser.EabKeyId) > 0,
+		KeyRolledAt: int64(acmeUser.KeyRolledAt),
 	}}, nil
 }
 
@@ -178,7 +223,30 @@ func (this *ACMEUserService) FindAllACMEUsers(ctx context.Context, req *pb.FindA
 			Email:       user.Email,
 			Description: user.Description,
 			CreatedAt:   int64(user.CreatedAt),
+			CaURL:       user.CaURL,
+			EabKeyId:    user.EabKeyId,
+			HasEAB:      len(user.EabKeyId) > 0,
 		})
 	}
 	return &pb.FindAllACMEUsersResponse{AcmeUsers: result}, nil
 }
+
+// FindAllACMEProviders 查找所有内置的CA服务商
+func (this *ACMEUserService) FindAllACMEProviders(ctx context.Context, req *pb.FindAllACMEProvidersRequest) (*pb.FindAllACMEProvidersResponse, error) {
+	_, _, err := this.ValidateAdminAndUser(ctx, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var pbProviders = []*pb.ACMEProvider{}
+	for _, provider := range acme.FindAllProviders() {
+		pbProviders = append(pbProviders, &pb.ACMEProvider{
+			Name:        provider.Name,
+			Code:        provider.Code,
+			Description: provider.Description,
+			CaURL:       provider.CAURL,
+			RequireEAB:  provider.RequireEAB,
+		})
+	}
+	return &pb.FindAllACMEProvidersResponse{AcmeProviders: pbProviders}, nil
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
 	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"github.com/iwind/TeaGo/types"
 )
 
 type APINodeService struct {
@@ -186,24 +187,51 @@ func (this *APINodeService) FindEnabledAPINode(ctx context.Context, req *pb.Find
 	}
 
 	result := &pb.APINode{
-		Id:              int64(node.Id),
-		IsOn:            node.IsOn == 1,
-		NodeClusterId:   int64(node.ClusterId),
-		UniqueId:        node.UniqueId,
-		Secret:          node.Secret,
-		Name:            node.Name,
-		Description:     node.Description,
-		HttpJSON:        []byte(node.Http),
-		HttpsJSON:       []byte(node.Https),
-		RestIsOn:        node.RestIsOn == 1,
-		RestHTTPJSON:    []byte(node.RestHTTP),
-		RestHTTPSJSON:   []byte(node.RestHTTPS),
-		AccessAddrsJSON: []byte(node.AccessAddrs),
-		AccessAddrs:     accessAddrs,
+		Id:                int64(node.Id),
+		IsOn:              node.IsOn == 1,
+		NodeClusterId:     int64(node.ClusterId),
+		UniqueId:          node.UniqueId,
+		Secret:            node.Secret,
+		Name:              node.Name,
+		Description:       node.Description,
+		HttpJSON:          []byte(node.Http),
+		HttpsJSON:         []byte(node.Https),
+		RestIsOn:          node.RestIsOn == 1,
+		RestHTTPJSON:      []byte(node.RestHTTP),
+		RestHTTPSJSON:     []byte(node.RestHTTPS),
+		AccessAddrsJSON:   []byte(node.AccessAddrs),
+		AccessAddrs:       accessAddrs,
+		RequireClientCert: node.RequireClientCert == 1,
 	}
 	return &pb.FindEnabledAPINodeResponse{Node: result}, nil
 }
 
+// UpdateAPINodeRequireClientCert 设置是否要求节点使用客户端证书
+// 修改后需要重启API节点才能生效
+func (this *APINodeService) UpdateAPINodeRequireClientCert(ctx context.Context, req *pb.UpdateAPINodeRequireClientCertRequest) (*pb.RPCSuccess, error) {
+	adminId, err := this.ValidateAdmin(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+
+	err = models.SharedAPINodeDAO.UpdateAPINodeRequireClientCert(tx, req.NodeId, req.RequireClientCert)
+	if err != nil {
+		return nil, err
+	}
+
+	var description = "关闭API节点 " + types.String(req.NodeId) + " 的客户端证书认证"
+	if req.RequireClientCert {
+		description = "开启API节点 " + types.String(req.NodeId) + " 的客户端证书认证"
+	}
+	err = this.CreateAdminOrUserLog(tx, adminId, 0, description, "APINodeService.UpdateAPINodeRequireClientCert")
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
 // 获取当前API节点的版本
 func (this *APINodeService) FindCurrentAPINodeVersion(ctx context.Context, req *pb.FindCurrentAPINodeVersionRequest) (*pb.FindCurrentAPINodeVersionResponse, error) {
 	_, _, err := rpcutils.ValidateRequest(ctx)
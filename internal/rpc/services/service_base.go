@@ -163,6 +163,12 @@ func (this *BaseService) ValidateNodeId(ctx context.Context, roles ...rpcutils.U
 		return rpcutils.UserTypeNone, 0, errors.New("authenticate timeout, please check your system clock")
 	}
 
+	// 校验客户端证书
+	err = rpcutils.ValidateNodeClientCert(ctx, apiToken.Role, nodeId)
+	if err != nil {
+		return rpcutils.UserTypeNone, 0, err
+	}
+
 	switch apiToken.Role {
 	case rpcutils.UserTypeNode:
 		nodeIntId, err = models.SharedNodeDAO.FindEnabledNodeIdWithUniqueId(nil, nodeId)
@@ -197,6 +203,14 @@ func (this *BaseService) ValidateNodeId(ctx context.Context, roles ...rpcutils.U
 	return
 }
 
+// CreateAdminOrUserLog 记录管理员或用户的操作日志
+func (this *BaseService) CreateAdminOrUserLog(tx *dbs.Tx, adminId int64, userId int64, description string, action string) error {
+	if userId > 0 {
+		return models.SharedLogDAO.CreateLog(tx, rpcutils.UserTypeUser, userId, models.LevelInfo, description, action, "")
+	}
+	return models.SharedLogDAO.CreateLog(tx, rpcutils.UserTypeAdmin, adminId, models.LevelInfo, description, action, "")
+}
+
 // Success 返回成功
 func (this *BaseService) Success() (*pb.RPCSuccess, error) {
 	return &pb.RPCSuccess{}, nil
@@ -9,6 +9,7 @@ import (
 	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
 	"github.com/TeaOSLab/EdgeAPI/internal/errors"
 	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
+	"github.com/TeaOSLab/EdgeAPI/internal/tasks"
 	"github.com/TeaOSLab/EdgeAPI/internal/utils"
 	"github.com/TeaOSLab/EdgeAPI/internal/utils/numberutils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/nodeconfigs"
@@ -300,6 +301,212 @@ func (this *DNSDomainService) ExistAvailableDomains(ctx context.Context, req *pb
 	return &pb.ExistAvailableDomainsResponse{Exist: exist}, nil
 }
 
+// FindDNSDomainDrifts 查找域名最近一次检查发现的记录偏差
+func (this *DNSDomainService) FindDNSDomainDrifts(ctx context.Context, req *pb.FindDNSDomainDriftsRequest) (*pb.FindDNSDomainDriftsResponse, error) {
+	_, err := this.ValidateAdmin(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+
+	domain, err := dns.SharedDNSDomainDAO.FindEnabledDNSDomain(tx, req.DnsDomainId)
+	if err != nil {
+		return nil, err
+	}
+	if domain == nil {
+		return &pb.FindDNSDomainDriftsResponse{}, nil
+	}
+
+	drifts, err := domain.DecodeDrifts()
+	if err != nil {
+		return nil, err
+	}
+	if drifts == nil {
+		drifts = []*dns.DNSDomainDrift{}
+	}
+	driftsJSON, err := json.Marshal(drifts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.FindDNSDomainDriftsResponse{
+		DriftsJSON: driftsJSON,
+		CheckedAt:  int64(domain.DriftCheckedAt),
+		AutoHeal:   domain.DriftAutoHeal == 1,
+	}, nil
+}
+
+// UpdateDNSDomainDriftAutoHeal 设置是否自动修复域名记录偏差
+func (this *DNSDomainService) UpdateDNSDomainDriftAutoHeal(ctx context.Context, req *pb.UpdateDNSDomainDriftAutoHealRequest) (*pb.RPCSuccess, error) {
+	_, err := this.ValidateAdmin(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+
+	err = dns.SharedDNSDomainDAO.UpdateDomainDriftAutoHeal(tx, req.DnsDomainId, req.AutoHeal)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// FindDNSDomainMirror 查找域名的镜像服务商设置
+func (this *DNSDomainService) FindDNSDomainMirror(ctx context.Context, req *pb.FindDNSDomainMirrorRequest) (*pb.FindDNSDomainMirrorResponse, error) {
+	_, err := this.ValidateAdmin(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+
+	domain, err := dns.SharedDNSDomainDAO.FindEnabledDNSDomain(tx, req.DnsDomainId)
+	if err != nil {
+		return nil, err
+	}
+	if domain == nil {
+		return &pb.FindDNSDomainMirrorResponse{}, nil
+	}
+
+	routeMapping, err := domain.DecodeMirrorRoutes()
+	if err != nil {
+		return nil, err
+	}
+	routeMappingJSON, err := json.Marshal(routeMapping)
+	if err != nil {
+		return nil, err
+	}
+
+	// 镜像服务商支持的线路，用来设置线路映射
+	pbRoutes := []*pb.DNSRoute{}
+	if domain.MirrorProviderId > 0 {
+		secondary, err := tasks.NewDNSTaskExecutor().FindProviderManager(tx, int64(domain.MirrorProviderId))
+		if err != nil {
+			return nil, err
+		}
+		if secondary != nil {
+			routes, err := secondary.GetRoutes(domain.Name)
+			if err != nil {
+				return nil, errors.New("get mirror routes failed: " + err.Error())
+			}
+			for _, route := range routes {
+				pbRoutes = append(pbRoutes, &pb.DNSRoute{
+					Name: route.Name,
+					Code: route.Code,
+				})
+			}
+		}
+	}
+
+	return &pb.FindDNSDomainMirrorResponse{
+		MirrorDNSProviderId: int64(domain.MirrorProviderId),
+		MirrorRoutesJSON:    routeMappingJSON,
+		MirrorRoutes:        pbRoutes,
+	}, nil
+}
+
+// UpdateDNSDomainMirror 设置域名的镜像服务商
+func (this *DNSDomainService) UpdateDNSDomainMirror(ctx context.Context, req *pb.UpdateDNSDomainMirrorRequest) (*pb.RPCSuccess, error) {
+	_, err := this.ValidateAdmin(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+
+	domain, err := dns.SharedDNSDomainDAO.FindEnabledDNSDomain(tx, req.DnsDomainId)
+	if err != nil {
+		return nil, err
+	}
+	if domain == nil {
+		return nil, errors.New("can not find domain '" + numberutils.FormatInt64(req.DnsDomainId) + "'")
+	}
+
+	if req.MirrorDNSProviderId > 0 {
+		if req.MirrorDNSProviderId == int64(domain.ProviderId) {
+			return nil, errors.New("mirror provider should be different from the primary provider")
+		}
+		provider, err := dns.SharedDNSProviderDAO.FindEnabledDNSProvider(tx, req.MirrorDNSProviderId)
+		if err != nil {
+			return nil, err
+		}
+		if provider == nil {
+			return nil, errors.New("can not find mirror provider '" + numberutils.FormatInt64(req.MirrorDNSProviderId) + "'")
+		}
+	}
+
+	routeMapping := map[string]string{}
+	if len(req.MirrorRoutesJSON) > 0 {
+		err = json.Unmarshal(req.MirrorRoutesJSON, &routeMapping)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = dns.SharedDNSDomainDAO.UpdateDomainMirror(tx, req.DnsDomainId, req.MirrorDNSProviderId, routeMapping)
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// CheckDNSDomainMirror 检查镜像服务商中的记录是否和主服务商一致
+func (this *DNSDomainService) CheckDNSDomainMirror(ctx context.Context, req *pb.CheckDNSDomainMirrorRequest) (*pb.CheckDNSDomainMirrorResponse, error) {
+	_, err := this.ValidateAdmin(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+
+	domain, err := dns.SharedDNSDomainDAO.FindEnabledDNSDomain(tx, req.DnsDomainId)
+	if err != nil {
+		return nil, err
+	}
+	if domain == nil || domain.MirrorProviderId == 0 {
+		return &pb.CheckDNSDomainMirrorResponse{IsOk: false, Error: "域名没有设置镜像服务商"}, nil
+	}
+
+	executor := tasks.NewDNSTaskExecutor()
+	primary, err := executor.FindProviderManager(tx, int64(domain.ProviderId))
+	if err != nil {
+		return &pb.CheckDNSDomainMirrorResponse{IsOk: false, Error: "主服务商认证失败：" + err.Error()}, nil
+	}
+	secondary, err := executor.FindProviderManager(tx, int64(domain.MirrorProviderId))
+	if err != nil {
+		return &pb.CheckDNSDomainMirrorResponse{IsOk: false, Error: "镜像服务商认证失败：" + err.Error()}, nil
+	}
+	if primary == nil || secondary == nil {
+		return &pb.CheckDNSDomainMirrorResponse{IsOk: false, Error: "找不到域名服务商或者服务商类型不被支持"}, nil
+	}
+
+	primaryRecords, err := primary.GetRecords(domain.Name)
+	if err != nil {
+		return &pb.CheckDNSDomainMirrorResponse{IsOk: false, Error: "获取主服务商记录失败：" + err.Error()}, nil
+	}
+	secondaryRecords, err := secondary.GetRecords(domain.Name)
+	if err != nil {
+		return &pb.CheckDNSDomainMirrorResponse{IsOk: false, Error: "获取镜像服务商记录失败：" + err.Error()}, nil
+	}
+	routeMapping, err := domain.DecodeMirrorRoutes()
+	if err != nil {
+		return nil, err
+	}
+
+	changeSet := dnsclients.DiffMirrorRecords(domain.Name, primaryRecords, secondary, secondaryRecords, routeMapping)
+	changeSetJSON, err := json.Marshal(changeSet)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CheckDNSDomainMirrorResponse{
+		IsOk:          true,
+		IsConsistent:  changeSet.IsEmpty(),
+		ChangeSetJSON: changeSetJSON,
+	}, nil
+}
+
 // 转换域名信息
 func (this *DNSDomainService) convertDomainToPB(domain *dns.DNSDomain) (*pb.DNSDomain, error) {
 	domainId := int64(domain.Id)
@@ -380,11 +587,15 @@ func (this *DNSDomainService) convertDomainToPB(domain *dns.DNSDomain) (*pb.DNSD
 // 转换域名记录信息
 func (this *DNSDomainService) convertRecordToPB(record *dnstypes.Record) *pb.DNSRecord {
 	return &pb.DNSRecord{
-		Id:    record.Id,
-		Name:  record.Name,
-		Value: record.Value,
-		Type:  record.Type,
-		Route: record.Route,
+		Id:       record.Id,
+		Name:     record.Name,
+		Value:    record.Value,
+		Type:     record.Type,
+		Route:    record.Route,
+		Ttl:      record.TTL,
+		Priority: record.Priority,
+		Weight:   record.Weight,
+		Port:     record.Port,
 	}
 }
 
@@ -2,9 +2,12 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models/dns"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/tasks"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
 )
 
@@ -53,12 +56,13 @@ func (this *DNSTaskService) FindAllDoingDNSTasks(ctx context.Context, req *pb.Fi
 	pbTasks := []*pb.DNSTask{}
 	for _, task := range tasks {
 		pbTask := &pb.DNSTask{
-			Id:        int64(task.Id),
-			Type:      task.Type,
-			IsDone:    task.IsDone == 1,
-			IsOk:      task.IsOk == 1,
-			Error:     task.Error,
-			UpdatedAt: int64(task.UpdatedAt),
+			Id:             int64(task.Id),
+			Type:           task.Type,
+			IsDone:         task.IsDone == 1,
+			IsOk:           task.IsOk == 1,
+			Error:          task.Error,
+			UpdatedAt:      int64(task.UpdatedAt),
+			ChangeSetsJSON: []byte(task.ChangeSets),
 		}
 
 		switch task.Type {
@@ -117,3 +121,47 @@ func (this *DNSTaskService) DeleteDNSTask(ctx context.Context, req *pb.DeleteDNS
 	}
 	return this.Success()
 }
+
+// PlanDNSTask 预览DNS任务将要执行的变更，不会修改任何记录
+// 可以在修改集群域名、子域名或者节点线路之前，使用将要修改的值预览变更
+func (this *DNSTaskService) PlanDNSTask(ctx context.Context, req *pb.PlanDNSTaskRequest) (*pb.PlanDNSTaskResponse, error) {
+	_, err := this.ValidateAdmin(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	var targetId int64
+	var options = &tasks.DNSTaskPlanOptions{}
+	switch req.Type {
+	case dns.DNSTaskTypeClusterChange:
+		targetId = req.NodeClusterId
+		options.DNSDomainId = req.DnsDomainId
+		options.DNSName = req.DnsName
+	case dns.DNSTaskTypeNodeChange:
+		targetId = req.NodeId
+		if req.DnsRoutesJSON != nil {
+			routes := []string{}
+			err = json.Unmarshal(req.DnsRoutesJSON, &routes)
+			if err != nil {
+				return nil, errors.New("decode routes failed: " + err.Error())
+			}
+			options.NodeRoutes = map[int64][]string{req.NodeId: routes}
+		}
+	case dns.DNSTaskTypeServerChange:
+		targetId = req.ServerId
+	case dns.DNSTaskTypeDomainChange:
+		targetId = req.DnsDomainId
+	default:
+		return nil, errors.New("invalid task type '" + req.Type + "'")
+	}
+
+	plan, err := tasks.NewDNSTaskExecutor().Plan(req.Type, targetId, options)
+	if err != nil {
+		return nil, err
+	}
+	planJSON, err := json.Marshal(plan)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.PlanDNSTaskResponse{DnsTaskPlanJSON: planJSON}, nil
+}
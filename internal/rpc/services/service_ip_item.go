@@ -240,6 +240,15 @@ func (this *IPItemService) ListIPItemsAfterVersion(ctx context.Context, req *pb.
 
 	tx := this.NullTx()
 
+	// 客户端版本早于最小可用版本时，部分删除操作已经无法同步，需要完整同步
+	resyncRequired, horizonVersion, err := models.SharedSyncHorizonDAO.CheckResyncRequired(tx, models.SyncTombstoneKindIPItem, req.Version)
+	if err != nil {
+		return nil, err
+	}
+	if resyncRequired {
+		return &pb.ListIPItemsAfterVersionResponse{ResyncRequired: true, HorizonVersion: horizonVersion}, nil
+	}
+
 	result := []*pb.IPItem{}
 	items, err := models.SharedIPItemDAO.ListIPItemsAfterVersion(tx, req.Version, req.Size)
 	if err != nil {
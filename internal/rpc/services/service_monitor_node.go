@@ -61,6 +61,12 @@ func (this *MonitorNodeService) DeleteMonitorNode(ctx context.Context, req *pb.D
 		return nil, err
 	}
 
+	// 吊销客户端证书
+	err = models.SharedNodeClientCertDAO.RevokeNodeClientCerts(tx, rpcutils.UserTypeMonitor, req.NodeId)
+	if err != nil {
+		return nil, err
+	}
+
 	return this.Success()
 }
 
@@ -101,10 +101,18 @@ func (this *NodeService) RegisterClusterNode(ctx context.Context, req *pb.Regist
 		return nil, err
 	}
 
+	// 签发客户端证书
+	clientCert, err := models.SharedNodeClientCertDAO.IssueNodeClientCert(tx, rpcutils.UserTypeNode, nodeId, node.UniqueId)
+	if err != nil {
+		return nil, err
+	}
+
 	return &pb.RegisterClusterNodeResponse{
-		UniqueId:  node.UniqueId,
-		Secret:    node.Secret,
-		Endpoints: apiAddrs,
+		UniqueId:       node.UniqueId,
+		Secret:         node.Secret,
+		Endpoints:      apiAddrs,
+		ClientCertData: clientCert.CertData,
+		ClientKeyData:  clientCert.KeyData,
 	}, nil
 }
 
@@ -331,6 +339,12 @@ func (this *NodeService) DeleteNode(ctx context.Context, req *pb.DeleteNodeReque
 		return nil, err
 	}
 
+	// 吊销客户端证书
+	err = models.SharedNodeClientCertDAO.RevokeNodeClientCerts(tx, rpcutils.UserTypeNode, req.NodeId)
+	if err != nil {
+		return nil, err
+	}
+
 	return this.Success()
 }
 
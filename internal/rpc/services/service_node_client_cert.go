@@ -0,0 +1,191 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package services
+
+import (
+	"context"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models/nameservers"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/sslutils"
+	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/types"
+	"time"
+)
+
+// NodeClientCertService 节点客户端证书相关服务
+type NodeClientCertService struct {
+	BaseService
+}
+
+// IssueNodeClientCert 为节点签发客户端证书
+// 用于安装或者注册节点时获取证书
+func (this *NodeClientCertService) IssueNodeClientCert(ctx context.Context, req *pb.IssueNodeClientCertRequest) (*pb.IssueNodeClientCertResponse, error) {
+	adminId, err := this.ValidateAdmin(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+
+	clientCert, err := issueNodeClientCert(tx, req.Role, req.NodeId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = this.CreateAdminOrUserLog(tx, adminId, 0, "为"+req.Role+"节点 "+types.String(req.NodeId)+" 签发客户端证书", "NodeClientCertService.IssueNodeClientCert")
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.IssueNodeClientCertResponse{
+		CertData:  clientCert.CertData,
+		KeyData:   clientCert.KeyData,
+		ExpiresAt: clientCert.ExpiresAt,
+	}, nil
+}
+
+// RenewNodeClientCert 节点更新自己的客户端证书
+// 证书快要过期时才会签发新证书，否则返回空的证书内容
+func (this *NodeClientCertService) RenewNodeClientCert(ctx context.Context, req *pb.RenewNodeClientCertRequest) (*pb.RenewNodeClientCertResponse, error) {
+	role, nodeId, err := this.ValidateNodeId(ctx, rpcutils.UserTypeNode, rpcutils.UserTypeDNS, rpcutils.UserTypeMonitor, rpcutils.UserTypeUser)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+
+	isRotating, err := checkNodeClientCertRotating(ctx, tx, role, nodeId)
+	if err != nil {
+		return nil, err
+	}
+	if !isRotating {
+		return &pb.RenewNodeClientCertResponse{}, nil
+	}
+
+	clientCert, err := issueNodeClientCert(tx, role, nodeId)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.RenewNodeClientCertResponse{
+		CertData:  clientCert.CertData,
+		KeyData:   clientCert.KeyData,
+		ExpiresAt: clientCert.ExpiresAt,
+	}, nil
+}
+
+// FindNodeClientCert 查找节点当前使用的客户端证书
+func (this *NodeClientCertService) FindNodeClientCert(ctx context.Context, req *pb.FindNodeClientCertRequest) (*pb.FindNodeClientCertResponse, error) {
+	_, err := this.ValidateAdmin(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+
+	cert, err := models.SharedNodeClientCertDAO.FindLatestNodeClientCert(tx, req.Role, req.NodeId)
+	if err != nil {
+		return nil, err
+	}
+	if cert == nil {
+		return &pb.FindNodeClientCertResponse{NodeClientCert: nil}, nil
+	}
+	return &pb.FindNodeClientCertResponse{NodeClientCert: &pb.NodeClientCert{
+		Id:        int64(cert.Id),
+		Role:      cert.Role,
+		NodeId:    int64(cert.NodeId),
+		Serial:    cert.Serial,
+		CertData:  []byte(cert.CertData),
+		ExpiresAt: int64(cert.ExpiresAt),
+		CreatedAt: int64(cert.CreatedAt),
+	}}, nil
+}
+
+// RevokeNodeClientCerts 吊销节点所有的客户端证书
+func (this *NodeClientCertService) RevokeNodeClientCerts(ctx context.Context, req *pb.RevokeNodeClientCertsRequest) (*pb.RPCSuccess, error) {
+	adminId, err := this.ValidateAdmin(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+
+	err = models.SharedNodeClientCertDAO.RevokeNodeClientCerts(tx, req.Role, req.NodeId)
+	if err != nil {
+		return nil, err
+	}
+
+	err = this.CreateAdminOrUserLog(tx, adminId, 0, "吊销"+req.Role+"节点 "+types.String(req.NodeId)+" 的客户端证书", "NodeClientCertService.RevokeNodeClientCerts")
+	if err != nil {
+		return nil, err
+	}
+	return this.Success()
+}
+
+// 为节点签发客户端证书
+func issueNodeClientCert(tx *dbs.Tx, role string, nodeId int64) (*sslutils.NodeClientCert, error) {
+	uniqueId, err := findNodeUniqueId(tx, role, nodeId)
+	if err != nil {
+		return nil, err
+	}
+	return models.SharedNodeClientCertDAO.IssueNodeClientCert(tx, role, nodeId, uniqueId)
+}
+
+// 检查节点是否需要更新客户端证书
+// 优先检查节点当前连接使用的证书，节点还没有使用证书时检查最近签发的证书
+func checkNodeClientCertRotating(ctx context.Context, tx *dbs.Tx, role string, nodeId int64) (bool, error) {
+	var cert = rpcutils.FindPeerCert(ctx)
+	if cert != nil {
+		return cert.NotAfter.Before(time.Now().Add(models.NodeClientCertRotateBefore)), nil
+	}
+	return models.SharedNodeClientCertDAO.CheckNodeClientCertRotating(tx, role, nodeId)
+}
+
+// 查找节点的唯一ID，证书中使用唯一ID标识节点
+func findNodeUniqueId(tx *dbs.Tx, role string, nodeId int64) (string, error) {
+	if !rpcutils.IsNodeClientCertRole(role) {
+		return "", errors.New("unsupported node role '" + role + "'")
+	}
+
+	var uniqueId string
+	switch role {
+	case rpcutils.UserTypeNode:
+		node, err := models.SharedNodeDAO.FindEnabledNode(tx, nodeId)
+		if err != nil {
+			return "", err
+		}
+		if node != nil {
+			uniqueId = node.UniqueId
+		}
+	case rpcutils.UserTypeDNS:
+		node, err := nameservers.SharedNSNodeDAO.FindEnabledNSNode(tx, nodeId)
+		if err != nil {
+			return "", err
+		}
+		if node != nil {
+			uniqueId = node.UniqueId
+		}
+	case rpcutils.UserTypeMonitor:
+		node, err := models.SharedMonitorNodeDAO.FindEnabledMonitorNode(tx, nodeId)
+		if err != nil {
+			return "", err
+		}
+		if node != nil {
+			uniqueId = node.UniqueId
+		}
+	case rpcutils.UserTypeUser:
+		node, err := models.SharedUserNodeDAO.FindEnabledUserNode(tx, nodeId)
+		if err != nil {
+			return "", err
+		}
+		if node != nil {
+			uniqueId = node.UniqueId
+		}
+	}
+	if len(uniqueId) == 0 {
+		return "", errors.New("can not find " + role + " node with id '" + types.String(nodeId) + "'")
+	}
+	return uniqueId, nil
+}
@@ -631,6 +631,62 @@ func (this *NodeClusterService) UpdateNodeClusterTOA(ctx context.Context, req *p
 	return this.Success()
 }
 
+// FindNodeClusterDNSFailover 查找集群的DNS故障转移策略和状态
+func (this *NodeClusterService) FindNodeClusterDNSFailover(ctx context.Context, req *pb.FindNodeClusterDNSFailoverRequest) (*pb.FindNodeClusterDNSFailoverResponse, error) {
+	_, err := this.ValidateAdmin(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+
+	config, err := models.SharedNodeClusterDAO.FindClusterDNSFailoverConfig(tx, req.NodeClusterId)
+	if err != nil {
+		return nil, err
+	}
+	configJSON, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	state, err := models.SharedNodeClusterDAO.FindClusterDNSFailoverState(tx, req.NodeClusterId)
+	if err != nil {
+		return nil, err
+	}
+	stateJSON, err := json.Marshal(state)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.FindNodeClusterDNSFailoverResponse{
+		DnsFailoverJSON:      configJSON,
+		DnsFailoverStateJSON: stateJSON,
+	}, nil
+}
+
+// UpdateNodeClusterDNSFailover 修改集群的DNS故障转移策略
+func (this *NodeClusterService) UpdateNodeClusterDNSFailover(ctx context.Context, req *pb.UpdateNodeClusterDNSFailoverRequest) (*pb.RPCSuccess, error) {
+	_, err := this.ValidateAdmin(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	config := models.DefaultNodeClusterDNSFailoverConfig()
+	err = json.Unmarshal(req.DnsFailoverJSON, config)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+
+	err = models.SharedNodeClusterDAO.UpdateClusterDNSFailoverConfig(tx, req.NodeClusterId, config)
+	if err != nil {
+		return nil, err
+	}
+
+	return this.Success()
+}
+
 // CountAllEnabledNodeClustersWithHTTPCachePolicyId 计算使用某个缓存策略的集群数量
 func (this *NodeClusterService) CountAllEnabledNodeClustersWithHTTPCachePolicyId(ctx context.Context, req *pb.CountAllEnabledNodeClustersWithHTTPCachePolicyIdRequest) (*pb.RPCCountResponse, error) {
 	_, err := this.ValidateAdmin(ctx, 0)
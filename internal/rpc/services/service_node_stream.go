@@ -133,6 +133,9 @@ func (this *NodeService) NodeStream(server pb.NodeService_NodeStreamServer) erro
 	}
 	nodeLocker.Unlock()
 
+	// 自动更新客户端证书
+	go this.rotateNodeClientCert(server.Context(), nodeId, requestChan)
+
 	// 发送请求
 	go func() {
 		for {
@@ -286,3 +289,60 @@ func (this *NodeService) SendCommandToNode(ctx context.Context, req *pb.NodeStre
 		}, nil
 	}
 }
+
+// 在客户端证书快要过期时通过stream向节点推送新证书
+// 每个连接最多推送一次，节点使用新证书重新连接后会重新检查
+func (this *NodeService) rotateNodeClientCert(ctx context.Context, nodeId int64, requestChan chan *CommandRequest) {
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	for {
+		isPushed, err := this.pushNodeClientCert(ctx, nodeId, requestChan)
+		if err != nil {
+			logs.Println("[RPC]push client cert to node '" + strconv.FormatInt(nodeId, 10) + "' failed: " + err.Error())
+		}
+		if isPushed {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// 签发新的客户端证书并加入到节点的命令队列中
+func (this *NodeService) pushNodeClientCert(ctx context.Context, nodeId int64, requestChan chan *CommandRequest) (isPushed bool, err error) {
+	tx := this.NullTx()
+
+	isRotating, err := checkNodeClientCertRotating(ctx, tx, rpcutils.UserTypeNode, nodeId)
+	if err != nil || !isRotating {
+		return false, err
+	}
+
+	clientCert, err := issueNodeClientCert(tx, rpcutils.UserTypeNode, nodeId)
+	if err != nil {
+		return false, err
+	}
+	messageJSON, err := json.Marshal(&messageconfigs.NewNodeClientCertMessage{
+		CertData:  clientCert.CertData,
+		KeyData:   clientCert.KeyData,
+		ExpiresAt: clientCert.ExpiresAt,
+	})
+	if err != nil {
+		return false, errors.Wrap(err)
+	}
+
+	select {
+	case requestChan <- &CommandRequest{
+		Id:          NextCommandRequestId(),
+		Code:        messageconfigs.MessageCodeNewNodeClientCert,
+		CommandJSON: messageJSON,
+	}:
+		return true, nil
+	default:
+		return false, errors.New("command queue is full over " + strconv.Itoa(len(requestChan)))
+	}
+}
@@ -5,10 +5,16 @@ import (
 	"encoding/json"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models/acme"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/sslutils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
 	"github.com/TeaOSLab/EdgeCommon/pkg/serverconfigs/sslconfigs"
+	"github.com/iwind/TeaGo/dbs"
 )
 
+// 导入的证书库文件的最大尺寸
+const maxImportedKeyStoreSize = 1 << 20
+
 // SSL证书相关服务
 type SSLCertService struct {
 	BaseService
@@ -24,16 +30,26 @@ func (this *SSLCertService) CreateSSLCert(ctx context.Context, req *pb.CreateSSL
 
 	tx := this.NullTx()
 
-	certId, err := models.SharedSSLCertDAO.CreateCert(tx, adminId, userId, req.IsOn, req.Name, req.Description, req.ServerName, req.IsCA, req.CertData, req.KeyData, req.TimeBeginAt, req.TimeEndAt, req.DnsNames, req.CommonNames)
+	// 检查并补全证书链
+	var certData = req.CertData
+	var reportJSON []byte
+	if !req.IsCA {
+		certData, reportJSON, err = this.verifyCertChain(tx, userId, req.CertData, req.KeyData)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	certId, err := models.SharedSSLCertDAO.CreateCert(tx, adminId, userId, req.IsOn, req.Name, req.Description, req.ServerName, req.IsCA, certData, req.KeyData, req.TimeBeginAt, req.TimeEndAt, req.DnsNames, req.CommonNames)
 	if err != nil {
 		return nil, err
 	}
 
-	return &pb.CreateSSLCertResponse{SslCertId: certId}, nil
+	return &pb.CreateSSLCertResponse{SslCertId: certId, ChainReportJSON: reportJSON}, nil
 }
 
 // 修改Cert
-func (this *SSLCertService) UpdateSSLCert(ctx context.Context, req *pb.UpdateSSLCertRequest) (*pb.RPCSuccess, error) {
+func (this *SSLCertService) UpdateSSLCert(ctx context.Context, req *pb.UpdateSSLCertRequest) (*pb.UpdateSSLCertResponse, error) {
 	// 校验请求
 	_, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
 	if err != nil {
@@ -50,12 +66,142 @@ func (this *SSLCertService) UpdateSSLCert(ctx context.Context, req *pb.UpdateSSL
 		}
 	}
 
-	err = models.SharedSSLCertDAO.UpdateCert(tx, req.SslCertId, req.IsOn, req.Name, req.Description, req.ServerName, req.IsCA, req.CertData, req.KeyData, req.TimeBeginAt, req.TimeEndAt, req.DnsNames, req.CommonNames)
+	// 重新上传证书时检查并补全证书链
+	var certData = req.CertData
+	var reportJSON []byte
+	if !req.IsCA && len(req.CertData) > 0 {
+		var keyData = req.KeyData
+		if len(keyData) == 0 {
+			cert, err := models.SharedSSLCertDAO.FindEnabledSSLCert(tx, req.SslCertId)
+			if err != nil {
+				return nil, err
+			}
+			if cert != nil {
+				keyData = []byte(cert.KeyData)
+			}
+		}
+		certData, reportJSON, err = this.verifyCertChain(tx, userId, req.CertData, keyData)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = models.SharedSSLCertDAO.UpdateCert(tx, req.SslCertId, req.IsOn, req.Name, req.Description, req.ServerName, req.IsCA, certData, req.KeyData, req.TimeBeginAt, req.TimeEndAt, req.DnsNames, req.CommonNames)
 	if err != nil {
 		return nil, err
 	}
 
-	return this.Success()
+	return &pb.UpdateSSLCertResponse{ChainReportJSON: reportJSON}, nil
+}
+
+// CheckSSLCertChain 检查证书链和私钥，但不保存
+func (this *SSLCertService) CheckSSLCertChain(ctx context.Context, req *pb.CheckSSLCertChainRequest) (*pb.CheckSSLCertChainResponse, error) {
+	// 校验请求
+	_, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+
+	report, err := this.composeChainReport(tx, userId, req.CertData, req.KeyData)
+	if err != nil {
+		return nil, err
+	}
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.CheckSSLCertChainResponse{
+		ChainReportJSON: reportJSON,
+		CertData:        report.CertData,
+	}, nil
+}
+
+// ImportSSLCertPKCS12 导入PKCS#12格式（.pfx/.p12）的证书
+func (this *SSLCertService) ImportSSLCertPKCS12(ctx context.Context, req *pb.ImportSSLCertPKCS12Request) (*pb.ImportSSLCertPKCS12Response, error) {
+	// 校验请求
+	adminId, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.PfxData) > maxImportedKeyStoreSize {
+		return nil, errors.New("pkcs#12 file is too large")
+	}
+
+	certData, keyData, err := sslutils.DecodePKCS12(req.PfxData, req.Password)
+	if err != nil {
+		return nil, errors.New("decode pkcs#12 file failed: " + err.Error())
+	}
+
+	tx := this.NullTx()
+	certId, reportJSON, err := this.createImportedCert(tx, adminId, userId, req.IsOn, req.Name, req.Description, req.ServerName, certData, keyData)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ImportSSLCertPKCS12Response{SslCertId: certId, ChainReportJSON: reportJSON}, nil
+}
+
+// ImportSSLCertJKS 导入Java KeyStore（.jks）格式的证书
+func (this *SSLCertService) ImportSSLCertJKS(ctx context.Context, req *pb.ImportSSLCertJKSRequest) (*pb.ImportSSLCertJKSResponse, error) {
+	// 校验请求
+	adminId, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(req.JksData) > maxImportedKeyStoreSize {
+		return nil, errors.New("keystore file is too large")
+	}
+
+	certData, keyData, err := sslutils.DecodeJKS(req.JksData, req.Password, req.KeyPassword, req.Alias)
+	if err != nil {
+		return nil, errors.New("decode keystore failed: " + err.Error())
+	}
+
+	tx := this.NullTx()
+	certId, reportJSON, err := this.createImportedCert(tx, adminId, userId, req.IsOn, req.Name, req.Description, req.ServerName, certData, keyData)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ImportSSLCertJKSResponse{SslCertId: certId, ChainReportJSON: reportJSON}, nil
+}
+
+// ExportSSLCertPKCS12 将证书导出为PKCS#12格式
+func (this *SSLCertService) ExportSSLCertPKCS12(ctx context.Context, req *pb.ExportSSLCertPKCS12Request) (*pb.ExportSSLCertPKCS12Response, error) {
+	// 校验请求
+	_, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+
+	// 检查权限
+	if userId > 0 {
+		err := models.SharedSSLCertDAO.CheckUserCert(tx, req.SslCertId, userId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cert, err := models.SharedSSLCertDAO.FindEnabledSSLCert(tx, req.SslCertId)
+	if err != nil {
+		return nil, err
+	}
+	if cert == nil {
+		return nil, errors.New("can not find cert")
+	}
+	if cert.IsCA == 1 || len(cert.KeyData) == 0 {
+		return nil, errors.New("the cert has no private key to export")
+	}
+
+	pfxData, err := sslutils.EncodePKCS12([]byte(cert.CertData), []byte(cert.KeyData), req.Password)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.ExportSSLCertPKCS12Response{PfxData: pfxData}, nil
 }
 
 // 查找证书配置
@@ -172,3 +318,71 @@ func (this *SSLCertService) ListSSLCerts(ctx context.Context, req *pb.ListSSLCer
 	}
 	return &pb.ListSSLCertsResponse{SslCertsJSON: certConfigsJSON}, nil
 }
+
+// 检查证书链，返回补全中间证书后的证书内容
+// 证书无法解析或者私钥和证书不匹配时返回错误
+func (this *SSLCertService) verifyCertChain(tx *dbs.Tx, userId int64, certData []byte, keyData []byte) (resultCertData []byte, reportJSON []byte, err error) {
+	report, err := this.composeChainReport(tx, userId, certData, keyData)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(report.Chain) == 0 || (report.HasKey && !report.KeyMatched) {
+		return nil, nil, errors.New(report.Errors[0])
+	}
+
+	reportJSON, err = json.Marshal(report)
+	if err != nil {
+		return nil, nil, err
+	}
+	return report.CertData, reportJSON, nil
+}
+
+// 生成证书链检查报告
+// 使用当前用户上传的CA证书补全中间证书
+func (this *SSLCertService) composeChainReport(tx *dbs.Tx, userId int64, certData []byte, keyData []byte) (*sslutils.ChainReport, error) {
+	var verifier = sslutils.NewChainVerifier()
+	caCertDataList, err := models.SharedSSLCertDAO.FindAllEnabledCACertData(tx, userId)
+	if err != nil {
+		return nil, err
+	}
+	for _, caCertData := range caCertDataList {
+		caCerts, err := sslutils.ParseCerts(caCertData)
+		if err != nil {
+			continue
+		}
+		verifier.AddCACerts(caCerts...)
+	}
+	return verifier.Verify(certData, keyData), nil
+}
+
+// 保存从证书库中导入的证书
+// 证书信息从证书内容中分析得到，名称为空时使用证书中的域名
+func (this *SSLCertService) createImportedCert(tx *dbs.Tx, adminId int64, userId int64, isOn bool, name string, description string, serverName string, certData []byte, keyData []byte) (certId int64, reportJSON []byte, err error) {
+	certData, reportJSON, err = this.verifyCertChain(tx, userId, certData, keyData)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	sslConfig := &sslconfigs.SSLCertConfig{
+		CertData: certData,
+		KeyData:  keyData,
+	}
+	err = sslConfig.Init()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if len(name) == 0 {
+		if len(sslConfig.DNSNames) > 0 {
+			name = sslConfig.DNSNames[0]
+		} else if len(sslConfig.CommonNames) > 0 {
+			name = sslConfig.CommonNames[0]
+		}
+	}
+
+	certId, err = models.SharedSSLCertDAO.CreateCert(tx, adminId, userId, isOn, name, description, serverName, false, certData, keyData, sslConfig.TimeBeginAt, sslConfig.TimeEndAt, sslConfig.DNSNames, sslConfig.CommonNames)
+	if err != nil {
+		return 0, nil, err
+	}
+	return certId, reportJSON, nil
+}
@@ -4,7 +4,9 @@ import (
 	"context"
 	"encoding/json"
 	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
 	rpcutils "github.com/TeaOSLab/EdgeAPI/internal/rpc/utils"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/sslutils"
 	"github.com/TeaOSLab/EdgeCommon/pkg/rpc/pb"
 	"github.com/TeaOSLab/EdgeCommon/pkg/serverconfigs/sslconfigs"
 )
@@ -98,3 +100,131 @@ func (this *SSLPolicyService) FindEnabledSSLPolicyConfig(ctx context.Context, re
 
 	return &pb.FindEnabledSSLPolicyConfigResponse{SslPolicyJSON: configJSON}, nil
 }
+
+// LintSSLPolicy 检查SSL策略的安全性并评级
+func (this *SSLPolicyService) LintSSLPolicy(ctx context.Context, req *pb.LintSSLPolicyRequest) (*pb.LintSSLPolicyResponse, error) {
+	// 校验请求
+	_, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+
+	if userId > 0 {
+		err := models.SharedSSLPolicyDAO.CheckUserPolicy(tx, req.SslPolicyId, userId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	config, err := models.SharedSSLPolicyDAO.ComposePolicyConfig(tx, req.SslPolicyId)
+	if err != nil {
+		return nil, err
+	}
+	if config == nil {
+		return nil, errors.New("can not find ssl policy")
+	}
+
+	var report = sslutils.LintSSLPolicy(config)
+	reportJSON, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	return &pb.LintSSLPolicyResponse{
+		Grade:      report.Grade,
+		ReportJSON: reportJSON,
+	}, nil
+}
+
+// FindSSLPolicyLintReport 查找SSL策略最近一次定期检查的报告
+func (this *SSLPolicyService) FindSSLPolicyLintReport(ctx context.Context, req *pb.FindSSLPolicyLintReportRequest) (*pb.FindSSLPolicyLintReportResponse, error) {
+	// 校验请求
+	_, userId, err := this.ValidateAdminAndUser(ctx, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+
+	if userId > 0 {
+		err := models.SharedSSLPolicyDAO.CheckUserPolicy(tx, req.SslPolicyId, userId)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	report, err := models.SharedSSLPolicyLintReportDAO.FindPolicyLintReport(tx, req.SslPolicyId)
+	if err != nil {
+		return nil, err
+	}
+	if report == nil {
+		return &pb.FindSSLPolicyLintReportResponse{}, nil
+	}
+	return &pb.FindSSLPolicyLintReportResponse{SslPolicyLintReport: this.toPBLintReport(report)}, nil
+}
+
+// CountAllSSLPolicyLintReports 计算SSL策略检查报告数量
+func (this *SSLPolicyService) CountAllSSLPolicyLintReports(ctx context.Context, req *pb.CountAllSSLPolicyLintReportsRequest) (*pb.RPCCountResponse, error) {
+	_, err := this.ValidateAdmin(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+	count, err := models.SharedSSLPolicyLintReportDAO.CountAllPolicyLintReports(tx, req.Grade)
+	if err != nil {
+		return nil, err
+	}
+	return this.SuccessCount(count)
+}
+
+// ListSSLPolicyLintReports 列出单页SSL策略检查报告
+func (this *SSLPolicyService) ListSSLPolicyLintReports(ctx context.Context, req *pb.ListSSLPolicyLintReportsRequest) (*pb.ListSSLPolicyLintReportsResponse, error) {
+	_, err := this.ValidateAdmin(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+	reports, err := models.SharedSSLPolicyLintReportDAO.ListPolicyLintReports(tx, req.Grade, req.Offset, req.Size)
+	if err != nil {
+		return nil, err
+	}
+	var pbReports = []*pb.SSLPolicyLintReport{}
+	for _, report := range reports {
+		pbReports = append(pbReports, this.toPBLintReport(report))
+	}
+	return &pb.ListSSLPolicyLintReportsResponse{SslPolicyLintReports: pbReports}, nil
+}
+
+// SumSSLPolicyLintGrades 统计各个等级的SSL策略数量，用于看板
+func (this *SSLPolicyService) SumSSLPolicyLintGrades(ctx context.Context, req *pb.SumSSLPolicyLintGradesRequest) (*pb.SumSSLPolicyLintGradesResponse, error) {
+	_, err := this.ValidateAdmin(ctx, 0)
+	if err != nil {
+		return nil, err
+	}
+
+	tx := this.NullTx()
+	var pbGrades = []*pb.SumSSLPolicyLintGradesResponse_Grade{}
+	for _, grade := range sslutils.AllPolicyGrades {
+		count, err := models.SharedSSLPolicyLintReportDAO.CountAllPolicyLintReports(tx, grade)
+		if err != nil {
+			return nil, err
+		}
+		pbGrades = append(pbGrades, &pb.SumSSLPolicyLintGradesResponse_Grade{
+			Grade: grade,
+			Count: count,
+		})
+	}
+	return &pb.SumSSLPolicyLintGradesResponse{Grades: pbGrades}, nil
+}
+
+func (this *SSLPolicyService) toPBLintReport(report *models.SSLPolicyLintReport) *pb.SSLPolicyLintReport {
+	return &pb.SSLPolicyLintReport{
+		SslPolicyId: int64(report.PolicyId),
+		Grade:       report.Grade,
+		IssuesJSON:  []byte(report.Issues),
+		CheckedAt:   int64(report.CheckedAt),
+	}
+}
@@ -61,6 +61,12 @@ func (this *UserNodeService) DeleteUserNode(ctx context.Context, req *pb.DeleteU
 		return nil, err
 	}
 
+	// 吊销客户端证书
+	err = models.SharedNodeClientCertDAO.RevokeNodeClientCerts(tx, rpcutils.UserTypeUser, req.NodeId)
+	if err != nil {
+		return nil, err
+	}
+
 	return this.Success()
 }
 
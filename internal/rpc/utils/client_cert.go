@@ -0,0 +1,87 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package rpcutils
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/sslutils"
+	"github.com/iwind/TeaGo/lists"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+	"sync/atomic"
+)
+
+// 可以使用客户端证书认证的节点角色
+var nodeClientCertRoles = []string{UserTypeNode, UserTypeDNS, UserTypeMonitor, UserTypeUser}
+
+var requireNodeClientCert int32
+
+// SetRequireNodeClientCert 设置是否要求节点使用客户端证书
+// 管理员等其他角色不受影响，以便管理平台仍然可以通过同一个端口连接
+func SetRequireNodeClientCert(b bool) {
+	if b {
+		atomic.StoreInt32(&requireNodeClientCert, 1)
+	} else {
+		atomic.StoreInt32(&requireNodeClientCert, 0)
+	}
+}
+
+// IsNodeClientCertRequired 是否要求节点使用客户端证书
+func IsNodeClientCertRequired() bool {
+	return atomic.LoadInt32(&requireNodeClientCert) == 1
+}
+
+// IsNodeClientCertRole 判断某个角色是否可以使用客户端证书
+func IsNodeClientCertRole(role string) bool {
+	return lists.ContainsString(nodeClientCertRoles, role)
+}
+
+// ValidateNodeClientCert 校验节点的客户端证书
+// 证书需要和请求中的节点ID、角色一致，并且没有被吊销
+func ValidateNodeClientCert(ctx context.Context, role string, uniqueId string) error {
+	if !IsNodeClientCertRole(role) {
+		return nil
+	}
+
+	var cert = FindPeerCert(ctx)
+	if cert == nil {
+		if IsNodeClientCertRequired() {
+			return errors.New("context: client certificate required")
+		}
+		return nil
+	}
+
+	certRole, certUniqueId := sslutils.ParseNodeClientCertIdentity(cert)
+	if certRole != role || certUniqueId != uniqueId {
+		return errors.New("context: client certificate does not match node '" + uniqueId + "'")
+	}
+
+	isRevoked, err := models.SharedNodeClientCertDAO.CheckNodeClientCertRevokedCacheable(nil, sslutils.FormatSerial(cert.SerialNumber))
+	if err != nil {
+		return err
+	}
+	if isRevoked {
+		return errors.New("context: client certificate has been revoked")
+	}
+	return nil
+}
+
+// FindPeerCert 读取已经通过校验的客户端证书
+func FindPeerCert(ctx context.Context) *x509.Certificate {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.AuthInfo == nil {
+		return nil
+	}
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok {
+		return nil
+	}
+	var chains = tlsInfo.State.VerifiedChains
+	if len(chains) == 0 || len(chains[0]) == 0 {
+		return nil
+	}
+	return chains[0][0]
+}
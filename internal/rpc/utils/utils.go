@@ -136,6 +136,12 @@ func ValidateRequest(ctx context.Context, userTypes ...UserType) (userType UserT
 		return UserTypeNone, 0, errors.New("not supported node type: '" + t + "'")
 	}
 
+	// 校验客户端证书
+	err = ValidateNodeClientCert(ctx, apiToken.Role, nodeId)
+	if err != nil {
+		return UserTypeNone, 0, err
+	}
+
 	switch apiToken.Role {
 	case UserTypeNode:
 		// TODO 需要检查集群是否已经删除
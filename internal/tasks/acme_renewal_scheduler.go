@@ -0,0 +1,143 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package tasks
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models/acme"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/maps"
+	timeutil "github.com/iwind/TeaGo/utils/time"
+	"strconv"
+	"sync"
+	"time"
+)
+
+func init() {
+	dbs.OnReadyDone(func() {
+		go NewACMERenewalScheduler().Start()
+	})
+}
+
+// 每次最多续期的任务数
+const acmeRenewalBatchSize = 100
+
+// ACMERenewalScheduler ACME证书自动续期
+// 在证书有效期剩余一定比例时续期，失败后按照指数退避重试
+type ACMERenewalScheduler struct {
+}
+
+func NewACMERenewalScheduler() *ACMERenewalScheduler {
+	return &ACMERenewalScheduler{}
+}
+
+func (this *ACMERenewalScheduler) Start() {
+	seconds := int64(300)
+	ticker := time.NewTicker(time.Duration(seconds) * time.Second)
+	for range ticker.C {
+		err := this.LoopWithLocker(seconds)
+		if err != nil {
+			remotelogs.Error("ACMERenewalScheduler", err.Error())
+		}
+	}
+}
+
+func (this *ACMERenewalScheduler) LoopWithLocker(seconds int64) error {
+	ok, err := models.SharedSysLockerDAO.Lock(nil, "acme_renewal_scheduler", seconds-1)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	return this.Loop()
+}
+
+func (this *ACMERenewalScheduler) Loop() error {
+	config, err := acme.SharedACMETaskDAO.FindRenewalConfig(nil)
+	if err != nil {
+		return err
+	}
+	taskIds, err := acme.SharedACMETaskDAO.FindAllRenewingACMETaskIds(nil, config, acmeRenewalBatchSize)
+	if err != nil {
+		return err
+	}
+	if len(taskIds) == 0 {
+		return nil
+	}
+
+	// 限制同时续期的任务数
+	var taskIdChan = make(chan int64, len(taskIds))
+	for _, taskId := range taskIds {
+		taskIdChan <- taskId
+	}
+	close(taskIdChan)
+
+	var concurrent = config.Concurrent
+	if concurrent > len(taskIds) {
+		concurrent = len(taskIds)
+	}
+	var wg = &sync.WaitGroup{}
+	wg.Add(concurrent)
+	for i := 0; i < concurrent; i++ {
+		go func() {
+			defer wg.Done()
+			for taskId := range taskIdChan {
+				err := this.renew(taskId, config)
+				if err != nil {
+					remotelogs.Error("ACMERenewalScheduler", "renew task '"+strconv.FormatInt(taskId, 10)+"' failed: "+err.Error())
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// 续期单个任务并发送通知
+func (this *ACMERenewalScheduler) renew(taskId int64, config *acme.ACMERenewalConfig) error {
+	result, err := acme.SharedACMETaskDAO.RenewTask(nil, taskId, config)
+	if err != nil {
+		return err
+	}
+
+	task, err := acme.SharedACMETaskDAO.FindEnabledACMETask(nil, taskId)
+	if err != nil {
+		return err
+	}
+	if task == nil || task.CertId == 0 {
+		return nil
+	}
+	cert, err := models.SharedSSLCertDAO.FindEnabledSSLCert(nil, int64(task.CertId))
+	if err != nil {
+		return err
+	}
+	if cert == nil {
+		return nil
+	}
+
+	var params = maps.Map{
+		"certId":     cert.Id,
+		"acmeTaskId": taskId,
+	}.AsJSON()
+	if result.IsOk {
+		subject := "系统已成功为你自动更新了证书\"" + cert.Name + "\""
+		msg := "系统已成功为你自动更新了证书\"" + cert.Name + "\"（" + cert.DnsNames + "）。"
+		return models.SharedMessageDAO.CreateMessage(nil, int64(cert.AdminId), int64(cert.UserId), models.MessageTypeSSLCertACMETaskSuccess, models.MessageLevelSuccess, subject, msg, params)
+	}
+
+	// 只在第一次失败和放弃重试时发送通知，以免消息过多
+	if result.Attempt > 1 && result.NextRetryAt > 0 {
+		return nil
+	}
+	subject := "系统在尝试自动更新证书\"" + cert.Name + "\"时发生错误"
+	msg := "系统在尝试自动更新证书\"" + cert.Name + "\"（" + cert.DnsNames + "）时发生错误：" + result.Error + "。"
+	if result.NextRetryAt > 0 {
+		msg += "系统将在" + timeutil.FormatTime("Y-m-d H:i:s", result.NextRetryAt) + "重试，请检查系统设置并修复错误。"
+	} else {
+		msg += "已连续失败" + strconv.Itoa(result.Attempt) + "次，系统不再自动重试，请修复错误后手动执行续期任务。"
+	}
+	return models.SharedMessageDAO.CreateMessage(nil, int64(cert.AdminId), int64(cert.UserId), models.MessageTypeSSLCertACMETaskFailed, models.MessageLevelError, subject, msg, params)
+}
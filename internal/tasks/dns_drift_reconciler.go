@@ -0,0 +1,252 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package tasks
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	dnsmodels "github.com/TeaOSLab/EdgeAPI/internal/db/models/dns"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/maps"
+	"strconv"
+	"time"
+)
+
+func init() {
+	dbs.OnReadyDone(func() {
+		go NewDNSDriftReconciler().Start()
+	})
+}
+
+// DNSDriftReconciler DNS记录偏差检查
+// 定期对比集群期望的解析记录和DNS服务商中的实际记录，发现偏差时发送消息，并根据设置自动修复
+type DNSDriftReconciler struct {
+}
+
+func NewDNSDriftReconciler() *DNSDriftReconciler {
+	return &DNSDriftReconciler{}
+}
+
+func (this *DNSDriftReconciler) Start() {
+	seconds := int64(600)
+	ticker := time.NewTicker(time.Duration(seconds) * time.Second)
+	for range ticker.C {
+		err := this.LoopWithLocker(seconds)
+		if err != nil {
+			remotelogs.Error("DNSDriftReconciler", err.Error())
+		}
+	}
+}
+
+func (this *DNSDriftReconciler) LoopWithLocker(seconds int64) error {
+	ok, err := models.SharedSysLockerDAO.Lock(nil, "dns_drift_reconciler", seconds-1)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	return this.Loop()
+}
+
+func (this *DNSDriftReconciler) Loop() error {
+	// 有正在执行的DNS任务时不检查，以免把尚未同步的变更当成偏差
+	hasDoingTasks, err := dnsmodels.SharedDNSTaskDAO.ExistDoingTasks(nil)
+	if err != nil {
+		return err
+	}
+	if hasDoingTasks {
+		return nil
+	}
+
+	domains, err := dnsmodels.SharedDNSDomainDAO.FindAllEnabledAndOnDomains(nil)
+	if err != nil {
+		return err
+	}
+	for _, domain := range domains {
+		err = this.reconcileDomain(nil, domain)
+		if err != nil {
+			remotelogs.Error("DNSDriftReconciler", "reconcile domain '"+domain.Name+"' failed: "+err.Error())
+		}
+	}
+	return nil
+}
+
+// 检查单个域名
+func (this *DNSDriftReconciler) reconcileDomain(tx *dbs.Tx, domain *dnsmodels.DNSDomain) error {
+	domainId := int64(domain.Id)
+	clusters, err := models.SharedNodeClusterDAO.FindAllEnabledClustersWithDNSDomainId(tx, domainId)
+	if err != nil {
+		return err
+	}
+	if len(clusters) == 0 {
+		return nil
+	}
+
+	executor := NewDNSTaskExecutor()
+	manager, err := executor.findDomainDNSManager(tx, domain)
+	if err != nil {
+		return err
+	}
+	if manager == nil {
+		return nil
+	}
+	records, err := manager.GetRecords(domain.Name)
+	if err != nil {
+		return err
+	}
+
+	// 上次同步后保存的记录，用来判断偏差是否由在DNS服务商中直接修改引起
+	syncedRecords, err := domain.DecodeRecords()
+	if err != nil {
+		return err
+	}
+
+	// 上次发现的偏差，用来避免重复发送消息
+	oldDrifts, err := domain.DecodeDrifts()
+	if err != nil {
+		return err
+	}
+	oldFingerprints := map[int64]string{} // clusterId => fingerprint
+	for _, drift := range oldDrifts {
+		oldFingerprints[drift.ClusterId] = drift.Fingerprint()
+	}
+
+	drifts := []*dnsmodels.DNSDomainDrift{}
+	isHealed := false
+	for _, cluster := range clusters {
+		if len(cluster.DnsName) == 0 {
+			continue
+		}
+		clusterId := int64(cluster.Id)
+
+		nodeChangeSet, failoverDecision, err := executor.diffClusterNodes(tx, manager, records, domainId, domain.Name, clusterId, cluster.DnsName)
+		if err != nil {
+			return err
+		}
+		serverChangeSet, err := executor.diffClusterServers(tx, manager, records, domain.Name, clusterId, cluster.DnsName)
+		if err != nil {
+			return err
+		}
+		changeSet := &dnstypes.ChangeSet{Domain: domain.Name}
+		changeSet.Changes = append(nodeChangeSet.Changes, serverChangeSet.Changes...)
+		if changeSet.IsEmpty() {
+			continue
+		}
+
+		drift := &dnsmodels.DNSDomainDrift{
+			ClusterId:   clusterId,
+			ChangeSet:   changeSet,
+			IsOutOfBand: this.isOutOfBand(changeSet, syncedRecords),
+			CreatedAt:   time.Now().Unix(),
+		}
+		drifts = append(drifts, drift)
+
+		// 自动修复
+		if domain.DriftAutoHeal == 1 {
+			err = dnsclients.ApplyChangeSet(manager, changeSet)
+			if err != nil {
+				drift.Error = err.Error()
+			} else {
+				drift.IsHealed = true
+				isHealed = true
+				executor.mirrorChangeSet(tx, 0, domainId, manager, changeSet)
+
+				// 修复的变更中可能包含故障转移引起的变更，需要同时保存故障转移状态
+				err = executor.saveFailoverDecision(tx, clusterId, manager, failoverDecision)
+				if err != nil {
+					return err
+				}
+			}
+		}
+
+		// 同样的偏差只通知一次
+		fingerprint := drift.Fingerprint()
+		if oldFingerprints[clusterId] == fingerprint {
+			continue
+		}
+		err = this.notify(tx, cluster, domain, drift)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = dnsmodels.SharedDNSDomainDAO.UpdateDomainDrifts(tx, domainId, drifts)
+	if err != nil {
+		return err
+	}
+
+	// 修复后重新同步域名记录
+	if isHealed {
+		err = dnsmodels.SharedDNSTaskDAO.CreateDomainTask(tx, domainId, dnsmodels.DNSTaskTypeDomainChange)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 判断偏差是否由在DNS服务商中直接修改记录引起
+// 上次同步时存在、现在却缺失的记录，或者上次同步时不存在、现在却多出来的记录，都认为是被直接修改过
+func (this *DNSDriftReconciler) isOutOfBand(changeSet *dnstypes.ChangeSet, syncedRecords []*dnstypes.Record) bool {
+	// 从未同步过，无法判断
+	if len(syncedRecords) == 0 {
+		return false
+	}
+
+	syncedKeys := map[string]bool{}
+	for _, record := range syncedRecords {
+		syncedKeys[dnsmodels.DNSRecordKey(record)] = true
+	}
+	for _, change := range changeSet.Changes {
+		_, synced := syncedKeys[dnsmodels.DNSRecordKey(change.Record)]
+		switch change.Action {
+		case dnstypes.ChangeActionCreate:
+			if synced {
+				return true
+			}
+		case dnstypes.ChangeActionDelete:
+			if !synced {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 发送偏差消息
+func (this *DNSDriftReconciler) notify(tx *dbs.Tx, cluster *models.NodeCluster, domain *dnsmodels.DNSDomain, drift *dnsmodels.DNSDomainDrift) error {
+	clusterId := int64(cluster.Id)
+	countChanges := strconv.Itoa(len(drift.ChangeSet.Changes))
+	params := maps.Map{
+		"clusterId":   clusterId,
+		"domainId":    domain.Id,
+		"isOutOfBand": drift.IsOutOfBand,
+	}.AsJSON()
+
+	if drift.IsHealed {
+		subject := "集群\"" + cluster.Name + "\"的DNS记录偏差已自动修复"
+		body := "集群\"" + cluster.Name + "\"在域名\"" + domain.Name + "\"中有" + countChanges + "条DNS记录和期望的不一致，系统已自动修复。"
+		return models.SharedMessageDAO.CreateClusterMessage(tx, clusterId, models.MessageTypeClusterDNSDriftHealed, models.MessageLevelSuccess, subject, body, params)
+	}
+
+	// 非直接修改引起的偏差只记录下来，除非自动修复失败
+	if !drift.IsOutOfBand && len(drift.Error) == 0 {
+		return nil
+	}
+
+	subject := "集群\"" + cluster.Name + "\"的DNS记录和期望的不一致"
+	body := "集群\"" + cluster.Name + "\"在域名\"" + domain.Name + "\"中有" + countChanges + "条DNS记录和期望的不一致"
+	if drift.IsOutOfBand {
+		body += "，可能是在DNS服务商中被直接修改过"
+	}
+	if len(drift.Error) > 0 {
+		body += "，自动修复失败：" + drift.Error + "。"
+	} else {
+		body += "，请在集群DNS设置中检查并重新同步。"
+	}
+	return models.SharedMessageDAO.CreateClusterMessage(tx, clusterId, models.MessageTypeClusterDNSDrifted, models.MessageLevelWarning, subject, body, params)
+}
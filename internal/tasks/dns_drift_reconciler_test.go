@@ -0,0 +1,64 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package tasks
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/iwind/TeaGo/dbs"
+	"testing"
+)
+
+func TestDNSDriftReconciler_Loop(t *testing.T) {
+	dbs.NotifyReady()
+
+	reconciler := NewDNSDriftReconciler()
+	err := reconciler.Loop()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log("ok")
+}
+
+func TestDNSDriftReconciler_isOutOfBand(t *testing.T) {
+	reconciler := NewDNSDriftReconciler()
+	syncedRecords := []*dnstypes.Record{
+		{Id: "1", Name: "cdn", Type: dnstypes.RecordTypeA, Value: "192.168.1.1", Route: "default"},
+		{Id: "2", Name: "www", Type: dnstypes.RecordTypeCNAME, Value: "cdn.example.com.", Route: "default"},
+	}
+
+	// 尚未同步的新节点
+	{
+		changeSet := &dnstypes.ChangeSet{}
+		changeSet.AddCreate(&dnstypes.Record{Name: "cdn", Type: dnstypes.RecordTypeA, Value: "192.168.1.2", Route: "default"})
+		if reconciler.isOutOfBand(changeSet, syncedRecords) {
+			t.Fatal("new node record should not be out of band")
+		}
+	}
+
+	// 在服务商中被删除的记录
+	{
+		changeSet := &dnstypes.ChangeSet{}
+		changeSet.AddCreate(&dnstypes.Record{Name: "www", Type: dnstypes.RecordTypeCNAME, Value: "cdn.example.com", Route: "default"})
+		if !reconciler.isOutOfBand(changeSet, syncedRecords) {
+			t.Fatal("deleted record should be out of band")
+		}
+	}
+
+	// 在服务商中添加的记录
+	{
+		changeSet := &dnstypes.ChangeSet{}
+		changeSet.AddDelete(&dnstypes.Record{Id: "3", Name: "cdn", Type: dnstypes.RecordTypeA, Value: "10.0.0.1", Route: "default"})
+		if !reconciler.isOutOfBand(changeSet, syncedRecords) {
+			t.Fatal("added record should be out of band")
+		}
+	}
+
+	// 从未同步过
+	{
+		changeSet := &dnstypes.ChangeSet{}
+		changeSet.AddDelete(&dnstypes.Record{Id: "3", Name: "cdn", Type: dnstypes.RecordTypeA, Value: "10.0.0.1", Route: "default"})
+		if reconciler.isOutOfBand(changeSet, nil) {
+			t.Fatal("should not be out of band without synced records")
+		}
+	}
+}
@@ -0,0 +1,180 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package tasks
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/maps"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// DNS故障转移决策
+type dnsFailoverDecision struct {
+	config      *models.NodeClusterDNSFailoverConfig
+	state       *models.NodeClusterDNSFailoverState // 新的状态
+	transitions []*dnsFailoverTransition            // 状态发生变化的线路
+}
+
+// 是否需要将某个线路切换到备用目标
+func (this *dnsFailoverDecision) isFailover(route string) bool {
+	if this == nil || this.config == nil || !this.config.IsOn {
+		return false
+	}
+	routeState, ok := this.state.Routes[route]
+	return ok && routeState.IsFailover
+}
+
+// 单个线路的状态变化
+type dnsFailoverTransition struct {
+	route             string
+	isFailover        bool
+	countHealthyNodes int
+}
+
+// 计算集群的故障转移状态
+// allRoutes 为集群中所有节点（包括已下线节点）使用的线路，healthyCounts 为每个线路中健康节点数量
+func (this *DNSTaskExecutor) decideFailover(tx *dbs.Tx, clusterId int64, allRoutes []string, healthyCounts map[string]int) (*dnsFailoverDecision, error) {
+	config, err := models.SharedNodeClusterDAO.FindClusterDNSFailoverConfig(tx, clusterId)
+	if err != nil {
+		return nil, err
+	}
+	oldState, err := models.SharedNodeClusterDAO.FindClusterDNSFailoverState(tx, clusterId)
+	if err != nil {
+		return nil, err
+	}
+	newState, transitions := decideDNSFailover(config, oldState, allRoutes, healthyCounts, time.Now().Unix())
+	return &dnsFailoverDecision{
+		config:      config,
+		state:       newState,
+		transitions: transitions,
+	}, nil
+}
+
+// 根据健康节点数量计算新的故障转移状态
+// 进入故障转移只需要健康节点数量低于 MinNodes；切回则需要健康节点数量不低于 RecoverNodes，并且持续 RecoverSeconds 秒
+func decideDNSFailover(config *models.NodeClusterDNSFailoverConfig, oldState *models.NodeClusterDNSFailoverState, allRoutes []string, healthyCounts map[string]int, now int64) (*models.NodeClusterDNSFailoverState, []*dnsFailoverTransition) {
+	newState := &models.NodeClusterDNSFailoverState{
+		Routes: map[string]*models.NodeClusterDNSFailoverRouteState{},
+	}
+	transitions := []*dnsFailoverTransition{}
+	isOn := config != nil && config.IsOn && config.HasTargets()
+
+	// 保证顺序一致
+	sort.Strings(allRoutes)
+
+	for _, route := range allRoutes {
+		countHealthyNodes := healthyCounts[route]
+		oldRouteState, _ := oldState.Routes[route]
+		wasFailover := oldRouteState != nil && oldRouteState.IsFailover
+
+		routeState := &models.NodeClusterDNSFailoverRouteState{
+			IsFailover:        wasFailover,
+			CountHealthyNodes: countHealthyNodes,
+		}
+		if oldRouteState != nil {
+			routeState.UpdatedAt = oldRouteState.UpdatedAt
+		}
+
+		if !isOn {
+			routeState.IsFailover = false
+		} else if !wasFailover {
+			if countHealthyNodes < config.MinNodes {
+				routeState.IsFailover = true
+			}
+		} else if countHealthyNodes >= config.RecoverNodes {
+			// 开始恢复，或者已经恢复了足够长的时间
+			if oldRouteState.RecoveringAt == 0 {
+				routeState.RecoveringAt = now
+			} else {
+				routeState.RecoveringAt = oldRouteState.RecoveringAt
+			}
+			if now-routeState.RecoveringAt >= config.RecoverSeconds {
+				routeState.IsFailover = false
+				routeState.RecoveringAt = 0
+			}
+		}
+
+		if routeState.IsFailover != wasFailover {
+			routeState.UpdatedAt = now
+			transitions = append(transitions, &dnsFailoverTransition{
+				route:             route,
+				isFailover:        routeState.IsFailover,
+				countHealthyNodes: countHealthyNodes,
+			})
+		}
+		newState.Routes[route] = routeState
+	}
+
+	// 已经不再使用的线路直接切回
+	var oldRoutes = []string{}
+	for route := range oldState.Routes {
+		oldRoutes = append(oldRoutes, route)
+	}
+	sort.Strings(oldRoutes)
+	for _, route := range oldRoutes {
+		_, ok := newState.Routes[route]
+		if ok || !oldState.Routes[route].IsFailover {
+			continue
+		}
+		transitions = append(transitions, &dnsFailoverTransition{
+			route:      route,
+			isFailover: false,
+		})
+	}
+
+	return newState, transitions
+}
+
+// 保存故障转移状态，并为每次切换发送消息
+func (this *DNSTaskExecutor) saveFailoverDecision(tx *dbs.Tx, clusterId int64, manager dnsclients.ProviderInterface, decision *dnsFailoverDecision) error {
+	if decision == nil {
+		return nil
+	}
+	err := models.SharedNodeClusterDAO.UpdateClusterDNSFailoverState(tx, clusterId, decision.state)
+	if err != nil {
+		return err
+	}
+	if len(decision.transitions) == 0 {
+		return nil
+	}
+
+	clusterName, err := models.SharedNodeClusterDAO.FindNodeClusterName(tx, clusterId)
+	if err != nil {
+		return err
+	}
+	for _, transition := range decision.transitions {
+		routeName := transition.route
+		if routeName == manager.DefaultRoute() {
+			routeName = "默认线路"
+		}
+		params := maps.Map{
+			"clusterId":         clusterId,
+			"route":             transition.route,
+			"isFailover":        transition.isFailover,
+			"countHealthyNodes": transition.countHealthyNodes,
+		}.AsJSON()
+
+		if transition.isFailover {
+			target := "备用IP"
+			if len(decision.config.BackupCNAME) > 0 {
+				target = "备用集群域名\"" + decision.config.BackupCNAME + "\""
+			}
+			subject := "集群\"" + clusterName + "\"的线路\"" + routeName + "\"已切换到" + target
+			body := "集群\"" + clusterName + "\"的线路\"" + routeName + "\"中健康节点数量为" + strconv.Itoa(transition.countHealthyNodes) + "，低于故障转移阈值" + strconv.Itoa(decision.config.MinNodes) + "，DNS解析已切换到" + target + "。"
+			err = models.SharedMessageDAO.CreateClusterMessage(tx, clusterId, models.MessageTypeClusterDNSFailover, models.MessageLevelError, subject, body, params)
+		} else {
+			subject := "集群\"" + clusterName + "\"的线路\"" + routeName + "\"已从故障转移中恢复"
+			body := "集群\"" + clusterName + "\"的线路\"" + routeName + "\"中健康节点数量已恢复到" + strconv.Itoa(transition.countHealthyNodes) + "，DNS解析已切换回集群节点。"
+			err = models.SharedMessageDAO.CreateClusterMessage(tx, clusterId, models.MessageTypeClusterDNSFailoverRecovered, models.MessageLevelSuccess, subject, body, params)
+		}
+		if err != nil {
+			remotelogs.Error("DNSTaskExecutor", "create failover message failed: "+err.Error())
+		}
+	}
+	return nil
+}
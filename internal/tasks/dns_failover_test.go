@@ -0,0 +1,81 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package tasks
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"testing"
+)
+
+func TestDecideDNSFailover(t *testing.T) {
+	config := &models.NodeClusterDNSFailoverConfig{
+		IsOn:           true,
+		MinNodes:       1,
+		RecoverNodes:   2,
+		RecoverSeconds: 60,
+		StandbyIPs:     []string{"192.168.2.1"},
+	}
+	err := config.Init()
+	if err != nil {
+		t.Fatal(err)
+	}
+	routes := []string{"default", "telecom"}
+
+	// 所有节点都正常
+	state := &models.NodeClusterDNSFailoverState{Routes: map[string]*models.NodeClusterDNSFailoverRouteState{}}
+	state, transitions := decideDNSFailover(config, state, routes, map[string]int{"default": 2, "telecom": 2}, 1000)
+	if len(transitions) != 0 || state.HasFailover() {
+		t.Fatal("should not failover")
+	}
+
+	// telecom线路中所有节点都失败
+	state, transitions = decideDNSFailover(config, state, routes, map[string]int{"default": 2, "telecom": 0}, 1010)
+	if len(transitions) != 1 || transitions[0].route != "telecom" || !transitions[0].isFailover {
+		t.Fatal("telecom should failover")
+	}
+
+	// 恢复了一个节点，还未达到恢复数量
+	state, transitions = decideDNSFailover(config, state, routes, map[string]int{"default": 2, "telecom": 1}, 1020)
+	if len(transitions) != 0 || !state.Routes["telecom"].IsFailover {
+		t.Fatal("telecom should keep failover")
+	}
+
+	// 达到恢复数量，但持续时间不够
+	state, transitions = decideDNSFailover(config, state, routes, map[string]int{"default": 2, "telecom": 2}, 1030)
+	if len(transitions) != 0 || state.Routes["telecom"].RecoveringAt != 1030 {
+		t.Fatal("telecom should be recovering")
+	}
+
+	// 恢复期间再次失败，需要重新计算恢复时间
+	state, _ = decideDNSFailover(config, state, routes, map[string]int{"default": 2, "telecom": 1}, 1040)
+	if state.Routes["telecom"].RecoveringAt != 0 {
+		t.Fatal("recovering should be reset")
+	}
+	state, _ = decideDNSFailover(config, state, routes, map[string]int{"default": 2, "telecom": 2}, 1050)
+	state, transitions = decideDNSFailover(config, state, routes, map[string]int{"default": 2, "telecom": 2}, 1100)
+	if len(transitions) != 0 {
+		t.Fatal("telecom should still be recovering")
+	}
+
+	// 持续足够时间后切回
+	state, transitions = decideDNSFailover(config, state, routes, map[string]int{"default": 2, "telecom": 2}, 1110)
+	if len(transitions) != 1 || transitions[0].isFailover || state.HasFailover() {
+		t.Fatal("telecom should recover")
+	}
+}
+
+func TestDecideDNSFailover_Off(t *testing.T) {
+	state := &models.NodeClusterDNSFailoverState{
+		Routes: map[string]*models.NodeClusterDNSFailoverRouteState{
+			"default": {IsFailover: true},
+			"unicom":  {IsFailover: true},
+		},
+	}
+
+	// 关闭故障转移后，所有线路都应该切回
+	config := models.DefaultNodeClusterDNSFailoverConfig()
+	state, transitions := decideDNSFailover(config, state, []string{"default"}, map[string]int{"default": 0}, 1000)
+	if state.HasFailover() || len(transitions) != 2 {
+		t.Fatal("all routes should recover")
+	}
+}
@@ -24,6 +24,7 @@ func init() {
 
 // DNSTaskExecutor DNS任务执行器
 type DNSTaskExecutor struct {
+	planOptions *DNSTaskPlanOptions // 预览变更时使用的选项，正常执行时为nil
 }
 
 func NewDNSTaskExecutor() *DNSTaskExecutor {
@@ -134,81 +135,26 @@ func (this *DNSTaskExecutor) doServer(taskId int64, serverId int64) error {
 		return nil
 	}
 
-	recordName := serverDNS.DnsName
-	recordValue := clusterDNSName + "." + domain + "."
-	recordRoute := manager.DefaultRoute()
-	recordType := dnstypes.RecordTypeCNAME
-	if serverDNS.State == models.ServerStateDisabled || serverDNS.IsOn == 0 {
-		// 检查记录是否已经存在
-		record, err := manager.QueryRecord(domain, recordName, recordType)
-		if err != nil {
-			return err
-		}
-		if record != nil {
-			// 删除
-			err = manager.DeleteRecord(domain, record)
-			if err != nil {
-				return err
-			}
-			err = dnsmodels.SharedDNSTaskDAO.CreateDomainTask(tx, domainId, dnsmodels.DNSTaskTypeDomainChange)
-			if err != nil {
-				return err
-			}
-		}
-
+	changeSet, err := this.diffServer(tx, manager, domainId, domain, clusterDNSName, serverDNS)
+	if err != nil {
+		return err
+	}
+	if changeSet.IsEmpty() {
 		isOk = true
-	} else {
-		// 是否已存在
-		exist, err := dnsmodels.SharedDNSDomainDAO.ExistDomainRecord(tx, domainId, recordName, recordType, recordRoute, recordValue)
-		if err != nil {
-			return err
-		}
-		if exist {
-			isOk = true
-			return nil
-		}
-
-		// 检查记录是否已经存在
-		record, err := manager.QueryRecord(domain, recordName, recordType)
-		if err != nil {
-			return err
-		}
-		if record != nil {
-			if record.Value == recordValue || record.Value == strings.TrimRight(recordValue, ".") {
-				isOk = true
-				return nil
-			}
-
-			// 删除
-			err = manager.DeleteRecord(domain, record)
-			if err != nil {
-				return err
-			}
-			err = dnsmodels.SharedDNSTaskDAO.CreateDomainTask(tx, domainId, dnsmodels.DNSTaskTypeDomainChange)
-			if err != nil {
-				return err
-			}
-		}
-
-		err = manager.AddRecord(domain, &dnstypes.Record{
-			Id:    "",
-			Name:  recordName,
-			Type:  recordType,
-			Value: recordValue,
-			Route: recordRoute,
-		})
-		if err != nil {
-			return err
-		}
-
-		err = dnsmodels.SharedDNSTaskDAO.CreateDomainTask(tx, domainId, dnsmodels.DNSTaskTypeDomainChange)
-		if err != nil {
-			return err
-		}
+		return nil
+	}
+	err = this.applyChangeSet(tx, taskId, domainId, manager, changeSet)
+	if err != nil {
+		return err
+	}
 
-		isOk = true
+	err = dnsmodels.SharedDNSTaskDAO.CreateDomainTask(tx, domainId, dnsmodels.DNSTaskTypeDomainChange)
+	if err != nil {
+		return err
 	}
 
+	isOk = true
+
 	return nil
 }
 
@@ -272,92 +218,29 @@ func (this *DNSTaskExecutor) doCluster(taskId int64, clusterId int64) error {
 		return nil
 	}
 
-	// 以前的节点记录
 	records, err := manager.GetRecords(domain)
 	if err != nil {
 		return err
 	}
-	oldRecordsMap := map[string]*dnstypes.Record{} // route@value => record
-	for _, record := range records {
-		if (record.Type == dnstypes.RecordTypeA || record.Type == dnstypes.RecordTypeAAAA) && record.Name == clusterDNSName {
-			key := record.Route + "@" + record.Value
-			oldRecordsMap[key] = record
-		}
-	}
-
-	// 当前的节点记录
-	newRecordKeys := []string{}
-	nodes, err := models.SharedNodeDAO.FindAllEnabledNodesDNSWithClusterId(tx, clusterId)
+	changeSet, failoverDecision, err := this.diffClusterNodes(tx, manager, records, domainId, domain, clusterId, clusterDNSName)
 	if err != nil {
 		return err
 	}
-	isChanged := false
-	for _, node := range nodes {
-		routes, err := node.DNSRouteCodesForDomainId(domainId)
-		if err != nil {
-			return err
-		}
-		if len(routes) == 0 {
-			routes = []string{manager.DefaultRoute()}
-		}
-
-		// 所有的IP记录
-		ipAddresses, err := models.SharedNodeIPAddressDAO.FindAllEnabledAddressesWithNode(tx, int64(node.Id), nodeconfigs.NodeRoleNode)
-		if err != nil {
-			return err
-		}
-		if len(ipAddresses) == 0 {
-			continue
-		}
-		for _, ipAddress := range ipAddresses {
-			ip := ipAddress.Ip
-			if len(ip) == 0 || ipAddress.CanAccess == 0 {
-				continue
-			}
-			if net.ParseIP(ip) == nil {
-				continue
-			}
-			for _, route := range routes {
-				key := route + "@" + ip
-				_, ok := oldRecordsMap[key]
-				if ok {
-					newRecordKeys = append(newRecordKeys, key)
-					continue
-				}
 
-				recordType := dnstypes.RecordTypeA
-				if utils.IsIPv6(ip) {
-					recordType = dnstypes.RecordTypeAAAA
-				}
-				err = manager.AddRecord(domain, &dnstypes.Record{
-					Id:    "",
-					Name:  clusterDNSName,
-					Type:  recordType,
-					Value: ip,
-					Route: route,
-				})
-				if err != nil {
-					return err
-				}
-				isChanged = true
-				newRecordKeys = append(newRecordKeys, key)
-			}
-		}
+	// 执行所有变更，如果失败则回滚
+	err = this.applyChangeSet(tx, taskId, domainId, manager, changeSet)
+	if err != nil {
+		return err
 	}
 
-	// 删除多余的节点解析记录
-	for key, record := range oldRecordsMap {
-		if !lists.ContainsString(newRecordKeys, key) {
-			isChanged = true
-			err = manager.DeleteRecord(domain, record)
-			if err != nil {
-				return err
-			}
-		}
+	// 变更成功后才保存故障转移状态
+	err = this.saveFailoverDecision(tx, clusterId, manager, failoverDecision)
+	if err != nil {
+		return err
 	}
 
 	// 通知更新域名
-	if isChanged {
+	if !changeSet.IsEmpty() {
 		err = dnsmodels.SharedDNSTaskDAO.CreateDomainTask(tx, domainId, dnsmodels.DNSTaskTypeDomainChange)
 		if err != nil {
 			return err
@@ -390,35 +273,14 @@ func (this *DNSTaskExecutor) doDomain(taskId int64, domainId int64) error {
 		isOk = true
 		return nil
 	}
-	providerId := int64(dnsDomain.ProviderId)
-	if providerId <= 0 {
-		isOk = true
-		return nil
-	}
-
-	provider, err := dnsmodels.SharedDNSProviderDAO.FindEnabledDNSProvider(tx, providerId)
+	manager, err := this.findDomainDNSManager(tx, dnsDomain)
 	if err != nil {
 		return err
 	}
-	if provider == nil {
-		isOk = true
-		return nil
-	}
-
-	manager := dnsclients.FindProvider(provider.Type)
 	if manager == nil {
-		remotelogs.Error("DNSTaskExecutor", "unsupported dns provider type '"+provider.Type+"'")
 		isOk = true
 		return nil
 	}
-	params, err := provider.DecodeAPIParams()
-	if err != nil {
-		return err
-	}
-	err = manager.Auth(params)
-	if err != nil {
-		return err
-	}
 	records, err := manager.GetRecords(dnsDomain.Name)
 	if err != nil {
 		return err
@@ -435,47 +297,440 @@ func (this *DNSTaskExecutor) doDomain(taskId int64, domainId int64) error {
 	return nil
 }
 
+// 执行变更集并记录到任务中
+// 执行成功后会同步到域名的镜像服务商
+func (this *DNSTaskExecutor) applyChangeSet(tx *dbs.Tx, taskId int64, domainId int64, manager dnsclients.ProviderInterface, changeSet *dnstypes.ChangeSet) error {
+	if changeSet.IsEmpty() {
+		return nil
+	}
+	err := dnsclients.ApplyChangeSet(manager, changeSet)
+	saveErr := dnsmodels.SharedDNSTaskDAO.AddDNSTaskChangeSet(tx, taskId, changeSet)
+	if saveErr != nil {
+		remotelogs.Error("DNSTaskExecutor", "save change set failed: "+saveErr.Error())
+	}
+	if err != nil {
+		return err
+	}
+
+	this.mirrorChangeSet(tx, taskId, domainId, manager, changeSet)
+	return nil
+}
+
+// 将已经执行的变更集同步到镜像服务商
+// 主服务商中的变更已经生效，所以同步失败时只记录错误，不影响任务的状态，可以通过一致性检查发现差异
+func (this *DNSTaskExecutor) mirrorChangeSet(tx *dbs.Tx, taskId int64, domainId int64, primary dnsclients.ProviderInterface, changeSet *dnstypes.ChangeSet) {
+	dnsDomain, err := dnsmodels.SharedDNSDomainDAO.FindEnabledDNSDomain(tx, domainId)
+	if err != nil {
+		remotelogs.Error("DNSTaskExecutor", "mirror change set failed: "+err.Error())
+		return
+	}
+	if dnsDomain == nil || dnsDomain.MirrorProviderId == 0 {
+		return
+	}
+
+	secondary, err := this.FindProviderManager(tx, int64(dnsDomain.MirrorProviderId))
+	if err != nil {
+		remotelogs.Error("DNSTaskExecutor", "mirror change set failed: "+err.Error())
+		return
+	}
+	if secondary == nil {
+		return
+	}
+	routeMapping, err := dnsDomain.DecodeMirrorRoutes()
+	if err != nil {
+		remotelogs.Error("DNSTaskExecutor", "mirror change set failed: "+err.Error())
+		return
+	}
+
+	mirrorChangeSet, err := dnsclients.MirrorChangeSet(primary, secondary, changeSet, routeMapping)
+	if err != nil {
+		remotelogs.Error("DNSTaskExecutor", "mirror change set to domain '"+dnsDomain.Name+"' failed: "+err.Error())
+		return
+	}
+	if mirrorChangeSet.IsEmpty() {
+		return
+	}
+	err = dnsclients.ApplyChangeSet(secondary, mirrorChangeSet)
+	if err != nil {
+		remotelogs.Error("DNSTaskExecutor", "mirror change set to domain '"+dnsDomain.Name+"' failed: "+err.Error())
+	}
+	if taskId > 0 {
+		saveErr := dnsmodels.SharedDNSTaskDAO.AddDNSTaskChangeSet(tx, taskId, mirrorChangeSet)
+		if saveErr != nil {
+			remotelogs.Error("DNSTaskExecutor", "save change set failed: "+saveErr.Error())
+		}
+	}
+}
+
+// 对比单个服务的CNAME记录和DNS服务商中的记录，生成需要执行的变更
+func (this *DNSTaskExecutor) diffServer(tx *dbs.Tx, manager dnsclients.ProviderInterface, domainId int64, domain string, clusterDNSName string, serverDNS *models.Server) (*dnstypes.ChangeSet, error) {
+	changeSet := &dnstypes.ChangeSet{Domain: domain}
+
+	recordName := serverDNS.DnsName
+	recordValue := clusterDNSName + "." + domain + "."
+	recordRoute := manager.DefaultRoute()
+	recordType := dnstypes.RecordTypeCNAME
+	if serverDNS.State == models.ServerStateDisabled || serverDNS.IsOn == 0 {
+		// 检查记录是否已经存在
+		record, err := manager.QueryRecord(domain, recordName, recordType)
+		if err != nil {
+			return nil, err
+		}
+		if record != nil {
+			// 删除
+			changeSet.AddDelete(record)
+		}
+		return changeSet, nil
+	}
+
+	// 是否已存在
+	exist, err := dnsmodels.SharedDNSDomainDAO.ExistDomainRecord(tx, domainId, recordName, recordType, recordRoute, recordValue)
+	if err != nil {
+		return nil, err
+	}
+	if exist {
+		return changeSet, nil
+	}
+
+	// 检查记录是否已经存在
+	record, err := manager.QueryRecord(domain, recordName, recordType)
+	if err != nil {
+		return nil, err
+	}
+	if record != nil {
+		if record.Value == recordValue || record.Value == strings.TrimRight(recordValue, ".") {
+			return changeSet, nil
+		}
+
+		// 删除
+		changeSet.AddDelete(record)
+	}
+
+	changeSet.AddCreate(&dnstypes.Record{
+		Id:    "",
+		Name:  recordName,
+		Type:  recordType,
+		Value: recordValue,
+		Route: recordRoute,
+	})
+	return changeSet, nil
+}
+
+// 对比集群节点的解析记录和DNS服务商中的记录，生成需要执行的变更
+// 同时根据故障转移策略计算每个线路是否需要切换到备用目标，返回的决策需要由调用者决定是否保存
+func (this *DNSTaskExecutor) diffClusterNodes(tx *dbs.Tx, manager dnsclients.ProviderInterface, records []*dnstypes.Record, domainId int64, domain string, clusterId int64, clusterDNSName string) (*dnstypes.ChangeSet, *dnsFailoverDecision, error) {
+	// 以前的节点记录
+	oldRecordsMap := map[string]*dnstypes.Record{}      // route@value => record
+	oldCNAMERecordsMap := map[string]*dnstypes.Record{} // route => record，故障转移时使用的CNAME记录
+	for _, record := range records {
+		if record.Name != clusterDNSName {
+			continue
+		}
+		switch record.Type {
+		case dnstypes.RecordTypeA, dnstypes.RecordTypeAAAA:
+			key := record.Route + "@" + record.Value
+			oldRecordsMap[key] = record
+		case dnstypes.RecordTypeCNAME:
+			oldCNAMERecordsMap[record.Route] = record
+		}
+	}
+
+	// 当前的节点记录
+	nodes, err := models.SharedNodeDAO.FindAllEnabledNodesDNSWithClusterId(tx, clusterId)
+	if err != nil {
+		return nil, nil, err
+	}
+	routeIPs := map[string][]string{} // route => [ip1, ...]
+	routes := []string{}              // 保持线路顺序
+	healthyCounts := map[string]int{} // route => count
+	for _, node := range nodes {
+		nodeRoutes, err := this.findNodeRoutes(node, domainId, manager)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// 所有的IP记录
+		ipAddresses, err := models.SharedNodeIPAddressDAO.FindAllEnabledAddressesWithNode(tx, int64(node.Id), nodeconfigs.NodeRoleNode)
+		if err != nil {
+			return nil, nil, err
+		}
+		ips := []string{}
+		for _, ipAddress := range ipAddresses {
+			ip := ipAddress.Ip
+			if len(ip) == 0 || ipAddress.CanAccess == 0 {
+				continue
+			}
+			if net.ParseIP(ip) == nil {
+				continue
+			}
+			ips = append(ips, ip)
+		}
+		if len(ips) == 0 {
+			continue
+		}
+		for _, route := range nodeRoutes {
+			_, ok := routeIPs[route]
+			if !ok {
+				routes = append(routes, route)
+			}
+			routeIPs[route] = append(routeIPs[route], ips...)
+			healthyCounts[route]++
+		}
+	}
+
+	// 故障转移，需要包括已下线节点的线路
+	allNodes, err := models.SharedNodeDAO.FindAllEnabledAndOnNodesDNSWithClusterId(tx, clusterId)
+	if err != nil {
+		return nil, nil, err
+	}
+	allRoutes := []string{}
+	for _, node := range allNodes {
+		nodeRoutes, err := this.findNodeRoutes(node, domainId, manager)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, route := range nodeRoutes {
+			if !lists.ContainsString(allRoutes, route) {
+				allRoutes = append(allRoutes, route)
+			}
+		}
+	}
+	decision, err := this.decideFailover(tx, clusterId, allRoutes, healthyCounts)
+	if err != nil {
+		return nil, nil, err
+	}
+	cnameRoutes := []string{} // 使用备用集群域名的线路
+	for _, route := range allRoutes {
+		if !decision.isFailover(route) {
+			continue
+		}
+		if len(decision.config.BackupCNAME) > 0 {
+			cnameRoutes = append(cnameRoutes, route)
+			continue
+		}
+		_, ok := routeIPs[route]
+		if !ok {
+			routes = append(routes, route)
+		}
+		routeIPs[route] = append(routeIPs[route], decision.config.StandbyIPs...)
+	}
+
+	changeSet := &dnstypes.ChangeSet{Domain: domain}
+
+	// 删除不再需要的CNAME记录，需要在添加A记录之前执行
+	for route, record := range oldCNAMERecordsMap {
+		if !lists.ContainsString(cnameRoutes, route) || strings.TrimSuffix(record.Value, ".") != strings.TrimSuffix(decision.config.BackupCNAME, ".") {
+			changeSet.AddDelete(record)
+		}
+	}
+
+	// 新增的节点解析记录
+	newRecordKeys := []string{}
+	for _, route := range routes {
+		if lists.ContainsString(cnameRoutes, route) {
+			continue
+		}
+		for _, ip := range routeIPs[route] {
+			key := route + "@" + ip
+			if lists.ContainsString(newRecordKeys, key) {
+				continue
+			}
+			newRecordKeys = append(newRecordKeys, key)
+			_, ok := oldRecordsMap[key]
+			if ok {
+				continue
+			}
+
+			recordType := dnstypes.RecordTypeA
+			if utils.IsIPv6(ip) {
+				recordType = dnstypes.RecordTypeAAAA
+			}
+			changeSet.AddCreate(&dnstypes.Record{
+				Id:    "",
+				Name:  clusterDNSName,
+				Type:  recordType,
+				Value: ip,
+				Route: route,
+			})
+		}
+	}
+
+	// 删除多余的节点解析记录
+	for key, record := range oldRecordsMap {
+		if !lists.ContainsString(newRecordKeys, key) {
+			changeSet.AddDelete(record)
+		}
+	}
+
+	// 切换到备用集群域名，需要在删除A记录之后执行
+	for _, route := range cnameRoutes {
+		record, ok := oldCNAMERecordsMap[route]
+		if ok && strings.TrimSuffix(record.Value, ".") == strings.TrimSuffix(decision.config.BackupCNAME, ".") {
+			continue
+		}
+		changeSet.AddCreate(&dnstypes.Record{
+			Id:    "",
+			Name:  clusterDNSName,
+			Type:  dnstypes.RecordTypeCNAME,
+			Value: decision.config.BackupCNAME,
+			Route: route,
+		})
+	}
+
+	return changeSet, decision, nil
+}
+
+// 查找节点在某个域名中使用的线路
+func (this *DNSTaskExecutor) findNodeRoutes(node *models.Node, domainId int64, manager dnsclients.ProviderInterface) ([]string, error) {
+	routes, err := node.DNSRouteCodesForDomainId(domainId)
+	if err != nil {
+		return nil, err
+	}
+	if this.planOptions != nil {
+		planRoutes, ok := this.planOptions.NodeRoutes[int64(node.Id)]
+		if ok {
+			routes = planRoutes
+		}
+	}
+	if len(routes) == 0 {
+		routes = []string{manager.DefaultRoute()}
+	}
+	return routes, nil
+}
+
+// 对比集群中所有服务的CNAME记录和DNS服务商中的记录，生成需要执行的变更
+func (this *DNSTaskExecutor) diffClusterServers(tx *dbs.Tx, manager dnsclients.ProviderInterface, records []*dnstypes.Record, domain string, clusterId int64, clusterDNSName string) (*dnstypes.ChangeSet, error) {
+	clusterDomain := clusterDNSName + "." + domain + "."
+
+	// 以前的服务记录
+	oldRecordsMap := map[string][]*dnstypes.Record{} // dnsName => records
+	for _, record := range records {
+		if record.Type == dnstypes.RecordTypeCNAME {
+			oldRecordsMap[record.Name] = append(oldRecordsMap[record.Name], record)
+		}
+	}
+
+	// 当前的服务记录
+	servers, err := models.SharedServerDAO.FindAllServersDNSWithClusterId(tx, clusterId)
+	if err != nil {
+		return nil, err
+	}
+	changeSet := &dnstypes.ChangeSet{Domain: domain}
+	serverDNSNames := []string{}
+	for _, server := range servers {
+		dnsName := server.DnsName
+		if len(dnsName) == 0 {
+			continue
+		}
+		serverDNSNames = append(serverDNSNames, dnsName)
+
+		oldRecords := oldRecordsMap[dnsName]
+		found := false
+		for _, record := range oldRecords {
+			if strings.TrimSuffix(record.Value, ".") == strings.TrimSuffix(clusterDomain, ".") {
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+
+		// 指向其他地方的同名记录需要先删除
+		for _, record := range oldRecords {
+			changeSet.AddDelete(record)
+		}
+		changeSet.AddCreate(&dnstypes.Record{
+			Id:    "",
+			Name:  dnsName,
+			Type:  dnstypes.RecordTypeCNAME,
+			Value: clusterDomain,
+			Route: manager.DefaultRoute(),
+		})
+	}
+
+	// 删除多余的服务解析记录
+	for _, record := range records {
+		if record.Type == dnstypes.RecordTypeCNAME &&
+			strings.TrimSuffix(record.Value, ".") == strings.TrimSuffix(clusterDomain, ".") &&
+			!lists.ContainsString(serverDNSNames, record.Name) {
+			changeSet.AddDelete(record)
+		}
+	}
+
+	return changeSet, nil
+}
+
 func (this *DNSTaskExecutor) findDNSManager(tx *dbs.Tx, clusterId int64) (manager dnsclients.ProviderInterface, domainId int64, domain string, clusterDNSName string, err error) {
 	clusterDNS, err := models.SharedNodeClusterDAO.FindClusterDNSInfo(tx, clusterId)
 	if err != nil {
 		return nil, 0, "", "", err
 	}
-	if clusterDNS == nil || len(clusterDNS.DnsName) == 0 || clusterDNS.DnsDomainId <= 0 {
+	if clusterDNS == nil {
+		return nil, 0, "", "", nil
+	}
+	dnsName := clusterDNS.DnsName
+	dnsDomainId := int64(clusterDNS.DnsDomainId)
+	if this.planOptions != nil {
+		if len(this.planOptions.DNSName) > 0 {
+			dnsName = this.planOptions.DNSName
+		}
+		if this.planOptions.DNSDomainId > 0 {
+			dnsDomainId = this.planOptions.DNSDomainId
+		}
+	}
+	if len(dnsName) == 0 || dnsDomainId <= 0 {
 		return nil, 0, "", "", nil
 	}
 
-	dnsDomain, err := dnsmodels.SharedDNSDomainDAO.FindEnabledDNSDomain(tx, int64(clusterDNS.DnsDomainId))
+	dnsDomain, err := dnsmodels.SharedDNSDomainDAO.FindEnabledDNSDomain(tx, dnsDomainId)
 	if err != nil {
 		return nil, 0, "", "", err
 	}
 	if dnsDomain == nil {
 		return nil, 0, "", "", nil
 	}
-	providerId := int64(dnsDomain.ProviderId)
-	if providerId <= 0 {
+	manager, err = this.findDomainDNSManager(tx, dnsDomain)
+	if err != nil {
+		return nil, 0, "", "", err
+	}
+	if manager == nil {
 		return nil, 0, "", "", nil
 	}
+	return manager, int64(dnsDomain.Id), dnsDomain.Name, dnsName, nil
+}
+
+// 查找域名对应的DNS服务商，并完成认证
+func (this *DNSTaskExecutor) findDomainDNSManager(tx *dbs.Tx, dnsDomain *dnsmodels.DNSDomain) (dnsclients.ProviderInterface, error) {
+	return this.FindProviderManager(tx, int64(dnsDomain.ProviderId))
+}
+
+// FindProviderManager 查找DNS服务商，并完成认证
+func (this *DNSTaskExecutor) FindProviderManager(tx *dbs.Tx, providerId int64) (dnsclients.ProviderInterface, error) {
+	if providerId <= 0 {
+		return nil, nil
+	}
 
 	provider, err := dnsmodels.SharedDNSProviderDAO.FindEnabledDNSProvider(tx, providerId)
 	if err != nil {
-		return nil, 0, "", "", err
+		return nil, err
 	}
 	if provider == nil {
-		return nil, 0, "", "", nil
+		return nil, nil
 	}
 
-	manager = dnsclients.FindProvider(provider.Type)
+	manager := dnsclients.FindProvider(provider.Type)
 	if manager == nil {
 		remotelogs.Error("DNSTaskExecutor", "unsupported dns provider type '"+provider.Type+"'")
-		return nil, 0, "", "", nil
+		return nil, nil
 	}
 	params, err := provider.DecodeAPIParams()
 	if err != nil {
-		return nil, 0, "", "", err
+		return nil, err
 	}
 	err = manager.Auth(params)
 	if err != nil {
-		return nil, 0, "", "", err
+		return nil, err
 	}
-	return manager, int64(dnsDomain.Id), dnsDomain.Name, clusterDNS.DnsName, nil
+	return manager, nil
 }
@@ -1,7 +1,9 @@
 package tasks
 
 import (
+	dnsmodels "github.com/TeaOSLab/EdgeAPI/internal/db/models/dns"
 	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/logs"
 	"testing"
 )
 
@@ -15,3 +17,14 @@ func TestDNSTaskExecutor_Loop(t *testing.T) {
 	}
 	t.Log("ok")
 }
+
+func TestDNSTaskExecutor_Plan(t *testing.T) {
+	dbs.NotifyReady()
+
+	executor := NewDNSTaskExecutor()
+	plan, err := executor.Plan(dnsmodels.DNSTaskTypeClusterChange, 1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	logs.PrintAsJSON(plan, t)
+}
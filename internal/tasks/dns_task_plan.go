@@ -0,0 +1,147 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package tasks
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	dnsmodels "github.com/TeaOSLab/EdgeAPI/internal/db/models/dns"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/iwind/TeaGo/dbs"
+	"time"
+)
+
+// DNSTaskPlanOptions 预览DNS任务时使用的选项，用来预览尚未保存的修改
+type DNSTaskPlanOptions struct {
+	DNSDomainId int64              // 集群将要使用的域名ID
+	DNSName     string             // 集群将要使用的子域名
+	NodeRoutes  map[int64][]string // 节点将要使用的线路，nodeId => [route code1, ...]
+}
+
+// DNSTaskPlan DNS任务执行计划
+type DNSTaskPlan struct {
+	Type      dnsmodels.DNSTaskType `json:"type"`
+	ClusterId int64                 `json:"clusterId"`
+	NodeId    int64                 `json:"nodeId"`
+	ServerId  int64                 `json:"serverId"`
+	DomainId  int64                 `json:"domainId"`
+	Domain    string                `json:"domain"`
+	ChangeSet *dnstypes.ChangeSet   `json:"changeSet"` // 将要执行的变更
+	CreatedAt int64                 `json:"createdAt"`
+}
+
+// Plan 预览DNS任务将要执行的变更
+// 和执行任务时使用同样的对比逻辑，但只读取DNS服务商中的记录，不做任何修改
+func (this *DNSTaskExecutor) Plan(taskType dnsmodels.DNSTaskType, targetId int64, options *DNSTaskPlanOptions) (*DNSTaskPlan, error) {
+	if options == nil {
+		options = &DNSTaskPlanOptions{}
+	}
+	this.planOptions = options
+	defer func() {
+		this.planOptions = nil
+	}()
+
+	var tx *dbs.Tx
+	plan := &DNSTaskPlan{
+		Type:      taskType,
+		ChangeSet: &dnstypes.ChangeSet{},
+		CreatedAt: time.Now().Unix(),
+	}
+
+	switch taskType {
+	case dnsmodels.DNSTaskTypeServerChange:
+		plan.ServerId = targetId
+		err := this.planServer(tx, plan, targetId)
+		if err != nil {
+			return nil, err
+		}
+	case dnsmodels.DNSTaskTypeNodeChange:
+		plan.NodeId = targetId
+		node, err := models.SharedNodeDAO.FindStatelessNodeDNS(tx, targetId)
+		if err != nil {
+			return nil, err
+		}
+		if node == nil || node.ClusterId == 0 {
+			return plan, nil
+		}
+
+		// 和执行时一样转交给集群处理
+		err = this.planCluster(tx, plan, int64(node.ClusterId))
+		if err != nil {
+			return nil, err
+		}
+	case dnsmodels.DNSTaskTypeClusterChange:
+		err := this.planCluster(tx, plan, targetId)
+		if err != nil {
+			return nil, err
+		}
+	case dnsmodels.DNSTaskTypeDomainChange:
+		// 域名任务只重新读取记录，不会修改记录
+		plan.DomainId = targetId
+		domainName, err := dnsmodels.SharedDNSDomainDAO.FindDNSDomainName(tx, targetId)
+		if err != nil {
+			return nil, err
+		}
+		plan.Domain = domainName
+		plan.ChangeSet.Domain = domainName
+	default:
+		return nil, errors.New("invalid task type '" + taskType + "'")
+	}
+
+	return plan, nil
+}
+
+// 预览服务相关记录的变更
+func (this *DNSTaskExecutor) planServer(tx *dbs.Tx, plan *DNSTaskPlan, serverId int64) error {
+	serverDNS, err := models.SharedServerDAO.FindStatelessServerDNS(tx, serverId)
+	if err != nil {
+		return err
+	}
+	if serverDNS == nil || len(serverDNS.DnsName) == 0 {
+		return nil
+	}
+	plan.ClusterId = int64(serverDNS.ClusterId)
+
+	manager, domainId, domain, clusterDNSName, err := this.findDNSManager(tx, int64(serverDNS.ClusterId))
+	if err != nil {
+		return err
+	}
+	if manager == nil {
+		return nil
+	}
+	plan.DomainId = domainId
+	plan.Domain = domain
+
+	changeSet, err := this.diffServer(tx, manager, domainId, domain, clusterDNSName, serverDNS)
+	if err != nil {
+		return err
+	}
+	plan.ChangeSet = changeSet
+	return nil
+}
+
+// 预览集群相关记录的变更
+func (this *DNSTaskExecutor) planCluster(tx *dbs.Tx, plan *DNSTaskPlan, clusterId int64) error {
+	plan.ClusterId = clusterId
+
+	manager, domainId, domain, clusterDNSName, err := this.findDNSManager(tx, clusterId)
+	if err != nil {
+		return err
+	}
+	if manager == nil {
+		return nil
+	}
+	plan.DomainId = domainId
+	plan.Domain = domain
+
+	records, err := manager.GetRecords(domain)
+	if err != nil {
+		return err
+	}
+	changeSet, _, err := this.diffClusterNodes(tx, manager, records, domainId, domain, clusterId, clusterDNSName)
+	if err != nil {
+		return err
+	}
+	plan.ChangeSet = changeSet
+	return nil
+}
@@ -156,6 +156,19 @@ func (this *HealthCheckExecutor) Run() ([]*HealthCheckResult, error) {
 	}
 	wg.Wait()
 
+	// 处于故障转移中的集群需要重新计算DNS，以便在节点恢复一段时间后切回
+	if healthCheckConfig.AutoDown {
+		failoverState, err := models.SharedNodeClusterDAO.FindClusterDNSFailoverState(nil, this.clusterId)
+		if err != nil {
+			logs.Println("[HEALTH_CHECK]" + err.Error())
+		} else if failoverState.HasFailover() {
+			err = models.SharedNodeClusterDAO.NotifyDNSUpdate(nil, this.clusterId)
+			if err != nil {
+				logs.Println("[HEALTH_CHECK]" + err.Error())
+			}
+		}
+	}
+
 	return results, nil
 }
 
@@ -0,0 +1,53 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package tasks
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	"github.com/iwind/TeaGo/dbs"
+	"time"
+)
+
+func init() {
+	dbs.OnReadyDone(func() {
+		go NewNodeClientCertCleaner().Start()
+	})
+}
+
+// 过期的节点客户端证书记录保留天数
+const nodeClientCertKeepDays = 30
+
+// NodeClientCertCleaner 清理过期的节点客户端证书记录
+type NodeClientCertCleaner struct {
+}
+
+func NewNodeClientCertCleaner() *NodeClientCertCleaner {
+	return &NodeClientCertCleaner{}
+}
+
+func (this *NodeClientCertCleaner) Start() {
+	seconds := int64(86400)
+	ticker := time.NewTicker(time.Duration(seconds) * time.Second)
+	for range ticker.C {
+		err := this.LoopWithLocker(seconds)
+		if err != nil {
+			remotelogs.Error("NodeClientCertCleaner", err.Error())
+		}
+	}
+}
+
+func (this *NodeClientCertCleaner) LoopWithLocker(seconds int64) error {
+	ok, err := models.SharedSysLockerDAO.Lock(nil, "node_client_cert_cleaner", seconds-1)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	return this.Loop()
+}
+
+func (this *NodeClientCertCleaner) Loop() error {
+	return models.SharedNodeClientCertDAO.DeleteExpiredNodeClientCerts(nil, time.Now().Unix()-nodeClientCertKeepDays*86400)
+}
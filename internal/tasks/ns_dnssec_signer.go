@@ -0,0 +1,235 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package tasks
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models/nameservers"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/dnssecutils"
+	"github.com/iwind/TeaGo/dbs"
+	"strconv"
+	"sync"
+	"time"
+)
+
+func init() {
+	dbs.OnReadyDone(func() {
+		go SharedNSDNSSECSigner.Start()
+	})
+}
+
+var SharedNSDNSSECSigner = NewNSDNSSECSigner()
+
+// NSDNSSECSigner EdgeDNS域名DNSSEC签名
+// 定期轮换密钥，并在记录变化或者签名即将过期时重新生成DNSKEY、RRSIG和NSEC/NSEC3记录
+type NSDNSSECSigner struct {
+	states map[int64]*nsDNSSECSignState // domainId => state
+	locker sync.Mutex
+}
+
+// 域名最近一次签名的状态
+type nsDNSSECSignState struct {
+	version  int64  // 签名时记录的最新版本
+	keys     string // 签名时使用的密钥
+	signedAt int64  // 签名时间
+}
+
+func NewNSDNSSECSigner() *NSDNSSECSigner {
+	return &NSDNSSECSigner{
+		states: map[int64]*nsDNSSECSignState{},
+	}
+}
+
+func (this *NSDNSSECSigner) Start() {
+	seconds := int64(60)
+	ticker := time.NewTicker(time.Duration(seconds) * time.Second)
+	for range ticker.C {
+		err := this.LoopWithLocker(seconds)
+		if err != nil {
+			remotelogs.Error("NSDNSSECSigner", err.Error())
+		}
+	}
+}
+
+func (this *NSDNSSECSigner) LoopWithLocker(seconds int64) error {
+	ok, err := models.SharedSysLockerDAO.Lock(nil, "ns_dnssec_signer", seconds-1)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	return this.Loop()
+}
+
+func (this *NSDNSSECSigner) Loop() error {
+	domains, err := nameservers.SharedNSDomainDAO.FindAllEnabledDNSSECDomains(nil)
+	if err != nil {
+		return err
+	}
+	for _, domain := range domains {
+		err = this.SignDomain(nil, domain, false)
+		if err != nil {
+			remotelogs.Error("NSDNSSECSigner", "sign domain '"+domain.Name+"' failed: "+err.Error())
+		}
+	}
+	return nil
+}
+
+// SignDomain 轮换密钥并签名单个域名
+// force 为 true 时不管记录有没有变化都重新计算
+func (this *NSDNSSECSigner) SignDomain(tx *dbs.Tx, domain *nameservers.NSDomain, force bool) error {
+	domainId := int64(domain.Id)
+	config, err := domain.DecodeDNSSECConfig()
+	if err != nil {
+		return err
+	}
+	if !config.IsOn {
+		return nil
+	}
+	err = config.Init()
+	if err != nil {
+		return err
+	}
+
+	// 轮换密钥
+	keysChanged, err := this.rolloverKeys(tx, domain, config)
+	if err != nil {
+		return err
+	}
+
+	latestVersion, err := nameservers.SharedNSRecordDAO.FindDomainLatestVersion(tx, domainId)
+	if err != nil {
+		return err
+	}
+	keys, err := nameservers.SharedNSKeyDAO.FindAllEnabledDomainKeys(tx, domainId)
+	if err != nil {
+		return err
+	}
+	var keysString = ""
+	for _, key := range keys {
+		keysString += strconv.FormatUint(key.Id, 10) + ":" + key.Status + ","
+	}
+
+	// 检查是否需要重新签名，签名即将过期时也需要重新签名，这里每小时检查一次
+	var now = time.Now().Unix()
+	this.locker.Lock()
+	state, ok := this.states[domainId]
+	this.locker.Unlock()
+	if !force && !keysChanged && ok && state.version == latestVersion && state.keys == keysString && now-state.signedAt < 3600 {
+		return nil
+	}
+
+	var signKeys = []*dnssecutils.Key{}
+	for _, key := range keys {
+		signKey, err := key.DecodeKey()
+		if err != nil {
+			return errors.New("decode key '" + strconv.FormatUint(key.Id, 10) + "' failed: " + err.Error())
+		}
+		signKeys = append(signKeys, signKey)
+	}
+
+	records, err := nameservers.SharedNSRecordDAO.FindAllEnabledRecordsToSign(tx, domainId)
+	if err != nil {
+		return err
+	}
+	var signRecords = []*dnssecutils.Record{}
+	for _, record := range records {
+		signRecords = append(signRecords, &dnssecutils.Record{
+			Name:     record.Name,
+			Type:     record.Type,
+			Value:    record.Value,
+			TTL:      record.Ttl,
+			RouteIds: record.DecodeRouteIds(),
+		})
+	}
+
+	generatedRecords, err := nameservers.SharedNSRecordDAO.FindAllEnabledGeneratedRecords(tx, domainId)
+	if err != nil {
+		return err
+	}
+	var existingRecords = []*dnssecutils.Record{}
+	for _, record := range generatedRecords {
+		existingRecords = append(existingRecords, &dnssecutils.Record{
+			Name:     record.Name,
+			Type:     record.Type,
+			Value:    record.Value,
+			TTL:      record.Ttl,
+			RouteIds: record.DecodeRouteIds(),
+		})
+	}
+
+	result, err := dnssecutils.SignZone(signRecords, &dnssecutils.SignOptions{
+		Zone:             domain.Name,
+		Keys:             signKeys,
+		TTL:              config.TTL,
+		SignatureSeconds: int64(config.SignatureDays) * 86400,
+		NSEC3:            config.NSEC3,
+		NSEC3Iterations:  config.NSEC3Iterations,
+		NSEC3Salt:        config.NSEC3Salt,
+		Existing:         existingRecords,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = nameservers.SharedNSRecordDAO.SyncGeneratedRecords(tx, domainId, result)
+	if err != nil {
+		return err
+	}
+
+	this.locker.Lock()
+	this.states[domainId] = &nsDNSSECSignState{
+		version:  latestVersion,
+		keys:     keysString,
+		signedAt: now,
+	}
+	this.locker.Unlock()
+
+	return nil
+}
+
+// 根据轮换计划创建、启用和退役密钥
+func (this *NSDNSSECSigner) rolloverKeys(tx *dbs.Tx, domain *nameservers.NSDomain, config *nameservers.NSDomainDNSSECConfig) (changed bool, err error) {
+	domainId := int64(domain.Id)
+	keys, err := nameservers.SharedNSKeyDAO.FindAllEnabledDomainKeys(tx, domainId)
+	if err != nil {
+		return false, err
+	}
+
+	// 修改算法后需要重新生成所有密钥
+	var keyStates = []*dnssecutils.KeyState{}
+	for _, key := range keys {
+		if key.Algorithm != config.Algorithm {
+			continue
+		}
+		keyStates = append(keyStates, key.DecodeKeyState())
+	}
+	if len(keyStates) == 0 && len(keys) > 0 {
+		err = nameservers.SharedNSKeyDAO.DisableDomainKeys(tx, domainId)
+		if err != nil {
+			return false, err
+		}
+	}
+
+	actions := dnssecutils.PlanRollover(keyStates, config.RolloverOptions(), time.Now().Unix())
+	for _, action := range actions {
+		switch action.Type {
+		case dnssecutils.RolloverActionCreate:
+			_, err = nameservers.SharedNSKeyDAO.CreateKey(tx, domainId, domain.Name, action.Role, config.Algorithm, action.Status, config.TTL)
+		case dnssecutils.RolloverActionActivate:
+			err = nameservers.SharedNSKeyDAO.UpdateKeyStatus(tx, action.KeyId, dnssecutils.KeyStatusActive)
+		case dnssecutils.RolloverActionRetire:
+			err = nameservers.SharedNSKeyDAO.UpdateKeyStatus(tx, action.KeyId, dnssecutils.KeyStatusRetired)
+		case dnssecutils.RolloverActionRemove:
+			err = nameservers.SharedNSKeyDAO.DisableNSKey(tx, action.KeyId)
+		}
+		if err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	return
+}
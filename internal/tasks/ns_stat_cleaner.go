@@ -0,0 +1,72 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package tasks
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models/nameservers"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	"github.com/iwind/TeaGo/dbs"
+	timeutil "github.com/iwind/TeaGo/utils/time"
+	"time"
+)
+
+func init() {
+	dbs.OnReadyDone(func() {
+		go NewNSStatCleaner().Start()
+	})
+}
+
+const (
+	nsMinutelyStatKeepDays = 7  // 按分钟统计的保留天数
+	nsDailyStatKeepDays    = 90 // 按天统计的保留天数
+)
+
+// NSStatCleaner 清理过期的域名查询统计
+type NSStatCleaner struct {
+}
+
+func NewNSStatCleaner() *NSStatCleaner {
+	return &NSStatCleaner{}
+}
+
+func (this *NSStatCleaner) Start() {
+	seconds := int64(3600)
+	ticker := time.NewTicker(time.Duration(seconds) * time.Second)
+	for range ticker.C {
+		err := this.LoopWithLocker(seconds)
+		if err != nil {
+			remotelogs.Error("NSStatCleaner", err.Error())
+		}
+	}
+}
+
+func (this *NSStatCleaner) LoopWithLocker(seconds int64) error {
+	ok, err := models.SharedSysLockerDAO.Lock(nil, "ns_stat_cleaner", seconds-1)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	return this.Loop()
+}
+
+func (this *NSStatCleaner) Loop() error {
+	var minute = timeutil.Format("YmdHi", time.Now().AddDate(0, 0, -nsMinutelyStatKeepDays))
+	err := nameservers.SharedNSDomainMinutelyStatDAO.DeleteStatsBeforeMinute(nil, minute)
+	if err != nil {
+		return err
+	}
+
+	var day = timeutil.Format("Ymd", time.Now().AddDate(0, 0, -nsDailyStatKeepDays))
+	err = nameservers.SharedNSQueryDailyStatDAO.DeleteStatsBeforeDay(nil, day)
+	if err != nil {
+		return err
+	}
+	err = nameservers.SharedNSRouteDailyStatDAO.DeleteStatsBeforeDay(nil, day)
+	if err != nil {
+		return err
+	}
+	return nameservers.SharedNSRegionDailyStatDAO.DeleteStatsBeforeDay(nil, day)
+}
@@ -0,0 +1,180 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package tasks
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models/nameservers"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/miekg/dns"
+	"net"
+	"sync"
+	"time"
+)
+
+func init() {
+	dbs.OnReadyDone(func() {
+		go NewNSTransferNotifier().Start()
+	})
+}
+
+// NSTransferNotifier EdgeDNS域名区域传送通知
+// 域名的SOA序列号增加时向从服务器发送NOTIFY，同时清理过期的记录变更日志
+type NSTransferNotifier struct {
+}
+
+// 记录变更日志保留天数，从服务器的版本早于此时间时需要完整传送
+const nsRecordChangeKeepDays = 7
+
+// 同时发送通知的域名数
+const nsTransferNotifyConcurrent = 16
+
+func NewNSTransferNotifier() *NSTransferNotifier {
+	return &NSTransferNotifier{}
+}
+
+func (this *NSTransferNotifier) Start() {
+	seconds := int64(10)
+	ticker := time.NewTicker(time.Duration(seconds) * time.Second)
+	for range ticker.C {
+		err := this.LoopWithLocker(seconds)
+		if err != nil {
+			remotelogs.Error("NSTransferNotifier", err.Error())
+		}
+	}
+}
+
+// LoopWithLocker 加锁执行
+// 发送通知的时间可能超过循环间隔，所以锁的时间要足够长，执行完成后再释放
+func (this *NSTransferNotifier) LoopWithLocker(seconds int64) error {
+	const lockerKey = "ns_transfer_notifier"
+	ok, err := models.SharedSysLockerDAO.Lock(nil, lockerKey, 300)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	defer func() {
+		err := models.SharedSysLockerDAO.Unlock(nil, lockerKey)
+		if err != nil {
+			remotelogs.Error("NSTransferNotifier", "unlock failed: "+err.Error())
+		}
+	}()
+	return this.Loop()
+}
+
+func (this *NSTransferNotifier) Loop() error {
+	domains, err := nameservers.SharedNSDomainDAO.FindAllEnabledTransferDomains(nil)
+	if err != nil {
+		return err
+	}
+
+	if len(domains) > 0 {
+		var domainChan = make(chan *nameservers.NSDomain, len(domains))
+		for _, domain := range domains {
+			domainChan <- domain
+		}
+		close(domainChan)
+
+		var concurrent = nsTransferNotifyConcurrent
+		if concurrent > len(domains) {
+			concurrent = len(domains)
+		}
+		var wg = &sync.WaitGroup{}
+		wg.Add(concurrent)
+		for i := 0; i < concurrent; i++ {
+			go func() {
+				defer wg.Done()
+				for domain := range domainChan {
+					err := this.notifyDomain(domain)
+					if err != nil {
+						remotelogs.Error("NSTransferNotifier", "notify domain '"+domain.Name+"' failed: "+err.Error())
+					}
+				}
+			}()
+		}
+		wg.Wait()
+	}
+
+	// 清理过期的变更日志
+	return nameservers.SharedNSRecordChangeDAO.DeleteChangesBefore(nil, time.Now().Unix()-nsRecordChangeKeepDays*86400)
+}
+
+// 检查序列号并发送通知
+func (this *NSTransferNotifier) notifyDomain(domain *nameservers.NSDomain) error {
+	config, err := domain.DecodeTransferConfig()
+	if err != nil {
+		return err
+	}
+	err = config.Init()
+	if err != nil {
+		return err
+	}
+	if len(config.NotifyAddrs) == 0 {
+		return nil
+	}
+
+	var domainId = int64(domain.Id)
+	serial, err := nameservers.SharedNSDomainDAO.FindDomainTransferSerial(nil, domainId, config)
+	if err != nil {
+		return err
+	}
+	if domain.NotifiedSerial == serial {
+		return nil
+	}
+
+	var lastErr error
+	for _, addr := range config.NotifyAddrs {
+		err = this.sendNotify(domain.Name, config, serial, addr)
+		if err != nil {
+			lastErr = errors.New("notify '" + addr + "': " + err.Error())
+		}
+	}
+	err = nameservers.SharedNSDomainDAO.UpdateDomainNotifiedSerial(nil, domainId, serial)
+	if err != nil {
+		return err
+	}
+	return lastErr
+}
+
+// 发送NOTIFY消息
+func (this *NSTransferNotifier) sendNotify(domainName string, config *nameservers.NSDomainTransferConfig, serial uint32, addr string) error {
+	// 通知地址由用户填写，只允许发送到公网IP，防止用来探测内网服务
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	var ip = net.ParseIP(host)
+	if ip == nil || !utils.IsPublicIP(ip) {
+		return errors.New("forbidden notify address")
+	}
+
+	var msg = &dns.Msg{}
+	msg.SetNotify(dns.Fqdn(domainName))
+	msg.Answer = []dns.RR{config.ComposeSOA(domainName, serial)}
+
+	var client = &dns.Client{
+		Net:     "udp",
+		Timeout: 5 * time.Second,
+	}
+	if len(config.NotifyTSIGKey) > 0 {
+		var key = config.FindTSIGKey(config.NotifyTSIGKey)
+		if key != nil {
+			client.TsigSecret = map[string]string{key.Name: key.Secret}
+			msg.SetTsig(key.Name, key.Algorithm, 300, time.Now().Unix())
+		}
+	}
+
+	resp, _, err := client.Exchange(msg, addr)
+	if err != nil {
+		return err
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		return errors.New("unexpected rcode '" + dns.RcodeToString[resp.Rcode] + "'")
+	}
+	return nil
+}
@@ -77,7 +77,7 @@ func (this *SSLCertExpireCheckExecutor) loop(seconds int64) error {
 				}
 				if task != nil {
 					if task.AutoRenew == 1 {
-						msg += "此证书是免费申请的证书，且已设置了自动续期，将会在到期前三天自动尝试续期。"
+						msg += "此证书是免费申请的证书，且已设置了自动续期，系统将会在到期前自动尝试续期。"
 					} else {
 						msg += "此证书是免费申请的证书，没有设置自动续期，请在到期前手动执行续期任务。"
 					}
@@ -102,7 +102,8 @@ func (this *SSLCertExpireCheckExecutor) loop(seconds int64) error {
 		}
 	}
 
-	// 自动续期
+	// 即将过期
+	// 自动续期由ACMERenewalScheduler负责，这里只提醒尚未续期成功的证书
 	for _, days := range []int{3, 2, 1} {
 		certs, err := models.SharedSSLCertDAO.FindAllExpiringCerts(nil, days)
 		if err != nil {
@@ -121,40 +122,7 @@ func (this *SSLCertExpireCheckExecutor) loop(seconds int64) error {
 				}
 				if task != nil {
 					if task.AutoRenew == 1 {
-						isOk, errMsg, _ := acme.SharedACMETaskDAO.RunTask(nil, int64(cert.AcmeTaskId))
-						if isOk {
-							// 发送成功通知
-							subject := "系统已成功为你自动更新了证书\"" + cert.Name + "\""
-							msg = "系统已成功为你自动更新了证书\"" + cert.Name + "\"（" + cert.DnsNames + "）。"
-							err = models.SharedMessageDAO.CreateMessage(nil, int64(cert.AdminId), int64(cert.UserId), models.MessageTypeSSLCertACMETaskSuccess, models.MessageLevelSuccess, subject, msg, maps.Map{
-								"certId":     cert.Id,
-								"acmeTaskId": cert.AcmeTaskId,
-							}.AsJSON())
-
-							// 更新通知时间
-							err = models.SharedSSLCertDAO.UpdateCertNotifiedAt(nil, int64(cert.Id))
-							if err != nil {
-								return err
-							}
-						} else {
-							// 发送失败通知
-							subject := "系统在尝试自动更新证书\"" + cert.Name + "\"时发生错误"
-							msg = "系统在尝试自动更新证书\"" + cert.Name + "\"（" + cert.DnsNames + "）时发生错误：" + errMsg + "。请检查系统设置并修复错误。"
-							err = models.SharedMessageDAO.CreateMessage(nil, int64(cert.AdminId), int64(cert.UserId), models.MessageTypeSSLCertACMETaskFailed, models.MessageLevelError, subject, msg, maps.Map{
-								"certId":     cert.Id,
-								"acmeTaskId": cert.AcmeTaskId,
-							}.AsJSON())
-
-							// 更新通知时间
-							err = models.SharedSSLCertDAO.UpdateCertNotifiedAt(nil, int64(cert.Id))
-							if err != nil {
-								return err
-							}
-						}
-
-						// 中止不发送消息
-						continue
-
+						msg += "此证书已设置自动续期，但尚未续期成功，请检查任务的执行日志并修复错误。"
 					} else {
 						msg += "此证书是免费申请的证书，没有设置自动续期，请在到期前手动执行续期任务。"
 					}
@@ -0,0 +1,126 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package tasks
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/sslutils"
+	"github.com/iwind/TeaGo/dbs"
+	"github.com/iwind/TeaGo/maps"
+	timeutil "github.com/iwind/TeaGo/utils/time"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+func init() {
+	dbs.OnReadyDone(func() {
+		go NewSSLCertOCSPUpdater().Start()
+	})
+}
+
+const (
+	sslCertOCSPBatchSize  = 200 // 每次最多更新的证书数
+	sslCertOCSPConcurrent = 8   // 同时请求的证书数
+)
+
+// SSLCertOCSPUpdater 统一获取证书的OCSP响应并下发到边缘节点
+// 这样边缘节点就不需要各自请求OCSP服务
+type SSLCertOCSPUpdater struct {
+	client *http.Client
+}
+
+func NewSSLCertOCSPUpdater() *SSLCertOCSPUpdater {
+	return &SSLCertOCSPUpdater{
+		client: sslutils.NewPublicHTTPClient(10 * time.Second),
+	}
+}
+
+func (this *SSLCertOCSPUpdater) Start() {
+	seconds := int64(600)
+	ticker := time.NewTicker(time.Duration(seconds) * time.Second)
+	for range ticker.C {
+		err := this.LoopWithLocker(seconds)
+		if err != nil {
+			remotelogs.Error("SSLCertOCSPUpdater", err.Error())
+		}
+	}
+}
+
+func (this *SSLCertOCSPUpdater) LoopWithLocker(seconds int64) error {
+	ok, err := models.SharedSysLockerDAO.Lock(nil, "ssl_cert_ocsp_updater", seconds-1)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	return this.Loop()
+}
+
+func (this *SSLCertOCSPUpdater) Loop() error {
+	certs, err := models.SharedSSLCertDAO.FindAllOCSPUpdatingCerts(nil, sslCertOCSPBatchSize)
+	if err != nil {
+		return err
+	}
+	if len(certs) == 0 {
+		return nil
+	}
+
+	var certChan = make(chan *models.SSLCert, len(certs))
+	for _, cert := range certs {
+		certChan <- cert
+	}
+	close(certChan)
+
+	var concurrent = sslCertOCSPConcurrent
+	if concurrent > len(certs) {
+		concurrent = len(certs)
+	}
+	var wg = &sync.WaitGroup{}
+	wg.Add(concurrent)
+	for i := 0; i < concurrent; i++ {
+		go func() {
+			defer wg.Done()
+			for cert := range certChan {
+				err := this.update(cert)
+				if err != nil {
+					remotelogs.Error("SSLCertOCSPUpdater", "update cert '"+strconv.FormatInt(int64(cert.Id), 10)+"' failed: "+err.Error())
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return nil
+}
+
+// 更新单个证书的OCSP响应
+func (this *SSLCertOCSPUpdater) update(cert *models.SSLCert) error {
+	resp, err := sslutils.FetchOCSP(this.client, []byte(cert.CertData))
+	if err != nil {
+		return models.SharedSSLCertDAO.UpdateCertOCSPError(nil, int64(cert.Id), err.Error())
+	}
+
+	err = models.SharedSSLCertDAO.UpdateCertOCSP(nil, int64(cert.Id), resp)
+	if err != nil {
+		return err
+	}
+
+	// 只在第一次发现被吊销时发送通知
+	if !resp.IsRevoked() || cert.RevokedAt > 0 {
+		return nil
+	}
+	err = models.SharedSSLCertDAO.UpdateCertRevoked(nil, int64(cert.Id), uint8(resp.RevocationReason))
+	if err != nil {
+		return err
+	}
+	subject := "SSL证书\"" + cert.Name + "\"已被吊销"
+	msg := "OCSP服务报告SSL证书\"" + cert.Name + "\"（" + cert.DnsNames + "）已于" + timeutil.FormatTime("Y-m-d H:i:s", resp.RevokedAt) + "被吊销，吊销原因代码：" + strconv.Itoa(resp.RevocationReason) + "，请尽快更换证书。"
+	return models.SharedMessageDAO.CreateMessage(nil, int64(cert.AdminId), int64(cert.UserId), models.MessageTypeSSLCertRevoked, models.MessageLevelError, subject, msg, maps.Map{
+		"certId":     cert.Id,
+		"acmeTaskId": cert.AcmeTaskId,
+	}.AsJSON())
+}
@@ -0,0 +1,84 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package tasks
+
+import (
+	"encoding/json"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils/sslutils"
+	"github.com/iwind/TeaGo/dbs"
+	"strconv"
+	"time"
+)
+
+func init() {
+	dbs.OnReadyDone(func() {
+		go NewSSLPolicyLintTask().Start()
+	})
+}
+
+// SSLPolicyLintTask 定期检查所有启用的服务使用的SSL策略，并保存检查报告
+type SSLPolicyLintTask struct {
+}
+
+func NewSSLPolicyLintTask() *SSLPolicyLintTask {
+	return &SSLPolicyLintTask{}
+}
+
+func (this *SSLPolicyLintTask) Start() {
+	seconds := int64(6 * 3600)
+	ticker := time.NewTicker(time.Duration(seconds) * time.Second)
+	for range ticker.C {
+		err := this.LoopWithLocker(seconds)
+		if err != nil {
+			remotelogs.Error("SSLPolicyLintTask", err.Error())
+		}
+	}
+}
+
+func (this *SSLPolicyLintTask) LoopWithLocker(seconds int64) error {
+	ok, err := models.SharedSysLockerDAO.Lock(nil, "ssl_policy_lint_task", seconds-1)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	return this.Loop()
+}
+
+func (this *SSLPolicyLintTask) Loop() error {
+	var startAt = time.Now().Unix()
+
+	policyIds, err := models.SharedServerDAO.FindAllEnabledSSLPolicyIds(nil)
+	if err != nil {
+		return err
+	}
+	for _, policyId := range policyIds {
+		err = this.lintPolicy(policyId)
+		if err != nil {
+			remotelogs.Error("SSLPolicyLintTask", "lint policy '"+strconv.FormatInt(policyId, 10)+"' failed: "+err.Error())
+		}
+	}
+
+	// 删除不再使用的策略的报告
+	return models.SharedSSLPolicyLintReportDAO.DeletePolicyLintReportsCheckedBefore(nil, startAt)
+}
+
+func (this *SSLPolicyLintTask) lintPolicy(policyId int64) error {
+	policyConfig, err := models.SharedSSLPolicyDAO.ComposePolicyConfig(nil, policyId)
+	if err != nil {
+		return err
+	}
+	if policyConfig == nil {
+		return nil
+	}
+
+	var report = sslutils.LintSSLPolicy(policyConfig)
+	issuesJSON, err := json.Marshal(report.Issues)
+	if err != nil {
+		return err
+	}
+	return models.SharedSSLPolicyLintReportDAO.UpdatePolicyLintReport(nil, policyId, report.Grade, issuesJSON)
+}
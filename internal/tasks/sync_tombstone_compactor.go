@@ -0,0 +1,119 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package tasks
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models"
+	"github.com/TeaOSLab/EdgeAPI/internal/db/models/nameservers"
+	"github.com/TeaOSLab/EdgeAPI/internal/remotelogs"
+	"github.com/iwind/TeaGo/dbs"
+	"time"
+)
+
+func init() {
+	dbs.OnReadyDone(func() {
+		go NewSyncTombstoneCompactor().Start()
+	})
+}
+
+const (
+	syncTombstoneKeepDays  = 30   // 删除标记保留天数，客户端超过此时间未同步时需要完整同步
+	syncTombstoneBatchSize = 1000 // 每次每种数据最多清理的删除标记数量
+)
+
+// SyncTombstoneCompactor 清理过期的删除标记和对应的已删除数据
+// 清理后提高对应数据的最小可用版本，版本更旧的客户端会收到需要完整同步的信号
+type SyncTombstoneCompactor struct {
+	hasCreatedMissing bool
+}
+
+func NewSyncTombstoneCompactor() *SyncTombstoneCompactor {
+	return &SyncTombstoneCompactor{}
+}
+
+func (this *SyncTombstoneCompactor) Start() {
+	seconds := int64(600)
+	ticker := time.NewTicker(time.Duration(seconds) * time.Second)
+	for range ticker.C {
+		err := this.LoopWithLocker(seconds)
+		if err != nil {
+			remotelogs.Error("SyncTombstoneCompactor", err.Error())
+		}
+	}
+}
+
+func (this *SyncTombstoneCompactor) LoopWithLocker(seconds int64) error {
+	ok, err := models.SharedSysLockerDAO.Lock(nil, "sync_tombstone_compactor", seconds-1)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return nil
+	}
+	return this.Loop()
+}
+
+func (this *SyncTombstoneCompactor) Loop() error {
+	var tables = this.tables()
+
+	// 为以前删除的数据补充删除标记
+	if !this.hasCreatedMissing {
+		for kind, table := range tables {
+			err := models.SharedSyncTombstoneDAO.CreateMissingTombstones(nil, kind, table)
+			if err != nil {
+				return err
+			}
+		}
+		this.hasCreatedMissing = true
+	}
+
+	var beforeTime = time.Now().Unix() - syncTombstoneKeepDays*86400
+	for kind, table := range tables {
+		err := this.compact(kind, table, beforeTime)
+		if err != nil {
+			remotelogs.Error("SyncTombstoneCompactor", "compact '"+kind+"' failed: "+err.Error())
+		}
+	}
+	return nil
+}
+
+// 清理某种数据的删除标记
+func (this *SyncTombstoneCompactor) compact(kind models.SyncTombstoneKind, table string, beforeTime int64) error {
+	tombstones, err := models.SharedSyncTombstoneDAO.FindExpiredTombstones(nil, kind, beforeTime, syncTombstoneBatchSize)
+	if err != nil {
+		return err
+	}
+	if len(tombstones) == 0 {
+		return nil
+	}
+
+	// 先提高最小可用版本再删除数据，以免客户端错过删除操作
+	var maxVersion int64
+	for _, tombstone := range tombstones {
+		if int64(tombstone.Version) > maxVersion {
+			maxVersion = int64(tombstone.Version)
+		}
+	}
+	err = models.SharedSyncHorizonDAO.UpdateHorizon(nil, kind, maxVersion)
+	if err != nil {
+		return err
+	}
+
+	for _, tombstone := range tombstones {
+		err = models.SharedSyncTombstoneDAO.PurgeTombstone(nil, table, tombstone)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 数据类型和对应的表
+func (this *SyncTombstoneCompactor) tables() map[models.SyncTombstoneKind]string {
+	return map[models.SyncTombstoneKind]string{
+		models.SyncTombstoneKindNSDomain: nameservers.SharedNSDomainDAO.Table,
+		models.SyncTombstoneKindNSRecord: nameservers.SharedNSRecordDAO.Table,
+		models.SyncTombstoneKindNSRoute:  nameservers.SharedNSRouteDAO.Table,
+		models.SyncTombstoneKindIPItem:   models.SharedIPItemDAO.Table,
+	}
+}
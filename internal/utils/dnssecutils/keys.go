@@ -0,0 +1,140 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package dnssecutils
+
+import (
+	"crypto"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/miekg/dns"
+	"strings"
+)
+
+// KeyRole 密钥角色
+type KeyRole = string
+
+const (
+	KeyRoleKSK KeyRole = "ksk" // 密钥签名密钥，用来签名DNSKEY记录，对应注册商处的DS记录
+	KeyRoleZSK KeyRole = "zsk" // 区域签名密钥，用来签名其他记录
+)
+
+// KeyStatus 密钥状态
+type KeyStatus = string
+
+const (
+	KeyStatusPublished KeyStatus = "published" // 已发布到DNSKEY中，但还没有用来签名
+	KeyStatusActive    KeyStatus = "active"    // 正在用来签名
+	KeyStatusRetired   KeyStatus = "retired"   // 已不再用来签名，但仍然保留在DNSKEY中，直到旧的签名过期
+)
+
+// AlgorithmNames 支持的算法
+var AlgorithmNames = map[string]uint8{
+	"RSASHA256":       dns.RSASHA256,
+	"ECDSAP256SHA256": dns.ECDSAP256SHA256,
+	"ECDSAP384SHA384": dns.ECDSAP384SHA384,
+	"ED25519":         dns.ED25519,
+}
+
+// DefaultAlgorithm 默认算法
+const DefaultAlgorithm = "ECDSAP256SHA256"
+
+// Key 用于签名的密钥
+type Key struct {
+	Role       KeyRole
+	Status     KeyStatus
+	DNSKEY     *dns.DNSKEY
+	PrivateKey crypto.Signer
+}
+
+// KeyTag 密钥标签
+func (this *Key) KeyTag() uint16 {
+	return this.DNSKEY.KeyTag()
+}
+
+// GenerateKey 为域名生成新的密钥
+// 返回DNSKEY记录文本和私钥文本，私钥文本为BIND私钥文件格式
+func GenerateKey(zone string, role KeyRole, algorithmName string, ttl uint32) (dnskeyString string, privateKeyString string, keyTag uint16, err error) {
+	algorithm, ok := AlgorithmNames[algorithmName]
+	if !ok {
+		return "", "", 0, errors.New("unsupported algorithm '" + algorithmName + "'")
+	}
+
+	var flags uint16 = 256
+	if role == KeyRoleKSK {
+		flags = 257
+	}
+
+	key := &dns.DNSKEY{
+		Hdr: dns.RR_Header{
+			Name:   dns.Fqdn(zone),
+			Rrtype: dns.TypeDNSKEY,
+			Class:  dns.ClassINET,
+			Ttl:    ttl,
+		},
+		Flags:     flags,
+		Protocol:  3,
+		Algorithm: algorithm,
+	}
+
+	var bits = 256
+	switch algorithm {
+	case dns.RSASHA256:
+		bits = 2048
+	case dns.ECDSAP384SHA384:
+		bits = 384
+	}
+	privateKey, err := key.Generate(bits)
+	if err != nil {
+		return "", "", 0, err
+	}
+
+	return key.String(), key.PrivateKeyString(privateKey), key.KeyTag(), nil
+}
+
+// ParseKey 从DNSKEY记录文本和私钥文本中解析密钥
+func ParseKey(role KeyRole, status KeyStatus, dnskeyString string, privateKeyString string) (*Key, error) {
+	rr, err := dns.NewRR(dnskeyString)
+	if err != nil {
+		return nil, err
+	}
+	dnskey, ok := rr.(*dns.DNSKEY)
+	if !ok {
+		return nil, errors.New("invalid DNSKEY record")
+	}
+
+	privateKey, err := dnskey.ReadPrivateKey(strings.NewReader(privateKeyString), "")
+	if err != nil {
+		return nil, errors.New("read private key failed: " + err.Error())
+	}
+	signer, ok := privateKey.(crypto.Signer)
+	if !ok {
+		return nil, errors.New("invalid private key")
+	}
+
+	return &Key{
+		Role:       role,
+		Status:     status,
+		DNSKEY:     dnskey,
+		PrivateKey: signer,
+	}, nil
+}
+
+// ComposeDS 生成需要提交到注册商的DS记录
+func ComposeDS(dnskeyString string) ([]string, error) {
+	rr, err := dns.NewRR(dnskeyString)
+	if err != nil {
+		return nil, err
+	}
+	dnskey, ok := rr.(*dns.DNSKEY)
+	if !ok {
+		return nil, errors.New("invalid DNSKEY record")
+	}
+
+	var result = []string{}
+	for _, digestType := range []uint8{dns.SHA256, dns.SHA384} {
+		ds := dnskey.ToDS(digestType)
+		if ds != nil {
+			result = append(result, ds.String())
+		}
+	}
+	return result, nil
+}
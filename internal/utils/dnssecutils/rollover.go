@@ -0,0 +1,110 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package dnssecutils
+
+import "sort"
+
+// KeyState 密钥状态信息，用来计算密钥轮换
+type KeyState struct {
+	Id          int64
+	Role        KeyRole
+	Status      KeyStatus
+	CreatedAt   int64
+	ActivatedAt int64
+	RetiredAt   int64
+}
+
+// RolloverOptions 密钥轮换选项
+// ZSK使用预发布方式轮换：新的ZSK先发布到DNSKEY中，等待缓存过期后再开始签名，旧的ZSK退役后继续保留一段时间
+type RolloverOptions struct {
+	ZSKLifetimeSeconds int64 // ZSK使用期限
+	PublishSeconds     int64 // 新的ZSK提前发布的时间，不能小于DNSKEY的TTL
+	RetireSeconds      int64 // 旧的ZSK退役后保留的时间，不能小于签名记录的最大TTL
+}
+
+// RolloverActionType 轮换动作类型
+type RolloverActionType = string
+
+const (
+	RolloverActionCreate   RolloverActionType = "create"   // 创建新密钥
+	RolloverActionActivate RolloverActionType = "activate" // 开始使用密钥签名
+	RolloverActionRetire   RolloverActionType = "retire"   // 停止使用密钥签名
+	RolloverActionRemove   RolloverActionType = "remove"   // 从DNSKEY中删除密钥
+)
+
+// RolloverAction 轮换动作
+type RolloverAction struct {
+	Type   RolloverActionType
+	KeyId  int64     // 已有密钥ID，创建时为0
+	Role   KeyRole   // 创建时使用的角色
+	Status KeyStatus // 创建时使用的状态
+}
+
+// PlanRollover 根据密钥当前状态计算需要执行的动作
+// KSK只在不存在时创建，KSK轮换需要同时更新注册商处的DS记录，所以需要手动操作
+func PlanRollover(keys []*KeyState, options *RolloverOptions, now int64) []*RolloverAction {
+	var actions = []*RolloverAction{}
+
+	for _, role := range []KeyRole{KeyRoleKSK, KeyRoleZSK} {
+		var activeKeys = []*KeyState{}
+		var publishedKeys = []*KeyState{}
+		for _, key := range keys {
+			if key.Role != role {
+				continue
+			}
+			switch key.Status {
+			case KeyStatusActive:
+				activeKeys = append(activeKeys, key)
+			case KeyStatusPublished:
+				publishedKeys = append(publishedKeys, key)
+			case KeyStatusRetired:
+				if now-key.RetiredAt >= options.RetireSeconds {
+					actions = append(actions, &RolloverAction{Type: RolloverActionRemove, KeyId: key.Id})
+				}
+			}
+		}
+
+		// 最早发布的密钥优先
+		sort.Slice(publishedKeys, func(i, j int) bool {
+			return publishedKeys[i].CreatedAt < publishedKeys[j].CreatedAt
+		})
+
+		// 没有可用的密钥
+		if len(activeKeys) == 0 {
+			if len(publishedKeys) > 0 {
+				actions = append(actions, &RolloverAction{Type: RolloverActionActivate, KeyId: publishedKeys[0].Id})
+			} else {
+				actions = append(actions, &RolloverAction{Type: RolloverActionCreate, Role: role, Status: KeyStatusActive})
+			}
+			continue
+		}
+
+		if role != KeyRoleZSK || options.ZSKLifetimeSeconds <= 0 {
+			continue
+		}
+
+		// 已发布足够长时间的密钥，替换当前的密钥
+		if len(publishedKeys) > 0 {
+			if now-publishedKeys[0].CreatedAt >= options.PublishSeconds {
+				actions = append(actions, &RolloverAction{Type: RolloverActionActivate, KeyId: publishedKeys[0].Id})
+				for _, key := range activeKeys {
+					actions = append(actions, &RolloverAction{Type: RolloverActionRetire, KeyId: key.Id})
+				}
+			}
+			continue
+		}
+
+		// 当前密钥即将到期，提前发布新的密钥
+		var latestActivatedAt int64 = 0
+		for _, key := range activeKeys {
+			if key.ActivatedAt > latestActivatedAt {
+				latestActivatedAt = key.ActivatedAt
+			}
+		}
+		if now-latestActivatedAt >= options.ZSKLifetimeSeconds-options.PublishSeconds {
+			actions = append(actions, &RolloverAction{Type: RolloverActionCreate, Role: role, Status: KeyStatusPublished})
+		}
+	}
+
+	return actions
+}
@@ -0,0 +1,61 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package dnssecutils
+
+import "testing"
+
+func TestPlanRollover(t *testing.T) {
+	var options = &RolloverOptions{
+		ZSKLifetimeSeconds: 30 * 86400,
+		PublishSeconds:     86400,
+		RetireSeconds:      86400,
+	}
+
+	// 没有任何密钥
+	actions := PlanRollover(nil, options, 1000)
+	if len(actions) != 2 || actions[0].Role != KeyRoleKSK || actions[1].Role != KeyRoleZSK || actions[1].Status != KeyStatusActive {
+		t.Fatal("should create ksk and zsk")
+	}
+
+	var keys = []*KeyState{
+		{Id: 1, Role: KeyRoleKSK, Status: KeyStatusActive, CreatedAt: 0, ActivatedAt: 0},
+		{Id: 2, Role: KeyRoleZSK, Status: KeyStatusActive, CreatedAt: 0, ActivatedAt: 0},
+	}
+
+	// 还未到期
+	actions = PlanRollover(keys, options, 10*86400)
+	if len(actions) != 0 {
+		t.Fatal("should do nothing")
+	}
+
+	// 即将到期，提前发布
+	actions = PlanRollover(keys, options, 29*86400)
+	if len(actions) != 1 || actions[0].Type != RolloverActionCreate || actions[0].Status != KeyStatusPublished {
+		t.Fatal("should publish new zsk")
+	}
+
+	// 发布时间不够
+	keys = append(keys, &KeyState{Id: 3, Role: KeyRoleZSK, Status: KeyStatusPublished, CreatedAt: 29 * 86400})
+	actions = PlanRollover(keys, options, 29*86400+3600)
+	if len(actions) != 0 {
+		t.Fatal("should wait for publishing")
+	}
+
+	// 替换
+	actions = PlanRollover(keys, options, 30*86400)
+	if len(actions) != 2 ||
+		actions[0].Type != RolloverActionActivate || actions[0].KeyId != 3 ||
+		actions[1].Type != RolloverActionRetire || actions[1].KeyId != 2 {
+		t.Fatal("should activate new zsk and retire old one")
+	}
+
+	// 删除已退役的密钥
+	keys[1].Status = KeyStatusRetired
+	keys[1].RetiredAt = 30 * 86400
+	keys[2].Status = KeyStatusActive
+	keys[2].ActivatedAt = 30 * 86400
+	actions = PlanRollover(keys, options, 31*86400)
+	if len(actions) != 1 || actions[0].Type != RolloverActionRemove || actions[0].KeyId != 2 {
+		t.Fatal("should remove retired zsk")
+	}
+}
@@ -0,0 +1,462 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package dnssecutils
+
+import (
+	"fmt"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/miekg/dns"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Record 记录信息，和NSRecord中的字段对应
+type Record struct {
+	Name     string  // 记录名，@或者空表示域名本身
+	Type     string  // 类型
+	Value    string  // 记录值，格式和区域文件中的RDATA一致
+	TTL      uint32  // TTL
+	RouteIds []int64 // 线路
+}
+
+// SignOptions 签名选项
+type SignOptions struct {
+	Zone             string    // 域名
+	Keys             []*Key    // 所有的密钥，包括尚未启用和已退役的密钥
+	TTL              uint32    // DNSKEY、NSEC和NSEC3记录的TTL
+	SignatureSeconds int64     // 签名有效期
+	RefreshSeconds   int64     // 签名剩余有效期低于此值时重新签名
+	NSEC3            bool      // 是否使用NSEC3
+	NSEC3Iterations  uint16    // NSEC3迭代次数
+	NSEC3Salt        string    // NSEC3盐值，十六进制格式
+	Now              time.Time // 当前时间
+	Existing         []*Record // 上一次生成的记录，用来复用尚未过期的签名
+}
+
+// 同一个名称、类型和线路的记录集合
+type rrSet struct {
+	owner    string
+	rrType   uint16
+	routeIds []int64
+	rrs      []dns.RR
+}
+
+// SignZone 对域名中的记录签名
+// 返回自动生成的DNSKEY、RRSIG、NSEC或NSEC3记录；每个线路的记录集合会单独签名，NSEC/NSEC3记录不区分线路
+// 域名本身的SOA和NS记录如果由节点自动生成，则需要由节点自行处理，这里只在NSEC类型位图中声明
+func SignZone(records []*Record, options *SignOptions) ([]*Record, error) {
+	if options == nil || len(options.Zone) == 0 {
+		return nil, errors.New("'zone' should not be empty")
+	}
+	var zone = dns.Fqdn(strings.ToLower(options.Zone))
+	if options.TTL == 0 {
+		options.TTL = 3600
+	}
+	if options.SignatureSeconds <= 0 {
+		options.SignatureSeconds = 14 * 86400
+	}
+	if options.RefreshSeconds <= 0 || options.RefreshSeconds >= options.SignatureSeconds {
+		options.RefreshSeconds = options.SignatureSeconds / 2
+	}
+	if options.Now.IsZero() {
+		options.Now = time.Now()
+	}
+
+	var kskList = []*Key{}
+	var zskList = []*Key{}
+	var dnskeyRRs = []dns.RR{}
+	for _, key := range options.Keys {
+		var dnskey = *key.DNSKEY
+		dnskey.Hdr.Name = zone
+		dnskey.Hdr.Ttl = options.TTL
+		dnskeyRRs = append(dnskeyRRs, &dnskey)
+		if key.Status != KeyStatusActive {
+			continue
+		}
+		if key.Role == KeyRoleKSK {
+			kskList = append(kskList, key)
+		} else {
+			zskList = append(zskList, key)
+		}
+	}
+	if len(kskList) == 0 {
+		return nil, errors.New("no active KSK")
+	}
+	if len(zskList) == 0 {
+		return nil, errors.New("no active ZSK")
+	}
+
+	// 整理记录集合
+	var rrSetMap = map[string]*rrSet{}
+	var rrSetKeys = []string{}
+	for _, record := range records {
+		var rrType = dns.StringToType[strings.ToUpper(record.Type)]
+		switch rrType {
+		case 0:
+			return nil, errors.New("unsupported record type '" + record.Type + "'")
+		case dns.TypeDNSKEY, dns.TypeRRSIG, dns.TypeNSEC, dns.TypeNSEC3, dns.TypeNSEC3PARAM:
+			continue
+		}
+
+		var owner = recordOwner(record.Name, zone)
+		rr, err := dns.NewRR(owner + " " + strconv.FormatUint(uint64(record.TTL), 10) + " IN " + dns.TypeToString[rrType] + " " + record.Value)
+		if err != nil {
+			return nil, errors.New("parse record '" + record.Name + " " + record.Type + " " + record.Value + "' failed: " + err.Error())
+		}
+		if rr == nil {
+			continue
+		}
+
+		var routeIds = sortRouteIds(record.RouteIds)
+		var key = owner + "|" + dns.TypeToString[rrType] + "|" + routeKey(routeIds)
+		set, ok := rrSetMap[key]
+		if !ok {
+			set = &rrSet{
+				owner:    owner,
+				rrType:   rrType,
+				routeIds: routeIds,
+			}
+			rrSetMap[key] = set
+			rrSetKeys = append(rrSetKeys, key)
+		}
+		set.rrs = append(set.rrs, rr)
+	}
+	sort.Strings(rrSetKeys)
+
+	// 子域名授权点，授权点以下的记录不需要签名
+	var delegations = []string{}
+	for _, set := range rrSetMap {
+		if set.rrType == dns.TypeNS && set.owner != zone {
+			delegations = append(delegations, set.owner)
+		}
+	}
+
+	// 所有的名称和类型
+	var typeMap = map[string]map[uint16]bool{
+		zone: {dns.TypeSOA: true, dns.TypeNS: true, dns.TypeDNSKEY: true},
+	}
+	var signedNames = map[string]bool{zone: true}
+	var signSets = []*rrSet{}
+	for _, key := range rrSetKeys {
+		var set = rrSetMap[key]
+		if isOccluded(set.owner, delegations) {
+			continue
+		}
+		types, ok := typeMap[set.owner]
+		if !ok {
+			types = map[uint16]bool{}
+			typeMap[set.owner] = types
+		}
+		types[set.rrType] = true
+
+		// 授权点上只有DS记录需要签名
+		if set.owner != zone && isDelegation(set.owner, delegations) && set.rrType != dns.TypeDS {
+			continue
+		}
+		signedNames[set.owner] = true
+		signSets = append(signSets, set)
+	}
+
+	// NSEC或NSEC3
+	var chainSets []*rrSet
+	if options.NSEC3 {
+		typeMap[zone][dns.TypeNSEC3PARAM] = true
+		param := &dns.NSEC3PARAM{
+			Hdr:        dns.RR_Header{Name: zone, Rrtype: dns.TypeNSEC3PARAM, Class: dns.ClassINET, Ttl: 0},
+			Hash:       dns.SHA1,
+			Flags:      0,
+			Iterations: options.NSEC3Iterations,
+			SaltLength: uint8(len(options.NSEC3Salt) / 2),
+			Salt:       options.NSEC3Salt,
+		}
+		if len(param.Salt) == 0 {
+			param.Salt = "-"
+		}
+		chainSets = append(chainSets, &rrSet{owner: zone, rrType: dns.TypeNSEC3PARAM, rrs: []dns.RR{param}})
+		chainSets = append(chainSets, composeNSEC3(zone, typeMap, signedNames, options)...)
+	} else {
+		chainSets = composeNSEC(zone, typeMap, options.TTL)
+	}
+	signSets = append(signSets, chainSets...)
+
+	// 复用已有的签名
+	var existingSigs = map[string][]*dns.RRSIG{}
+	for _, record := range options.Existing {
+		if strings.ToUpper(record.Type) != "RRSIG" {
+			continue
+		}
+		var owner = recordOwner(record.Name, zone)
+		rr, err := dns.NewRR(owner + " " + strconv.FormatUint(uint64(record.TTL), 10) + " IN RRSIG " + record.Value)
+		if err != nil || rr == nil {
+			continue
+		}
+		sig := rr.(*dns.RRSIG)
+		var key = owner + "|" + dns.TypeToString[sig.TypeCovered] + "|" + routeKey(sortRouteIds(record.RouteIds)) + "|" + strconv.Itoa(int(sig.KeyTag))
+		existingSigs[key] = append(existingSigs[key], sig)
+	}
+
+	var result = []*Record{}
+
+	// DNSKEY
+	for _, rr := range dnskeyRRs {
+		result = append(result, convertRR(rr, zone, nil))
+	}
+	for _, ksk := range kskList {
+		sig, err := signRRSet(&rrSet{owner: zone, rrType: dns.TypeDNSKEY, rrs: dnskeyRRs}, ksk, zone, options, existingSigs)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, convertRR(sig, zone, nil))
+	}
+
+	for _, set := range chainSets {
+		for _, rr := range set.rrs {
+			result = append(result, convertRR(rr, zone, nil))
+		}
+	}
+	for _, set := range signSets {
+		for _, zsk := range zskList {
+			sig, err := signRRSet(set, zsk, zone, options, existingSigs)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, convertRR(sig, zone, set.routeIds))
+		}
+	}
+
+	return result, nil
+}
+
+// 签名单个记录集合，如果已有的签名仍然有效，则直接使用已有的签名
+func signRRSet(set *rrSet, key *Key, zone string, options *SignOptions, existingSigs map[string][]*dns.RRSIG) (*dns.RRSIG, error) {
+	var now = options.Now.Unix()
+	var sigKey = set.owner + "|" + dns.TypeToString[set.rrType] + "|" + routeKey(set.routeIds) + "|" + strconv.Itoa(int(key.KeyTag()))
+	for _, sig := range existingSigs[sigKey] {
+		if int64(sig.Expiration)-now < options.RefreshSeconds || int64(sig.Inception) > now {
+			continue
+		}
+		if sig.Verify(key.DNSKEY, set.rrs) == nil {
+			return sig, nil
+		}
+	}
+
+	sig := &dns.RRSIG{
+		Hdr: dns.RR_Header{
+			Name:   set.owner,
+			Rrtype: dns.TypeRRSIG,
+			Class:  dns.ClassINET,
+			Ttl:    set.rrs[0].Header().Ttl,
+		},
+		KeyTag:     key.KeyTag(),
+		SignerName: zone,
+		Algorithm:  key.DNSKEY.Algorithm,
+		Inception:  uint32(now - 3600), // 允许一定的时钟误差
+		Expiration: uint32(now + options.SignatureSeconds),
+	}
+	err := sig.Sign(key.PrivateKey, set.rrs)
+	if err != nil {
+		return nil, errors.New("sign '" + set.owner + " " + dns.TypeToString[set.rrType] + "' failed: " + err.Error())
+	}
+	return sig, nil
+}
+
+// 生成NSEC记录链
+func composeNSEC(zone string, typeMap map[string]map[uint16]bool, ttl uint32) []*rrSet {
+	var names = sortedNames(typeMap)
+	var result = []*rrSet{}
+	for index, name := range names {
+		var next = names[(index+1)%len(names)]
+		var types = []uint16{dns.TypeRRSIG, dns.TypeNSEC}
+		for t := range typeMap[name] {
+			types = append(types, t)
+		}
+		result = append(result, &rrSet{
+			owner:  name,
+			rrType: dns.TypeNSEC,
+			rrs: []dns.RR{&dns.NSEC{
+				Hdr:        dns.RR_Header{Name: name, Rrtype: dns.TypeNSEC, Class: dns.ClassINET, Ttl: ttl},
+				NextDomain: next,
+				TypeBitMap: sortTypes(types),
+			}},
+		})
+	}
+	return result
+}
+
+// 生成NSEC3记录链
+func composeNSEC3(zone string, typeMap map[string]map[uint16]bool, signedNames map[string]bool, options *SignOptions) []*rrSet {
+	// 加入空的非终端节点
+	var allTypes = map[string]map[uint16]bool{}
+	for name, types := range typeMap {
+		allTypes[name] = types
+		for parent := parentName(name); len(parent) > 0 && parent != zone && dns.IsSubDomain(zone, parent); parent = parentName(parent) {
+			if _, ok := allTypes[parent]; !ok {
+				allTypes[parent] = map[uint16]bool{}
+			}
+		}
+	}
+
+	type hashedName struct {
+		hash string
+		name string
+	}
+	var hashes = []*hashedName{}
+	for name := range allTypes {
+		hashes = append(hashes, &hashedName{
+			hash: strings.ToLower(dns.HashName(name, dns.SHA1, options.NSEC3Iterations, options.NSEC3Salt)),
+			name: name,
+		})
+	}
+	sort.Slice(hashes, func(i, j int) bool {
+		return hashes[i].hash < hashes[j].hash
+	})
+
+	var result = []*rrSet{}
+	for index, h := range hashes {
+		var next = hashes[(index+1)%len(hashes)]
+		var types = []uint16{}
+		for t := range allTypes[h.name] {
+			types = append(types, t)
+		}
+		if signedNames[h.name] {
+			types = append(types, dns.TypeRRSIG)
+		}
+		var salt = options.NSEC3Salt
+		if len(salt) == 0 {
+			salt = "-"
+		}
+		var owner = h.hash + "." + zone
+		result = append(result, &rrSet{
+			owner:  owner,
+			rrType: dns.TypeNSEC3,
+			rrs: []dns.RR{&dns.NSEC3{
+				Hdr:        dns.RR_Header{Name: owner, Rrtype: dns.TypeNSEC3, Class: dns.ClassINET, Ttl: options.TTL},
+				Hash:       dns.SHA1,
+				Flags:      0,
+				Iterations: options.NSEC3Iterations,
+				SaltLength: uint8(len(options.NSEC3Salt) / 2),
+				Salt:       salt,
+				HashLength: 20,
+				NextDomain: next.hash,
+				TypeBitMap: sortTypes(types),
+			}},
+		})
+	}
+	return result
+}
+
+// 转换为记录
+func convertRR(rr dns.RR, zone string, routeIds []int64) *Record {
+	var header = rr.Header()
+	var name = header.Name
+	if name == zone {
+		name = "@"
+	} else {
+		name = strings.TrimSuffix(name, "."+zone)
+	}
+	return &Record{
+		Name:     name,
+		Type:     dns.TypeToString[header.Rrtype],
+		Value:    strings.TrimSpace(strings.TrimPrefix(rr.String(), header.String())),
+		TTL:      header.Ttl,
+		RouteIds: routeIds,
+	}
+}
+
+// 记录的完整名称
+func recordOwner(name string, zone string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if len(name) == 0 || name == "@" {
+		return zone
+	}
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "." + zone
+}
+
+// 是否为授权点
+func isDelegation(name string, delegations []string) bool {
+	for _, delegation := range delegations {
+		if name == delegation {
+			return true
+		}
+	}
+	return false
+}
+
+// 是否在授权点以下
+func isOccluded(name string, delegations []string) bool {
+	for _, delegation := range delegations {
+		if name != delegation && dns.IsSubDomain(delegation, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// 上一级名称
+func parentName(name string) string {
+	index := strings.Index(name, ".")
+	if index < 0 || index == len(name)-1 {
+		return ""
+	}
+	return name[index+1:]
+}
+
+// 按照RFC 4034 6.1中的规范顺序排列名称
+func sortedNames(typeMap map[string]map[uint16]bool) []string {
+	var names = []string{}
+	for name := range typeMap {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return canonicalLess(names[i], names[j])
+	})
+	return names
+}
+
+func canonicalLess(name1 string, name2 string) bool {
+	var labels1 = dns.SplitDomainName(strings.ToLower(name1))
+	var labels2 = dns.SplitDomainName(strings.ToLower(name2))
+	for i, j := len(labels1)-1, len(labels2)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if labels1[i] != labels2[j] {
+			return labels1[i] < labels2[j]
+		}
+	}
+	return len(labels1) < len(labels2)
+}
+
+func sortTypes(types []uint16) []uint16 {
+	var m = map[uint16]bool{}
+	var result = []uint16{}
+	for _, t := range types {
+		if m[t] {
+			continue
+		}
+		m[t] = true
+		result = append(result, t)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i] < result[j]
+	})
+	return result
+}
+
+func sortRouteIds(routeIds []int64) []int64 {
+	var result = append([]int64{}, routeIds...)
+	sort.Slice(result, func(i, j int) bool {
+		return result[i] < result[j]
+	})
+	return result
+}
+
+func routeKey(routeIds []int64) string {
+	var pieces = []string{}
+	for _, routeId := range routeIds {
+		pieces = append(pieces, fmt.Sprintf("%d", routeId))
+	}
+	return strings.Join(pieces, ",")
+}
@@ -0,0 +1,239 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package dnssecutils
+
+import (
+	"github.com/miekg/dns"
+	"testing"
+	"time"
+)
+
+func testSignKeys(t *testing.T, zone string) []*Key {
+	var keys = []*Key{}
+	for _, role := range []KeyRole{KeyRoleKSK, KeyRoleZSK} {
+		dnskeyString, privateKeyString, _, err := GenerateKey(zone, role, DefaultAlgorithm, 3600)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key, err := ParseKey(role, KeyStatusActive, dnskeyString, privateKeyString)
+		if err != nil {
+			t.Fatal(err)
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+func testSignRecords() []*Record {
+	return []*Record{
+		{Name: "@", Type: "A", Value: "192.168.1.100", TTL: 600},
+		{Name: "www", Type: "A", Value: "192.168.1.100", TTL: 600},
+		{Name: "www", Type: "A", Value: "192.168.1.101", TTL: 600},
+		{Name: "www", Type: "A", Value: "192.168.2.100", TTL: 600, RouteIds: []int64{2}},
+		{Name: "mail", Type: "MX", Value: "10 mx.teaos.cn.", TTL: 600},
+		{Name: "a.b", Type: "TXT", Value: "\"hello\"", TTL: 600},
+		{Name: "sub", Type: "NS", Value: "ns1.other.com.", TTL: 600},
+		{Name: "host.sub", Type: "A", Value: "192.168.3.100", TTL: 600},
+	}
+}
+
+func TestSignZone_NSEC(t *testing.T) {
+	var zone = "teaos.cn"
+	var keys = testSignKeys(t, zone)
+	var options = &SignOptions{
+		Zone: zone,
+		Keys: keys,
+	}
+	result, err := SignZone(testSignRecords(), options)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var countSigs = map[string]int{}
+	var nsecNames = []string{}
+	for _, record := range result {
+		switch record.Type {
+		case "RRSIG":
+			rr, err := dns.NewRR(record.Name + ".teaos.cn. 600 IN RRSIG " + record.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			countSigs[record.Name+" "+dns.TypeToString[rr.(*dns.RRSIG).TypeCovered]]++
+		case "NSEC":
+			nsecNames = append(nsecNames, record.Name)
+		}
+	}
+
+	// 不同线路的记录分别签名
+	if countSigs["www A"] != 2 {
+		t.Fatal("www should have 2 signatures for different routes", countSigs)
+	}
+	if countSigs["@ DNSKEY"] != 1 || countSigs["@ A"] != 1 || countSigs["mail MX"] != 1 {
+		t.Fatal("invalid signatures", countSigs)
+	}
+
+	// 授权点的NS记录和以下的记录都不需要签名
+	if countSigs["sub NS"] != 0 || countSigs["host.sub A"] != 0 {
+		t.Fatal("delegation should not be signed", countSigs)
+	}
+
+	// NSEC链按规范顺序排列
+	var expectedNames = []string{"@", "a.b", "mail", "sub", "www"}
+	if len(nsecNames) != len(expectedNames) {
+		t.Fatal("invalid nsec chain", nsecNames)
+	}
+	for index, name := range expectedNames {
+		if nsecNames[index] != name {
+			t.Fatal("invalid nsec chain", nsecNames)
+		}
+	}
+}
+
+func TestSignZone_Verify(t *testing.T) {
+	var zone = "teaos.cn"
+	var keys = testSignKeys(t, zone)
+	result, err := SignZone([]*Record{
+		{Name: "www", Type: "A", Value: "192.168.1.100", TTL: 600},
+		{Name: "www", Type: "A", Value: "192.168.1.101", TTL: 600},
+	}, &SignOptions{
+		Zone: zone,
+		Keys: keys,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rrset = []dns.RR{}
+	for _, value := range []string{"192.168.1.100", "192.168.1.101"} {
+		rr, _ := dns.NewRR("www.teaos.cn. 600 IN A " + value)
+		rrset = append(rrset, rr)
+	}
+	var verified = false
+	for _, record := range result {
+		if record.Type != "RRSIG" || record.Name != "www" {
+			continue
+		}
+		rr, err := dns.NewRR("www.teaos.cn. 600 IN RRSIG " + record.Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig := rr.(*dns.RRSIG)
+		if sig.TypeCovered != dns.TypeA {
+			continue
+		}
+		err = sig.Verify(keys[1].DNSKEY, rrset)
+		if err != nil {
+			t.Fatal(err)
+		}
+		verified = true
+	}
+	if !verified {
+		t.Fatal("signature not found")
+	}
+}
+
+func TestSignZone_Reuse(t *testing.T) {
+	var zone = "teaos.cn"
+	var keys = testSignKeys(t, zone)
+	var now = time.Now()
+	result1, err := SignZone(testSignRecords(), &SignOptions{
+		Zone: zone,
+		Keys: keys,
+		Now:  now,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 没有到刷新时间，使用原有签名
+	result2, err := SignZone(testSignRecords(), &SignOptions{
+		Zone:     zone,
+		Keys:     keys,
+		Now:      now.Add(1 * time.Hour),
+		Existing: result1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result1) != len(result2) {
+		t.Fatal("count should be equal")
+	}
+	for index, record := range result1 {
+		if record.Value != result2[index].Value {
+			t.Fatal("signature should be reused", record.Name, record.Type)
+		}
+	}
+
+	// 修改记录后重新签名
+	var records = testSignRecords()
+	records[0].Value = "192.168.1.200"
+	result3, err := SignZone(records, &SignOptions{
+		Zone:     zone,
+		Keys:     keys,
+		Now:      now.Add(1 * time.Hour),
+		Existing: result1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var countChanged = 0
+	for index, record := range result1 {
+		if record.Value != result3[index].Value {
+			countChanged++
+		}
+	}
+	if countChanged != 1 {
+		t.Fatal("only one signature should be changed, but got", countChanged)
+	}
+}
+
+func TestSignZone_NSEC3(t *testing.T) {
+	var zone = "teaos.cn"
+	var keys = testSignKeys(t, zone)
+	result, err := SignZone(testSignRecords(), &SignOptions{
+		Zone:            zone,
+		Keys:            keys,
+		NSEC3:           true,
+		NSEC3Iterations: 1,
+		NSEC3Salt:       "AABBCCDD",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var countNSEC3 = 0
+	var hasParam = false
+	for _, record := range result {
+		switch record.Type {
+		case "NSEC":
+			t.Fatal("should not contain NSEC")
+		case "NSEC3":
+			countNSEC3++
+		case "NSEC3PARAM":
+			hasParam = record.Name == "@"
+		}
+	}
+
+	// 包括空的非终端节点b
+	if countNSEC3 != 6 {
+		t.Fatal("invalid NSEC3 count", countNSEC3)
+	}
+	if !hasParam {
+		t.Fatal("NSEC3PARAM should be at apex")
+	}
+}
+
+func TestComposeDS(t *testing.T) {
+	dnskeyString, _, _, err := GenerateKey("teaos.cn", KeyRoleKSK, DefaultAlgorithm, 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dsList, err := ComposeDS(dnskeyString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dsList) != 2 {
+		t.Fatal("should have 2 DS records")
+	}
+	t.Log(dsList)
+}
@@ -29,3 +29,41 @@ func IP2Long(ip string) uint64 {
 func IsIPv6(ip string) bool {
 	return strings.Contains(ip, ":")
 }
+
+// 内网和保留的地址段
+var privateIPNets = func() []*net.IPNet {
+	var result = []*net.IPNet{}
+	for _, cidr := range []string{
+		"0.0.0.0/8",
+		"10.0.0.0/8",
+		"100.64.0.0/10",
+		"172.16.0.0/12",
+		"192.168.0.0/16",
+		"fc00::/7",
+	} {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err == nil {
+			result = append(result, ipNet)
+		}
+	}
+	return result
+}()
+
+// IsPublicIP 判断是否为公网地址
+// 用来限制根据用户输入的地址发起的请求，防止被用来访问内网服务
+func IsPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() ||
+		ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() {
+		return false
+	}
+	for _, ipNet := range privateIPNets {
+		if ipNet.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
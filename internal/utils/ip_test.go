@@ -0,0 +1,19 @@
+package utils
+
+import (
+	"net"
+	"testing"
+)
+
+func TestIsPublicIP(t *testing.T) {
+	for _, s := range []string{"127.0.0.1", "::1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "100.64.0.1", "0.0.0.0", "::", "fe80::1", "fd00::1", "::ffff:127.0.0.1", "224.0.0.1"} {
+		if IsPublicIP(net.ParseIP(s)) {
+			t.Fatal(s + " should not be public")
+		}
+	}
+	for _, s := range []string{"8.8.8.8", "1.1.1.1", "2001:4860:4860::8888"} {
+		if !IsPublicIP(net.ParseIP(s)) {
+			t.Fatal(s + " should be public")
+		}
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package sslutils
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// 下载的证书最大尺寸
+const maxCertDownloadSize = 1 << 20
+
+// ParseCerts 解析PEM格式的证书链
+func ParseCerts(certData []byte) ([]*x509.Certificate, error) {
+	var result = []*x509.Certificate{}
+	for {
+		var block *pem.Block
+		block, certData = pem.Decode(certData)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, cert)
+	}
+	if len(result) == 0 {
+		return nil, errors.New("no certificate found")
+	}
+	return result, nil
+}
+
+// ParseLeafAndIssuer 从证书链中找出站点证书和它的签发者证书
+// 证书链中没有签发者证书时issuer为nil
+func ParseLeafAndIssuer(certData []byte) (leaf *x509.Certificate, issuer *x509.Certificate, err error) {
+	certs, err := ParseCerts(certData)
+	if err != nil {
+		return nil, nil, err
+	}
+	leaf = certs[0]
+	for _, cert := range certs[1:] {
+		if leaf.CheckSignatureFrom(cert) == nil {
+			issuer = cert
+			break
+		}
+	}
+	return
+}
+
+// FetchIssuer 通过证书中的AIA信息下载签发者证书
+func FetchIssuer(client *http.Client, cert *x509.Certificate) (*x509.Certificate, error) {
+	if len(cert.IssuingCertificateURL) == 0 {
+		return nil, errors.New("no issuing certificate url")
+	}
+
+	var lastErr error
+	for _, url := range cert.IssuingCertificateURL {
+		issuer, err := fetchCert(client, url)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		err = cert.CheckSignatureFrom(issuer)
+		if err != nil {
+			lastErr = errors.New("invalid issuer from '" + url + "': " + err.Error())
+			continue
+		}
+		return issuer, nil
+	}
+	return nil, lastErr
+}
+
+// 下载单个证书，支持DER和PEM格式
+// 连接失败时不返回原始的错误信息，以免泄露网络环境
+func fetchCert(client *http.Client, url string) (*x509.Certificate, error) {
+	err := checkFetchURL(url)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Get(url)
+	if err != nil {
+		return nil, errors.New("fetch '" + url + "' failed")
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("fetch '" + url + "' failed: " + resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxCertDownloadSize))
+	if err != nil {
+		return nil, errors.New("fetch '" + url + "' failed")
+	}
+
+	block, _ := pem.Decode(data)
+	if block != nil {
+		data = block.Bytes
+	}
+	return x509.ParseCertificate(data)
+}
@@ -0,0 +1,283 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package sslutils
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 证书链最大深度
+const maxChainDepth = 8
+
+// ChainCertSource 证书链中证书的来源
+type ChainCertSource = string
+
+const (
+	ChainCertSourceUploaded ChainCertSource = "uploaded" // 上传的证书中包含
+	ChainCertSourceStored   ChainCertSource = "stored"   // 从已保存的CA证书中找到
+	ChainCertSourceAIA      ChainCertSource = "aia"      // 通过AIA下载
+)
+
+// ChainCert 证书链中的单个证书信息
+type ChainCert struct {
+	Subject   string          `json:"subject"`
+	Issuer    string          `json:"issuer"`
+	NotBefore int64           `json:"notBefore"`
+	NotAfter  int64           `json:"notAfter"`
+	IsCA      bool            `json:"isCA"`
+	Source    ChainCertSource `json:"source"`
+}
+
+// ChainReport 证书链检查报告
+type ChainReport struct {
+	IsValid            bool         `json:"isValid"`            // 证书链是否完整并且可信
+	HasKey             bool         `json:"hasKey"`             // 是否检查了私钥
+	KeyMatched         bool         `json:"keyMatched"`         // 私钥和证书是否匹配
+	Chain              []*ChainCert `json:"chain"`              // 站点证书到最后一个中间证书
+	AddedIntermediates int          `json:"addedIntermediates"` // 自动补全的中间证书数量
+	RemovedCerts       int          `json:"removedCerts"`       // 上传的证书中没有用到的证书数量
+	Errors             []string     `json:"errors"`
+	Warnings           []string     `json:"warnings"`
+
+	CertData []byte `json:"-"` // 补全后的证书链，PEM格式，不包含根证书
+}
+
+func (this *ChainReport) addError(err string) {
+	this.Errors = append(this.Errors, err)
+}
+
+func (this *ChainReport) addWarning(warning string) {
+	this.Warnings = append(this.Warnings, warning)
+}
+
+// ChainVerifier 证书链检查
+// 检查证书链是否能够验证到可信的根证书，并自动补全缺少的中间证书
+type ChainVerifier struct {
+	client  *http.Client
+	roots   *x509.CertPool
+	caCerts []*x509.Certificate
+}
+
+func NewChainVerifier() *ChainVerifier {
+	return &ChainVerifier{
+		client: NewPublicHTTPClient(10 * time.Second),
+		roots:  NewRootPool(),
+	}
+}
+
+// SetHTTPClient 设置下载中间证书时使用的客户端
+func (this *ChainVerifier) SetHTTPClient(client *http.Client) {
+	this.client = client
+}
+
+// SetRoots 设置可信的根证书
+func (this *ChainVerifier) SetRoots(roots *x509.CertPool) {
+	this.roots = roots
+}
+
+// AddCACerts 添加已保存的CA证书
+// 自签名的证书作为可信的根证书，其他的用来补全中间证书
+func (this *ChainVerifier) AddCACerts(certs ...*x509.Certificate) {
+	for _, cert := range certs {
+		if !cert.IsCA {
+			continue
+		}
+		if isSelfSigned(cert) {
+			this.roots.AddCert(cert)
+		}
+		this.caCerts = append(this.caCerts, cert)
+	}
+}
+
+// Verify 检查证书链和私钥
+// keyData 为空时不检查私钥
+func (this *ChainVerifier) Verify(certData []byte, keyData []byte) *ChainReport {
+	var report = &ChainReport{
+		Chain:    []*ChainCert{},
+		Errors:   []string{},
+		Warnings: []string{},
+	}
+
+	certs, err := ParseCerts(certData)
+	if err != nil {
+		report.addError("无法解析证书：" + err.Error())
+		return report
+	}
+	var leaf = certs[0]
+
+	// 私钥
+	if len(keyData) > 0 {
+		report.HasKey = true
+		report.KeyMatched, err = matchKey(leaf, keyData)
+		if err != nil {
+			report.addError("无法解析私钥：" + err.Error())
+		} else if !report.KeyMatched {
+			report.addError("私钥和证书不匹配")
+		}
+	}
+
+	// 查找签发者证书
+	var chain = []*x509.Certificate{leaf}
+	var sources = []ChainCertSource{ChainCertSourceUploaded}
+	var used = map[int]bool{0: true}
+	for len(chain) < maxChainDepth {
+		var last = chain[len(chain)-1]
+		if isSelfSigned(last) || this.isTrusted(last) {
+			break
+		}
+
+		var issuer *x509.Certificate
+		var source ChainCertSource
+		for index, cert := range certs {
+			if !used[index] && last.CheckSignatureFrom(cert) == nil {
+				issuer = cert
+				source = ChainCertSourceUploaded
+				used[index] = true
+				break
+			}
+		}
+		if issuer == nil {
+			for _, cert := range this.caCerts {
+				if last.CheckSignatureFrom(cert) == nil {
+					issuer = cert
+					source = ChainCertSourceStored
+					break
+				}
+			}
+		}
+		if issuer == nil && len(last.IssuingCertificateURL) > 0 && this.client != nil {
+			issuer, err = FetchIssuer(this.client, last)
+			if err != nil {
+				report.addWarning("通过AIA下载 '" + last.Issuer.String() + "' 的证书失败")
+			}
+			source = ChainCertSourceAIA
+		}
+		if issuer == nil {
+			break
+		}
+		chain = append(chain, issuer)
+		sources = append(sources, source)
+		if source != ChainCertSourceUploaded {
+			report.AddedIntermediates++
+		}
+	}
+
+	// 上传的根证书不算作无关的证书
+	var last = chain[len(chain)-1]
+	for index, cert := range certs {
+		if !used[index] && isSelfSigned(cert) && last.CheckSignatureFrom(cert) == nil {
+			used[index] = true
+		}
+	}
+	report.RemovedCerts = len(certs) - len(used)
+	if report.RemovedCerts > 0 {
+		report.addWarning("上传的证书中有不属于此证书链的证书，已忽略")
+	}
+
+	// 根证书不需要放在证书链中
+	if len(chain) > 1 && isSelfSigned(chain[len(chain)-1]) {
+		chain = chain[:len(chain)-1]
+	}
+
+	var intermediates = x509.NewCertPool()
+	var buf = &bytes.Buffer{}
+	for index, cert := range chain {
+		if index > 0 {
+			intermediates.AddCert(cert)
+		}
+		_ = pem.Encode(buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+		report.Chain = append(report.Chain, &ChainCert{
+			Subject:   cert.Subject.String(),
+			Issuer:    cert.Issuer.String(),
+			NotBefore: cert.NotBefore.Unix(),
+			NotAfter:  cert.NotAfter.Unix(),
+			IsCA:      cert.IsCA,
+			Source:    sources[index],
+		})
+	}
+	report.CertData = buf.Bytes()
+
+	// 验证证书链
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:         this.roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		report.addError("证书链验证失败：" + err.Error())
+	} else {
+		report.IsValid = true
+	}
+
+	return report
+}
+
+// 是否可以直接被根证书验证
+func (this *ChainVerifier) isTrusted(cert *x509.Certificate) bool {
+	_, err := cert.Verify(x509.VerifyOptions{
+		Roots:     this.roots,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err == nil
+}
+
+// 是否为自签名证书
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil
+}
+
+// 检查私钥是否和证书匹配
+func matchKey(cert *x509.Certificate, keyData []byte) (bool, error) {
+	key, err := parsePEMPrivateKey(keyData)
+	if err != nil {
+		return false, err
+	}
+	return matchPrivateKey(cert, key)
+}
+
+// 检查已解析的私钥是否和证书匹配
+func matchPrivateKey(cert *x509.Certificate, key crypto.PrivateKey) (bool, error) {
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return false, errors.New("unsupported private key")
+	}
+	publicKey, ok := signer.Public().(interface {
+		Equal(x crypto.PublicKey) bool
+	})
+	if !ok {
+		return false, errors.New("unsupported public key")
+	}
+	return publicKey.Equal(cert.PublicKey), nil
+}
+
+// 解析PEM格式的私钥
+func parsePEMPrivateKey(keyData []byte) (crypto.PrivateKey, error) {
+	for {
+		var block *pem.Block
+		block, keyData = pem.Decode(keyData)
+		if block == nil {
+			return nil, errors.New("no private key found")
+		}
+		if strings.HasSuffix(block.Type, "PRIVATE KEY") {
+			return parsePrivateKey(block.Bytes)
+		}
+	}
+}
+
+// 解析各种格式的私钥
+func parsePrivateKey(der []byte) (crypto.PrivateKey, error) {
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		return key, nil
+	}
+	return x509.ParseECPrivateKey(der)
+}
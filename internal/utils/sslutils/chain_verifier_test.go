@@ -0,0 +1,159 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package sslutils
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// 生成由parent签发的证书，parent为nil时生成自签名证书
+func newTestChainCert(t *testing.T, serial int64, name string, isCA bool, parent *testCA, issuerURL string) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var template = &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	if isCA {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		template.DNSNames = []string{name}
+		template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth}
+	}
+	if len(issuerURL) > 0 {
+		template.IssuingCertificateURL = []string{issuerURL}
+	}
+
+	var parentCert = template
+	var parentKey = key
+	if parent != nil {
+		parentCert = parent.cert
+		parentKey = parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, parentCert, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+func encodeTestKey(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	der, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: der})
+}
+
+func TestChainVerifier_Verify(t *testing.T) {
+	var root = newTestChainCert(t, 1, "Test Root", true, nil, "")
+	var intermediate = newTestChainCert(t, 2, "Test Intermediate", true, root, "")
+
+	var mux = http.NewServeMux()
+	mux.HandleFunc("/intermediate.der", func(writer http.ResponseWriter, req *http.Request) {
+		_, _ = writer.Write(intermediate.cert.Raw)
+	})
+	var server = httptest.NewServer(mux)
+	defer server.Close()
+
+	var newVerifier = func() *ChainVerifier {
+		var roots = x509.NewCertPool()
+		roots.AddCert(root.cert)
+		var verifier = NewChainVerifier()
+		verifier.SetHTTPClient(server.Client())
+		verifier.SetRoots(roots)
+		return verifier
+	}
+
+	var leaf = newTestChainCert(t, 3, "example.com", false, intermediate, server.URL+"/intermediate.der")
+	var keyData = encodeTestKey(t, leaf.key)
+
+	// 通过AIA补全
+	{
+		var report = newVerifier().Verify(encodeTestCerts(leaf.cert), keyData)
+		if !report.IsValid || !report.KeyMatched || len(report.Errors) > 0 {
+			t.Fatalf("unexpected report: %#v", report)
+		}
+		if report.AddedIntermediates != 1 || len(report.Chain) != 2 || report.Chain[1].Source != ChainCertSourceAIA {
+			t.Fatalf("unexpected chain: %#v", report.Chain)
+		}
+		if !bytes.Equal(report.CertData, encodeTestCerts(leaf.cert, intermediate.cert)) {
+			t.Fatal("unexpected cert data")
+		}
+	}
+
+	// 从已保存的CA证书中补全
+	{
+		var leaf2 = newTestChainCert(t, 4, "example.org", false, intermediate, "")
+		var verifier = newVerifier()
+		verifier.AddCACerts(intermediate.cert)
+		var report = verifier.Verify(encodeTestCerts(leaf2.cert), nil)
+		if !report.IsValid || report.HasKey || report.AddedIntermediates != 1 || report.Chain[1].Source != ChainCertSourceStored {
+			t.Fatalf("unexpected report: %#v", report)
+		}
+	}
+
+	// 顺序错误并且包含无关的证书
+	{
+		var other = newTestChainCert(t, 5, "Other", true, nil, "")
+		var report = newVerifier().Verify(encodeTestCerts(leaf.cert, root.cert, other.cert, intermediate.cert), keyData)
+		if !report.IsValid || report.AddedIntermediates != 0 || report.RemovedCerts != 1 || len(report.Warnings) != 1 {
+			t.Fatalf("unexpected report: %#v", report)
+		}
+		if !bytes.Equal(report.CertData, encodeTestCerts(leaf.cert, intermediate.cert)) {
+			t.Fatal("unexpected cert data")
+		}
+	}
+
+	// 私钥不匹配
+	{
+		var other = newTestChainCert(t, 6, "example.net", false, intermediate, "")
+		var report = newVerifier().Verify(encodeTestCerts(leaf.cert, intermediate.cert), encodeTestKey(t, other.key))
+		if report.KeyMatched || len(report.Errors) != 1 {
+			t.Fatalf("unexpected report: %#v", report)
+		}
+	}
+
+	// 不可信的根证书
+	{
+		var verifier = newVerifier()
+		verifier.SetRoots(x509.NewCertPool())
+		var report = verifier.Verify(encodeTestCerts(leaf.cert, intermediate.cert), keyData)
+		if report.IsValid || len(report.Errors) != 1 {
+			t.Fatalf("unexpected report: %#v", report)
+		}
+	}
+
+	// 使用已保存的私有根证书
+	{
+		var verifier = newVerifier()
+		verifier.SetRoots(x509.NewCertPool())
+		verifier.AddCACerts(root.cert)
+		var report = verifier.Verify(encodeTestCerts(leaf.cert, intermediate.cert), keyData)
+		if !report.IsValid {
+			t.Fatalf("unexpected report: %#v", report)
+		}
+	}
+}
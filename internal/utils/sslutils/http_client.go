@@ -0,0 +1,63 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package sslutils
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/TeaOSLab/EdgeAPI/internal/utils"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// 下载时最多允许的跳转次数
+const maxFetchRedirects = 3
+
+var errForbiddenAddress = errors.New("forbidden address")
+
+// NewPublicHTTPClient 创建用来下载证书中AIA、OCSP等地址的HTTP客户端
+// 证书可能由用户上传，所以只允许访问公网地址，防止被用来访问内网服务
+func NewPublicHTTPClient(timeout time.Duration) *http.Client {
+	var dialer = &net.Dialer{
+		Timeout: timeout,
+
+		// 在域名解析之后检查实际连接的地址
+		Control: func(network string, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			var ip = net.ParseIP(host)
+			if ip == nil || !utils.IsPublicIP(ip) {
+				return errForbiddenAddress
+			}
+			return nil
+		},
+	}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        32,
+			IdleConnTimeout:     90 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxFetchRedirects {
+				return errors.New("too many redirects")
+			}
+			return checkFetchURL(req.URL.String())
+		},
+	}
+}
+
+// 检查下载地址，只允许HTTP和HTTPS
+func checkFetchURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Hostname()) == 0 {
+		return errors.New("invalid url '" + rawURL + "'")
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package sslutils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckFetchURL(t *testing.T) {
+	for _, s := range []string{"http://example.com/ca.crt", "https://example.com/ocsp"} {
+		if checkFetchURL(s) != nil {
+			t.Fatal(s + " should be allowed")
+		}
+	}
+	for _, s := range []string{"file:///etc/passwd", "ftp://example.com/ca.crt", "ldap://example.com", "http://"} {
+		if checkFetchURL(s) == nil {
+			t.Fatal(s + " should not be allowed")
+		}
+	}
+}
+
+func TestNewPublicHTTPClient(t *testing.T) {
+	var server = httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		_, _ = writer.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	var client = NewPublicHTTPClient(5 * time.Second)
+	_, err := client.Get(server.URL)
+	if err == nil {
+		t.Fatal("should not be able to access loopback address")
+	}
+
+	// 不返回原始的错误信息
+	_, err = fetchCert(client, server.URL)
+	if err == nil || !strings.HasPrefix(err.Error(), "fetch '"+server.URL+"' failed") || strings.Contains(err.Error(), "forbidden") {
+		t.Fatal("unexpected error:", err)
+	}
+}
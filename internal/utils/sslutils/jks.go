@@ -0,0 +1,265 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package sslutils
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha1"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"io"
+	"unicode/utf16"
+)
+
+const (
+	jksMagic   = 0xFEEDFEED
+	jceksMagic = 0xCECECECE
+
+	jksTagPrivateKey  = 1
+	jksTagTrustedCert = 2
+)
+
+// JKS私钥保护算法
+var oidJKSKeyProtector = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 42, 2, 17, 1, 1}
+
+// JKS中的私钥条目
+type jksPrivateKeyEntry struct {
+	alias        string
+	encryptedKey []byte
+	certs        []*x509.Certificate
+}
+
+// DecodeJKS 解析Java KeyStore（.jks）格式的证书库
+// alias 为空时使用第一个私钥条目；keyPassword 为空时使用证书库的密码
+// 新版本Java默认生成的是PKCS#12格式的证书库，这种情况会自动按照PKCS#12解析
+func DecodeJKS(jksData []byte, storePassword string, keyPassword string, alias string) (certData []byte, keyData []byte, err error) {
+	if len(jksData) < 4 {
+		return nil, nil, errors.New("invalid keystore data")
+	}
+	switch binary.BigEndian.Uint32(jksData) {
+	case jksMagic:
+	case jceksMagic:
+		return nil, nil, errors.New("JCEKS keystore is not supported, please convert it to PKCS#12 first")
+	default:
+		return DecodePKCS12(jksData, storePassword)
+	}
+
+	entries, err := parseJKS(jksData, storePassword)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var entry *jksPrivateKeyEntry
+	for _, e := range entries {
+		if len(alias) == 0 || e.alias == alias {
+			entry = e
+			break
+		}
+	}
+	if entry == nil {
+		if len(alias) > 0 {
+			return nil, nil, errors.New("can not find private key entry with alias '" + alias + "'")
+		}
+		return nil, nil, errors.New("no private key found")
+	}
+
+	if len(keyPassword) == 0 {
+		keyPassword = storePassword
+	}
+	key, err := recoverJKSKey(entry.encryptedKey, keyPassword)
+	if err != nil {
+		return nil, nil, err
+	}
+	return composeKeyPair(entry.certs, []crypto.PrivateKey{key})
+}
+
+// 读取JKS中所有的私钥条目，并校验完整性
+func parseJKS(jksData []byte, storePassword string) ([]*jksPrivateKeyEntry, error) {
+	if len(jksData) < sha1.Size {
+		return nil, errors.New("invalid keystore data")
+	}
+	var body = jksData[:len(jksData)-sha1.Size]
+
+	// 校验完整性
+	var h = sha1.New()
+	h.Write(jksPasswordBytes(storePassword))
+	h.Write([]byte("Mighty Aphrodite"))
+	h.Write(body)
+	if subtle.ConstantTimeCompare(h.Sum(nil), jksData[len(body):]) != 1 {
+		return nil, errors.New("incorrect password or keystore has been tampered with")
+	}
+
+	var reader = bytes.NewReader(body)
+	var header struct {
+		Magic   uint32
+		Version uint32
+		Count   uint32
+	}
+	err := binary.Read(reader, binary.BigEndian, &header)
+	if err != nil {
+		return nil, errors.New("invalid keystore data")
+	}
+	if header.Version != 1 && header.Version != 2 {
+		return nil, errors.New("unsupported keystore version")
+	}
+
+	var entries = []*jksPrivateKeyEntry{}
+	for i := uint32(0); i < header.Count; i++ {
+		var tag uint32
+		err = binary.Read(reader, binary.BigEndian, &tag)
+		if err != nil {
+			return nil, errors.New("invalid keystore data")
+		}
+		alias, err := readJKSUTF(reader)
+		if err != nil {
+			return nil, err
+		}
+		_, err = readJKSBytes(reader, 8) // 创建时间
+		if err != nil {
+			return nil, err
+		}
+
+		switch tag {
+		case jksTagPrivateKey:
+			encryptedKey, err := readJKSBlock(reader)
+			if err != nil {
+				return nil, err
+			}
+			var certCount uint32
+			err = binary.Read(reader, binary.BigEndian, &certCount)
+			if err != nil {
+				return nil, errors.New("invalid keystore data")
+			}
+			var entry = &jksPrivateKeyEntry{
+				alias:        alias,
+				encryptedKey: encryptedKey,
+			}
+			for j := uint32(0); j < certCount; j++ {
+				cert, err := readJKSCert(reader, header.Version)
+				if err != nil {
+					return nil, err
+				}
+				entry.certs = append(entry.certs, cert)
+			}
+			entries = append(entries, entry)
+		case jksTagTrustedCert:
+			_, err = readJKSCert(reader, header.Version)
+			if err != nil {
+				return nil, err
+			}
+		default:
+			return nil, errors.New("unsupported keystore entry type")
+		}
+	}
+	return entries, nil
+}
+
+// 解密JKS私钥
+// 格式为 salt(20) + 加密的私钥 + 校验码(20)，使用SHA1生成的密钥流进行异或
+func recoverJKSKey(encryptedKeyInfo []byte, password string) (crypto.PrivateKey, error) {
+	var info pkcs12EncryptedPrivateKeyInfo
+	_, err := asn1.Unmarshal(encryptedKeyInfo, &info)
+	if err != nil {
+		return nil, errors.New("invalid private key entry: " + err.Error())
+	}
+	if !info.AlgorithmIdentifier.Algorithm.Equal(oidJKSKeyProtector) {
+		return nil, errors.New("unsupported private key protection algorithm '" + info.AlgorithmIdentifier.Algorithm.String() + "'")
+	}
+
+	var protectedKey = info.EncryptedData
+	if len(protectedKey) < 2*sha1.Size {
+		return nil, errors.New("invalid private key entry")
+	}
+	var salt = protectedKey[:sha1.Size]
+	var encryptedKey = protectedKey[sha1.Size : len(protectedKey)-sha1.Size]
+	var check = protectedKey[len(protectedKey)-sha1.Size:]
+	var passwordBytes = jksPasswordBytes(password)
+
+	var plainKey = make([]byte, len(encryptedKey))
+	var digest = salt
+	for offset := 0; offset < len(encryptedKey); offset += sha1.Size {
+		var h = sha1.New()
+		h.Write(passwordBytes)
+		h.Write(digest)
+		digest = h.Sum(nil)
+		for i := 0; i < sha1.Size && offset+i < len(encryptedKey); i++ {
+			plainKey[offset+i] = encryptedKey[offset+i] ^ digest[i]
+		}
+	}
+
+	var h = sha1.New()
+	h.Write(passwordBytes)
+	h.Write(plainKey)
+	if subtle.ConstantTimeCompare(h.Sum(nil), check) != 1 {
+		return nil, errors.New("incorrect key password")
+	}
+	return x509.ParsePKCS8PrivateKey(plainKey)
+}
+
+// 读取证书
+func readJKSCert(reader io.Reader, version uint32) (*x509.Certificate, error) {
+	if version == 2 {
+		certType, err := readJKSUTF(reader)
+		if err != nil {
+			return nil, err
+		}
+		if certType != "X.509" {
+			return nil, errors.New("unsupported certificate type '" + certType + "'")
+		}
+	}
+	certBytes, err := readJKSBlock(reader)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(certBytes)
+}
+
+// 读取Java DataOutput.writeUTF()写入的字符串
+func readJKSUTF(reader io.Reader) (string, error) {
+	var size uint16
+	err := binary.Read(reader, binary.BigEndian, &size)
+	if err != nil {
+		return "", errors.New("invalid keystore data")
+	}
+	data, err := readJKSBytes(reader, int(size))
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// 读取以4字节长度开头的数据块
+func readJKSBlock(reader io.Reader) ([]byte, error) {
+	var size uint32
+	err := binary.Read(reader, binary.BigEndian, &size)
+	if err != nil {
+		return nil, errors.New("invalid keystore data")
+	}
+	if size > 1<<20 {
+		return nil, errors.New("invalid keystore data")
+	}
+	return readJKSBytes(reader, int(size))
+}
+
+// 读取固定长度的数据
+func readJKSBytes(reader io.Reader, size int) ([]byte, error) {
+	var data = make([]byte, size)
+	_, err := io.ReadFull(reader, data)
+	if err != nil {
+		return nil, errors.New("invalid keystore data")
+	}
+	return data, nil
+}
+
+// JKS密码的字节形式，每个字符使用两个字节（大端）
+func jksPasswordBytes(password string) []byte {
+	var result = []byte{}
+	for _, c := range utf16.Encode([]rune(password)) {
+		result = append(result, byte(c>>8), byte(c))
+	}
+	return result
+}
@@ -0,0 +1,150 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package sslutils
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"testing"
+)
+
+// 生成测试用的JKS证书库
+func encodeTestJKS(t *testing.T, alias string, certs []*x509.Certificate, keyDER []byte, storePassword string, keyPassword string) []byte {
+	// 使用和 recoverJKSKey 相反的过程加密私钥
+	var salt = bytes.Repeat([]byte{1}, sha1.Size)
+	var passwordBytes = jksPasswordBytes(keyPassword)
+	var encryptedKey = make([]byte, len(keyDER))
+	var digest = salt
+	for offset := 0; offset < len(keyDER); offset += sha1.Size {
+		var h = sha1.New()
+		h.Write(passwordBytes)
+		h.Write(digest)
+		digest = h.Sum(nil)
+		for i := 0; i < sha1.Size && offset+i < len(keyDER); i++ {
+			encryptedKey[offset+i] = keyDER[offset+i] ^ digest[i]
+		}
+	}
+	var h = sha1.New()
+	h.Write(passwordBytes)
+	h.Write(keyDER)
+	var protectedKey = append(append(append([]byte{}, salt...), encryptedKey...), h.Sum(nil)...)
+	keyInfoData, err := asn1.Marshal(pkcs12EncryptedPrivateKeyInfo{
+		AlgorithmIdentifier: pkix.AlgorithmIdentifier{Algorithm: oidJKSKeyProtector, Parameters: asn1.NullRawValue},
+		EncryptedData:       protectedKey,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf = &bytes.Buffer{}
+	var write = func(v interface{}) {
+		err := binary.Write(buf, binary.BigEndian, v)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	var writeUTF = func(s string) {
+		write(uint16(len(s)))
+		buf.WriteString(s)
+	}
+	write(uint32(jksMagic))
+	write(uint32(2))
+	write(uint32(2))
+
+	// 私钥条目
+	write(uint32(jksTagPrivateKey))
+	writeUTF(alias)
+	write(int64(0))
+	write(uint32(len(keyInfoData)))
+	buf.Write(keyInfoData)
+	write(uint32(len(certs)))
+	for _, cert := range certs {
+		writeUTF("X.509")
+		write(uint32(len(cert.Raw)))
+		buf.Write(cert.Raw)
+	}
+
+	// 信任的证书条目
+	write(uint32(jksTagTrustedCert))
+	writeUTF("root")
+	write(int64(0))
+	writeUTF("X.509")
+	write(uint32(len(certs[len(certs)-1].Raw)))
+	buf.Write(certs[len(certs)-1].Raw)
+
+	h = sha1.New()
+	h.Write(jksPasswordBytes(storePassword))
+	h.Write([]byte("Mighty Aphrodite"))
+	h.Write(buf.Bytes())
+	buf.Write(h.Sum(nil))
+	return buf.Bytes()
+}
+
+func TestDecodeJKS(t *testing.T) {
+	var root = newTestCA(t)
+	var intermediate = newTestChainCert(t, 2, "Test Intermediate", true, root, "")
+	var leaf = newTestChainCert(t, 3, "example.com", false, intermediate, "")
+	keyDER, err := x509.MarshalPKCS8PrivateKey(leaf.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var jksData = encodeTestJKS(t, "tomcat", []*x509.Certificate{leaf.cert, intermediate.cert}, keyDER, "store123", "key123")
+
+	certData, keyData, err := DecodeJKS(jksData, "store123", "key123", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(certData, encodeTestCerts(leaf.cert, intermediate.cert)) {
+		t.Fatal("cert chain not match")
+	}
+	ok, err := matchKey(leaf.cert, keyData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("private key not match")
+	}
+
+	// 指定别名
+	_, _, err = DecodeJKS(jksData, "store123", "key123", "tomcat")
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = DecodeJKS(jksData, "store123", "key123", "jetty")
+	if err == nil {
+		t.Fatal("should fail with unknown alias")
+	}
+
+	// 错误的密码
+	_, _, err = DecodeJKS(jksData, "store456", "key123", "")
+	if err == nil {
+		t.Fatal("should fail with incorrect store password")
+	}
+	_, _, err = DecodeJKS(jksData, "store123", "", "")
+	if err == nil {
+		t.Fatal("should fail with incorrect key password")
+	}
+}
+
+func TestDecodeJKS_PKCS12(t *testing.T) {
+	var root = newTestCA(t)
+	var leaf = newTestChainCert(t, 2, "example.com", false, root, "")
+	pfxData, err := EncodePKCS12(encodeTestCerts(leaf.cert), encodeTestKey(t, leaf.key), "123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 新版本Java生成的PKCS#12格式证书库
+	certData, _, err := DecodeJKS(pfxData, "123456", "", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(certData, encodeTestCerts(leaf.cert)) {
+		t.Fatal("cert not match")
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package sslutils
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+)
+
+// 将从证书库中读取的证书和私钥转换为PEM格式
+// 证书按照从叶子证书到上级证书的顺序排列，和私钥不匹配的叶子证书会被忽略
+func composeKeyPair(certs []*x509.Certificate, keys []crypto.PrivateKey) (certData []byte, keyData []byte, err error) {
+	if len(certs) == 0 {
+		return nil, nil, errors.New("no certificate found")
+	}
+	if len(keys) == 0 {
+		return nil, nil, errors.New("no private key found")
+	}
+
+	// 查找和私钥匹配的证书
+	var leaf *x509.Certificate
+	var leafKey crypto.PrivateKey
+	for _, key := range keys {
+		for _, cert := range certs {
+			ok, err := matchPrivateKey(cert, key)
+			if err != nil {
+				return nil, nil, err
+			}
+			if ok {
+				leaf = cert
+				leafKey = key
+				break
+			}
+		}
+		if leaf != nil {
+			break
+		}
+	}
+	if leaf == nil {
+		return nil, nil, errors.New("no certificate matches the private key")
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(leafKey)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var buf = &bytes.Buffer{}
+	for _, cert := range sortChain(leaf, certs) {
+		err = pem.Encode(buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return buf.Bytes(), pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), nil
+}
+
+// 从叶子证书开始按照签发关系排列证书链
+// 和证书链无关的证书会被忽略
+func sortChain(leaf *x509.Certificate, certs []*x509.Certificate) []*x509.Certificate {
+	var result = []*x509.Certificate{leaf}
+	var current = leaf
+	for len(result) < maxChainDepth {
+		if isSelfSigned(current) {
+			break
+		}
+		var issuer *x509.Certificate
+		for _, cert := range certs {
+			if containsCert(result, cert) {
+				continue
+			}
+			if current.CheckSignatureFrom(cert) == nil {
+				issuer = cert
+				break
+			}
+		}
+		if issuer == nil {
+			break
+		}
+		result = append(result, issuer)
+		current = issuer
+	}
+	return result
+}
+
+// 判断证书列表中是否包含某个证书
+func containsCert(certs []*x509.Certificate, cert *x509.Certificate) bool {
+	for _, c := range certs {
+		if c.Equal(cert) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,149 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package sslutils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"math/big"
+	"time"
+)
+
+// 内置CA的组织名称
+const nodeCAOrganization = "GoEdge"
+
+// 签发时间往前推移，以免节点时钟略慢时证书还未生效
+const nodeCertBackdate = 10 * time.Minute
+
+// NodeClientCert 签发给节点的客户端证书
+type NodeClientCert struct {
+	CertData  []byte // 证书内容
+	KeyData   []byte // 私钥内容
+	Serial    string // 序列号
+	ExpiresAt int64  // 过期时间
+}
+
+// GenerateNodeCA 生成用于签发节点客户端证书的CA
+func GenerateNodeCA(commonName string, life time.Duration) (certData []byte, keyData []byte, err error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, nil, err
+	}
+	var now = time.Now()
+	var template = &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   commonName,
+			Organization: []string{nodeCAOrganization},
+		},
+		NotBefore:             now.Add(-nodeCertBackdate),
+		NotAfter:              now.Add(life),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		MaxPathLenZero:        true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	certData = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	keyData = pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+	return certData, keyData, nil
+}
+
+// IssueNodeClientCert 使用CA为节点签发客户端证书
+// 证书的CommonName为节点的唯一ID，OrganizationalUnit为节点角色
+func IssueNodeClientCert(caCertData []byte, caKeyData []byte, role string, uniqueId string, life time.Duration) (*NodeClientCert, error) {
+	if len(role) == 0 || len(uniqueId) == 0 {
+		return nil, errors.New("'role' and 'uniqueId' should not be empty")
+	}
+
+	caCerts, err := ParseCerts(caCertData)
+	if err != nil {
+		return nil, errors.New("parse ca cert failed: " + err.Error())
+	}
+	var caCert = caCerts[0]
+	caKey, err := parsePEMPrivateKey(caKeyData)
+	if err != nil {
+		return nil, errors.New("parse ca key failed: " + err.Error())
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+	var now = time.Now()
+	var notAfter = now.Add(life)
+	if notAfter.After(caCert.NotAfter) {
+		notAfter = caCert.NotAfter
+	}
+	var template = &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:         uniqueId,
+			OrganizationalUnit: []string{role},
+			Organization:       []string{nodeCAOrganization},
+		},
+		NotBefore:   now.Add(-nodeCertBackdate),
+		NotAfter:    notAfter,
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, caCert, &key.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	return &NodeClientCert{
+		CertData:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		KeyData:   pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		Serial:    FormatSerial(serial),
+		ExpiresAt: notAfter.Unix(),
+	}, nil
+}
+
+// ParseNodeClientCertIdentity 从节点客户端证书中读取节点角色和唯一ID
+func ParseNodeClientCertIdentity(cert *x509.Certificate) (role string, uniqueId string) {
+	if cert == nil {
+		return
+	}
+	if len(cert.Subject.OrganizationalUnit) > 0 {
+		role = cert.Subject.OrganizationalUnit[0]
+	}
+	uniqueId = cert.Subject.CommonName
+	return
+}
+
+// FormatSerial 将证书序列号转换为字符串
+func FormatSerial(serial *big.Int) string {
+	if serial == nil {
+		return ""
+	}
+	return serial.Text(16)
+}
+
+// 生成随机的证书序列号
+func randomSerial() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
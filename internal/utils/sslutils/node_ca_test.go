@@ -0,0 +1,98 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package sslutils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"testing"
+	"time"
+)
+
+func TestIssueNodeClientCert(t *testing.T) {
+	caCertData, caKeyData, err := GenerateNodeCA("Test Node CA", 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clientCert, err := IssueNodeClientCert(caCertData, caKeyData, "node", "abc123", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(clientCert.Serial) == 0 {
+		t.Fatal("serial should not be empty")
+	}
+	if clientCert.ExpiresAt <= time.Now().Unix() {
+		t.Fatal("invalid expiresAt:", clientCert.ExpiresAt)
+	}
+
+	// 证书和私钥需要匹配
+	_, err = tls.X509KeyPair(clientCert.CertData, clientCert.KeyData)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certs, err := ParseCerts(clientCert.CertData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cert = certs[0]
+	if FormatSerial(cert.SerialNumber) != clientCert.Serial {
+		t.Fatal("serial not match")
+	}
+	role, uniqueId := ParseNodeClientCertIdentity(cert)
+	if role != "node" || uniqueId != "abc123" {
+		t.Fatal("invalid identity:", role, uniqueId)
+	}
+
+	// 使用CA校验
+	caCerts, err := ParseCerts(caCertData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var pool = x509.NewCertPool()
+	pool.AddCert(caCerts[0])
+	_, err = cert.Verify(x509.VerifyOptions{
+		Roots:     pool,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 不能用作服务器证书
+	_, err = cert.Verify(x509.VerifyOptions{
+		Roots:     pool,
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	})
+	if err == nil {
+		t.Fatal("client cert should not be used for server auth")
+	}
+}
+
+func TestIssueNodeClientCert_CALife(t *testing.T) {
+	caCertData, caKeyData, err := GenerateNodeCA("Test Node CA", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clientCert, err := IssueNodeClientCert(caCertData, caKeyData, "dns", "abc123", 24*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 证书有效期不能超过CA
+	if clientCert.ExpiresAt > time.Now().Add(time.Hour).Unix() {
+		t.Fatal("client cert should not expire after ca")
+	}
+}
+
+func TestIssueNodeClientCert_Empty(t *testing.T) {
+	caCertData, caKeyData, err := GenerateNodeCA("Test Node CA", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = IssueNodeClientCert(caCertData, caKeyData, "", "abc123", time.Hour)
+	if err == nil {
+		t.Fatal("should return error for empty role")
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package sslutils
+
+import (
+	"bytes"
+	"crypto"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"golang.org/x/crypto/ocsp"
+	"io"
+	"io/ioutil"
+	"net/http"
+)
+
+// OCSP响应的最大尺寸
+const maxOCSPResponseSize = 1 << 20
+
+// 响应中没有下次更新时间时默认的有效期
+const defaultOCSPLifeSeconds = 86400
+
+// ErrNoOCSPServer 证书中没有OCSP服务地址
+var ErrNoOCSPServer = errors.New("no ocsp server in certificate")
+
+// OCSPResponse OCSP响应
+type OCSPResponse struct {
+	Raw              []byte // 原始响应，用于OCSP Stapling
+	Status           int    // 证书状态：ocsp.Good, ocsp.Revoked, ocsp.Unknown
+	ThisUpdate       int64  // 响应生成时间
+	NextUpdate       int64  // 响应过期时间
+	RevokedAt        int64  // 吊销时间
+	RevocationReason int    // 吊销原因代码
+}
+
+// IsRevoked 证书是否已被吊销
+func (this *OCSPResponse) IsRevoked() bool {
+	return this.Status == ocsp.Revoked
+}
+
+// FetchOCSP 获取PEM格式证书的OCSP响应
+// 证书链中没有签发者证书时尝试通过AIA下载
+func FetchOCSP(client *http.Client, certData []byte) (*OCSPResponse, error) {
+	leaf, issuer, err := ParseLeafAndIssuer(certData)
+	if err != nil {
+		return nil, err
+	}
+	if len(leaf.OCSPServer) == 0 {
+		return nil, ErrNoOCSPServer
+	}
+	if issuer == nil {
+		issuer, err = FetchIssuer(client, leaf)
+		if err != nil {
+			return nil, errors.New("can not find issuer: " + err.Error())
+		}
+	}
+
+	reqData, err := ocsp.CreateRequest(leaf, issuer, &ocsp.RequestOptions{Hash: crypto.SHA1})
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, server := range leaf.OCSPServer {
+		resp, err := fetchOCSPFromServer(client, server, reqData)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		ocspResp, err := ocsp.ParseResponseForCert(resp, leaf, issuer)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		var result = &OCSPResponse{
+			Raw:        resp,
+			Status:     ocspResp.Status,
+			ThisUpdate: ocspResp.ThisUpdate.Unix(),
+		}
+		if ocspResp.NextUpdate.IsZero() {
+			result.NextUpdate = result.ThisUpdate + defaultOCSPLifeSeconds
+		} else {
+			result.NextUpdate = ocspResp.NextUpdate.Unix()
+		}
+		if ocspResp.Status == ocsp.Revoked {
+			result.RevokedAt = ocspResp.RevokedAt.Unix()
+			result.RevocationReason = ocspResp.RevocationReason
+		}
+		return result, nil
+	}
+	return nil, lastErr
+}
+
+// 向单个OCSP服务发送请求
+// 连接失败时不返回原始的错误信息，以免泄露网络环境
+func fetchOCSPFromServer(client *http.Client, server string, reqData []byte) ([]byte, error) {
+	err := checkFetchURL(server)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Post(server, "application/ocsp-request", bytes.NewReader(reqData))
+	if err != nil {
+		return nil, errors.New("request '" + server + "' failed")
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("request '" + server + "' failed: " + resp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxOCSPResponseSize))
+	if err != nil {
+		return nil, errors.New("request '" + server + "' failed")
+	}
+	return data, nil
+}
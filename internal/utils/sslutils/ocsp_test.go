@@ -0,0 +1,165 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package sslutils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"golang.org/x/crypto/ocsp"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var template = &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &testCA{cert: cert, key: key}
+}
+
+func (this *testCA) issue(t *testing.T, serial int64, ocspServer string, issuerURL string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var template = &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if len(ocspServer) > 0 {
+		template.OCSPServer = []string{ocspServer}
+	}
+	if len(issuerURL) > 0 {
+		template.IssuingCertificateURL = []string{issuerURL}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, this.cert, &key.PublicKey, this.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+func encodeTestCerts(certs ...*x509.Certificate) []byte {
+	var result = []byte{}
+	for _, cert := range certs {
+		result = append(result, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	return result
+}
+
+func TestFetchOCSP(t *testing.T) {
+	var ca = newTestCA(t)
+	var revokedSerial = big.NewInt(3)
+
+	var mux = http.NewServeMux()
+	mux.HandleFunc("/ocsp", func(writer http.ResponseWriter, req *http.Request) {
+		body, err := ioutil.ReadAll(req.Body)
+		if err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		ocspReq, err := ocsp.ParseRequest(body)
+		if err != nil {
+			writer.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var template = ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: ocspReq.SerialNumber,
+			ThisUpdate:   time.Now().Add(-time.Minute),
+			NextUpdate:   time.Now().Add(time.Hour),
+		}
+		if ocspReq.SerialNumber.Cmp(revokedSerial) == 0 {
+			template.Status = ocsp.Revoked
+			template.RevokedAt = time.Now().Add(-time.Minute)
+			template.RevocationReason = ocsp.KeyCompromise
+		}
+		resp, err := ocsp.CreateResponse(ca.cert, ca.cert, template, ca.key)
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		_, _ = writer.Write(resp)
+	})
+	mux.HandleFunc("/ca.der", func(writer http.ResponseWriter, req *http.Request) {
+		_, _ = writer.Write(ca.cert.Raw)
+	})
+	var server = httptest.NewServer(mux)
+	defer server.Close()
+
+	// 证书链中包含签发者证书
+	{
+		var cert = ca.issue(t, 2, server.URL+"/ocsp", "")
+		resp, err := FetchOCSP(server.Client(), encodeTestCerts(cert, ca.cert))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.Status != ocsp.Good || resp.IsRevoked() || len(resp.Raw) == 0 {
+			t.Fatalf("unexpected response: %#v", resp)
+		}
+		if resp.NextUpdate <= time.Now().Unix() {
+			t.Fatal("unexpected next update:", resp.NextUpdate)
+		}
+	}
+
+	// 通过AIA下载签发者证书
+	{
+		var cert = ca.issue(t, revokedSerial.Int64(), server.URL+"/ocsp", server.URL+"/ca.der")
+		resp, err := FetchOCSP(server.Client(), encodeTestCerts(cert))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !resp.IsRevoked() || resp.RevocationReason != ocsp.KeyCompromise {
+			t.Fatalf("unexpected response: %#v", resp)
+		}
+	}
+
+	// 没有OCSP服务地址
+	{
+		var cert = ca.issue(t, 4, "", "")
+		_, err := FetchOCSP(server.Client(), encodeTestCerts(cert, ca.cert))
+		if err != ErrNoOCSPServer {
+			t.Fatal("expect ErrNoOCSPServer, but got:", err)
+		}
+	}
+}
@@ -0,0 +1,422 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package sslutils
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"hash"
+	"unicode/utf16"
+)
+
+// 导出PKCS#12时密钥派生的迭代次数
+const pkcs12Iterations = 2048
+
+var (
+	oidDataContentType            = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidEncryptedDataContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 6}
+	oidCertBag                    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidPKCS8ShroudedKeyBag        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertTypeX509               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidLocalKeyID                 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+	oidSHA1                       = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidPBEWithSHAAnd3KeyTripleDES = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 3}
+	oidPBEWithSHAAnd128BitRC2CBC  = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 5}
+	oidPBEWithSHAAnd40BitRC2CBC   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1, 6}
+)
+
+type pkcs12PFX struct {
+	Version  int
+	AuthSafe pkcs12ContentInfo
+	MacData  pkcs12MacData `asn1:"optional"`
+}
+
+type pkcs12ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"tag:0,explicit,optional"`
+}
+
+type pkcs12EncryptedData struct {
+	Version              int
+	EncryptedContentInfo pkcs12EncryptedContentInfo
+}
+
+type pkcs12EncryptedContentInfo struct {
+	ContentType                asn1.ObjectIdentifier
+	ContentEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedContent           asn1.RawValue `asn1:"tag:0,optional"`
+}
+
+type pkcs12MacData struct {
+	Mac        pkcs12DigestInfo
+	MacSalt    []byte
+	Iterations int `asn1:"optional,default:1"`
+}
+
+type pkcs12DigestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type pkcs12SafeBag struct {
+	Id         asn1.ObjectIdentifier
+	Value      asn1.RawValue     `asn1:"tag:0,explicit"`
+	Attributes []pkcs12Attribute `asn1:"set,optional,omitempty"`
+}
+
+type pkcs12Attribute struct {
+	Id    asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+type pkcs12CertBag struct {
+	Id   asn1.ObjectIdentifier
+	Data asn1.RawValue `asn1:"tag:0,explicit"`
+}
+
+type pkcs12PBEParams struct {
+	Salt       []byte
+	Iterations int
+}
+
+type pkcs12EncryptedPrivateKeyInfo struct {
+	AlgorithmIdentifier pkix.AlgorithmIdentifier
+	EncryptedData       []byte
+}
+
+// DecodePKCS12 解析PKCS#12格式的证书文件（.pfx/.p12）
+// 返回PEM格式的证书链和私钥，证书链按照从叶子证书到上级证书的顺序排列
+func DecodePKCS12(pfxData []byte, password string) (certData []byte, keyData []byte, err error) {
+	certs, keys, err := decodePKCS12(pfxData, password)
+	if err != nil {
+		return nil, nil, err
+	}
+	return composeKeyPair(certs, keys)
+}
+
+// EncodePKCS12 将PEM格式的证书链和私钥转换为PKCS#12格式
+// 使用兼容性较好的 pbeWithSHAAnd3-KeyTripleDES-CBC 加密，以便Windows和Java等都可以导入
+func EncodePKCS12(certData []byte, keyData []byte, password string) ([]byte, error) {
+	certs, err := ParseCerts(certData)
+	if err != nil {
+		return nil, err
+	}
+	key, err := parsePEMPrivateKey(keyData)
+	if err != nil {
+		return nil, err
+	}
+	var leaf *x509.Certificate
+	for _, cert := range certs {
+		ok, err := matchPrivateKey(cert, key)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			leaf = cert
+			break
+		}
+	}
+	if leaf == nil {
+		return nil, errors.New("no certificate matches the private key")
+	}
+
+	encodedPassword, err := bmpString(password)
+	if err != nil {
+		return nil, err
+	}
+
+	var localKeyId = sha1.Sum(leaf.Raw)
+	localKeyIdAttr, err := newLocalKeyIdAttribute(localKeyId[:])
+	if err != nil {
+		return nil, err
+	}
+
+	// 证书
+	var certBags = []pkcs12SafeBag{}
+	for _, cert := range sortChain(leaf, certs) {
+		bag, err := newCertBag(cert)
+		if err != nil {
+			return nil, err
+		}
+		if cert == leaf {
+			bag.Attributes = []pkcs12Attribute{localKeyIdAttr}
+		}
+		certBags = append(certBags, *bag)
+	}
+	certContentInfo, err := newEncryptedContentInfo(certBags, encodedPassword)
+	if err != nil {
+		return nil, err
+	}
+
+	// 私钥
+	keyBag, err := newShroudedKeyBag(key, encodedPassword)
+	if err != nil {
+		return nil, err
+	}
+	keyBag.Attributes = []pkcs12Attribute{localKeyIdAttr}
+	keyContentInfo, err := newDataContentInfo([]pkcs12SafeBag{*keyBag})
+	if err != nil {
+		return nil, err
+	}
+
+	authSafeData, err := asn1.Marshal([]pkcs12ContentInfo{*certContentInfo, *keyContentInfo})
+	if err != nil {
+		return nil, err
+	}
+
+	// 校验码
+	macSalt, err := randomBytes(8)
+	if err != nil {
+		return nil, err
+	}
+	var macKey = pkcs12KDF(sha1.New, encodedPassword, macSalt, pkcs12Iterations, 3, 20)
+	var mac = hmac.New(sha1.New, macKey)
+	mac.Write(authSafeData)
+
+	authSafeContent, err := explicitOctetString(authSafeData)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(pkcs12PFX{
+		Version: 3,
+		AuthSafe: pkcs12ContentInfo{
+			ContentType: oidDataContentType,
+			Content:     authSafeContent,
+		},
+		MacData: pkcs12MacData{
+			Mac: pkcs12DigestInfo{
+				Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA1, Parameters: asn1.NullRawValue},
+				Digest:    mac.Sum(nil),
+			},
+			MacSalt:    macSalt,
+			Iterations: pkcs12Iterations,
+		},
+	})
+}
+
+// 生成证书包
+func newCertBag(cert *x509.Certificate) (*pkcs12SafeBag, error) {
+	certValue, err := explicitOctetString(cert.Raw)
+	if err != nil {
+		return nil, err
+	}
+	certBagData, err := asn1.Marshal(pkcs12CertBag{
+		Id:   oidCertTypeX509,
+		Data: certValue,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pkcs12SafeBag{
+		Id:    oidCertBag,
+		Value: explicitValue(certBagData),
+	}, nil
+}
+
+// 生成加密的私钥包
+func newShroudedKeyBag(key crypto.PrivateKey, encodedPassword []byte) (*pkcs12SafeBag, error) {
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	algorithm, encrypted, err := pbeEncrypt(keyDER, encodedPassword)
+	if err != nil {
+		return nil, err
+	}
+	keyInfoData, err := asn1.Marshal(pkcs12EncryptedPrivateKeyInfo{
+		AlgorithmIdentifier: *algorithm,
+		EncryptedData:       encrypted,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pkcs12SafeBag{
+		Id:    oidPKCS8ShroudedKeyBag,
+		Value: explicitValue(keyInfoData),
+	}, nil
+}
+
+// 生成明文的内容
+func newDataContentInfo(bags []pkcs12SafeBag) (*pkcs12ContentInfo, error) {
+	bagsData, err := asn1.Marshal(bags)
+	if err != nil {
+		return nil, err
+	}
+	content, err := explicitOctetString(bagsData)
+	if err != nil {
+		return nil, err
+	}
+	return &pkcs12ContentInfo{
+		ContentType: oidDataContentType,
+		Content:     content,
+	}, nil
+}
+
+// 生成加密的内容
+func newEncryptedContentInfo(bags []pkcs12SafeBag, encodedPassword []byte) (*pkcs12ContentInfo, error) {
+	bagsData, err := asn1.Marshal(bags)
+	if err != nil {
+		return nil, err
+	}
+	algorithm, encrypted, err := pbeEncrypt(bagsData, encodedPassword)
+	if err != nil {
+		return nil, err
+	}
+	encryptedData, err := asn1.Marshal(pkcs12EncryptedData{
+		Version: 0,
+		EncryptedContentInfo: pkcs12EncryptedContentInfo{
+			ContentType:                oidDataContentType,
+			ContentEncryptionAlgorithm: *algorithm,
+			EncryptedContent:           asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, Bytes: encrypted},
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &pkcs12ContentInfo{
+		ContentType: oidEncryptedDataContentType,
+		Content:     explicitValue(encryptedData),
+	}, nil
+}
+
+// 生成 localKeyId 属性，用来关联证书和私钥
+func newLocalKeyIdAttribute(id []byte) (pkcs12Attribute, error) {
+	idData, err := asn1.Marshal(id)
+	if err != nil {
+		return pkcs12Attribute{}, err
+	}
+	return pkcs12Attribute{
+		Id:    oidLocalKeyID,
+		Value: asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: idData},
+	}, nil
+}
+
+// 使用 pbeWithSHAAnd3-KeyTripleDES-CBC 加密数据
+func pbeEncrypt(data []byte, encodedPassword []byte) (*pkix.AlgorithmIdentifier, []byte, error) {
+	salt, err := randomBytes(8)
+	if err != nil {
+		return nil, nil, err
+	}
+	paramsData, err := asn1.Marshal(pkcs12PBEParams{Salt: salt, Iterations: pkcs12Iterations})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var key = pkcs12KDF(sha1.New, encodedPassword, salt, pkcs12Iterations, 1, 24)
+	var iv = pkcs12KDF(sha1.New, encodedPassword, salt, pkcs12Iterations, 2, 8)
+	block, err := des.NewTripleDESCipher(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// PKCS#7 填充
+	var padding = block.BlockSize() - len(data)%block.BlockSize()
+	var encrypted = make([]byte, len(data), len(data)+padding)
+	copy(encrypted, data)
+	encrypted = append(encrypted, bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(encrypted, encrypted)
+
+	return &pkix.AlgorithmIdentifier{
+		Algorithm:  oidPBEWithSHAAnd3KeyTripleDES,
+		Parameters: asn1.RawValue{FullBytes: paramsData},
+	}, encrypted, nil
+}
+
+// PKCS#12 密钥派生算法（RFC 7292 附录B.2）
+func pkcs12KDF(newHash func() hash.Hash, encodedPassword []byte, salt []byte, iterations int, id byte, size int) []byte {
+	var v = newHash().BlockSize()
+
+	var D = bytes.Repeat([]byte{id}, v)
+	var S = fillBlocks(salt, v)
+	var P = fillBlocks(encodedPassword, v)
+	var I = append(S, P...)
+
+	var result = []byte{}
+	for len(result) < size {
+		var h = newHash()
+		h.Write(D)
+		h.Write(I)
+		var A = h.Sum(nil)
+		for i := 1; i < iterations; i++ {
+			h.Reset()
+			h.Write(A)
+			A = h.Sum(nil)
+		}
+		result = append(result, A...)
+		if len(result) >= size {
+			break
+		}
+
+		// Ij = (Ij + B + 1) mod 2^(v*8)
+		var B = fillBlocks(A, v)[:v]
+		for j := 0; j < len(I); j += v {
+			var carry = 1
+			for k := v - 1; k >= 0; k-- {
+				carry += int(I[j+k]) + int(B[k])
+				I[j+k] = byte(carry)
+				carry >>= 8
+			}
+		}
+	}
+	return result[:size]
+}
+
+// 重复数据直到长度为 v 的整数倍
+func fillBlocks(data []byte, v int) []byte {
+	if len(data) == 0 {
+		return nil
+	}
+	var size = v * ((len(data) + v - 1) / v)
+	var result = make([]byte, size)
+	for i := 0; i < size; i++ {
+		result[i] = data[i%len(data)]
+	}
+	return result
+}
+
+// 将密码转换为以两个0字节结尾的BMPString
+func bmpString(s string) ([]byte, error) {
+	var result = make([]byte, 0, 2*len(s)+2)
+	for _, r := range s {
+		if r > 0xFFFF {
+			return nil, errors.New("password contains unsupported character")
+		}
+		for _, c := range utf16.Encode([]rune{r}) {
+			result = append(result, byte(c>>8), byte(c))
+		}
+	}
+	return append(result, 0, 0), nil
+}
+
+// 生成 [0] EXPLICIT 包装的数据
+func explicitValue(data []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: data}
+}
+
+// 生成 [0] EXPLICIT 包装的 OCTET STRING
+func explicitOctetString(data []byte) (asn1.RawValue, error) {
+	octetString, err := asn1.Marshal(data)
+	if err != nil {
+		return asn1.RawValue{}, err
+	}
+	return explicitValue(octetString), nil
+}
+
+// 生成随机数据
+func randomBytes(size int) ([]byte, error) {
+	var b = make([]byte, size)
+	_, err := rand.Read(b)
+	if err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -0,0 +1,351 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package sslutils
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/des"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"golang.org/x/crypto/pbkdf2"
+	"hash"
+)
+
+var (
+	oidKeyBag         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 1}
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA1   = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 7}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidHMACWithSHA384 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 10}
+	oidHMACWithSHA512 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 11}
+	oidSHA256         = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384         = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512         = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+	oidAES128CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 2}
+	oidAES192CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 22}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidDESEDE3CBC     = asn1.ObjectIdentifier{1, 2, 840, 113549, 3, 7}
+)
+
+// 密钥派生允许的最大迭代次数，防止恶意文件消耗大量CPU
+const pkcs12MaxIterations = 1_000_000
+
+var (
+	errPKCS12UnsupportedAlgorithm = errors.New("unsupported pkcs#12 algorithm")
+	errPKCS12IncorrectPassword    = errors.New("incorrect password")
+	errPKCS12InvalidIterations    = errors.New("invalid pkcs#12 iteration count")
+)
+
+type pkcs12PBES2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pkcs12PBKDF2Params struct {
+	Salt       []byte
+	Iterations int
+	KeyLength  int                      `asn1:"optional"`
+	PRF        pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+// 解析PKCS#12文件中的证书和私钥
+// 支持OpenSSL 3和新版Windows默认使用的PBES2（AES）加密，以及旧版本工具使用的3DES、RC2加密；其他算法返回 errPKCS12UnsupportedAlgorithm
+func decodePKCS12(pfxData []byte, password string) (certs []*x509.Certificate, keys []crypto.PrivateKey, err error) {
+	var pfx pkcs12PFX
+	rest, err := asn1.Unmarshal(pfxData, &pfx)
+	if err != nil || len(rest) > 0 {
+		return nil, nil, errors.New("invalid pkcs#12 data")
+	}
+	if pfx.Version != 3 {
+		return nil, nil, errors.New("unsupported pkcs#12 version")
+	}
+	if !pfx.AuthSafe.ContentType.Equal(oidDataContentType) {
+		return nil, nil, errPKCS12UnsupportedAlgorithm
+	}
+
+	var authSafeData []byte
+	_, err = asn1.Unmarshal(pfx.AuthSafe.Content.Bytes, &authSafeData)
+	if err != nil {
+		return nil, nil, errors.New("invalid pkcs#12 data")
+	}
+
+	encodedPassword, err := bmpString(password)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 校验码
+	if len(pfx.MacData.Mac.Algorithm.Algorithm) == 0 {
+		return nil, nil, errPKCS12UnsupportedAlgorithm
+	}
+	newHash := findDigestHash(pfx.MacData.Mac.Algorithm.Algorithm)
+	if newHash == nil {
+		return nil, nil, errPKCS12UnsupportedAlgorithm
+	}
+	err = checkPKCS12Iterations(pfx.MacData.Iterations)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !checkPKCS12Mac(newHash, pfx.MacData, authSafeData, encodedPassword) {
+		// 有些工具在密码为空时不使用结尾的两个0字节
+		if len(password) > 0 || !checkPKCS12Mac(newHash, pfx.MacData, authSafeData, nil) {
+			return nil, nil, errPKCS12IncorrectPassword
+		}
+		encodedPassword = nil
+	}
+
+	var contentInfos = []pkcs12ContentInfo{}
+	_, err = asn1.Unmarshal(authSafeData, &contentInfos)
+	if err != nil {
+		return nil, nil, errors.New("invalid pkcs#12 data")
+	}
+
+	for _, contentInfo := range contentInfos {
+		var bagsData []byte
+		switch {
+		case contentInfo.ContentType.Equal(oidDataContentType):
+			_, err = asn1.Unmarshal(contentInfo.Content.Bytes, &bagsData)
+			if err != nil {
+				return nil, nil, errors.New("invalid pkcs#12 data")
+			}
+		case contentInfo.ContentType.Equal(oidEncryptedDataContentType):
+			var encryptedData pkcs12EncryptedData
+			_, err = asn1.Unmarshal(contentInfo.Content.Bytes, &encryptedData)
+			if err != nil {
+				return nil, nil, errors.New("invalid pkcs#12 data")
+			}
+			var info = encryptedData.EncryptedContentInfo
+			bagsData, err = pbeDecrypt(info.ContentEncryptionAlgorithm, info.EncryptedContent.Bytes, password, encodedPassword)
+			if err != nil {
+				return nil, nil, err
+			}
+		default:
+			return nil, nil, errPKCS12UnsupportedAlgorithm
+		}
+
+		var bags = []pkcs12SafeBag{}
+		_, err = asn1.Unmarshal(bagsData, &bags)
+		if err != nil {
+			return nil, nil, errors.New("invalid pkcs#12 data")
+		}
+		for _, bag := range bags {
+			switch {
+			case bag.Id.Equal(oidCertBag):
+				var certBag pkcs12CertBag
+				_, err = asn1.Unmarshal(bag.Value.Bytes, &certBag)
+				if err != nil {
+					return nil, nil, errors.New("invalid pkcs#12 certificate bag")
+				}
+				if !certBag.Id.Equal(oidCertTypeX509) {
+					continue
+				}
+				var certDER []byte
+				_, err = asn1.Unmarshal(certBag.Data.Bytes, &certDER)
+				if err != nil {
+					return nil, nil, errors.New("invalid pkcs#12 certificate bag")
+				}
+				cert, err := x509.ParseCertificate(certDER)
+				if err != nil {
+					return nil, nil, err
+				}
+				certs = append(certs, cert)
+			case bag.Id.Equal(oidKeyBag):
+				key, err := parsePrivateKey(bag.Value.Bytes)
+				if err != nil {
+					return nil, nil, err
+				}
+				keys = append(keys, key)
+			case bag.Id.Equal(oidPKCS8ShroudedKeyBag):
+				var keyInfo pkcs12EncryptedPrivateKeyInfo
+				_, err = asn1.Unmarshal(bag.Value.Bytes, &keyInfo)
+				if err != nil {
+					return nil, nil, errors.New("invalid pkcs#12 key bag")
+				}
+				keyDER, err := pbeDecrypt(keyInfo.AlgorithmIdentifier, keyInfo.EncryptedData, password, encodedPassword)
+				if err != nil {
+					return nil, nil, err
+				}
+				key, err := parsePrivateKey(keyDER)
+				if err != nil {
+					return nil, nil, err
+				}
+				keys = append(keys, key)
+			}
+		}
+	}
+	return certs, keys, nil
+}
+
+// 检查密钥派生的迭代次数
+func checkPKCS12Iterations(iterations int) error {
+	if iterations <= 0 || iterations > pkcs12MaxIterations {
+		return errPKCS12InvalidIterations
+	}
+	return nil
+}
+
+// 检查校验码
+func checkPKCS12Mac(newHash func() hash.Hash, macData pkcs12MacData, data []byte, encodedPassword []byte) bool {
+	var macKey = pkcs12KDF(newHash, encodedPassword, macData.MacSalt, macData.Iterations, 3, newHash().Size())
+	var mac = hmac.New(newHash, macKey)
+	mac.Write(data)
+	return hmac.Equal(mac.Sum(nil), macData.Mac.Digest)
+}
+
+// 解密PKCS#12中的数据
+func pbeDecrypt(algorithm pkix.AlgorithmIdentifier, data []byte, password string, encodedPassword []byte) ([]byte, error) {
+	var block cipher.Block
+	var iv []byte
+
+	switch {
+	case algorithm.Algorithm.Equal(oidPBEWithSHAAnd3KeyTripleDES),
+		algorithm.Algorithm.Equal(oidPBEWithSHAAnd128BitRC2CBC),
+		algorithm.Algorithm.Equal(oidPBEWithSHAAnd40BitRC2CBC):
+		var params pkcs12PBEParams
+		_, err := asn1.Unmarshal(algorithm.Parameters.FullBytes, &params)
+		if err != nil {
+			return nil, errors.New("invalid pbe parameters")
+		}
+		err = checkPKCS12Iterations(params.Iterations)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case algorithm.Algorithm.Equal(oidPBEWithSHAAnd128BitRC2CBC):
+			block = newRC2Cipher(pkcs12KDF(sha1.New, encodedPassword, params.Salt, params.Iterations, 1, 16), 128)
+		case algorithm.Algorithm.Equal(oidPBEWithSHAAnd40BitRC2CBC):
+			block = newRC2Cipher(pkcs12KDF(sha1.New, encodedPassword, params.Salt, params.Iterations, 1, 5), 40)
+		default:
+			block, err = des.NewTripleDESCipher(pkcs12KDF(sha1.New, encodedPassword, params.Salt, params.Iterations, 1, 24))
+			if err != nil {
+				return nil, err
+			}
+		}
+		iv = pkcs12KDF(sha1.New, encodedPassword, params.Salt, params.Iterations, 2, 8)
+	case algorithm.Algorithm.Equal(oidPBES2):
+		var err error
+		block, iv, err = pbes2Cipher(algorithm, password)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errPKCS12UnsupportedAlgorithm
+	}
+
+	if len(data) == 0 || len(data)%block.BlockSize() != 0 || len(iv) != block.BlockSize() {
+		return nil, errors.New("invalid encrypted data")
+	}
+	var decrypted = make([]byte, len(data))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(decrypted, data)
+
+	// 去除 PKCS#7 填充
+	var padding = int(decrypted[len(decrypted)-1])
+	if padding == 0 || padding > block.BlockSize() {
+		return nil, errPKCS12IncorrectPassword
+	}
+	for _, b := range decrypted[len(decrypted)-padding:] {
+		if int(b) != padding {
+			return nil, errPKCS12IncorrectPassword
+		}
+	}
+	return decrypted[:len(decrypted)-padding], nil
+}
+
+// 根据PBES2参数生成解密器
+// PBES2直接使用UTF-8编码的密码，而不是BMPString
+func pbes2Cipher(algorithm pkix.AlgorithmIdentifier, password string) (cipher.Block, []byte, error) {
+	var params pkcs12PBES2Params
+	_, err := asn1.Unmarshal(algorithm.Parameters.FullBytes, &params)
+	if err != nil {
+		return nil, nil, errors.New("invalid pbes2 parameters")
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, nil, errPKCS12UnsupportedAlgorithm
+	}
+	var kdfParams pkcs12PBKDF2Params
+	_, err = asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams)
+	if err != nil {
+		return nil, nil, errors.New("invalid pbkdf2 parameters")
+	}
+	err = checkPKCS12Iterations(kdfParams.Iterations)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var prf func() hash.Hash
+	switch {
+	case len(kdfParams.PRF.Algorithm) == 0, kdfParams.PRF.Algorithm.Equal(oidHMACWithSHA1):
+		prf = sha1.New
+	case kdfParams.PRF.Algorithm.Equal(oidHMACWithSHA256):
+		prf = sha256.New
+	case kdfParams.PRF.Algorithm.Equal(oidHMACWithSHA384):
+		prf = sha512.New384
+	case kdfParams.PRF.Algorithm.Equal(oidHMACWithSHA512):
+		prf = sha512.New
+	default:
+		return nil, nil, errPKCS12UnsupportedAlgorithm
+	}
+
+	var keySize int
+	var isDES bool
+	var scheme = params.EncryptionScheme.Algorithm
+	switch {
+	case scheme.Equal(oidAES128CBC):
+		keySize = 16
+	case scheme.Equal(oidAES192CBC):
+		keySize = 24
+	case scheme.Equal(oidAES256CBC):
+		keySize = 32
+	case scheme.Equal(oidDESEDE3CBC):
+		keySize = 24
+		isDES = true
+	default:
+		return nil, nil, errPKCS12UnsupportedAlgorithm
+	}
+	if kdfParams.KeyLength > 0 && kdfParams.KeyLength != keySize {
+		return nil, nil, errors.New("invalid pbkdf2 key length")
+	}
+
+	var iv []byte
+	_, err = asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv)
+	if err != nil {
+		return nil, nil, errors.New("invalid pbes2 parameters")
+	}
+
+	var key = pbkdf2.Key([]byte(password), kdfParams.Salt, kdfParams.Iterations, keySize, prf)
+	var block cipher.Block
+	if isDES {
+		block, err = des.NewTripleDESCipher(key)
+	} else {
+		block, err = aes.NewCipher(key)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return block, iv, nil
+}
+
+// 根据摘要算法查找对应的Hash函数
+func findDigestHash(oid asn1.ObjectIdentifier) func() hash.Hash {
+	switch {
+	case oid.Equal(oidSHA1):
+		return sha1.New
+	case oid.Equal(oidSHA256):
+		return sha256.New
+	case oid.Equal(oidSHA384):
+		return sha512.New384
+	case oid.Equal(oidSHA512):
+		return sha512.New
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package sslutils
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/asn1"
+	"encoding/base64"
+	"testing"
+)
+
+func TestPKCS12KDF(t *testing.T) {
+	password, err := bmpString("sesame")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var key = pkcs12KDF(sha1.New, password, []byte("\xff\xff\xff\xff\xff\xff\xff\xff"), 2048, 1, 24)
+	var expected = []byte("\x7c\xd9\xfd\x3e\x2b\x3b\xe7\x69\x1a\x44\xe3\xbe\xf0\xf9\xea\x0f\xb9\xb8\x97\xd4\xe3\x25\xd9\xd1")
+	if !bytes.Equal(key, expected) {
+		t.Fatalf("expected key '%x', but found '%x'", expected, key)
+	}
+
+	// 中间结果有前导0的情况
+	key = pkcs12KDF(sha1.New, []byte("\x00\x00"), []byte("\xf3\x7e\x05\xb5\x18\x32\x4b\x4b"), 2048, 1, 24)
+	expected = []byte("\x00\xf7\x59\xff\x47\xd1\x4d\xd0\x36\x65\xd5\x94\x3c\xb3\xc4\xa3\x9a\x25\x55\xc0\x2a\xed\x66\xe1")
+	if !bytes.Equal(key, expected) {
+		t.Fatalf("expected key '%x', but found '%x'", expected, key)
+	}
+}
+
+func TestEncodePKCS12(t *testing.T) {
+	var root = newTestCA(t)
+	var intermediate = newTestChainCert(t, 2, "Test Intermediate", true, root, "")
+	var leaf = newTestChainCert(t, 3, "example.com", false, intermediate, "")
+
+	// 证书顺序错乱时也能正确导出
+	var certData = encodeTestCerts(intermediate.cert, leaf.cert)
+	var keyData = encodeTestKey(t, leaf.key)
+
+	pfxData, err := EncodePKCS12(certData, keyData, "123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resultCertData, resultKeyData, err := DecodePKCS12(pfxData, "123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(resultCertData, encodeTestCerts(leaf.cert, intermediate.cert)) {
+		t.Fatal("cert chain not match")
+	}
+	ok, err := matchKey(leaf.cert, resultKeyData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("private key not match")
+	}
+
+	// 错误的密码
+	_, _, err = DecodePKCS12(pfxData, "654321")
+	if err == nil {
+		t.Fatal("should fail with incorrect password")
+	}
+}
+
+func TestDecodePKCS12_Iterations(t *testing.T) {
+	var root = newTestCA(t)
+	var leaf = newTestChainCert(t, 2, "example.com", false, root, "")
+	pfxData, err := EncodePKCS12(encodeTestCerts(leaf.cert), encodeTestKey(t, leaf.key), "123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, iterations := range []int{-1, pkcs12MaxIterations + 1} {
+		var pfx pkcs12PFX
+		_, err = asn1.Unmarshal(pfxData, &pfx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		pfx.MacData.Iterations = iterations
+		data, err := asn1.Marshal(pfx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, _, err = DecodePKCS12(data, "123456")
+		if err != errPKCS12InvalidIterations {
+			t.Fatal("should reject iterations", iterations, "but got", err)
+		}
+	}
+}
+
+func TestEncodePKCS12_EmptyPassword(t *testing.T) {
+	var root = newTestCA(t)
+	var leaf = newTestChainCert(t, 2, "example.com", false, root, "")
+
+	pfxData, err := EncodePKCS12(encodeTestCerts(leaf.cert), encodeTestKey(t, leaf.key), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resultCertData, _, err := DecodePKCS12(pfxData, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(resultCertData, encodeTestCerts(leaf.cert)) {
+		t.Fatal("cert not match")
+	}
+}
+
+func TestEncodePKCS12_KeyNotMatch(t *testing.T) {
+	var root = newTestCA(t)
+	var leaf1 = newTestChainCert(t, 2, "a.example.com", false, root, "")
+	var leaf2 = newTestChainCert(t, 3, "b.example.com", false, root, "")
+
+	_, err := EncodePKCS12(encodeTestCerts(leaf1.cert), encodeTestKey(t, leaf2.key), "123456")
+	if err == nil {
+		t.Fatal("should fail with unmatched key")
+	}
+}
+
+// OpenSSL 3 默认生成的文件：PBES2（AES-256-CBC）加密，SHA256校验码
+// openssl pkcs12 -export -in ec.pem -inkey ec.key -passout pass:123456 -out ec.p12
+var testPBES2PKCS12 = "MIIEHAIBAzCCA9IGCSqGSIb3DQEHAaCCA8MEggO/MIIDuzCCAnIGCSqGSIb3DQEHBqCCAmMwggJf" +
+	"AgEAMIICWAYJKoZIhvcNAQcBMFcGCSqGSIb3DQEFDTBKMCkGCSqGSIb3DQEFDDAcBAipitkh5EL4" +
+	"XgICCAAwDAYIKoZIhvcNAgkFADAdBglghkgBZQMEASoEEDYQrHs2J+ZYN+mTYluBrd2AggHwks2z" +
+	"dHcph0thRN5fCVbh6QdIwBrn7x/uMqFDjMXhJKJb/hNE8sBWYTUtevbtBMRN/0n548n+kPJ/HMoQ" +
+	"x4/ghRwPlTecSLa6CB2Fv+/3pQp7FkeZsT3TVu2jkwU1/b6hiXtu8M26U7Pzb9FSkcVVnTZ4pQ9R" +
+	"7XoGT0PHQ3eCpRb1iaTM7JT+Gz1hlZ9Vq2ubxPz3C5g05ZPTXH/MbMJL6rsRn446R8Z0tiH5GFLN" +
+	"AB9cPrmrP2fuOc0ndHoEZBnQ6naIxf93aykM/+dnmTbqENfXClB/zMYEzg5QAgnr4lvHE9EI9glT" +
+	"XP8S7EUjfBbUwGt92BqxNPfU6dzmfloaxnFox8JdQqqfMtr01rft9BMuJE3Pu1uEV44pqZ9awXRa" +
+	"ywuRHBL0lBMWW903c4xedr7v/48JyozLc8TZ0BqqYb34QeFYp7feGaaQh+DoHlvQTr3aUN6vSLMq" +
+	"yoiw+zmZeoca8010R2jtgjBIyp5RPAFWiFCj/fBHFxpF9FQQ4KUPQW2GzWhojWBD8gKbaAJ5Oc5R" +
+	"TTG/ywT+al4P+sjMgC+sufFVpAQ1t0PExRsGOuSVY1P8sxpEH/UyFdpbhnTY5MEcWPIQ2UWWKjv3" +
+	"VdxpIUz5Lvjs86akwh1miATeGPHfnizg2rrLVcGc1w/KS3wy4jCCAUEGCSqGSIb3DQEHAaCCATIE" +
+	"ggEuMIIBKjCCASYGCyqGSIb3DQEMCgECoIHvMIHsMFcGCSqGSIb3DQEFDTBKMCkGCSqGSIb3DQEF" +
+	"DDAcBAgeRN+zgNEg/QICCAAwDAYIKoZIhvcNAgkFADAdBglghkgBZQMEASoEEAhlQpmRzh386AwO" +
+	"NFgSwgAEgZCA+RDHU6fBsDR5d5ImDgJ9ptkAOoQmXaJlsATm/lRPuvR7B6oU+3scda8Z4i87rig1" +
+	"IKbHeeHuMvBqAOl5w541BdAnsxKLycIIWftVeIUNfjeaa4bb/sYSaP/5iOMWtm2RatfAheHdrweC" +
+	"T5PIdcTM0dGMJC0U6KlCelTJPnYIww7ajmxwlDbiTmfI0+Ku9/UxJTAjBgkqhkiG9w0BCRUxFgQU" +
+	"JCzmx2VU4Ke1HNVEcfNubgZkMr0wQTAxMA0GCWCGSAFlAwQCAQUABCDGs/n4BLhcrja+/yR74dNb" +
+	"E+DqkD/pRkdPbdnmEVcW0AQIxlk73tBAt0ECAggA"
+
+func TestDecodePKCS12_PBES2(t *testing.T) {
+	pfxData, err := base64.StdEncoding.DecodeString(testPBES2PKCS12)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certData, keyData, err := DecodePKCS12(pfxData, "123456")
+	if err != nil {
+		t.Fatal(err)
+	}
+	certs, err := ParseCerts(certData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(certs) != 1 || certs[0].Subject.CommonName != "pbes2.example.com" {
+		t.Fatal("unexpected certs")
+	}
+	ok, err := matchKey(certs[0], keyData)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ok {
+		t.Fatal("private key not match")
+	}
+
+	_, _, err = DecodePKCS12(pfxData, "654321")
+	if err == nil {
+		t.Fatal("should fail with incorrect password")
+	}
+}
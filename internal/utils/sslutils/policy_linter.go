@@ -0,0 +1,262 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package sslutils
+
+import (
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/x509"
+	"github.com/TeaOSLab/EdgeCommon/pkg/serverconfigs/sslconfigs"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 证书剩余有效期小于此值时提示
+const lintCertExpiringDays = 30
+
+// HSTS的max-age小于此值时不能获得最高等级
+const lintHSTSMinMaxAge = 180 * 86400
+
+// PolicyGrade SSL策略等级
+type PolicyGrade = string
+
+const (
+	PolicyGradeAPlus PolicyGrade = "A+"
+	PolicyGradeA     PolicyGrade = "A"
+	PolicyGradeB     PolicyGrade = "B"
+	PolicyGradeC     PolicyGrade = "C"
+	PolicyGradeF     PolicyGrade = "F"
+)
+
+// AllPolicyGrades 所有等级，从高到低排列
+var AllPolicyGrades = []PolicyGrade{PolicyGradeAPlus, PolicyGradeA, PolicyGradeB, PolicyGradeC, PolicyGradeF}
+
+// PolicyLintLevel 问题级别
+type PolicyLintLevel = string
+
+const (
+	PolicyLintLevelInfo    PolicyLintLevel = "info"
+	PolicyLintLevelWarning PolicyLintLevel = "warning"
+	PolicyLintLevelError   PolicyLintLevel = "error"
+)
+
+// PolicyLintIssue SSL策略中发现的问题
+type PolicyLintIssue struct {
+	Code     string          `json:"code"`
+	Level    PolicyLintLevel `json:"level"`
+	Message  string          `json:"message"`
+	MaxGrade PolicyGrade     `json:"maxGrade,omitempty"` // 此问题限制的最高等级，为空表示不影响等级
+	CertId   int64           `json:"certId,omitempty"`   // 相关的证书ID
+}
+
+// PolicyLintReport SSL策略检查报告
+type PolicyLintReport struct {
+	Grade  PolicyGrade        `json:"grade"`
+	Issues []*PolicyLintIssue `json:"issues"`
+}
+
+func (this *PolicyLintReport) add(code string, level PolicyLintLevel, maxGrade PolicyGrade, certId int64, message string) {
+	this.Issues = append(this.Issues, &PolicyLintIssue{
+		Code:     code,
+		Level:    level,
+		Message:  message,
+		MaxGrade: maxGrade,
+		CertId:   certId,
+	})
+	if len(maxGrade) > 0 && gradeIndex(maxGrade) > gradeIndex(this.Grade) {
+		this.Grade = maxGrade
+	}
+}
+
+// LintSSLPolicy 检查SSL策略的安全性，并给出类似于SSL Labs的等级
+// 检查协议版本、加密套件、证书密钥和签名算法、证书有效期、HSTS和客户端认证设置
+func LintSSLPolicy(policy *sslconfigs.SSLPolicy) *PolicyLintReport {
+	return lintSSLPolicy(policy, time.Now())
+}
+
+func lintSSLPolicy(policy *sslconfigs.SSLPolicy, now time.Time) *PolicyLintReport {
+	var report = &PolicyLintReport{
+		Grade:  PolicyGradeAPlus,
+		Issues: []*PolicyLintIssue{},
+	}
+	if policy == nil {
+		report.add("NO_POLICY", PolicyLintLevelError, PolicyGradeF, 0, "没有设置SSL策略")
+		return report
+	}
+
+	lintVersion(report, policy.MinVersion)
+	if policy.CipherSuitesIsOn {
+		lintCipherSuites(report, policy.MinVersion, policy.CipherSuites)
+	}
+	lintCerts(report, policy.Certs, now)
+	lintHSTS(report, policy.HSTS)
+	lintClientAuth(report, policy, now)
+
+	return report
+}
+
+// 检查协议版本
+// 没有设置最低版本时默认支持TLS 1.0
+func lintVersion(report *PolicyLintReport, minVersion string) {
+	switch minVersion {
+	case "SSL 3.0":
+		report.add("SSL3_ENABLED", PolicyLintLevelError, PolicyGradeF, 0, "启用了不安全的SSL 3.0，建议将最低版本设置为TLS 1.2")
+	case "", "TLS 1.0", "TLS 1.1":
+		var version = minVersion
+		if len(version) == 0 {
+			version = "TLS 1.0"
+		}
+		report.add("LEGACY_TLS_ENABLED", PolicyLintLevelWarning, PolicyGradeB, 0, "支持已经废弃的"+version+"，建议将最低版本设置为TLS 1.2")
+	}
+}
+
+// 检查自定义的加密算法套件
+func lintCipherSuites(report *PolicyLintReport, minVersion string, cipherSuites []string) {
+	if len(cipherSuites) == 0 {
+		return
+	}
+
+	var weakCiphers = []string{}
+	var insecureCiphers = []string{}
+	var noForwardSecrecyCiphers = []string{}
+	var hasAEAD = false
+	for _, cipherSuite := range cipherSuites {
+		var name = strings.ToUpper(cipherSuite)
+		switch {
+		case strings.Contains(name, "_NULL_"), strings.Contains(name, "_EXPORT"), strings.Contains(name, "_ANON_"), strings.Contains(name, "_DES_"):
+			insecureCiphers = append(insecureCiphers, cipherSuite)
+			continue
+		case strings.Contains(name, "_RC4_"), strings.Contains(name, "_3DES_"):
+			weakCiphers = append(weakCiphers, cipherSuite)
+		}
+		if strings.HasPrefix(name, "TLS_RSA_") {
+			noForwardSecrecyCiphers = append(noForwardSecrecyCiphers, cipherSuite)
+		}
+		if strings.Contains(name, "_GCM_") || strings.Contains(name, "CHACHA20_POLY1305") {
+			hasAEAD = true
+		}
+	}
+
+	if len(insecureCiphers) > 0 {
+		report.add("INSECURE_CIPHER", PolicyLintLevelError, PolicyGradeF, 0, "使用了不安全的加密算法套件："+strings.Join(insecureCiphers, ", "))
+	}
+	if len(weakCiphers) > 0 {
+		report.add("WEAK_CIPHER", PolicyLintLevelError, PolicyGradeC, 0, "使用了弱加密算法套件（RC4、3DES）："+strings.Join(weakCiphers, ", "))
+	}
+	if len(noForwardSecrecyCiphers) > 0 {
+		report.add("NO_FORWARD_SECRECY", PolicyLintLevelWarning, PolicyGradeB, 0, "以下加密算法套件不支持前向保密（Forward Secrecy）："+strings.Join(noForwardSecrecyCiphers, ", "))
+	}
+
+	// TLS 1.3的加密套件固定为AEAD，不需要检查
+	if !hasAEAD && minVersion != "TLS 1.3" {
+		report.add("NO_AEAD_CIPHER", PolicyLintLevelWarning, PolicyGradeB, 0, "没有使用AEAD加密算法套件（GCM、ChaCha20-Poly1305）")
+	}
+}
+
+// 检查证书
+func lintCerts(report *PolicyLintReport, certConfigs []*sslconfigs.SSLCertConfig, now time.Time) {
+	var hasCert = false
+	for _, certConfig := range certConfigs {
+		if certConfig == nil || certConfig.IsCA {
+			continue
+		}
+		hasCert = true
+
+		var certId = certConfig.Id
+		var certName = "证书'" + certConfig.Name + "'"
+		certs, err := ParseCerts(certConfig.CertData)
+		if err != nil {
+			report.add("CERT_INVALID", PolicyLintLevelError, PolicyGradeF, certId, certName+"无法解析："+err.Error())
+			continue
+		}
+		var leaf = certs[0]
+
+		// 有效期
+		if now.After(leaf.NotAfter) {
+			report.add("CERT_EXPIRED", PolicyLintLevelError, PolicyGradeF, certId, certName+"已于"+leaf.NotAfter.Format("2006-01-02")+"过期")
+		} else if now.Before(leaf.NotBefore) {
+			report.add("CERT_NOT_YET_VALID", PolicyLintLevelError, PolicyGradeF, certId, certName+"尚未生效")
+		} else if days := int(leaf.NotAfter.Sub(now).Hours() / 24); days < lintCertExpiringDays {
+			report.add("CERT_EXPIRING", PolicyLintLevelWarning, "", certId, certName+"将在"+strconv.Itoa(days)+"天后过期")
+		}
+
+		// 密钥长度
+		switch key := leaf.PublicKey.(type) {
+		case *rsa.PublicKey:
+			if bits := key.N.BitLen(); bits < 2048 {
+				report.add("WEAK_KEY", PolicyLintLevelError, PolicyGradeF, certId, certName+"使用了长度过短的RSA密钥（"+strconv.Itoa(bits)+"位），至少需要2048位")
+			}
+		case *ecdsa.PublicKey:
+			if bits := key.Curve.Params().BitSize; bits < 256 {
+				report.add("WEAK_KEY", PolicyLintLevelError, PolicyGradeF, certId, certName+"使用了长度过短的ECDSA密钥（"+strconv.Itoa(bits)+"位），至少需要256位")
+			}
+		}
+
+		// 签名算法，根证书的签名不影响安全性
+		for _, cert := range certs {
+			if isSelfSigned(cert) {
+				continue
+			}
+			if isWeakSignatureAlgorithm(cert.SignatureAlgorithm) {
+				report.add("WEAK_SIGNATURE", PolicyLintLevelError, PolicyGradeF, certId, certName+"的证书链中使用了不安全的签名算法"+cert.SignatureAlgorithm.String()+"："+cert.Subject.CommonName)
+			}
+		}
+	}
+
+	if !hasCert {
+		report.add("NO_CERT", PolicyLintLevelError, PolicyGradeF, 0, "没有设置证书")
+	}
+}
+
+// 检查HSTS
+func lintHSTS(report *PolicyLintReport, hsts *sslconfigs.HSTSConfig) {
+	if hsts == nil || !hsts.IsOn {
+		report.add("HSTS_DISABLED", PolicyLintLevelInfo, PolicyGradeA, 0, "没有启用HSTS")
+		return
+	}
+	if hsts.MaxAge > 0 && hsts.MaxAge < lintHSTSMinMaxAge {
+		report.add("HSTS_SHORT_MAX_AGE", PolicyLintLevelInfo, PolicyGradeA, 0, "HSTS的有效期过短，建议设置为至少180天")
+	}
+}
+
+// 检查客户端认证设置
+func lintClientAuth(report *PolicyLintReport, policy *sslconfigs.SSLPolicy, now time.Time) {
+	switch policy.ClientAuthType {
+	case sslconfigs.SSLClientAuthTypeRequestClientCert, sslconfigs.SSLClientAuthTypeRequireAnyClientCert:
+		report.add("CLIENT_CERT_NOT_VERIFIED", PolicyLintLevelWarning, "", 0, "客户端证书没有经过校验，任何证书都可以通过认证")
+	case sslconfigs.SSLClientAuthTypeVerifyClientCertIfGiven, sslconfigs.SSLClientAuthTypeRequireAndVerifyClientCert:
+		var hasCA = false
+		for _, caConfig := range policy.ClientCACerts {
+			if caConfig == nil {
+				continue
+			}
+			hasCA = true
+			if caConfig.TimeEndAt > 0 && caConfig.TimeEndAt < now.Unix() {
+				report.add("CLIENT_CA_EXPIRED", PolicyLintLevelWarning, "", caConfig.Id, "客户端认证CA证书'"+caConfig.Name+"'已经过期")
+			}
+		}
+		if !hasCA {
+			report.add("CLIENT_CA_MISSING", PolicyLintLevelError, "", 0, "要求校验客户端证书，但是没有设置客户端认证CA证书")
+		}
+	}
+}
+
+// 判断签名算法是否已经不安全
+func isWeakSignatureAlgorithm(algorithm x509.SignatureAlgorithm) bool {
+	switch algorithm {
+	case x509.MD2WithRSA, x509.MD5WithRSA, x509.SHA1WithRSA, x509.DSAWithSHA1, x509.ECDSAWithSHA1:
+		return true
+	}
+	return false
+}
+
+// 等级的序号，越大表示越差
+func gradeIndex(grade PolicyGrade) int {
+	for index, g := range AllPolicyGrades {
+		if g == grade {
+			return index
+		}
+	}
+	return 0
+}
@@ -0,0 +1,186 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package sslutils
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"github.com/TeaOSLab/EdgeCommon/pkg/serverconfigs/sslconfigs"
+	"math/big"
+	"testing"
+	"time"
+)
+
+func findLintIssue(report *PolicyLintReport, code string) *PolicyLintIssue {
+	for _, issue := range report.Issues {
+		if issue.Code == code {
+			return issue
+		}
+	}
+	return nil
+}
+
+func newTestLintPolicy(t *testing.T) *sslconfigs.SSLPolicy {
+	var root = newTestCA(t)
+	var leaf = newTestChainCert(t, 2, "example.com", false, root, "")
+	return &sslconfigs.SSLPolicy{
+		IsOn:       true,
+		MinVersion: "TLS 1.2",
+		Certs: []*sslconfigs.SSLCertConfig{
+			{
+				Id:       1,
+				Name:     "example.com",
+				CertData: encodeTestCerts(leaf.cert),
+			},
+		},
+		HSTS: &sslconfigs.HSTSConfig{
+			IsOn:   true,
+			MaxAge: 365 * 86400,
+		},
+	}
+}
+
+func TestLintSSLPolicy(t *testing.T) {
+	var policy = newTestLintPolicy(t)
+	var report = lintSSLPolicy(policy, time.Now())
+	if report.Grade != PolicyGradeAPlus {
+		t.Fatalf("expected grade 'A+', but got '%s': %+v", report.Grade, report.Issues)
+	}
+
+	// 没有HSTS
+	policy.HSTS = nil
+	report = lintSSLPolicy(policy, time.Now())
+	if report.Grade != PolicyGradeA || findLintIssue(report, "HSTS_DISABLED") == nil {
+		t.Fatal("expected grade 'A', but got '" + report.Grade + "'")
+	}
+
+	// 旧的协议版本
+	policy.MinVersion = ""
+	report = lintSSLPolicy(policy, time.Now())
+	if report.Grade != PolicyGradeB || findLintIssue(report, "LEGACY_TLS_ENABLED") == nil {
+		t.Fatal("expected grade 'B', but got '" + report.Grade + "'")
+	}
+
+	policy.MinVersion = "SSL 3.0"
+	report = lintSSLPolicy(policy, time.Now())
+	if report.Grade != PolicyGradeF || findLintIssue(report, "SSL3_ENABLED") == nil {
+		t.Fatal("expected grade 'F', but got '" + report.Grade + "'")
+	}
+}
+
+func TestLintSSLPolicy_CipherSuites(t *testing.T) {
+	var policy = newTestLintPolicy(t)
+	policy.CipherSuitesIsOn = true
+	policy.CipherSuites = []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", "TLS_RSA_WITH_AES_128_CBC_SHA"}
+	var report = lintSSLPolicy(policy, time.Now())
+	if report.Grade != PolicyGradeB || findLintIssue(report, "NO_FORWARD_SECRECY") == nil {
+		t.Fatal("expected grade 'B', but got '" + report.Grade + "'")
+	}
+	if findLintIssue(report, "NO_AEAD_CIPHER") != nil {
+		t.Fatal("should have AEAD cipher")
+	}
+
+	policy.CipherSuites = []string{"TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA", "TLS_ECDHE_RSA_WITH_RC4_128_SHA"}
+	report = lintSSLPolicy(policy, time.Now())
+	if report.Grade != PolicyGradeC || findLintIssue(report, "WEAK_CIPHER") == nil || findLintIssue(report, "NO_AEAD_CIPHER") == nil {
+		t.Fatal("expected grade 'C', but got '" + report.Grade + "'")
+	}
+
+	// 没有启用自定义加密套件时不检查
+	policy.CipherSuitesIsOn = false
+	report = lintSSLPolicy(policy, time.Now())
+	if report.Grade != PolicyGradeAPlus {
+		t.Fatal("expected grade 'A+', but got '" + report.Grade + "'")
+	}
+}
+
+func TestLintSSLPolicy_Certs(t *testing.T) {
+	var policy = newTestLintPolicy(t)
+
+	// 尚未生效
+	var report = lintSSLPolicy(policy, time.Now().Add(-29*24*time.Hour))
+	var issue = findLintIssue(report, "CERT_NOT_YET_VALID")
+	if issue == nil || issue.CertId != 1 {
+		t.Fatal("should be not yet valid")
+	}
+
+	// 即将过期
+	report = lintSSLPolicy(policy, time.Now().Add(time.Hour))
+	if findLintIssue(report, "CERT_EXPIRING") == nil || report.Grade != PolicyGradeAPlus {
+		t.Fatal("should be expiring without affecting the grade")
+	}
+
+	// 已过期
+	report = lintSSLPolicy(policy, time.Now().Add(48*time.Hour))
+	if report.Grade != PolicyGradeF || findLintIssue(report, "CERT_EXPIRED") == nil {
+		t.Fatal("expected grade 'F', but got '" + report.Grade + "'")
+	}
+
+	// 没有证书
+	policy.Certs = nil
+	report = lintSSLPolicy(policy, time.Now())
+	if report.Grade != PolicyGradeF || findLintIssue(report, "NO_CERT") == nil {
+		t.Fatal("expected grade 'F', but got '" + report.Grade + "'")
+	}
+}
+
+func TestLintSSLPolicy_WeakKey(t *testing.T) {
+	var root = newTestCA(t)
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(365 * 24 * time.Hour),
+	}, root.cert, &key.PublicKey, root.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var policy = newTestLintPolicy(t)
+	policy.Certs[0].CertData = encodeTestCerts(cert)
+	var report = lintSSLPolicy(policy, time.Now())
+	if report.Grade != PolicyGradeF || findLintIssue(report, "WEAK_KEY") == nil {
+		t.Fatal("expected grade 'F', but got '" + report.Grade + "'")
+	}
+}
+
+func TestLintSSLPolicy_ClientAuth(t *testing.T) {
+	var policy = newTestLintPolicy(t)
+	policy.ClientAuthType = sslconfigs.SSLClientAuthTypeRequireAndVerifyClientCert
+	var report = lintSSLPolicy(policy, time.Now())
+	if findLintIssue(report, "CLIENT_CA_MISSING") == nil {
+		t.Fatal("should report missing client CA")
+	}
+
+	policy.ClientCACerts = []*sslconfigs.SSLCertConfig{{Id: 2, Name: "client ca", IsCA: true, TimeEndAt: time.Now().Unix() - 1}}
+	report = lintSSLPolicy(policy, time.Now())
+	if findLintIssue(report, "CLIENT_CA_MISSING") != nil || findLintIssue(report, "CLIENT_CA_EXPIRED") == nil {
+		t.Fatal("should report expired client CA")
+	}
+
+	policy.ClientAuthType = sslconfigs.SSLClientAuthTypeRequestClientCert
+	report = lintSSLPolicy(policy, time.Now())
+	if findLintIssue(report, "CLIENT_CERT_NOT_VERIFIED") == nil {
+		t.Fatal("should report not verified client cert")
+	}
+}
+
+func TestIsWeakSignatureAlgorithm(t *testing.T) {
+	if !isWeakSignatureAlgorithm(x509.SHA1WithRSA) || !isWeakSignatureAlgorithm(x509.ECDSAWithSHA1) {
+		t.Fatal("SHA1 should be weak")
+	}
+	if isWeakSignatureAlgorithm(x509.SHA256WithRSA) || isWeakSignatureAlgorithm(x509.ECDSAWithSHA256) {
+		t.Fatal("SHA256 should not be weak")
+	}
+}
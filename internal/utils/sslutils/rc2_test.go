@@ -0,0 +1,57 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package sslutils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// RFC 2268 中的测试数据
+// RFC 2268 中没有40位有效密钥长度的数据，最后两条使用 openssl enc -rc2-40-cbc 生成
+var testRC2Vectors = []struct {
+	key           string
+	effectiveBits int
+	plain         string
+	cipher        string
+}{
+	{"0000000000000000", 63, "0000000000000000", "ebb773f993278eff"},
+	{"ffffffffffffffff", 64, "ffffffffffffffff", "278b27e42e2f0d49"},
+	{"3000000000000000", 64, "1000000000000001", "30649edf9be7d2c2"},
+	{"88", 64, "0000000000000000", "61a8a244adacccf0"},
+	{"88bca90e90875a", 64, "0000000000000000", "6ccf4308974c267f"},
+	{"88bca90e90875a7f0f79c384627bafb2", 64, "0000000000000000", "1a807d272bbe5db1"},
+	{"88bca90e90875a7f0f79c384627bafb2", 128, "0000000000000000", "2269552ab0f85ca6"},
+	{"88bca90e90875a7f0f79c384627bafb216f80a6f85920584c42fceb0be255daf1e", 129, "0000000000000000", "5b78d3a43dfff1f1"},
+	{"0102030405", 40, "0000000000000000", "269b2c0070a1cb64"},
+	{"88bca90e90", 40, "1000000000000001", "dd5376ca80f18208"},
+}
+
+func TestRC2Cipher_Encrypt(t *testing.T) {
+	for _, v := range testRC2Vectors {
+		key, _ := hex.DecodeString(v.key)
+		plain, _ := hex.DecodeString(v.plain)
+		encrypted, _ := hex.DecodeString(v.cipher)
+
+		var dst = make([]byte, rc2BlockSize)
+		newRC2Cipher(key, v.effectiveBits).Encrypt(dst, plain)
+		if !bytes.Equal(dst, encrypted) {
+			t.Fatal("key '"+v.key+"':", v.effectiveBits, "bits: expected", v.cipher, "but got", hex.EncodeToString(dst))
+		}
+	}
+}
+
+func TestRC2Cipher_Decrypt(t *testing.T) {
+	for _, v := range testRC2Vectors {
+		key, _ := hex.DecodeString(v.key)
+		plain, _ := hex.DecodeString(v.plain)
+		encrypted, _ := hex.DecodeString(v.cipher)
+
+		var dst = make([]byte, rc2BlockSize)
+		newRC2Cipher(key, v.effectiveBits).Decrypt(dst, encrypted)
+		if !bytes.Equal(dst, plain) {
+			t.Fatal("key '"+v.key+"':", v.effectiveBits, "bits: expected", v.plain, "but got", hex.EncodeToString(dst))
+		}
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package sslutils
+
+import (
+	"crypto/x509"
+	"github.com/iwind/TeaGo/Tea"
+	"io/ioutil"
+	"sync"
+)
+
+var rootsData []byte
+var rootsOnce = &sync.Once{}
+
+// NewRootPool 使用内置的根证书生成新的证书池
+// 找不到内置的根证书时使用系统的根证书
+func NewRootPool() *x509.CertPool {
+	rootsOnce.Do(func() {
+		data, err := ioutil.ReadFile(Tea.Root + "/resources/certs/roots.pem")
+		if err == nil {
+			rootsData = data
+		}
+	})
+
+	if len(rootsData) > 0 {
+		var pool = x509.NewCertPool()
+		if pool.AppendCertsFromPEM(rootsData) {
+			return pool
+		}
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		return x509.NewCertPool()
+	}
+	return pool
+}
@@ -0,0 +1,170 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package zoneutils
+
+import (
+	"bytes"
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/miekg/dns"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ComposeOptions 导出选项
+type ComposeOptions struct {
+	Origin     string           // 域名
+	DefaultTTL int32            // 用于$TTL，以及TTL为0的记录
+	RouteNames map[int64]string // 线路ID => 线路名称，用于线路区块的说明
+}
+
+// ComposeZone 将记录组合成RFC 1035格式的区域文件
+// 默认线路的记录直接输出；BIND不支持按线路解析，所以线路记录会按线路分组后以注释的形式输出，可以通过 ParseZone() 再次导入
+func ComposeZone(records []*Record, options *ComposeOptions) []byte {
+	var origin = fqdn(options.Origin)
+	var defaultTTL = options.DefaultTTL
+	if defaultTTL <= 0 {
+		defaultTTL = 3600
+	}
+
+	var defaultRecords = []*Record{}
+	var routeRecords = map[string][]*Record{}
+	var routeIdsMap = map[string][]int64{}
+	for _, record := range records {
+		if len(record.RouteIds) == 0 {
+			defaultRecords = append(defaultRecords, record)
+			continue
+		}
+		var key = routeKey(record.RouteIds)
+		routeRecords[key] = append(routeRecords[key], record)
+		routeIdsMap[key] = record.RouteIds
+	}
+
+	var buf = &bytes.Buffer{}
+	buf.WriteString("; zone: " + origin + "\n")
+	buf.WriteString("; SOA and NS records of the zone are managed by the DNS cluster\n")
+	buf.WriteString("$ORIGIN " + origin + "\n")
+	buf.WriteString("$TTL " + strconv.Itoa(int(defaultTTL)) + "\n")
+	buf.WriteString("\n")
+
+	for _, line := range composeLines(defaultRecords, origin, defaultTTL) {
+		buf.WriteString(line + "\n")
+	}
+
+	// 线路记录
+	var routeKeys = []string{}
+	for key := range routeRecords {
+		routeKeys = append(routeKeys, key)
+	}
+	sort.Strings(routeKeys)
+	if len(routeKeys) > 0 {
+		buf.WriteString("\n")
+		buf.WriteString("; records below are only resolved for the specified routes\n")
+	}
+	for _, key := range routeKeys {
+		var routeNames = []string{}
+		for _, routeId := range routeIdsMap[key] {
+			routeName, ok := options.RouteNames[routeId]
+			if !ok || len(routeName) == 0 {
+				routeName = strconv.FormatInt(routeId, 10)
+			}
+			routeNames = append(routeNames, routeName)
+		}
+
+		buf.WriteString("\n")
+		buf.WriteString("; " + routeSectionPrefix + " " + key + " " + strings.Join(routeNames, ", ") + "\n")
+		for _, line := range composeLines(routeRecords[key], origin, defaultTTL) {
+			if strings.HasPrefix(line, ";") {
+				buf.WriteString(line + "\n")
+			} else {
+				buf.WriteString(";" + line + "\n")
+			}
+		}
+	}
+
+	return buf.Bytes()
+}
+
+// 组合记录行
+func composeLines(records []*Record, origin string, defaultTTL int32) []string {
+	records = append([]*Record{}, records...)
+	sort.SliceStable(records, func(i, j int) bool {
+		var name1 = recordName(records[i].Name)
+		var name2 = recordName(records[j].Name)
+		if name1 != name2 {
+			if name1 == "@" || name2 == "@" {
+				return name1 == "@"
+			}
+			return name1 < name2
+		}
+		if records[i].Type != records[j].Type {
+			return records[i].Type < records[j].Type
+		}
+		return records[i].Value < records[j].Value
+	})
+
+	var lines = []string{}
+	for _, record := range records {
+		var name = recordName(record.Name)
+		var ttl = record.TTL
+		if ttl <= 0 {
+			ttl = defaultTTL
+		}
+		var rdata = composeRData(record)
+		var line = name + "\t" + strconv.Itoa(int(ttl)) + "\tIN\t" + record.Type + "\t" + rdata
+
+		// 无法被正确分析的记录以注释的形式输出
+		_, err := dns.NewRR(absoluteName(name, origin) + " " + strconv.Itoa(int(ttl)) + " IN " + record.Type + " " + rdata)
+		if err != nil {
+			line = "; invalid record: " + line
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+// 组合记录值
+func composeRData(record *Record) string {
+	if record.Type == dnstypes.RecordTypeTXT {
+		return quoteTXT(record.Value)
+	}
+
+	// 目标域名需要以点结尾，以免被当成相对名称
+	var dnsRecord = &dnstypes.Record{Type: record.Type}
+	dnsRecord.ParseValue(record.Value)
+	if dnsRecord.IsDomainValue() && len(dnsRecord.Value) > 0 && !strings.HasSuffix(dnsRecord.Value, ".") {
+		dnsRecord.Value += "."
+	}
+	return dnsRecord.ComposeValue()
+}
+
+// 将TXT文本转换为带引号的字符串，超过255字节时分段
+func quoteTXT(value string) string {
+	// 已经是带引号的格式
+	if strings.HasPrefix(value, "\"") && strings.HasSuffix(value, "\"") && len(value) > 1 {
+		return value
+	}
+
+	var pieces = []string{}
+	for len(value) > 255 {
+		pieces = append(pieces, value[:255])
+		value = value[255:]
+	}
+	pieces = append(pieces, value)
+
+	var result = []string{}
+	for _, piece := range pieces {
+		piece = strings.ReplaceAll(piece, "\\", "\\\\")
+		piece = strings.ReplaceAll(piece, "\"", "\\\"")
+		result = append(result, "\""+piece+"\"")
+	}
+	return strings.Join(result, " ")
+}
+
+// 格式化记录名
+func recordName(name string) string {
+	if len(name) == 0 {
+		return "@"
+	}
+	return name
+}
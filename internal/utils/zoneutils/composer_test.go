@@ -0,0 +1,61 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package zoneutils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestComposeZone(t *testing.T) {
+	var records = []*Record{
+		{Name: "www", Type: "A", Value: "192.168.1.100", TTL: 600},
+		{Name: "@", Type: "A", Value: "192.168.1.100"},
+		{Name: "www", Type: "A", Value: "192.168.2.100", TTL: 600, RouteIds: []int64{2}},
+		{Name: "mail", Type: "MX", Value: "10 mx.teaos.cn", TTL: 600},
+		{Name: "txt", Type: "TXT", Value: `hello "world"`, TTL: 600},
+		{Name: "bad", Type: "A", Value: "192.168.1", TTL: 600},
+	}
+	var data = ComposeZone(records, &ComposeOptions{
+		Origin:     "teaos.cn",
+		DefaultTTL: 300,
+		RouteNames: map[int64]string{2: "电信"},
+	})
+	t.Log("\n" + string(data))
+
+	var zone = string(data)
+	for _, s := range []string{
+		"$ORIGIN teaos.cn.\n",
+		"@\t300\tIN\tA\t192.168.1.100\n",
+		"mail\t600\tIN\tMX\t10 mx.teaos.cn.\n",
+		"txt\t600\tIN\tTXT\t\"hello \\\"world\\\"\"\n",
+		"; invalid record: bad",
+		"; $ROUTE 2 电信\n;www\t600\tIN\tA\t192.168.2.100\n",
+	} {
+		if !strings.Contains(zone, s) {
+			t.Fatal("should contain '" + s + "'")
+		}
+	}
+
+	// 再次导入
+	result, err := ParseZone(data, &ParseOptions{Origin: "teaos.cn", WithRoutes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Records) != 5 {
+		t.Fatal("invalid records count", len(result.Records))
+	}
+	for _, record := range result.Records {
+		if record.Type == "TXT" && record.Value != `hello "world"` {
+			t.Fatal("invalid txt value", record.Value)
+		}
+	}
+}
+
+func TestQuoteTXT(t *testing.T) {
+	var value = strings.Repeat("a", 300)
+	var quoted = quoteTXT(value)
+	if quoted != "\""+strings.Repeat("a", 255)+"\" \""+strings.Repeat("a", 45)+"\"" {
+		t.Fatal("long text should be split")
+	}
+}
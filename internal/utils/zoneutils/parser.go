@@ -0,0 +1,188 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package zoneutils
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/errors"
+	"github.com/miekg/dns"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var routeSectionReg = regexp.MustCompile(`^;\s*\` + routeSectionPrefix + `\s+([0-9,]+)`)
+
+// ParseOptions 导入选项
+type ParseOptions struct {
+	Origin     string // 域名，区域文件中没有$ORIGIN时使用此域名补全相对名称
+	DefaultTTL uint32 // 区域文件中没有$TTL也没有指定TTL时使用的TTL
+	WithRoutes bool   // 是否导入以注释形式保存的线路记录
+}
+
+// ParseResult 导入结果
+type ParseResult struct {
+	Records []*Record
+	Skipped []*SkippedRecord
+}
+
+// ParseZone 分析RFC 1035格式的区域文件
+// 支持$ORIGIN、$TTL和相对名称，不支持$INCLUDE；SOA记录、域名本身的NS记录和不支持的记录类型会放在Skipped中
+func ParseZone(data []byte, options *ParseOptions) (*ParseResult, error) {
+	if options == nil || len(options.Origin) == 0 {
+		return nil, errors.New("'origin' should not be empty")
+	}
+	var origin = fqdn(options.Origin)
+	var result = &ParseResult{
+		Records: []*Record{},
+		Skipped: []*SkippedRecord{},
+	}
+	var recordKeys = map[string]bool{}
+
+	err := parseSection(string(data), origin, nil, options, result, recordKeys)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.WithRoutes {
+		for _, section := range findRouteSections(string(data)) {
+			err = parseSection("$ORIGIN "+origin+"\n"+section.text, origin, section.routeIds, options, result, recordKeys)
+			if err != nil {
+				return nil, errors.New("route '" + routeKey(section.routeIds) + "': " + err.Error())
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// 以注释形式保存的线路记录
+type routeSection struct {
+	routeIds []int64
+	text     string
+}
+
+// 查找以注释形式保存的线路记录
+// 格式为一行"; $ROUTE 线路ID1,线路ID2 线路名称"，后面每行一个以分号开头的记录，直到遇到非注释行
+func findRouteSections(data string) []*routeSection {
+	var sections = []*routeSection{}
+	var current *routeSection
+	for _, line := range strings.Split(data, "\n") {
+		line = strings.TrimRight(line, "\r")
+		var matches = routeSectionReg.FindStringSubmatch(line)
+		if len(matches) > 0 {
+			var routeIds = []int64{}
+			for _, piece := range strings.Split(matches[1], ",") {
+				routeId, err := strconv.ParseInt(piece, 10, 64)
+				if err == nil && routeId > 0 {
+					routeIds = append(routeIds, routeId)
+				}
+			}
+			if len(routeIds) == 0 {
+				current = nil
+				continue
+			}
+			current = &routeSection{routeIds: routeIds}
+			sections = append(sections, current)
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		if !strings.HasPrefix(line, ";") {
+			current = nil
+			continue
+		}
+
+		// 分号后紧跟空白或者分号的是普通注释
+		var recordLine = line[1:]
+		if len(recordLine) == 0 || recordLine[0] == ' ' || recordLine[0] == '\t' || recordLine[0] == ';' {
+			continue
+		}
+		current.text += recordLine + "\n"
+	}
+	return sections
+}
+
+// 分析一段区域文件
+func parseSection(text string, origin string, routeIds []int64, options *ParseOptions, result *ParseResult, recordKeys map[string]bool) error {
+	if routeIds == nil {
+		routeIds = []int64{}
+	}
+
+	var parser = dns.NewZoneParser(strings.NewReader(text), origin, "")
+	if options.DefaultTTL > 0 {
+		parser.SetDefaultTTL(options.DefaultTTL)
+	}
+	for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+		var header = rr.Header()
+		var rrType = dns.TypeToString[header.Rrtype]
+		var value = strings.TrimSpace(strings.TrimPrefix(rr.String(), header.String()))
+		// 只有一段文本的TXT记录使用不带引号的文本，多段文本则保留带引号的格式
+		if txt, ok := rr.(*dns.TXT); ok && len(txt.Txt) == 1 {
+			value = unescapeTXT(txt.Txt[0])
+		}
+
+		name, ok := relativeName(header.Name, origin)
+		if !ok {
+			result.Skipped = append(result.Skipped, &SkippedRecord{Name: header.Name, Type: rrType, Value: value, Reason: SkipReasonOutOfZone})
+			continue
+		}
+
+		var skipReason SkipReason
+		switch {
+		case header.Rrtype == dns.TypeSOA:
+			skipReason = SkipReasonSOA
+		case header.Rrtype == dns.TypeNS && name == "@":
+			skipReason = SkipReasonApexNS
+		case !IsSupportedType(rrType):
+			skipReason = SkipReasonUnsupported
+		}
+		if len(skipReason) > 0 {
+			result.Skipped = append(result.Skipped, &SkippedRecord{Name: name, Type: rrType, Value: value, Reason: skipReason})
+			continue
+		}
+
+		var key = name + "|" + rrType + "|" + value + "|" + routeKey(routeIds)
+		if recordKeys[key] {
+			result.Skipped = append(result.Skipped, &SkippedRecord{Name: name, Type: rrType, Value: value, Reason: SkipReasonDuplicated})
+			continue
+		}
+		recordKeys[key] = true
+
+		result.Records = append(result.Records, &Record{
+			Name:     name,
+			Type:     rrType,
+			Value:    value,
+			TTL:      int32(header.Ttl),
+			RouteIds: routeIds,
+		})
+	}
+	return parser.Err()
+}
+
+// 去除TXT字符串中的转义字符，比如 \" 和 \DDD
+func unescapeTXT(s string) string {
+	if !strings.Contains(s, "\\") {
+		return s
+	}
+	var result = []byte{}
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i == len(s)-1 {
+			result = append(result, s[i])
+			continue
+		}
+		if i+3 < len(s) && isDigit(s[i+1]) && isDigit(s[i+2]) && isDigit(s[i+3]) {
+			code, _ := strconv.Atoi(s[i+1 : i+4])
+			result = append(result, byte(code))
+			i += 3
+			continue
+		}
+		i++
+		result = append(result, s[i])
+	}
+	return string(result)
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
@@ -0,0 +1,105 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package zoneutils
+
+import "testing"
+
+func TestParseZone(t *testing.T) {
+	var data = `$ORIGIN teaos.cn.
+$TTL 600
+@	IN	SOA	ns1.teaos.cn. admin.teaos.cn. 1 3600 600 86400 600
+@	IN	NS	ns1.teaos.cn.
+@		A	192.168.1.100
+www	300	IN	A	192.168.1.100
+www		A	192.168.1.100 ; duplicated
+mail	MX	10 mx
+txt	TXT	"hello \"world\"" " again"
+txt2	TXT	"hello"
+sub	NS	ns1.other.com.
+_sip._tcp	SRV	10 20 5060 sip
+sec	DNSKEY	257 3 13 mdsswUyr3DPW132mOi8V9xESWE8jTo0dxCjjnopKl+GqJxpVXckHAeF+KkxLbxILfDLUT0rAK9iUzy1L53eKGQ==
+other.com.	A	192.168.1.200
+$ORIGIN api.teaos.cn.
+v1	A	192.168.1.101
+`
+	result, err := ParseZone([]byte(data), &ParseOptions{Origin: "teaos.cn"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var records = map[string]*Record{}
+	for _, record := range result.Records {
+		records[record.Name+" "+record.Type] = record
+	}
+	if len(result.Records) != 8 {
+		t.Fatal("invalid records count", len(result.Records))
+	}
+	if records["@ A"] == nil || records["@ A"].TTL != 600 {
+		t.Fatal("$TTL should be used")
+	}
+	if records["www A"].TTL != 300 {
+		t.Fatal("invalid ttl", records["www A"].TTL)
+	}
+	if records["mail MX"].Value != "10 mx.teaos.cn." {
+		t.Fatal("relative name should be completed", records["mail MX"].Value)
+	}
+	if records["txt TXT"].Value != `"hello \"world\"" " again"` {
+		t.Fatal("invalid txt value", records["txt TXT"].Value)
+	}
+	if records["txt2 TXT"].Value != "hello" {
+		t.Fatal("invalid txt value", records["txt2 TXT"].Value)
+	}
+	if records["_sip._tcp SRV"].Value != "10 20 5060 sip.teaos.cn." {
+		t.Fatal("invalid srv value", records["_sip._tcp SRV"].Value)
+	}
+	if records["v1.api A"] == nil {
+		t.Fatal("$ORIGIN should be used")
+	}
+
+	var reasons = map[SkipReason]int{}
+	for _, skipped := range result.Skipped {
+		reasons[skipped.Reason]++
+	}
+	if reasons[SkipReasonSOA] != 1 || reasons[SkipReasonApexNS] != 1 || reasons[SkipReasonDuplicated] != 1 ||
+		reasons[SkipReasonUnsupported] != 1 || reasons[SkipReasonOutOfZone] != 1 {
+		t.Fatal("invalid skipped records", reasons)
+	}
+}
+
+func TestParseZone_Error(t *testing.T) {
+	_, err := ParseZone([]byte("www IN A 192.168.1"), &ParseOptions{Origin: "teaos.cn"})
+	if err == nil {
+		t.Fatal("should return error")
+	}
+	t.Log(err)
+}
+
+func TestParseZone_Routes(t *testing.T) {
+	var data = `$ORIGIN teaos.cn.
+www	600	IN	A	192.168.1.100
+
+; $ROUTE 2,3 电信, 联通
+;www	600	IN	A	192.168.2.100
+; comment
+;www	600	IN	A	192.168.2.101
+`
+	result, err := ParseZone([]byte(data), &ParseOptions{Origin: "teaos.cn"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Records) != 1 {
+		t.Fatal("route records should be ignored")
+	}
+
+	result, err = ParseZone([]byte(data), &ParseOptions{Origin: "teaos.cn", WithRoutes: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Records) != 3 {
+		t.Fatal("route records should be imported", len(result.Records))
+	}
+	var routeIds = result.Records[1].RouteIds
+	if len(routeIds) != 2 || routeIds[0] != 2 || routeIds[1] != 3 {
+		t.Fatal("invalid route ids", routeIds)
+	}
+}
@@ -0,0 +1,54 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package zoneutils
+
+import (
+	"github.com/miekg/dns"
+	"strconv"
+	"strings"
+)
+
+// Change 记录变更，用于增量区域传送
+type Change struct {
+	Version   int64
+	IsDeleted bool
+	Record    *Record
+}
+
+// ComposeRR 将记录转换为RR
+func ComposeRR(record *Record, origin string, defaultTTL uint32) (dns.RR, error) {
+	var ttl = uint32(record.TTL)
+	if record.TTL <= 0 {
+		ttl = defaultTTL
+	}
+	return dns.NewRR(absoluteName(recordName(record.Name), fqdn(origin)) + " " + strconv.FormatUint(uint64(ttl), 10) + " IN " + record.Type + " " + composeRData(record))
+}
+
+// DiffChanges 合并一组按版本排列的变更
+// 返回和变更前相比需要删除和增加的记录，在中间版本中增加后又删除的记录会被忽略
+func DiffChanges(changes []*Change) (deleted []*Record, added []*Record) {
+	var keys = []string{}
+	var firstChanges = map[string]*Change{}
+	var lastChanges = map[string]*Change{}
+	for _, change := range changes {
+		var record = change.Record
+		var key = strings.ToLower(recordName(record.Name)) + "|" + record.Type + "|" + record.Value + "|" + strconv.Itoa(int(record.TTL))
+		_, ok := firstChanges[key]
+		if !ok {
+			firstChanges[key] = change
+			keys = append(keys, key)
+		}
+		lastChanges[key] = change
+	}
+
+	for _, key := range keys {
+		var existedBefore = firstChanges[key].IsDeleted
+		var existsAfter = !lastChanges[key].IsDeleted
+		if existedBefore && !existsAfter {
+			deleted = append(deleted, firstChanges[key].Record)
+		} else if !existedBefore && existsAfter {
+			added = append(added, lastChanges[key].Record)
+		}
+	}
+	return
+}
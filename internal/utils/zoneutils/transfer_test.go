@@ -0,0 +1,48 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package zoneutils
+
+import (
+	"github.com/miekg/dns"
+	"testing"
+)
+
+func TestComposeRR(t *testing.T) {
+	rr, err := ComposeRR(&Record{Name: "mail", Type: "MX", Value: "10 mx.teaos.cn"}, "teaos.cn", 600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mx, ok := rr.(*dns.MX)
+	if !ok || mx.Hdr.Name != "mail.teaos.cn." || mx.Hdr.Ttl != 600 || mx.Mx != "mx.teaos.cn." {
+		t.Fatal("invalid rr", rr)
+	}
+
+	rr, err = ComposeRR(&Record{Name: "@", Type: "TXT", Value: "hello world", TTL: 300}, "teaos.cn", 600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	txt := rr.(*dns.TXT)
+	if txt.Hdr.Name != "teaos.cn." || len(txt.Txt) != 1 || txt.Txt[0] != "hello world" {
+		t.Fatal("invalid rr", rr)
+	}
+}
+
+func TestDiffChanges(t *testing.T) {
+	var a1 = &Record{Name: "www", Type: "A", Value: "192.168.1.100", TTL: 600}
+	var a2 = &Record{Name: "www", Type: "A", Value: "192.168.1.101", TTL: 600}
+	var a3 = &Record{Name: "api", Type: "A", Value: "192.168.1.102", TTL: 600}
+	var a4 = &Record{Name: "tmp", Type: "A", Value: "192.168.1.103", TTL: 600}
+	deleted, added := DiffChanges([]*Change{
+		{Version: 1, IsDeleted: true, Record: a1}, // 修改www
+		{Version: 1, Record: a2},
+		{Version: 2, Record: a3}, // 增加api
+		{Version: 3, Record: a4}, // 增加后又删除tmp
+		{Version: 4, IsDeleted: true, Record: a4},
+	})
+	if len(deleted) != 1 || deleted[0] != a1 {
+		t.Fatal("invalid deleted records", deleted)
+	}
+	if len(added) != 2 || added[0] != a2 || added[1] != a3 {
+		t.Fatal("invalid added records", added)
+	}
+}
@@ -0,0 +1,334 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package zoneutils
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/miekg/dns"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// ValidationCode 校验错误代号
+type ValidationCode = string
+
+const (
+	ValidationCodeUnsupportedType ValidationCode = "unsupportedType" // 不支持的记录类型
+	ValidationCodeInvalidName     ValidationCode = "invalidName"     // 记录名格式错误
+	ValidationCodeInvalidValue    ValidationCode = "invalidValue"    // 记录值格式错误
+	ValidationCodeInvalidTTL      ValidationCode = "invalidTTL"      // TTL超出范围
+	ValidationCodeApexCNAME       ValidationCode = "apexCNAME"       // 域名本身不能设置CNAME
+	ValidationCodeCNAMEConflict   ValidationCode = "cnameConflict"   // CNAME和其他记录同名
+	ValidationCodeDuplicated      ValidationCode = "duplicated"      // 同一线路中有重复的记录
+)
+
+const (
+	DefaultMinTTL int32 = 1      // 默认最小TTL
+	DefaultMaxTTL int32 = 604800 // 默认最大TTL
+)
+
+// ValidationError 记录校验错误
+type ValidationError struct {
+	Code    ValidationCode `json:"code"`
+	Message string         `json:"message"`
+}
+
+func (this *ValidationError) Error() string {
+	return this.Message
+}
+
+func newValidationError(code ValidationCode, message string) *ValidationError {
+	return &ValidationError{
+		Code:    code,
+		Message: message,
+	}
+}
+
+// ValidateOptions 校验选项
+type ValidateOptions struct {
+	Origin string // 域名
+	MinTTL int32  // 最小TTL，TTL为0表示使用默认值，不受此限制
+	MaxTTL int32  // 最大TTL
+}
+
+// AuditProblem 批量检查发现的问题
+type AuditProblem struct {
+	Index int              // 记录在列表中的位置
+	Error *ValidationError // 错误
+}
+
+// ValidateRecord 校验单个记录的记录名、记录值和TTL
+func ValidateRecord(record *Record, options *ValidateOptions) *ValidationError {
+	if options == nil {
+		options = &ValidateOptions{}
+	}
+	var minTTL = options.MinTTL
+	if minTTL <= 0 {
+		minTTL = DefaultMinTTL
+	}
+	var maxTTL = options.MaxTTL
+	if maxTTL <= 0 {
+		maxTTL = DefaultMaxTTL
+	}
+
+	if !IsSupportedType(record.Type) {
+		return newValidationError(ValidationCodeUnsupportedType, "unsupported record type '"+record.Type+"'")
+	}
+
+	// 记录名
+	var name = recordName(record.Name)
+	if !isValidRecordName(name, options.Origin) {
+		return newValidationError(ValidationCodeInvalidName, "invalid record name '"+record.Name+"'")
+	}
+	if name == "@" && record.Type == dnstypes.RecordTypeCNAME {
+		return newValidationError(ValidationCodeApexCNAME, "CNAME record is not allowed at the zone apex")
+	}
+
+	// TTL
+	if record.TTL < 0 || (record.TTL > 0 && (record.TTL < minTTL || record.TTL > maxTTL)) {
+		return newValidationError(ValidationCodeInvalidTTL, "ttl should be between "+strconv.Itoa(int(minTTL))+" and "+strconv.Itoa(int(maxTTL)))
+	}
+
+	// 记录值
+	err := validateValue(record)
+	if err != nil {
+		return newValidationError(ValidationCodeInvalidValue, "invalid "+record.Type+" value '"+record.Value+"': "+err.Error())
+	}
+
+	// CNAME不能指向自己
+	if record.Type == dnstypes.RecordTypeCNAME && len(options.Origin) > 0 {
+		var origin = fqdn(options.Origin)
+		if fqdn(record.Value) == absoluteName(name, origin) {
+			return newValidationError(ValidationCodeInvalidValue, "CNAME record should not point to itself")
+		}
+	}
+
+	return nil
+}
+
+// ValidateRecordConflicts 检查记录和同一个域名中其他记录的冲突
+// 同名记录在同一个线路中（都为默认线路，或者线路有交集）时，CNAME不能和其他记录共存，也不能有重复的记录
+func ValidateRecordConflicts(record *Record, others []*Record) *ValidationError {
+	for _, other := range others {
+		err := checkConflict(record, other, true)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AuditRecords 批量检查一组记录
+// 重复的记录只在后出现的记录上报告
+func AuditRecords(records []*Record, options *ValidateOptions) []*AuditProblem {
+	var problems = []*AuditProblem{}
+	for index, record := range records {
+		err := ValidateRecord(record, options)
+		if err == nil {
+			for otherIndex, other := range records {
+				if otherIndex == index {
+					continue
+				}
+				err = checkConflict(record, other, otherIndex < index)
+				if err != nil {
+					break
+				}
+			}
+		}
+		if err != nil {
+			problems = append(problems, &AuditProblem{
+				Index: index,
+				Error: err,
+			})
+		}
+	}
+	return problems
+}
+
+// 检查两个记录是否冲突
+func checkConflict(record *Record, other *Record, checkDuplicate bool) *ValidationError {
+	if !strings.EqualFold(recordName(record.Name), recordName(other.Name)) || !isSameView(record.RouteIds, other.RouteIds) {
+		return nil
+	}
+
+	var isCNAME = record.Type == dnstypes.RecordTypeCNAME
+	var isOtherCNAME = other.Type == dnstypes.RecordTypeCNAME
+	if isCNAME && isOtherCNAME {
+		if fqdn(record.Value) == fqdn(other.Value) {
+			if checkDuplicate {
+				return newValidationError(ValidationCodeDuplicated, "duplicated CNAME record '"+recordName(record.Name)+"'")
+			}
+			return nil
+		}
+		return newValidationError(ValidationCodeCNAMEConflict, "only one CNAME record is allowed for '"+recordName(record.Name)+"'")
+	}
+	if isCNAME || isOtherCNAME {
+		return newValidationError(ValidationCodeCNAMEConflict, "CNAME record can not coexist with other records for '"+recordName(record.Name)+"'")
+	}
+
+	if checkDuplicate && record.Type == other.Type && normalizeValue(record) == normalizeValue(other) {
+		return newValidationError(ValidationCodeDuplicated, "duplicated "+record.Type+" record '"+recordName(record.Name)+"'")
+	}
+	return nil
+}
+
+// 两组线路是否会对同一个客户端生效
+func isSameView(routeIds1 []int64, routeIds2 []int64) bool {
+	if len(routeIds1) == 0 || len(routeIds2) == 0 {
+		return len(routeIds1) == 0 && len(routeIds2) == 0
+	}
+	for _, routeId1 := range routeIds1 {
+		for _, routeId2 := range routeIds2 {
+			if routeId1 == routeId2 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 用来比较的记录值
+func normalizeValue(record *Record) string {
+	switch record.Type {
+	case dnstypes.RecordTypeA, dnstypes.RecordTypeAAAA:
+		var ip = net.ParseIP(record.Value)
+		if ip != nil {
+			return ip.String()
+		}
+	case dnstypes.RecordTypeTXT:
+		return record.Value
+	}
+
+	var dnsRecord = &dnstypes.Record{Type: record.Type}
+	dnsRecord.ParseValue(record.Value)
+	if dnsRecord.IsDomainValue() {
+		dnsRecord.Value = fqdn(dnsRecord.Value)
+	}
+	return strings.Join(strings.Fields(dnsRecord.ComposeValue()), " ")
+}
+
+// 检查记录名
+func isValidRecordName(name string, origin string) bool {
+	if name == "@" {
+		return true
+	}
+	if strings.HasSuffix(name, ".") || strings.HasPrefix(name, ".") || strings.Contains(name, "..") {
+		return false
+	}
+
+	var labels = strings.Split(name, ".")
+	for index, label := range labels {
+		// 通配符只能在最左侧
+		if label == "*" {
+			if index > 0 {
+				return false
+			}
+			continue
+		}
+		if len(label) == 0 || len(label) > 63 {
+			return false
+		}
+		for _, c := range label {
+			if !(c >= 'a' && c <= 'z') && !(c >= 'A' && c <= 'Z') && !(c >= '0' && c <= '9') && c != '-' && c != '_' {
+				return false
+			}
+		}
+		if strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return false
+		}
+	}
+
+	var fullName = name + "."
+	if len(origin) > 0 {
+		fullName = absoluteName(name, fqdn(origin))
+	}
+	return len(fullName) <= 254
+}
+
+// 检查记录值
+func validateValue(record *Record) error {
+	var value = strings.TrimSpace(record.Value)
+	if len(value) == 0 {
+		return newValidationError(ValidationCodeInvalidValue, "value should not be empty")
+	}
+
+	switch record.Type {
+	case dnstypes.RecordTypeA:
+		var ip = net.ParseIP(value)
+		if ip == nil || ip.To4() == nil || strings.Contains(value, ":") {
+			return newValidationError(ValidationCodeInvalidValue, "should be an IPv4 address")
+		}
+	case dnstypes.RecordTypeAAAA:
+		var ip = net.ParseIP(value)
+		if ip == nil || !strings.Contains(value, ":") {
+			return newValidationError(ValidationCodeInvalidValue, "should be an IPv6 address")
+		}
+	case dnstypes.RecordTypeCNAME, dnstypes.RecordTypeNS:
+		if !isValidTarget(value, false) {
+			return newValidationError(ValidationCodeInvalidValue, "should be a domain name")
+		}
+	case dnstypes.RecordTypeMX:
+		var fields = strings.Fields(value)
+		if len(fields) != 2 || !isUint16(fields[0]) {
+			return newValidationError(ValidationCodeInvalidValue, "should be 'priority host'")
+		}
+		if !isValidTarget(fields[1], true) {
+			return newValidationError(ValidationCodeInvalidValue, "invalid mail server '"+fields[1]+"'")
+		}
+	case dnstypes.RecordTypeSRV:
+		var fields = strings.Fields(value)
+		if len(fields) != 4 || !isUint16(fields[0]) || !isUint16(fields[1]) || !isUint16(fields[2]) {
+			return newValidationError(ValidationCodeInvalidValue, "should be 'priority weight port target'")
+		}
+		if !isValidTarget(fields[3], true) {
+			return newValidationError(ValidationCodeInvalidValue, "invalid target '"+fields[3]+"'")
+		}
+	case dnstypes.RecordTypeTXT:
+		// 带引号的值可以分成多段，每段都不能超过255字节
+		if strings.HasPrefix(value, "\"") {
+			rr, err := dns.NewRR(". 1 IN TXT " + value)
+			if err != nil || rr == nil {
+				return newValidationError(ValidationCodeInvalidValue, "invalid quoted text")
+			}
+			for _, piece := range rr.(*dns.TXT).Txt {
+				if len(unescapeTXT(piece)) > 255 {
+					return newValidationError(ValidationCodeInvalidValue, "each text string should not be longer than 255 bytes")
+				}
+			}
+		} else if len(record.Value) > 255 {
+			return newValidationError(ValidationCodeInvalidValue, "text should not be longer than 255 bytes, use quoted strings to split long text")
+		}
+	case dnstypes.RecordTypeCAA:
+		rr, err := dns.NewRR(". 1 IN CAA " + value)
+		if err != nil || rr == nil {
+			return newValidationError(ValidationCodeInvalidValue, "should be 'flags tag \"value\"'")
+		}
+		var tag = rr.(*dns.CAA).Tag
+		if tag != "issue" && tag != "issuewild" && tag != "iodef" {
+			return newValidationError(ValidationCodeInvalidValue, "unsupported tag '"+tag+"'")
+		}
+	}
+	return nil
+}
+
+// 检查目标域名，allowRoot表示是否允许使用"."，比如MX中的"."表示不接收邮件
+func isValidTarget(value string, allowRoot bool) bool {
+	if value == "." {
+		return allowRoot
+	}
+	if net.ParseIP(value) != nil {
+		return false
+	}
+	_, ok := dns.IsDomainName(value)
+	if !ok || strings.Contains(value, "..") || strings.HasPrefix(value, ".") {
+		return false
+	}
+	return len(dns.Fqdn(value)) <= 254
+}
+
+// 是否为0-65535之间的整数
+func isUint16(s string) bool {
+	i, err := strconv.Atoi(s)
+	return err == nil && i >= 0 && i <= 65535
+}
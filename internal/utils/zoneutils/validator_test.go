@@ -0,0 +1,98 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package zoneutils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateRecord(t *testing.T) {
+	var options = &ValidateOptions{Origin: "teaos.cn"}
+	for _, testCase := range []struct {
+		record *Record
+		code   ValidationCode
+	}{
+		{&Record{Name: "www", Type: "A", Value: "192.168.1.100", TTL: 600}, ""},
+		{&Record{Name: "www", Type: "A", Value: "192.168.1", TTL: 600}, ValidationCodeInvalidValue},
+		{&Record{Name: "www", Type: "A", Value: "::1", TTL: 600}, ValidationCodeInvalidValue},
+		{&Record{Name: "www", Type: "AAAA", Value: "2001:db8::1"}, ""},
+		{&Record{Name: "www", Type: "AAAA", Value: "192.168.1.100"}, ValidationCodeInvalidValue},
+		{&Record{Name: "*.api", Type: "CNAME", Value: "api.teaos.cn."}, ""},
+		{&Record{Name: "api.*", Type: "A", Value: "192.168.1.100"}, ValidationCodeInvalidName},
+		{&Record{Name: "a..b", Type: "A", Value: "192.168.1.100"}, ValidationCodeInvalidName},
+		{&Record{Name: strings.Repeat("a", 64), Type: "A", Value: "192.168.1.100"}, ValidationCodeInvalidName},
+		{&Record{Name: "@", Type: "CNAME", Value: "other.com."}, ValidationCodeApexCNAME},
+		{&Record{Name: "www", Type: "CNAME", Value: "www.teaos.cn"}, ValidationCodeInvalidValue},
+		{&Record{Name: "www", Type: "CNAME", Value: "192.168.1.100"}, ValidationCodeInvalidValue},
+		{&Record{Name: "@", Type: "MX", Value: "10 mx.teaos.cn."}, ""},
+		{&Record{Name: "@", Type: "MX", Value: "mx.teaos.cn."}, ValidationCodeInvalidValue},
+		{&Record{Name: "_sip._tcp", Type: "SRV", Value: "10 20 5060 sip.teaos.cn."}, ""},
+		{&Record{Name: "_sip._tcp", Type: "SRV", Value: "10 20 70000 sip.teaos.cn."}, ValidationCodeInvalidValue},
+		{&Record{Name: "txt", Type: "TXT", Value: strings.Repeat("a", 255)}, ""},
+		{&Record{Name: "txt", Type: "TXT", Value: strings.Repeat("a", 256)}, ValidationCodeInvalidValue},
+		{&Record{Name: "txt", Type: "TXT", Value: "\"" + strings.Repeat("a", 255) + "\" \"a\""}, ""},
+		{&Record{Name: "@", Type: "CAA", Value: "0 issue \"letsencrypt.org\""}, ""},
+		{&Record{Name: "@", Type: "CAA", Value: "0 unknown \"letsencrypt.org\""}, ValidationCodeInvalidValue},
+		{&Record{Name: "www", Type: "A", Value: "192.168.1.100", TTL: 86400 * 30}, ValidationCodeInvalidTTL},
+		{&Record{Name: "www", Type: "A", Value: "192.168.1.100", TTL: -1}, ValidationCodeInvalidTTL},
+		{&Record{Name: "www", Type: "DNSKEY", Value: "257 3 13 abc"}, ValidationCodeUnsupportedType},
+	} {
+		err := ValidateRecord(testCase.record, options)
+		var code = ""
+		if err != nil {
+			code = err.Code
+		}
+		if code != testCase.code {
+			t.Fatal(testCase.record.Name, testCase.record.Type, testCase.record.Value, "expected '"+testCase.code+"', but got '"+code+"'", err)
+		}
+	}
+}
+
+func TestValidateRecordConflicts(t *testing.T) {
+	var others = []*Record{
+		{Name: "www", Type: "A", Value: "192.168.1.100"},
+		{Name: "api", Type: "CNAME", Value: "www.teaos.cn.", RouteIds: []int64{1, 2}},
+	}
+	for _, testCase := range []struct {
+		record *Record
+		code   ValidationCode
+	}{
+		{&Record{Name: "www", Type: "A", Value: "192.168.1.101"}, ""},
+		{&Record{Name: "WWW", Type: "A", Value: "192.168.1.100"}, ValidationCodeDuplicated},
+		{&Record{Name: "www", Type: "A", Value: "192.168.1.100", RouteIds: []int64{1}}, ""},
+		{&Record{Name: "www", Type: "CNAME", Value: "other.com."}, ValidationCodeCNAMEConflict},
+		{&Record{Name: "api", Type: "A", Value: "192.168.1.100"}, ""},
+		{&Record{Name: "api", Type: "A", Value: "192.168.1.100", RouteIds: []int64{2, 3}}, ValidationCodeCNAMEConflict},
+		{&Record{Name: "api", Type: "CNAME", Value: "other.com.", RouteIds: []int64{2}}, ValidationCodeCNAMEConflict},
+		{&Record{Name: "api", Type: "CNAME", Value: "www.teaos.cn", RouteIds: []int64{2}}, ValidationCodeDuplicated},
+	} {
+		err := ValidateRecordConflicts(testCase.record, others)
+		var code = ""
+		if err != nil {
+			code = err.Code
+		}
+		if code != testCase.code {
+			t.Fatal(testCase.record.Name, testCase.record.Type, testCase.record.Value, "expected '"+testCase.code+"', but got '"+code+"'", err)
+		}
+	}
+}
+
+func TestAuditRecords(t *testing.T) {
+	problems := AuditRecords([]*Record{
+		{Name: "www", Type: "A", Value: "192.168.1.100"},
+		{Name: "www", Type: "A", Value: "192.168.1.100"},
+		{Name: "api", Type: "A", Value: "192.168.1"},
+		{Name: "@", Type: "CNAME", Value: "other.com."},
+		{Name: "mail", Type: "A", Value: "192.168.1.100"},
+	}, &ValidateOptions{Origin: "teaos.cn"})
+	if len(problems) != 3 ||
+		problems[0].Index != 1 || problems[0].Error.Code != ValidationCodeDuplicated ||
+		problems[1].Index != 2 || problems[1].Error.Code != ValidationCodeInvalidValue ||
+		problems[2].Index != 3 || problems[2].Error.Code != ValidationCodeApexCNAME {
+		for _, problem := range problems {
+			t.Log(problem.Index, problem.Error)
+		}
+		t.Fatal("invalid problems")
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2021 Liuxiangchao iwind.liu@gmail.com. All rights reserved.
+
+package zoneutils
+
+import (
+	"github.com/TeaOSLab/EdgeAPI/internal/dnsclients/dnstypes"
+	"github.com/miekg/dns"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Record 区域文件中的记录，和NSRecord中的字段对应
+type Record struct {
+	Name     string  `json:"name"`     // 记录名，@表示域名本身
+	Type     string  `json:"type"`     // 类型
+	Value    string  `json:"value"`    // 记录值，只有一段文本的TXT记录为不带引号的文本，其他和区域文件中的RDATA一致
+	TTL      int32   `json:"ttl"`      // TTL，0表示使用默认值
+	RouteIds []int64 `json:"routeIds"` // 线路
+}
+
+// SkipReason 跳过记录的原因
+type SkipReason = string
+
+const (
+	SkipReasonUnsupported  SkipReason = "unsupported"  // 不支持的记录类型
+	SkipReasonSOA          SkipReason = "soa"          // SOA记录由集群自动生成
+	SkipReasonApexNS       SkipReason = "apexNS"       // 域名本身的NS记录由集群自动生成
+	SkipReasonOutOfZone    SkipReason = "outOfZone"    // 不属于当前域名的记录
+	SkipReasonDuplicated   SkipReason = "duplicated"   // 重复的记录
+	SkipReasonInvalidRoute SkipReason = "invalidRoute" // 线路不存在
+	SkipReasonInvalid      SkipReason = "invalid"      // 不符合记录校验规则
+)
+
+// SkippedRecord 导入时跳过的记录
+type SkippedRecord struct {
+	Name    string     `json:"name"`
+	Type    string     `json:"type"`
+	Value   string     `json:"value"`
+	Reason  SkipReason `json:"reason"`
+	Message string     `json:"message,omitempty"` // 详细说明
+}
+
+// 路由区块的标记，导出时线路记录会以注释的形式放在此标记下
+const routeSectionPrefix = "$ROUTE"
+
+// IsSupportedType 检查记录类型是否支持导入
+func IsSupportedType(recordType string) bool {
+	for _, supportedType := range dnstypes.FindAllRecordTypes() {
+		if supportedType == recordType {
+			return true
+		}
+	}
+	return false
+}
+
+// 完整域名转换为相对于origin的记录名
+func relativeName(name string, origin string) (relName string, ok bool) {
+	name = strings.ToLower(name)
+	if name == origin {
+		return "@", true
+	}
+	if !strings.HasSuffix(name, "."+origin) {
+		return "", false
+	}
+	return strings.TrimSuffix(name, "."+origin), true
+}
+
+// 记录名转换为完整域名
+func absoluteName(name string, origin string) string {
+	if len(name) == 0 || name == "@" {
+		return origin
+	}
+	if strings.HasSuffix(name, ".") {
+		return name
+	}
+	return name + "." + origin
+}
+
+// 线路ID列表组合成唯一的键
+func routeKey(routeIds []int64) string {
+	var ids = append([]int64{}, routeIds...)
+	sort.Slice(ids, func(i, j int) bool {
+		return ids[i] < ids[j]
+	})
+	var pieces = []string{}
+	for _, routeId := range ids {
+		pieces = append(pieces, strconv.FormatInt(routeId, 10))
+	}
+	return strings.Join(pieces, ",")
+}
+
+// 格式化域名
+func fqdn(name string) string {
+	return dns.Fqdn(strings.ToLower(strings.TrimSpace(name)))
+}